@@ -22,6 +22,7 @@ import (
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4"
 
 	"github.com/newrelic/infrastructure-agent/internal/feature_flags"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/executor"
 
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/logs"
 
@@ -49,16 +50,21 @@ import (
 )
 
 var (
-	configFile   string
-	showVersion  bool
-	debug        bool
-	cpuprofile   string
-	memprofile   string
-	verbose      int
-	startTime    time.Time
-	buildVersion = "development"
-	gitCommit    = ""
-	svcName      = "newrelic-infra"
+	configFile      string
+	showVersion     bool
+	validateLogs    bool
+	runIntegration  string
+	replayCapture   string
+	integrationName string
+	runOnce         bool
+	debug           bool
+	cpuprofile      string
+	memprofile      string
+	verbose         int
+	startTime       time.Time
+	buildVersion    = "development"
+	gitCommit       = ""
+	svcName         = "newrelic-infra"
 )
 
 func elapsedTime() time.Duration {
@@ -68,6 +74,11 @@ func elapsedTime() time.Duration {
 func init() {
 	flag.StringVar(&configFile, "config", "", "Overrides default configuration file")
 	flag.BoolVar(&showVersion, "version", false, "Shows version details")
+	flag.BoolVar(&validateLogs, "validate-logs", false, "Validates the log forwarder configuration files, prints the resulting Fluent Bit config and exits")
+	flag.StringVar(&runIntegration, "run-integration", "", "Runs the integrations of the given configuration `file` and prints their data, without submitting it")
+	flag.StringVar(&replayCapture, "replay-integration", "", "Feeds the payloads of an integration capture `file` through the integrations emitter and prints the resulting data, without submitting it")
+	flag.StringVar(&integrationName, "name", "", "With -run-integration, only runs the integrations with the given name. With -replay-integration, sets the integration name")
+	flag.BoolVar(&runOnce, "once", false, "With -run-integration, runs the integrations a single time and exits")
 	flag.BoolVar(&debug, "debug", false, "Enables agent debugging functionality")
 	flag.StringVar(&cpuprofile, "cpuprofile", "", "Writes cpu profile to `file`")
 	flag.StringVar(&memprofile, "memprofile", "", "Writes memory profile to `file`")
@@ -119,6 +130,16 @@ func main() {
 		alog.WithError(err).Error("can't load configuration file")
 		os.Exit(1)
 	}
+	if validateLogs {
+		os.Exit(validateLogForwarderCfg(parsedConfig))
+	}
+	if replayCapture != "" {
+		os.Exit(replayIntegration(parsedConfig, replayCapture, integrationName))
+	}
+	if runIntegration != "" {
+		os.Exit(dryRunIntegration(parsedConfig, runIntegration, integrationName, runOnce))
+	}
+
 	if parsedConfig.Verbose == config.SmartVerboseLogging {
 		wlog.EnableSmartVerboseMode(parsedConfig.SmartVerboseModeEntryLimit)
 	}
@@ -169,6 +190,26 @@ func main() {
 	}
 }
 
+// integrationSourceDirs returns the folders where the integrations definitions and executables are looked for.
+func integrationSourceDirs(c *config.Config) []string {
+	pluginSourceDirs := []string{
+		c.CustomPluginInstallationDir,
+		filepath.Join(c.AgentDir, "custom-integrations"),
+		filepath.Join(c.AgentDir, config.DefaultIntegrationsDir),
+		filepath.Join(c.AgentDir, "bundled-plugins"),
+		filepath.Join(c.AgentDir, "plugins"),
+	}
+	return helpers.RemoveEmptyAndDuplicateEntries(pluginSourceDirs)
+}
+
+// integrationsVerifier returns the verifier of the integration executables, or nil if the verification is disabled.
+func integrationsVerifier(c *config.Config) (executor.Verifier, error) {
+	if c.IntegrationsChecksumsFile == "" && c.IntegrationsSignaturePublicKey == "" {
+		return nil, nil
+	}
+	return executor.NewChecksumVerifier(c.IntegrationsChecksumsFile, c.IntegrationsSignaturePublicKey)
+}
+
 func logConfig(c *config.Config) {
 	// Log the configuration.
 	c.LogInfo()
@@ -239,24 +280,20 @@ func initializeAgentAndRun(c *config.Config, logFwCfg config.LogForward) error {
 		fatal(err, "Can't complete platform specific initialization.")
 	}
 
-	// Start all plugins we want the agent to run.
-	if err = plugins.RegisterPlugins(agt); err != nil {
-		aslog.WithError(err).Error("fatal error while registering plugins")
-		os.Exit(1)
-	}
-
 	// Start the external plugin system. It registers all agent plugins that
 	// are to be started later.
-	pluginSourceDirs := []string{
-		c.CustomPluginInstallationDir,
-		filepath.Join(c.AgentDir, "custom-integrations"),
-		filepath.Join(c.AgentDir, config.DefaultIntegrationsDir),
-		filepath.Join(c.AgentDir, "bundled-plugins"),
-		filepath.Join(c.AgentDir, "plugins"),
-	}
-	pluginSourceDirs = helpers.RemoveEmptyAndDuplicateEntries(pluginSourceDirs)
+	pluginSourceDirs := integrationSourceDirs(c)
 
 	metricsSenderConfig := dm.NewConfig(c.Staging, c.License, time.Duration(c.DMSubmissionPeriod)*time.Second)
+	metricsSenderConfig.CardinalityLimits = dm.CardinalityLimits{
+		MaxSeriesPerMetric: c.DMMaxSeriesPerMetric,
+		MaxSeries:          c.DMMaxSeries,
+		Policy:             dm.CardinalityPolicy(c.DMCardinalityPolicy),
+	}
+	metricsSenderConfig.Aggregation = dm.AggregationConfig{
+		Window:         time.Duration(c.DMAggregationWindow) * time.Second,
+		DropAttributes: c.DMAggregateBy,
+	}
 
 	dmSender, err := dm.NewDMSender(metricsSenderConfig, transport, agt.Context.IdContext())
 	if err != nil {
@@ -270,7 +307,27 @@ func initializeAgentAndRun(c *config.Config, logFwCfg config.LogForward) error {
 		c.PluginInstanceDirs,
 		pluginSourceDirs,
 	)
+	if integrationCfg.Verifier, err = integrationsVerifier(c); err != nil {
+		return fmt.Errorf("can't load the integrations verification files: %s", err)
+	}
+	integrationCfg.MaxPayloadSize = c.MaxIntegrationPayloadSize
 	integrationEmitter := emitter.NewIntegrationEmitter(agt, dmSender, ffManager)
+	if c.IntegrationsCaptureDir != "" {
+		capturer, err := emitter.NewCapturer(integrationEmitter, c.IntegrationsCaptureDir, int64(c.IntegrationsCaptureMaxSize), c.IntegrationsCaptureMaxFiles)
+		if err != nil {
+			return fmt.Errorf("can't create the integrations capture directory: %s", err)
+		}
+		defer capturer.Close()
+		capturer.MaxPayloadSize = c.MaxIntegrationPayloadSize
+		integrationEmitter = capturer
+	}
+
+	// Start all plugins we want the agent to run. Payloads pushed to the HTTP server are not captured.
+	if err = plugins.RegisterPlugins(agt, emitter.Uncaptured(integrationEmitter)); err != nil {
+		aslog.WithError(err).Error("fatal error while registering plugins")
+		os.Exit(1)
+	}
+
 	integrationManager := v4.NewManager(integrationCfg, integrationEmitter)
 
 	// log-forwarder
@@ -308,6 +365,7 @@ func initializeAgentAndRun(c *config.Config, logFwCfg config.LogForward) error {
 		fatal(err, "Can't load plugin configuration.")
 	}
 	runner := legacy.NewPluginRunner(pluginRegistry, agt)
+	runner.SetVerifier(integrationCfg.Verifier)
 	for _, pluginConf := range pluginConfig.PluginConfigs {
 		if err := runner.ConfigurePlugin(pluginConf, agt.Context.ActiveEntitiesChannel()); err != nil {
 			fatal(err, fmt.Sprint("Can't configure plugin.", pluginConf))
@@ -0,0 +1,92 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/internal/feature_flags"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/integration"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	v4 "github.com/newrelic/infrastructure-agent/pkg/integrations/v4"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/emitter"
+	wlog "github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/sysinfo/cloud"
+	"github.com/newrelic/infrastructure-agent/pkg/sysinfo/hostname"
+)
+
+// dryRunIntegration runs the integrations of a v4 configuration file, printing every emitted metric, event,
+// inventory item and entity key to stdout instead of submitting them. Agent logs are written to stderr.
+// It returns the exit code.
+func dryRunIntegration(c *config.Config, cfgPath, name string, once bool) int {
+	configureLogFormat(c)
+	wlog.SetOutput(os.Stderr)
+
+	integrationCfg := v4.NewConfig(
+		c.Verbose,
+		c.Features,
+		c.PassthroughEnvironment,
+		nil,
+		integrationSourceDirs(c),
+	)
+	verifier, err := integrationsVerifier(c)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR can't load the integrations verification files: %s\n", err)
+		return 1
+	}
+	integrationCfg.Verifier = verifier
+	integrationCfg.MaxPayloadSize = c.MaxIntegrationPayloadSize
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+		<-sigs
+		cancel()
+	}()
+
+	printer := emitter.NewPrinter(os.Stdout, c, agentIDLookup(c), feature_flags.NewManager(c.Features))
+	if err := v4.DryRun(ctx, integrationCfg, cfgPath, name, once, printer); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+// replayIntegration prints the data resulting from feeding an integration capture file through the integrations
+// emitter, so the output of different integration versions can be compared. It returns the exit code.
+func replayIntegration(c *config.Config, capturePath, name string) int {
+	configureLogFormat(c)
+	wlog.SetOutput(os.Stderr)
+
+	capture, err := os.Open(capturePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR %s\n", err)
+		return 1
+	}
+	defer capture.Close()
+
+	if name == "" {
+		name = emitter.CapturedIntegrationName(capturePath)
+	}
+	printer := emitter.NewPrinter(os.Stdout, c, agentIDLookup(c), feature_flags.NewManager(c.Features))
+	if _, err := emitter.Replay(capture, integration.Definition{Name: name}, printer); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+// agentIDLookup resolves the agent identifiers as the agent does, so the printed entity keys match the submitted ones.
+func agentIDLookup(c *config.Config) agent.IDLookup {
+	resolver := hostname.CreateResolver(c.OverrideHostname, c.OverrideHostnameShort, c.DnsHostnameResolution)
+	cloudHarvester := cloud.NewDetector(c.DisableCloudMetadata, c.CloudMaxRetryCount, c.CloudRetryBackOffSec, c.CloudMetadataExpiryInSec, c.CloudMetadataDisableKeepAlive)
+	cloudHarvester.Initialize()
+	return agent.NewIdLookup(resolver, cloudHarvester, c.DisplayName)
+}
@@ -0,0 +1,42 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/logs"
+	"github.com/newrelic/infrastructure-agent/pkg/sysinfo/hostname"
+)
+
+// validateLogForwarderCfg is a dry-run of the log forwarder configuration loading. It reports every issue found in the
+// logging configuration files to stderr, prints the generated Fluent Bit config to stdout and returns the exit code.
+func validateLogForwarderCfg(c *config.Config) int {
+	logFwCfg := config.NewLogForward(c, config.Troubleshoot{})
+	resolver := hostname.CreateResolver(c.OverrideHostname, c.OverrideHostnameShort, c.DnsHostnameResolution)
+	emptyIdentity := func() entity.Identity { return entity.EmptyIdentity }
+
+	loader := logs.NewFolderLoader(logFwCfg, emptyIdentity, resolver)
+	fbCfg, errs := loader.ValidateAll()
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "ERROR %s\n", err)
+	}
+
+	if len(fbCfg.Inputs) > 0 || fbCfg.ExternalCfg.CfgFilePath != "" {
+		content, _, err := fbCfg.Format()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR %s\n", err)
+			return 1
+		}
+		fmt.Println(content)
+	}
+
+	if len(errs) > 0 {
+		fmt.Fprintf(os.Stderr, "%d error(s) found in %s\n", len(errs), logFwCfg.ConfigsDir)
+		return 1
+	}
+	return 0
+}
@@ -151,6 +151,7 @@ type context struct {
 	EntityMap          entity.KnownIDs
 	idLookup           IDLookup
 	shouldIncludeEvent sampler.IncludeSampleMatchFn
+	transformEvent     sampler.TransformSampleFn
 }
 
 // AgentID provides agent ID, blocking until it's available
@@ -201,6 +202,11 @@ func NewContext(
 
 	var agentKey atomic.Value
 	agentKey.Store("")
+
+	var transformFn sampler.TransformSampleFn
+	if cfg != nil {
+		transformFn = sampler.NewSampleTransformFn(cfg.MetricsTransformations)
+	}
 	return &context{
 		cfg:                cfg,
 		Ctx:                ctx,
@@ -213,6 +219,7 @@ func NewContext(
 		resolver:           resolver,
 		idLookup:           lookup,
 		shouldIncludeEvent: sampleMatchFn,
+		transformEvent:     transformFn,
 		agentKey:           agentKey,
 	}
 }
@@ -297,10 +304,10 @@ func NewAgent(cfg *config.Config, buildVersion string, ffRetriever feature_flags
 	cloudHarvester.Initialize()
 
 	idLookupTable := NewIdLookup(hostnameResolver, cloudHarvester, cfg.DisplayName)
-	sampleMatchFn := sampler.NewSampleMatchFn(cfg.EnableProcessMetrics, cfg.IncludeMetricsMatchers, ffRetriever)
+	sampleMatchFn := sampler.NewSampleMatchFn(cfg.EnableProcessMetrics, cfg.IncludeMetricsMatchers, cfg.ExcludeMetricsMatchers, ffRetriever)
 	ctx := NewContext(cfg, buildVersion, hostnameResolver, idLookupTable, sampleMatchFn)
 
-	agentKey, err := idLookupTable.getAgentKey()
+	agentKey, err := idLookupTable.AgentKey()
 	if err != nil {
 		return
 	}
@@ -464,7 +471,8 @@ func New(
 	return a, nil
 }
 
-func (i IDLookup) getAgentKey() (agentKey string, err error) {
+// AgentKey returns the agent entity key: the first non blank identifier in the host identifiers priority.
+func (i IDLookup) AgentKey() (agentKey string, err error) {
 	if len(i) == 0 {
 		err = fmt.Errorf("No identifiers given")
 		return
@@ -694,7 +702,7 @@ func (a *Agent) updateIDLookupTable(hostAliases PluginInventoryDataset) (err err
 // If one is found, it will be set on the context object as well as returned.
 // Otherwise, the context is not modified.
 func (a *Agent) setAgentKey(idLookupTable IDLookup) error {
-	key, err := idLookupTable.getAgentKey()
+	key, err := idLookupTable.AgentKey()
 	if err != nil {
 		return err
 	}
@@ -1021,6 +1029,9 @@ func (c *context) SendEvent(event sample.Event, entityKey entity.Key) {
 
 		includeSample := c.shouldIncludeEvent(event)
 		if includeSample {
+			if c.transformEvent != nil {
+				event = c.transformEvent(event)
+			}
 			if err := c.eventSender.QueueEvent(event, entityKey); err != nil {
 				alog.WithField(
 					"entityKey", entityKey,
@@ -1030,6 +1041,11 @@ func (c *context) SendEvent(event sample.Event, entityKey entity.Key) {
 	}
 }
 
+// IncludeEvent returns whether the event passes the include/exclude matchers applied by SendEvent.
+func (c *context) IncludeEvent(event interface{}) bool {
+	return c.shouldIncludeEvent == nil || c.shouldIncludeEvent(event)
+}
+
 func (c *context) Unregister(id ids.PluginID) {
 	c.ch <- NewNotApplicableOutput(id)
 }
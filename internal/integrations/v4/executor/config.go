@@ -12,6 +12,11 @@ type Config struct {
 	Environment map[string]string
 	// Global variables that need to be retrieved before the integration runs
 	Passthrough []string
+	// Verifier checks the executable before each execution, if set
+	Verifier Verifier
+	// MaxOutputLineSize standard output lines bigger than this size, in bytes, are discarded while they are read,
+	// reporting a protocol.PayloadTooLargeError. Zero disables the limit.
+	MaxOutputLineSize int
 }
 
 // BuildEnv returns the environment configuration of an executable, merging the
@@ -60,5 +65,8 @@ func (c *Config) deepClone() *Config {
 		Directory:   c.Directory,
 		Environment: envCopy,
 		Passthrough: passthroughCopy,
+		Verifier:    c.Verifier,
+
+		MaxOutputLineSize: c.MaxOutputLineSize,
 	}
 }
@@ -15,6 +15,7 @@ import (
 
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/constants"
 	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
 	"github.com/newrelic/infrastructure-agent/pkg/log"
 )
 
@@ -42,6 +43,14 @@ func (r *Executor) Execute(ctx context.Context) OutputReceive {
 	commandCtx, cancelCommand := context.WithCancel(ctx)
 
 	go func() {
+		// executables not passing the verification are not started
+		if err := r.verify(); err != nil {
+			out.Errors <- err
+			cancelCommand()
+			out.Close()
+			return
+		}
+
 		cmd := r.buildCommand(commandCtx)
 
 		//argsS := make([]string, len(cmd.Args))
@@ -74,11 +83,11 @@ func (r *Executor) Execute(ctx context.Context) OutputReceive {
 		// scans standard output and error pipes and forwards individual lines to a channel
 		go func() {
 			defer allOutputForwarded.Done()
-			forwardCmdOutput(cmdOutput, out.Stdout, out.Errors)
+			forwardCmdOutput(cmdOutput, out.Stdout, out.Errors, r.Cfg.MaxOutputLineSize)
 		}()
 		go func() {
 			defer allOutputForwarded.Done()
-			forwardCmdOutput(cmdError, out.Stderr, out.Errors)
+			forwardCmdOutput(cmdError, out.Stderr, out.Errors, 0)
 		}()
 
 		// on normal output, when the output pipes are closed, we cancel the
@@ -104,24 +113,44 @@ func (r *Executor) Execute(ctx context.Context) OutputReceive {
 	return receiver
 }
 
-// reads lines from stdout or stderr and forwards them to the fwd channel
-func forwardCmdOutput(buffer io.Reader, fwd chan<- []byte, errors chan<- error) {
+// reads lines from stdout or stderr and forwards them to the fwd channel. Lines bigger than maxLineSize are discarded
+// as soon as they exceed it, submitting a PayloadTooLargeError instead. Zero or negative sizes don't limit the lines.
+func forwardCmdOutput(buffer io.Reader, fwd chan<- []byte, errors chan<- error, maxLineSize int) {
 	lineReader := bufio.NewReader(buffer)
 
-	// reads a line from stoud/stderr
-	line, err := lineReader.ReadBytes('\n')
-	for err == nil {
-		// removes trailing new line symbols to only forward the json payload
-		line = bytes.TrimRight(line, "\r\n")
-		fwd <- line
-		line, err = lineReader.ReadBytes('\n')
-	}
-	if err != io.EOF {
-		errors <- err
-	}
-	// if, after EOF, there was any data in the buffer, it submits it as a new line
-	if len(line) > 0 {
-		fwd <- line
+	var line []byte
+	discarded := 0 // size of the line being discarded, if any
+	for {
+		// reads a line from stoud/stderr, in chunks of the reader buffer size
+		chunk, err := lineReader.ReadSlice('\n')
+		if discarded > 0 {
+			discarded += len(chunk)
+		} else {
+			line = append(line, chunk...)
+			if maxLineSize > 0 && len(bytes.TrimRight(line, "\r\n")) > maxLineSize {
+				discarded, line = len(line), nil
+			}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+
+		if discarded > 0 {
+			errors <- &protocol.PayloadTooLargeError{Size: discarded, MaxSize: maxLineSize}
+			discarded = 0
+		} else if err == nil || len(line) > 0 {
+			// removes trailing new line symbols to only forward the json payload. If, after EOF, there was any
+			// data in the buffer, it submits it as a new line
+			fwd <- bytes.TrimRight(line, "\r\n")
+		}
+		line = nil
+
+		if err != nil {
+			if err != io.EOF {
+				errors <- err
+			}
+			return
+		}
 	}
 }
 
@@ -3,11 +3,13 @@
 package executor
 
 import (
+	"bufio"
 	"context"
 	"io/ioutil"
 	"os"
 	"os/user"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
 
@@ -15,6 +17,7 @@ import (
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/constants"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/fixtures"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/testhelp"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
 	"github.com/newrelic/infrastructure-agent/pkg/log"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -252,3 +255,44 @@ func execConfig(t require.TestingT) *Config {
 		Environment: nil,
 	}
 }
+
+func TestForwardCmdOutput_MaxLineSize(t *testing.T) {
+	// GIVEN an output with a line bigger than the reader buffer and the max line size
+	big := strings.Repeat("x", 2*4096)
+	output := strings.NewReader("first\r\n" + big + "\nlast")
+
+	// WHEN the output is forwarded with a line size limit
+	fwd := make(chan []byte, 10)
+	errs := make(chan error, 10)
+	forwardCmdOutput(output, fwd, errs, 100)
+	close(fwd)
+	close(errs)
+
+	// THEN the big line is discarded and reported, while the rest of lines are forwarded
+	var lines []string
+	for line := range fwd {
+		lines = append(lines, string(line))
+	}
+	assert.Equal(t, []string{"first", "last"}, lines)
+	require.Len(t, errs, 1)
+	err := <-errs
+	require.IsType(t, &protocol.PayloadTooLargeError{}, err)
+	assert.Equal(t, 100, err.(*protocol.PayloadTooLargeError).MaxSize)
+}
+
+func TestForwardCmdOutput_NoLimit(t *testing.T) {
+	big := strings.Repeat("x", 2*bufio.MaxScanTokenSize)
+	output := strings.NewReader("\n" + big + "\n")
+
+	fwd := make(chan []byte, 10)
+	errs := make(chan error, 10)
+	forwardCmdOutput(output, fwd, errs, 0)
+	close(fwd)
+
+	var lines []string
+	for line := range fwd {
+		lines = append(lines, string(line))
+	}
+	assert.Equal(t, []string{"", big}, lines, "empty lines are forwarded too")
+	assert.Empty(t, errs)
+}
@@ -0,0 +1,291 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// signatureSuffix is appended to the executable path to find its detached signature.
+const signatureSuffix = ".sig"
+
+// Verification errors
+var (
+	ErrDigestNotAllowed = errors.New("executable sha256 digest is not in the allow-list")
+	ErrSignatureMissing = errors.New("executable has no detached signature")
+	ErrSignatureInvalid = errors.New("executable signature doesn't match the public key")
+	ErrWritableByOthers = errors.New("executable is writable by its group or other users")
+	ErrUntrustedOwner   = errors.New("executable is not owned by root or the agent user")
+	ErrRelativeCommand  = errors.New("executables run as another user require an absolute path")
+
+	// directory errors are wrapped with the offending directory
+	ErrDirWritableByOthers = errors.New("executable directory is writable by its group or other users")
+	ErrDirUntrustedOwner   = errors.New("executable directory is not owned by root or the agent user")
+)
+
+// Verifier checks an executable before it's started.
+type Verifier interface {
+	Verify(path string) error
+}
+
+// VerificationError is returned when an executable doesn't pass the verification. The executable is not started.
+type VerificationError struct {
+	Path string
+	Err  error
+}
+
+func (e *VerificationError) Error() string {
+	return fmt.Sprintf("can't verify executable %s: %s", e.Path, e.Err)
+}
+
+// ChecksumVerifier verifies the executables against an allow-list of sha256 digests and/or their detached ed25519
+// signatures. An executable passes the verification if either its digest is allowed or its signature is valid.
+// Executables that other users could replace between their verification and their execution, either modifying them
+// or their parent directories, are rejected.
+type ChecksumVerifier struct {
+	// allowed paths per hex encoded digest. Digests allowed for any path have no paths.
+	digests   map[string][]string
+	publicKey ed25519.PublicKey
+
+	// verified files per path, so they aren't read again until they change
+	verified      map[string]fileKey
+	verifiedMutex sync.Mutex
+}
+
+// fileKey identifies the version of a file: it changes whenever the file is replaced or modified.
+type fileKey struct {
+	dev     uint64
+	ino     uint64
+	size    int64
+	modTime int64
+}
+
+// NewChecksumVerifier loads the digests allow-list and the signatures public key. Any of them can be empty.
+//
+// The allow-list file follows the sha256sum output format: a hex encoded digest per line, optionally followed by
+// the absolute path of the executable it applies to. Empty lines and lines starting with # are ignored.
+//
+// The public key file is a PEM encoded ed25519 public key (PKIX). The detached signature of each executable must be
+// placed beside it, with the .sig suffix, containing the ed25519 signature of the executable contents, either raw
+// or base64 encoded.
+func NewChecksumVerifier(checksumsFile, publicKeyFile string) (*ChecksumVerifier, error) {
+	v := &ChecksumVerifier{verified: map[string]fileKey{}}
+	if checksumsFile != "" {
+		digests, err := loadDigests(checksumsFile)
+		if err != nil {
+			return nil, err
+		}
+		v.digests = digests
+	}
+	if publicKeyFile != "" {
+		key, err := loadPublicKey(publicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		v.publicKey = key
+	}
+	return v, nil
+}
+
+// Verify returns a VerificationError if the executable in the path doesn't pass the verification. The digest and
+// signature checks are skipped while the file doesn't change since its last successful verification.
+func (v *ChecksumVerifier) Verify(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return &VerificationError{Path: path, Err: err}
+	}
+	defer f.Close()
+
+	// the opened file is checked, so it can't be replaced in between
+	info, err := f.Stat()
+	if err != nil {
+		return &VerificationError{Path: path, Err: err}
+	}
+	if err := checkFileOwner(info); err != nil {
+		return &VerificationError{Path: path, Err: err}
+	}
+	// directories are checked on every run, as their permissions don't change the file key
+	if err := checkParentDirs(path); err != nil {
+		return &VerificationError{Path: path, Err: err}
+	}
+
+	path = filepath.Clean(path)
+	key := fileKeyOf(info)
+	if v.isVerified(path, key) {
+		return nil
+	}
+	if err := v.verifyContent(path, f); err != nil {
+		return &VerificationError{Path: path, Err: err}
+	}
+	v.setVerified(path, key)
+	return nil
+}
+
+// verifyContent checks the digest of the file against the allow-list, and its signature when the digest is not
+// allowed.
+func (v *ChecksumVerifier) verifyContent(path string, f *os.File) error {
+	if v.digests != nil {
+		hash := sha256.New()
+		if _, err := io.Copy(hash, f); err != nil {
+			return err
+		}
+		if paths, ok := v.digests[hex.EncodeToString(hash.Sum(nil))]; ok && (len(paths) == 0 || containsPath(paths, path)) {
+			return nil
+		}
+		if v.publicKey == nil {
+			return ErrDigestNotAllowed
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	}
+
+	if v.publicKey == nil {
+		return nil
+	}
+	// ed25519 signatures are verified over the whole content
+	content, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	return v.verifySignature(path, content)
+}
+
+func (v *ChecksumVerifier) isVerified(path string, key fileKey) bool {
+	v.verifiedMutex.Lock()
+	defer v.verifiedMutex.Unlock()
+	verified, ok := v.verified[path]
+	return ok && verified == key
+}
+
+func (v *ChecksumVerifier) setVerified(path string, key fileKey) {
+	v.verifiedMutex.Lock()
+	defer v.verifiedMutex.Unlock()
+	v.verified[path] = key
+}
+
+func (v *ChecksumVerifier) verifySignature(path string, content []byte) error {
+	signature, err := ioutil.ReadFile(path + signatureSuffix)
+	if os.IsNotExist(err) {
+		return ErrSignatureMissing
+	}
+	if err != nil {
+		return err
+	}
+	if len(signature) != ed25519.SignatureSize {
+		decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(signature)))
+		if err != nil {
+			return ErrSignatureInvalid
+		}
+		signature = decoded
+	}
+	if len(signature) != ed25519.SignatureSize || !ed25519.Verify(v.publicKey, content, signature) {
+		return ErrSignatureInvalid
+	}
+	return nil
+}
+
+func loadDigests(file string) (map[string][]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	digests := map[string][]string{}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		digest := strings.ToLower(fields[0])
+		if decoded, err := hex.DecodeString(digest); err != nil || len(decoded) != sha256.Size {
+			return nil, fmt.Errorf("%s:%d: invalid sha256 digest %q", file, n, fields[0])
+		}
+		if _, ok := digests[digest]; !ok {
+			digests[digest] = nil
+		}
+		if len(fields) > 1 {
+			// sha256sum prefixes the path with * in binary mode
+			path := strings.TrimPrefix(strings.Join(fields[1:], " "), "*")
+			digests[digest] = append(digests[digest], filepath.Clean(path))
+		}
+	}
+	return digests, scanner.Err()
+}
+
+func loadPublicKey(file string) (ed25519.PublicKey, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM encoded public key found", file)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	edKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: public key is %T, not ed25519", file, key)
+	}
+	return edKey, nil
+}
+
+func containsPath(paths []string, path string) bool {
+	path = filepath.Clean(path)
+	for _, p := range paths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// executablePath resolves the path of the executable to be run, looking for it in the PATH when it's only a name,
+// and relative to the working directory when it's a relative path.
+func (r *Executor) executablePath() (string, error) {
+	if !strings.ContainsRune(r.Command, filepath.Separator) && !strings.ContainsRune(r.Command, '/') {
+		return exec.LookPath(r.Command)
+	}
+	if !filepath.IsAbs(r.Command) && r.Cfg.Directory != "" {
+		return filepath.Join(r.Cfg.Directory, r.Command), nil
+	}
+	return filepath.Abs(r.Command)
+}
+
+// verify runs the configured Verifier, if any, on the executable. Commands run as another user must be absolute
+// paths, as sudo looks for them in its own secure path instead of the agent one.
+func (r *Executor) verify() error {
+	if r.Cfg == nil || r.Cfg.Verifier == nil {
+		return nil
+	}
+	if r.Cfg.User != "" && !filepath.IsAbs(r.Command) {
+		return &VerificationError{Path: r.Command, Err: ErrRelativeCommand}
+	}
+	path, err := r.executablePath()
+	if err != nil {
+		return &VerificationError{Path: r.Command, Err: err}
+	}
+	return r.Cfg.Verifier.Verify(path)
+}
@@ -0,0 +1,309 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package executor
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/fixtures"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/testhelp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeExecutable creates a fake executable in a temporary folder and returns its path and sha256 digest.
+func writeExecutable(t *testing.T, dir string) (string, string) {
+	path := filepath.Join(dir, "nri-test")
+	content := []byte("#!/bin/sh\necho {}\n")
+	require.NoError(t, ioutil.WriteFile(path, content, 0755))
+	sum := sha256.Sum256(content)
+	return path, hex.EncodeToString(sum[:])
+}
+
+func writePublicKey(t *testing.T, dir string, key ed25519.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	require.NoError(t, err)
+	path := filepath.Join(dir, "integrations.pub")
+	require.NoError(t, ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644))
+	return path
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "verifier")
+	require.NoError(t, err)
+	return dir
+}
+
+func TestChecksumVerifier_Checksums(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	exe, digest := writeExecutable(t, dir)
+
+	tests := []struct {
+		name      string
+		checksums string
+		allowed   bool
+	}{
+		{"any path", fmt.Sprintf("# allowed integrations\n\n%s\n", digest), true},
+		{"sha256sum format", fmt.Sprintf("%s  %s\n", digest, exe), true},
+		{"sha256sum binary mode", fmt.Sprintf("%s *%s\n", digest, exe), true},
+		{"other path", fmt.Sprintf("%s  /usr/bin/other\n", digest), false},
+		{"other digest", fmt.Sprintf("%x  %s\n", sha256.Sum256([]byte("other")), exe), false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			file := filepath.Join(dir, "integrations.sha256")
+			require.NoError(t, ioutil.WriteFile(file, []byte(tc.checksums), 0644))
+
+			v, err := NewChecksumVerifier(file, "")
+			require.NoError(t, err)
+
+			err = v.Verify(exe)
+			if tc.allowed {
+				assert.NoError(t, err)
+			} else {
+				var verr *VerificationError
+				require.True(t, errors.As(err, &verr))
+				assert.Equal(t, exe, verr.Path)
+				assert.Equal(t, ErrDigestNotAllowed, verr.Err)
+			}
+		})
+	}
+}
+
+func TestChecksumVerifier_InvalidChecksumsFile(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "integrations.sha256")
+	require.NoError(t, ioutil.WriteFile(file, []byte("not-a-digest  /usr/bin/nri-test\n"), 0644))
+
+	_, err := NewChecksumVerifier(file, "")
+	assert.Error(t, err)
+}
+
+func TestChecksumVerifier_Signatures(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	exe, _ := writeExecutable(t, dir)
+	content, err := ioutil.ReadFile(exe)
+	require.NoError(t, err)
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, otherPrivate, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	publicKeyFile := writePublicKey(t, dir, public)
+
+	tests := []struct {
+		name      string
+		signature []byte
+		expected  error
+	}{
+		{"missing", nil, ErrSignatureMissing},
+		{"raw", ed25519.Sign(private, content), nil},
+		{"base64", []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(private, content)) + "\n"), nil},
+		{"other key", ed25519.Sign(otherPrivate, content), ErrSignatureInvalid},
+		{"garbage", []byte("garbage"), ErrSignatureInvalid},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			os.Remove(exe + signatureSuffix)
+			if tc.signature != nil {
+				require.NoError(t, ioutil.WriteFile(exe+signatureSuffix, tc.signature, 0644))
+			}
+			// a new verifier for each signature, as verified executables are cached until they change
+			v, err := NewChecksumVerifier("", publicKeyFile)
+			require.NoError(t, err)
+
+			err = v.Verify(exe)
+			if tc.expected == nil {
+				assert.NoError(t, err)
+			} else {
+				var verr *VerificationError
+				require.True(t, errors.As(err, &verr))
+				assert.Equal(t, tc.expected, verr.Err)
+			}
+		})
+	}
+}
+
+func TestChecksumVerifier_ChecksumOrSignature(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	exe, digest := writeExecutable(t, dir)
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	checksums := filepath.Join(dir, "integrations.sha256")
+	require.NoError(t, ioutil.WriteFile(checksums, []byte(digest+"\n"), 0644))
+
+	// GIVEN a verifier with both checksums and public key
+	v, err := NewChecksumVerifier(checksums, writePublicKey(t, dir, public))
+	require.NoError(t, err)
+
+	// THEN an allowed executable passes the verification even if it's not signed
+	assert.NoError(t, v.Verify(exe))
+
+	// AND a non-allowed executable requires a signature
+	require.NoError(t, ioutil.WriteFile(exe, []byte("modified"), 0755))
+	var verr *VerificationError
+	require.True(t, errors.As(v.Verify(exe), &verr))
+	assert.Equal(t, ErrSignatureMissing, verr.Err)
+}
+
+type verifierFunc func(path string) error
+
+func (f verifierFunc) Verify(path string) error {
+	return f(path)
+}
+
+func TestChecksumVerifier_VerifiedUntilChanged(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	exe, digest := writeExecutable(t, dir)
+	checksums := filepath.Join(dir, "integrations.sha256")
+	require.NoError(t, ioutil.WriteFile(checksums, []byte(digest+"\n"), 0644))
+	v, err := NewChecksumVerifier(checksums, "")
+	require.NoError(t, err)
+
+	// GIVEN a verified executable
+	require.NoError(t, v.Verify(exe))
+
+	// WHEN it's modified
+	require.NoError(t, ioutil.WriteFile(exe, []byte("#!/bin/sh\necho modified\n"), 0755))
+
+	// THEN it's verified again
+	var verr *VerificationError
+	require.True(t, errors.As(v.Verify(exe), &verr))
+	assert.Equal(t, ErrDigestNotAllowed, verr.Err)
+}
+
+func TestChecksumVerifier_WritableByOthers(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file permissions are not checked on windows")
+	}
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	exe, digest := writeExecutable(t, dir)
+	require.NoError(t, os.Chmod(exe, 0777))
+	checksums := filepath.Join(dir, "integrations.sha256")
+	require.NoError(t, ioutil.WriteFile(checksums, []byte(digest+"\n"), 0644))
+
+	v, err := NewChecksumVerifier(checksums, "")
+	require.NoError(t, err)
+
+	var verr *VerificationError
+	require.True(t, errors.As(v.Verify(exe), &verr))
+	assert.Equal(t, ErrWritableByOthers, verr.Err)
+}
+
+func TestChecksumVerifier_ParentDirWritableByOthers(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file permissions are not checked on windows")
+	}
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	exeDir := filepath.Join(dir, "bin")
+	require.NoError(t, os.Mkdir(exeDir, 0755))
+	exe, digest := writeExecutable(t, exeDir)
+	checksums := filepath.Join(dir, "integrations.sha256")
+	require.NoError(t, ioutil.WriteFile(checksums, []byte(digest+"\n"), 0644))
+
+	v, err := NewChecksumVerifier(checksums, "")
+	require.NoError(t, err)
+	require.NoError(t, v.Verify(exe))
+
+	// WHEN any user can replace the executable within its directory
+	require.NoError(t, os.Chmod(exeDir, 0777))
+
+	// THEN the already verified executable is rejected
+	var verr *VerificationError
+	require.True(t, errors.As(v.Verify(exe), &verr))
+	assert.True(t, errors.Is(verr.Err, ErrDirWritableByOthers))
+	assert.Contains(t, verr.Err.Error(), exeDir)
+
+	// unless only the owners can replace their files
+	require.NoError(t, os.Chmod(exeDir, 0777|os.ModeSticky))
+	assert.NoError(t, v.Verify(exe))
+}
+
+func TestChecksumVerifier_ParentDirUntrustedOwner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file permissions are not checked on windows")
+	}
+	if os.Geteuid() != 0 {
+		t.Skip("changing the directory owner requires root")
+	}
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	exeDir := filepath.Join(dir, "bin")
+	require.NoError(t, os.Mkdir(exeDir, 0755))
+	exe, digest := writeExecutable(t, exeDir)
+	checksums := filepath.Join(dir, "integrations.sha256")
+	require.NoError(t, ioutil.WriteFile(checksums, []byte(digest+"\n"), 0644))
+
+	// GIVEN the executable directory belongs to another user
+	require.NoError(t, os.Chown(exeDir, 12345, 12345))
+
+	v, err := NewChecksumVerifier(checksums, "")
+	require.NoError(t, err)
+
+	var verr *VerificationError
+	require.True(t, errors.As(v.Verify(exe), &verr))
+	assert.True(t, errors.Is(verr.Err, ErrDirUntrustedOwner))
+}
+
+func TestRunnable_Execute_WithUser_RelativeCommand(t *testing.T) {
+	// GIVEN a verified executor run as another user, referring to a command by its name
+	r := Executor{Command: "nri-test", Cfg: &Config{User: "nri-agent", Verifier: &ChecksumVerifier{}}}
+
+	// WHEN it's verified
+	err := r.verify()
+
+	// THEN it's rejected, as sudo could run a different file
+	var verr *VerificationError
+	require.True(t, errors.As(err, &verr))
+	assert.Equal(t, ErrRelativeCommand, verr.Err)
+}
+
+func TestRunnable_Execute_VerificationFailed(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	// GIVEN a runnable instance whose executable doesn't pass the verification
+	var verified string
+	cfg := execConfig(t)
+	cfg.Verifier = verifierFunc(func(path string) error {
+		verified = path
+		return &VerificationError{Path: path, Err: ErrDigestNotAllowed}
+	})
+	r := FromCmdSlice(testhelp.Command(fixtures.BasicCmd), cfg)
+
+	// WHEN it is executed
+	to := r.Execute(context.Background())
+
+	// THEN the verification error is returned
+	var verr *VerificationError
+	require.True(t, errors.As(testhelp.ChannelErrClosed(to.Errors), &verr))
+	assert.Equal(t, ErrDigestNotAllowed, verr.Err)
+	assert.Equal(t, verified, verr.Path)
+	assert.True(t, filepath.IsAbs(verified))
+
+	// AND the executable is not started
+	_, ok := <-to.Stdout
+	assert.False(t, ok)
+}
@@ -0,0 +1,58 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build darwin linux
+
+package executor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// checkFileOwner rejects the files that could be modified by users other than root or the agent one.
+func checkFileOwner(info os.FileInfo) error {
+	if info.Mode().Perm()&0022 != 0 {
+		return ErrWritableByOthers
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && stat.Uid != 0 && int(stat.Uid) != os.Geteuid() {
+		return ErrUntrustedOwner
+	}
+	return nil
+}
+
+// checkParentDirs rejects the executables placed in directories where users other than root or the agent one could
+// replace them. Directories with the sticky bit set, as /tmp, only allow replacing the files of their owner.
+func checkParentDirs(path string) error {
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	if path, err = filepath.Abs(path); err != nil {
+		return err
+	}
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return err
+		}
+		if info.Mode().Perm()&0022 != 0 && info.Mode()&os.ModeSticky == 0 {
+			return fmt.Errorf("%w: %s", ErrDirWritableByOthers, dir)
+		}
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok && stat.Uid != 0 && int(stat.Uid) != os.Geteuid() {
+			return fmt.Errorf("%w: %s", ErrDirUntrustedOwner, dir)
+		}
+		if filepath.Dir(dir) == dir {
+			return nil
+		}
+	}
+}
+
+func fileKeyOf(info os.FileInfo) fileKey {
+	key := fileKey{size: info.Size(), modTime: info.ModTime().UnixNano()}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		key.dev, key.ino = uint64(stat.Dev), uint64(stat.Ino)
+	}
+	return key
+}
@@ -0,0 +1,21 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package executor
+
+import (
+	"os"
+)
+
+// checkFileOwner is a no-op on Windows, where the access to the executables is controlled by their ACLs.
+func checkFileOwner(os.FileInfo) error {
+	return nil
+}
+
+// checkParentDirs is a no-op on Windows, where the access to the executables is controlled by their ACLs.
+func checkParentDirs(string) error {
+	return nil
+}
+
+func fileKeyOf(info os.FileInfo) fileKey {
+	return fileKey{size: info.Size(), modTime: info.ModTime().UnixNano()}
+}
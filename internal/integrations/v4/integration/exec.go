@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/executor"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/transform"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/when"
 	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
 	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/databind"
@@ -35,22 +36,28 @@ var elog = log.WithComponent("integrations.Definition")
 
 // Definition is a n `-exec` yaml entry. It will execute the provided command line or array of commands
 type Definition struct {
-	Name            string
-	Labels          map[string]string
-	ExecutorConfig  executor.Config
-	Interval        time.Duration
-	Timeout         time.Duration
-	ConfigTemplate  []byte // external configuration file, if provided
-	InventorySource ids.PluginID
-	WhenConditions  []when.Condition
-	runnable        executor.Executor
-	newTempFile     func(template []byte) (string, error)
+	Name             string
+	Labels           map[string]string
+	ExecutorConfig   executor.Config
+	Interval         time.Duration
+	Timeout          time.Duration
+	HeartbeatTimeout time.Duration // zero if the stall detection is disabled
+	ConfigTemplate   []byte        // external configuration file, if provided
+	InventorySource  ids.PluginID
+	WhenConditions   []when.Condition
+	Transform        *transform.Transformer // nil if the output is not transformed
+	runnable         executor.Executor
+	newTempFile      func(template []byte) (string, error)
 }
 
 func (d *Definition) TimeoutEnabled() bool {
 	return d.Timeout > 0
 }
 
+func (d *Definition) HeartbeatTimeoutEnabled() bool {
+	return d.HeartbeatTimeout > 0
+}
+
 // PluginID returns inventory plugin ID
 func (d *Definition) PluginID(integrationName string) ids.PluginID {
 	// user specified an inventory source has precedence
@@ -108,8 +115,14 @@ func (d *Definition) Run(ctx context.Context, bind *databind.Values) ([]Integrat
 	if d.ConfigTemplate != nil {
 		onDemand = ignoreConfigPathVar(&foundConfigPath)
 	}
+	// the verifier is not a discoverable field, so it's kept apart from the replaced config
+	runnable := d.runnable.DeepClone()
+	var verifier executor.Verifier
+	if runnable.Cfg != nil {
+		verifier, runnable.Cfg.Verifier = runnable.Cfg.Verifier, nil
+	}
 	matches, err := databind.Replace(bind, discoveredConfig{
-		Executor:       d.runnable.DeepClone(),
+		Executor:       runnable,
 		ConfigTemplate: d.ConfigTemplate,
 	}, databind.Provided(onDemand))
 	if err != nil {
@@ -157,6 +170,9 @@ func (d *Definition) Run(ctx context.Context, bind *databind.Values) ([]Integrat
 			logger.Debug("Found a nil ConfigTemplate.")
 		}
 
+		if dc.Executor.Cfg != nil {
+			dc.Executor.Cfg.Verifier = verifier
+		}
 		logger.Debug("Executing task.")
 		taskOutput := dc.Executor.Execute(ctx)
 		if removeFile != nil {
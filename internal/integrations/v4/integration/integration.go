@@ -11,6 +11,7 @@ import (
 	config2 "github.com/newrelic/infrastructure-agent/pkg/integrations/v4/config"
 
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/executor"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/transform"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/when"
 	"github.com/newrelic/infrastructure-agent/pkg/config"
 	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
@@ -45,6 +46,11 @@ type InstancesLookup struct {
 	Legacy func(DefinitionCommandConfig) (Definition, error)
 	// ByName looks for the path of an executable only by the name of the integration
 	ByName func(name string) (string, error)
+	// Verifier checks the integration executables before each execution. Optional.
+	Verifier executor.Verifier
+	// MaxPayloadSize integration payloads bigger than this size, in bytes, are discarded while being read. Zero
+	// disables the limit.
+	MaxPayloadSize int
 }
 
 // New interprets and validates a YAML ConfigEntry configuration and returns the proper
@@ -60,6 +66,9 @@ func New(te config2.ConfigEntry, lookup InstancesLookup, passthroughEnv []string
 			Directory:   te.WorkDir,
 			Environment: te.Env,
 			Passthrough: passthroughEnv,
+			Verifier:    lookup.Verifier,
+
+			MaxOutputLineSize: lookup.MaxPayloadSize,
 		},
 		Labels:         te.Labels,
 		Name:           te.Name,
@@ -98,6 +107,27 @@ func New(te config2.ConfigEntry, lookup InstancesLookup, passthroughEnv []string
 		d.Timeout = *te.Timeout
 	}
 
+	// Unset, zero or negative heartbeat timeout: stall detection disabled
+	if te.HeartbeatTimeout != nil && *te.HeartbeatTimeout > 0 {
+		if *te.HeartbeatTimeout < minimumTimeout {
+			ilog.WithFields(logrus.Fields{
+				"heartbeat_timeout": te.HeartbeatTimeout,
+				"minimum_timeout":   minimumTimeout,
+			}).Warn("heartbeat_timeout is too low (did you forget to append the time unit suffix?). Using minimum allowed value")
+			d.HeartbeatTimeout = minimumTimeout
+		} else {
+			d.HeartbeatTimeout = *te.HeartbeatTimeout
+		}
+	}
+
+	if te.Transform != nil {
+		var err error
+		d.Transform, err = transform.New(*te.Transform)
+		if err != nil {
+			return Definition{}, err
+		}
+	}
+
 	// if looking for a v3 integration from the v4 engine
 	if te.IntegrationName != "" {
 		err := d.fromLegacyV3(te, lookup)
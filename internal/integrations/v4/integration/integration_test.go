@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"runtime"
 	"testing"
+	"time"
 
 	config2 "github.com/newrelic/infrastructure-agent/pkg/integrations/v4/config"
 
@@ -197,3 +198,87 @@ timeout: 0
 	// THEN the integration has a disabled timeout
 	assert.False(t, i.TimeoutEnabled())
 }
+
+func TestTransform(t *testing.T) {
+	// GIVEN a configuration with an output transform
+	var config config2.ConfigEntry
+	require.NoError(t, yaml.Unmarshal([]byte(`
+name: foo
+exec: bar
+transform:
+  drop:
+    - metric: jvm.gc.*
+`), &config))
+
+	// WHEN the integration is loaded
+	i, err := New(config, noLookup, nil, nil)
+	require.NoError(t, err)
+
+	// THEN the integration transforms its output
+	assert.NotNil(t, i.Transform)
+}
+
+func TestTransform_Invalid(t *testing.T) {
+	// GIVEN a configuration with an invalid output transform
+	var config config2.ConfigEntry
+	require.NoError(t, yaml.Unmarshal([]byte(`
+name: foo
+exec: bar
+transform:
+  convert:
+    - metric: heap
+      from: bytes
+      to: seconds
+`), &config))
+
+	// WHEN the integration is loaded
+	_, err := New(config, noLookup, nil, nil)
+
+	// THEN it fails
+	assert.Error(t, err)
+}
+
+func TestHeartbeatTimeout(t *testing.T) {
+	// GIVEN a configuration with a heartbeat timeout
+	var config config2.ConfigEntry
+	require.NoError(t, yaml.Unmarshal([]byte(`
+name: foo
+exec: bar
+heartbeat_timeout: 5m
+`), &config))
+
+	// WHEN the integration is loaded
+	i, err := New(config, noLookup, nil, nil)
+	require.NoError(t, err)
+
+	// THEN the integration has the stall detection enabled
+	assert.True(t, i.HeartbeatTimeoutEnabled())
+	assert.Equal(t, 5*time.Minute, i.HeartbeatTimeout)
+}
+
+func TestHeartbeatTimeout_Default(t *testing.T) {
+	// GIVEN a configuration without heartbeat timeout
+	// WHEN an integration is loaded from it
+	i, err := New(config2.ConfigEntry{Name: "foo", Exec: config2.ShlexOpt{"bar"}}, noLookup, nil, nil)
+	require.NoError(t, err)
+
+	// THEN the stall detection is disabled
+	assert.False(t, i.HeartbeatTimeoutEnabled())
+}
+
+func TestHeartbeatTimeout_TooLow(t *testing.T) {
+	// GIVEN a configured heartbeat timeout where the user forgot to write a suffix
+	var config config2.ConfigEntry
+	require.NoError(t, yaml.Unmarshal([]byte(`
+name: foo
+exec: bar
+heartbeat_timeout: 40
+`), &config))
+
+	// WHEN the integration is loaded
+	i, err := New(config, noLookup, nil, nil)
+	require.NoError(t, err)
+
+	// THEN the integration has the minimum allowed heartbeat timeout
+	assert.Equal(t, minimumTimeout, i.HeartbeatTimeout)
+}
@@ -0,0 +1,69 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package runner
+
+import (
+	"encoding/json"
+
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/executor"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/emitter"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
+)
+
+// healthEventCategory is the category of the events reporting integration health errors.
+const healthEventCategory = "integrationHealth"
+
+// emitVerificationError reports an integration whose executable hasn't passed the verification.
+func (r *runner) emitVerificationError(verr *executor.VerificationError) {
+	r.log.WithError(verr).Error("integration executable verification failed, the integration won't be executed")
+
+	r.emitHealthError("integration executable verification failed", map[string]interface{}{
+		"executable": verr.Path,
+		"error":      verr.Err.Error(),
+	})
+}
+
+// emitStallError reports an integration that has been killed because it didn't emit any payload or heartbeat
+// within the heartbeat timeout.
+func (r *runner) emitStallError() {
+	r.log.WithField("heartbeat_timeout", r.Integration.HeartbeatTimeout).
+		WithField("stderr", r.lastStderr.Flush()).
+		Error("integration stalled: no payload or heartbeat received within the heartbeat timeout, restarting it")
+
+	r.emitHealthError("integration stalled", map[string]interface{}{
+		"heartbeatTimeout": r.Integration.HeartbeatTimeout.String(),
+		"error":            "no payload or heartbeat received within the heartbeat timeout",
+	})
+}
+
+// emitHealthError emits an integration health error event attached to the agent entity.
+func (r *runner) emitHealthError(summary string, attributes map[string]interface{}) {
+	attributes["integrationName"] = r.Integration.Name
+	attributes["status"] = "error"
+
+	payload, err := json.Marshal(protocol.PluginDataV3{
+		PluginOutputIdentifier: protocol.PluginOutputIdentifier{
+			Name:               r.Integration.Name,
+			RawProtocolVersion: "3",
+		},
+		DataSets: []protocol.PluginDataSetV3{{
+			PluginDataSet: protocol.PluginDataSet{
+				Events: []protocol.EventData{{
+					"summary":    summary,
+					"category":   healthEventCategory,
+					"attributes": attributes,
+				}},
+			},
+		}},
+	})
+	if err != nil {
+		r.log.WithError(err).Warn("can't marshal integration health event")
+		return
+	}
+	// agent generated events are neither transformed nor captured
+	metadata := r.Integration
+	metadata.Transform = nil
+	if err := emitter.Uncaptured(r.parent.emitter).Emit(metadata, nil, nil, payload); err != nil {
+		r.log.WithError(err).Warn("can't emit integration health event")
+	}
+}
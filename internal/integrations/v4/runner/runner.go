@@ -10,14 +10,17 @@ import (
 	"sync"
 	"time"
 
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/executor"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/integration"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/v3legacy"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/when"
+	"github.com/newrelic/infrastructure-agent/pkg/backend/backoff"
 	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
 	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/databind"
 	"github.com/newrelic/infrastructure-agent/pkg/helpers"
 	"github.com/newrelic/infrastructure-agent/pkg/helpers/contexts"
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/emitter"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
 	"github.com/newrelic/infrastructure-agent/pkg/log"
 
 	"github.com/sirupsen/logrus"
@@ -68,20 +71,46 @@ func (t *Group) Run(ctx context.Context) (hasStartedAnyOHI bool) {
 		t.getErrorHandler = sendErrorsToLog
 	}
 	for _, integr := range t.integrations {
-		r := runner{
-			parent:        t,
-			Integration:   integr,
-			heartBeatFunc: func() {},
-			stderrParser:  parseLogrusFields,
-		}
-		r.handleErrors = t.getErrorHandler(&r)
-		go r.Run(ctx)
+		go t.newRunner(integr).Run(ctx)
+		hasStartedAnyOHI = true
+	}
+
+	return
+}
+
+// RunOnce executes all the integrations a single time, blocking until all of them have finished or the provided
+// context is cancelled.
+func (t *Group) RunOnce(ctx context.Context) (hasStartedAnyOHI bool) {
+	if t.getErrorHandler == nil {
+		t.getErrorHandler = sendErrorsToLog
+	}
+	finished := sync.WaitGroup{}
+	finished.Add(len(t.integrations))
+	for _, integr := range t.integrations {
+		r := t.newRunner(integr)
+		go func() {
+			defer finished.Done()
+			r.RunOnce(ctx)
+		}()
 		hasStartedAnyOHI = true
 	}
+	finished.Wait()
 
 	return
 }
 
+func (t *Group) newRunner(integr integration.Definition) *runner {
+	r := &runner{
+		parent:        t,
+		Integration:   integr,
+		heartBeatFunc: func() {},
+		stderrParser:  parseLogrusFields,
+		stallBackoff:  backoff.NewDefaultBackoff(),
+	}
+	r.handleErrors = t.getErrorHandler(r)
+	return r
+}
+
 // runner for a single integration entry
 type runner struct {
 	ctx            context.Context // to avoid logging too many errors when the integration is cancelled by the user
@@ -94,33 +123,21 @@ type runner struct {
 	healthCheck    sync.Once
 	heartBeatFunc  func()
 	heartBeatMutex sync.RWMutex
+	// delays the restart of the integrations that stalled consecutively
+	stallBackoff *backoff.Backoff
 }
 
 func (r *runner) Run(ctx context.Context) {
-	r.ctx = ctx
-	config := r.Integration
-	fields := logrus.Fields{
-		"integration_name": config.Name,
-	}
-	for k, v := range config.Labels {
-		fields[k] = v
-	}
-	r.log = illog.WithFields(fields)
+	r.init(ctx)
 	for {
 		// we start counting the interval time on each integration execution
-		waitForNextExecution := time.After(config.Interval)
+		waitForNextExecution := time.After(r.Integration.Interval)
 
-		values, err := r.applyDiscovery()
-		if err != nil {
-			r.log.
-				WithError(
-					helpers.ObfuscateSensitiveDataFromError(err)).
-				Error("can't fetch discovery items")
+		// stalled integrations are restarted after a backoff, instead of the interval
+		if stalled := r.discoverAndExecute(ctx); stalled {
+			waitForNextExecution = time.After(r.stallBackoff.Duration())
 		} else {
-			// the integration runs only if all the when: conditions are true, if any
-			if when.All(r.Integration.WhenConditions...) {
-				r.execute(ctx, values)
-			}
+			r.stallBackoff.Reset()
 		}
 
 		select {
@@ -132,6 +149,40 @@ func (r *runner) Run(ctx context.Context) {
 	}
 }
 
+// RunOnce discovers and executes the integration a single time, returning when it finishes.
+func (r *runner) RunOnce(ctx context.Context) {
+	r.init(ctx)
+	r.discoverAndExecute(ctx)
+}
+
+func (r *runner) init(ctx context.Context) {
+	r.ctx = ctx
+	fields := logrus.Fields{
+		"integration_name": r.Integration.Name,
+	}
+	for k, v := range r.Integration.Labels {
+		fields[k] = v
+	}
+	r.log = illog.WithFields(fields)
+}
+
+// discoverAndExecute returns true if the integration has been killed because it stalled.
+func (r *runner) discoverAndExecute(ctx context.Context) (stalled bool) {
+	values, err := r.applyDiscovery()
+	if err != nil {
+		r.log.
+			WithError(
+				helpers.ObfuscateSensitiveDataFromError(err)).
+			Error("can't fetch discovery items")
+		return false
+	}
+	// the integration runs only if all the when: conditions are true, if any
+	if when.All(r.Integration.WhenConditions...) {
+		return r.execute(ctx, values)
+	}
+	return false
+}
+
 // applies discovery and returns the discovered values, if any.
 func (r *runner) applyDiscovery() (*databind.Values, error) {
 	if r.parent.discovery == nil {
@@ -165,30 +216,56 @@ func (r *runner) heartBeat() {
 // to finish
 // For long-time running integrations, avoids starting the next
 // discover-execute cycle until all the parallel processes have ended
-func (r *runner) execute(ctx context.Context, matches *databind.Values) {
+// It returns true if the integration has been killed because it didn't emit any payload or heartbeat within
+// the heartbeat timeout.
+func (r *runner) execute(ctx context.Context, matches *databind.Values) (stalled bool) {
 	config := r.Integration
+	parentCtx := ctx
+	var heartBeats []func()
 
 	// If timeout configuration is set, wraps current context in a heartbeat-enabled timeout context
 	if config.TimeoutEnabled() {
 		var act contexts.Actuator
 		ctx, act = contexts.WithHeartBeat(ctx, config.Timeout)
-		r.setHeartBeat(act.HeartBeat)
+		heartBeats = append(heartBeats, act.HeartBeat)
+	}
+
+	// If heartbeat timeout is set, wraps the context again, so the stalled processes are told apart from the
+	// ones reaching the timeout
+	timeoutCtx := ctx
+	if config.HeartbeatTimeoutEnabled() {
+		var act contexts.Actuator
+		ctx, act = contexts.WithHeartBeat(ctx, config.HeartbeatTimeout)
+		defer act.Cancel()
+		heartBeats = append(heartBeats, act.HeartBeat)
+	}
+
+	if len(heartBeats) > 0 {
+		r.setHeartBeat(func() {
+			for _, heartBeat := range heartBeats {
+				heartBeat()
+			}
+		})
 	}
 
 	// Runs all the matching integration instances
 	output, err := r.Integration.Run(ctx, matches)
 	if err != nil {
 		r.log.WithError(err).Error("can't start integration")
-		return
+		return false
 	}
 
 	// Waits for all the integrations to finish and reads the standard output and errors
 	instances := sync.WaitGroup{}
 	waitForCurrent := make(chan struct{})
-	instances.Add(len(output))
+	// for each instance, waits for both the errors and the standard output to be processed
+	instances.Add(2 * len(output))
 	for _, out := range output {
 		o := out
-		go r.handleLines(o.Output.Stdout, o.ExtraLabels, o.EntityRewrite)
+		go func() {
+			defer instances.Done()
+			r.handleLines(o.Output.Stdout, o.ExtraLabels, o.EntityRewrite)
+		}()
 		go r.handleStderr(o.Output.Stderr)
 		go func() {
 			defer instances.Done()
@@ -204,12 +281,17 @@ func (r *runner) execute(ctx context.Context, matches *databind.Values) {
 
 	select {
 	case <-ctx.Done():
+		if parentCtx.Err() == nil && timeoutCtx.Err() == nil {
+			r.emitStallError()
+			return true
+		}
 		r.log.Debug("Integration has been interrupted. Finishing.")
-		return
+		return false
 	case <-waitForCurrent:
 	}
 
 	r.log.Debug("Integration instances finished their execution. Waiting until next interval.")
+	return false
 }
 
 func (r *runner) handleStderr(stderr <-chan []byte) {
@@ -241,6 +323,14 @@ func (r *runner) logErrors(errs <-chan error) {
 				// channel closed: exiting
 				return
 			}
+			if verr, ok := err.(*executor.VerificationError); ok {
+				r.emitVerificationError(verr)
+				continue
+			}
+			if _, ok := err.(*protocol.PayloadTooLargeError); ok {
+				r.log.WithError(err).Warn("discarding integration payload")
+				continue
+			}
 			flush := r.lastStderr.Flush()
 			r.log.WithError(err).WithField("stderr", flush).
 				Warn("integration exited with error state")
@@ -12,6 +12,7 @@ import (
 	config2 "github.com/newrelic/infrastructure-agent/pkg/integrations/v4/config"
 
 	"github.com/fortytw2/leaktest"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/executor"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/fixtures"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/integration"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/testhelp"
@@ -64,6 +65,72 @@ func TestRunner(t *testing.T) {
 	assert.Empty(t, dataset.Metadata.Labels)
 }
 
+func TestRunner_RunOnce(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	// GIVEN a grouprunner that runs an integration with a long interval
+	te := &testemit.Emitter{}
+	loader := LoadFrom(config2.YAML{
+		Integrations: []config2.ConfigEntry{
+			{Name: "sayhello", Exec: testhelp.Command(fixtures.IntegrationScript, "hello"), Interval: "1h"},
+		},
+	}, nil)
+	gr, _, err := NewGroup(loader, integration.InstancesLookup{}, nil, te, "")
+	require.NoError(t, err)
+
+	// WHEN the Group executes the integrations once
+	require.True(t, gr.RunOnce(context.Background()))
+
+	// THEN the payload has been emitted
+	dataset, err := te.ReceiveFrom("sayhello")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", dataset.DataSet.Metrics[0]["value"])
+
+	// AND the integration is not executed again
+	assert.NoError(t, te.ExpectTimeout("sayhello", 100*time.Millisecond))
+}
+
+func TestRunner_VerificationFailed(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	// GIVEN a grouprunner whose integration executable doesn't pass the verification
+	te := &testemit.Emitter{}
+	loader := LoadFrom(config2.YAML{
+		Integrations: []config2.ConfigEntry{
+			{Name: "sayhello", Exec: testhelp.Command(fixtures.IntegrationScript, "hello"), Interval: "1h"},
+		},
+	}, nil)
+	lookup := integration.InstancesLookup{Verifier: rejectingVerifier{}}
+	gr, _, err := NewGroup(loader, lookup, nil, te, "")
+	require.NoError(t, err)
+
+	// WHEN the Group executes the integration
+	gr.RunOnce(context.Background())
+
+	// THEN an integration health error event is emitted for the agent entity
+	dataset, err := te.ReceiveFrom("sayhello")
+	require.NoError(t, err)
+	assert.Empty(t, dataset.DataSet.Metrics)
+	assert.Empty(t, dataset.DataSet.Entity.Name)
+	require.Len(t, dataset.DataSet.Events, 1)
+	event := dataset.DataSet.Events[0]
+	assert.Equal(t, "integrationHealth", event["category"])
+	attributes := event["attributes"].(map[string]interface{})
+	assert.Equal(t, "sayhello", attributes["integrationName"])
+	assert.Equal(t, "error", attributes["status"])
+	assert.NotEmpty(t, attributes["executable"])
+	assert.Equal(t, executor.ErrDigestNotAllowed.Error(), attributes["error"])
+
+	// AND no other payload is emitted
+	assert.NoError(t, te.ExpectTimeout("sayhello", 100*time.Millisecond))
+}
+
+type rejectingVerifier struct{}
+
+func (rejectingVerifier) Verify(path string) error {
+	return &executor.VerificationError{Path: path, Err: executor.ErrDigestNotAllowed}
+}
+
 func TestRunner_Inventory(t *testing.T) {
 	defer leaktest.Check(t)()
 
@@ -169,6 +236,49 @@ func TestRunner_Timeout(t *testing.T) {
 	}
 }
 
+func TestRunner_HeartbeatTimeout(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	// GIVEN a grouprunner that runs an integration that stalls, with a heartbeat timeout
+	te := &testemit.Emitter{}
+	noTimeout := time.Duration(0)
+	hbTimeout := 200 * time.Millisecond
+	loader := LoadFrom(config2.YAML{
+		Integrations: []config2.ConfigEntry{
+			{Name: "Hello", Exec: testhelp.Command(fixtures.BlockedCmd), Timeout: &noTimeout, HeartbeatTimeout: &hbTimeout},
+		},
+	}, nil)
+	gr, _, err := NewGroup(loader, integration.InstancesLookup{}, nil, te, "")
+	require.NoError(t, err)
+	// the "signal: killed" errors are ignored
+	gr.getErrorHandler = func(r *runner) runnerErrorHandler {
+		return func(errs <-chan error) {
+			for range errs {
+			}
+		}
+	}
+
+	// WHEN the Group executes the integration
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_ = gr.Run(ctx)
+
+	// THEN an integration health error event is emitted when the heartbeat timeout is reached
+	dataset, err := te.ReceiveFrom("Hello")
+	require.NoError(t, err)
+	require.Len(t, dataset.DataSet.Events, 1)
+	event := dataset.DataSet.Events[0]
+	assert.Equal(t, "integrationHealth", event["category"])
+	assert.Equal(t, "integration stalled", event["summary"])
+	attributes := event["attributes"].(map[string]interface{})
+	assert.Equal(t, "Hello", attributes["integrationName"])
+	assert.Equal(t, "200ms", attributes["heartbeatTimeout"])
+
+	// AND the integration is restarted, stalling again
+	_, err = te.ReceiveFrom("Hello")
+	require.NoError(t, err)
+}
+
 func TestRunner_DiscoveryChangesUpdated(t *testing.T) {
 	defer leaktest.Check(t)()
 
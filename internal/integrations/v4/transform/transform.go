@@ -0,0 +1,414 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// Package transform modifies the integrations output, as defined by the "transform" block of the integration
+// configuration, before it's emitted.
+package transform
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/config"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+)
+
+const (
+	// v3 metric samples are named by this field
+	eventTypeField = "event_type"
+	// events are matched by this field
+	categoryField = "category"
+	// events hold their custom attributes in this field
+	attributesField = "attributes"
+	// entity metadata fields are prefixed by this
+	metadataPrefix = "metadata."
+)
+
+var tlog = log.WithComponent("integrations.Transform")
+
+// Transformer applies the transform configuration of an integration to its datasets. A nil Transformer does
+// nothing.
+type Transformer struct {
+	drop             []dropRule
+	convert          []convertRule
+	renameMetrics    map[string]string
+	renameAttributes map[string]string
+	entityAttributes map[string]string
+}
+
+type dropRule struct {
+	metric     string
+	event      string
+	attributes map[string]string
+}
+
+type convertRule struct {
+	metric    string
+	attribute string
+	factor    float64
+}
+
+// New validates the transform configuration and returns its Transformer.
+func New(cfg config.Transform) (*Transformer, error) {
+	t := &Transformer{
+		renameMetrics:    cfg.RenameMetrics,
+		renameAttributes: cfg.RenameAttributes,
+		entityAttributes: cfg.EntityAttributes,
+	}
+	for i, rule := range cfg.Drop {
+		if rule.Metric == "" && rule.Event == "" && len(rule.Attributes) == 0 {
+			return nil, fmt.Errorf("transform drop rule %d: requires a 'metric', 'event' or 'attributes' field", i)
+		}
+		if rule.Metric != "" && rule.Event != "" {
+			return nil, fmt.Errorf("transform drop rule %d: only 'metric' or 'event' is allowed, not both at the same time", i)
+		}
+		patterns := []string{rule.Metric, rule.Event}
+		for _, value := range rule.Attributes {
+			patterns = append(patterns, value)
+		}
+		if err := validatePatterns(patterns...); err != nil {
+			return nil, fmt.Errorf("transform drop rule %d: %s", i, err)
+		}
+		t.drop = append(t.drop, dropRule{metric: rule.Metric, event: rule.Event, attributes: rule.Attributes})
+	}
+	for i, rule := range cfg.Convert {
+		if rule.Metric == "" && rule.Attribute == "" {
+			return nil, fmt.Errorf("transform convert rule %d: requires a 'metric' or 'attribute' field", i)
+		}
+		if err := validatePatterns(rule.Metric, rule.Attribute); err != nil {
+			return nil, fmt.Errorf("transform convert rule %d: %s", i, err)
+		}
+		factor, err := conversionFactor(rule)
+		if err != nil {
+			return nil, fmt.Errorf("transform convert rule %d: %s", i, err)
+		}
+		t.convert = append(t.convert, convertRule{metric: rule.Metric, attribute: rule.Attribute, factor: factor})
+	}
+	for field := range cfg.EntityAttributes {
+		if !isEntityField(field) {
+			return nil, fmt.Errorf("transform entity attributes: unknown entity field %q", field)
+		}
+	}
+	return t, nil
+}
+
+// DataSetV3 transforms a dataset of the protocols v1 to v3. The metric samples are named by their event_type,
+// and all their other fields are handled as attributes.
+func (t *Transformer) DataSetV3(dataSet *protocol.PluginDataSetV3) {
+	if t == nil {
+		return
+	}
+
+	metrics := dataSet.Metrics[:0]
+	for _, sample := range dataSet.Metrics {
+		name, _ := sample[eventTypeField].(string)
+		if t.dropsMetric(name, lookupIn(sample)) {
+			continue
+		}
+		for key, value := range sample {
+			if key != eventTypeField {
+				sample[key] = t.convertAttribute(name, key, value)
+			}
+		}
+		if newName, ok := t.renameMetrics[name]; ok {
+			sample[eventTypeField] = newName
+		}
+		renameKeys(sample, t.renameAttributes, eventTypeField)
+		for field, attribute := range t.entityAttributes {
+			if value := entityFieldV3(dataSet, field); value != "" {
+				setIfAbsent(sample, attribute, value)
+			}
+		}
+		metrics = append(metrics, sample)
+	}
+	dataSet.Metrics = metrics
+
+	dataSet.Events = t.events(dataSet.Events, func(field string) string {
+		return entityFieldV3(dataSet, field)
+	})
+}
+
+// DataSetV4 transforms a dataset of the protocol v4.
+func (t *Transformer) DataSetV4(dataSet *protocol.Dataset) {
+	if t == nil {
+		return
+	}
+
+	metrics := dataSet.Metrics[:0]
+	for _, metric := range dataSet.Metrics {
+		lookup := lookupIn(metric.Attributes, dataSet.Common.Attributes)
+		if t.dropsMetric(metric.Name, lookup) {
+			continue
+		}
+		for _, rule := range t.convert {
+			if rule.attribute == "" && match(rule.metric, metric.Name) {
+				metric.Value = convertMetricValue(metric, rule.factor)
+			}
+		}
+		for key, value := range metric.Attributes {
+			metric.Attributes[key] = t.convertAttribute(metric.Name, key, value)
+		}
+		if newName, ok := t.renameMetrics[metric.Name]; ok {
+			metric.Name = newName
+		}
+		renameKeys(metric.Attributes, t.renameAttributes, "")
+		metrics = append(metrics, metric)
+	}
+	dataSet.Metrics = metrics
+
+	for key, value := range dataSet.Common.Attributes {
+		dataSet.Common.Attributes[key] = t.convertAttribute("", key, value)
+	}
+	renameKeys(dataSet.Common.Attributes, t.renameAttributes, "")
+	for field, attribute := range t.entityAttributes {
+		if value := entityFieldV4(dataSet.Entity, field); value != "" {
+			if dataSet.Common.Attributes == nil {
+				dataSet.Common.Attributes = map[string]interface{}{}
+			}
+			setIfAbsent(dataSet.Common.Attributes, attribute, value)
+		}
+	}
+
+	dataSet.Events = t.events(dataSet.Events, func(field string) string {
+		return entityFieldV4(dataSet.Entity, field)
+	})
+}
+
+func (t *Transformer) events(events []protocol.EventData, entityField func(field string) string) []protocol.EventData {
+	kept := events[:0]
+	for _, event := range events {
+		attributes, _ := event[attributesField].(map[string]interface{})
+		category, _ := event[categoryField].(string)
+		if t.dropsEvent(category, lookupIn(attributes, event)) {
+			continue
+		}
+		for key, value := range attributes {
+			attributes[key] = t.convertAttribute("", key, value)
+		}
+		renameKeys(attributes, t.renameAttributes, "")
+		for field, attribute := range t.entityAttributes {
+			if value := entityField(field); value != "" {
+				if attributes == nil {
+					attributes = map[string]interface{}{}
+					event[attributesField] = attributes
+				}
+				setIfAbsent(attributes, attribute, value)
+			}
+		}
+		kept = append(kept, event)
+	}
+	return kept
+}
+
+func (t *Transformer) dropsMetric(name string, lookup attributeLookup) bool {
+	for _, rule := range t.drop {
+		if rule.event == "" && (rule.metric == "" || match(rule.metric, name)) && rule.matchesAttributes(lookup) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Transformer) dropsEvent(category string, lookup attributeLookup) bool {
+	for _, rule := range t.drop {
+		if rule.metric == "" && (rule.event == "" || match(rule.event, category)) && rule.matchesAttributes(lookup) {
+			return true
+		}
+	}
+	return false
+}
+
+// convertAttribute applies the attribute conversion rules to the value of an attribute. Metric name is empty for
+// the attributes that don't belong to a metric, which are only converted by the rules not matching metric names.
+func (t *Transformer) convertAttribute(metricName, key string, value interface{}) interface{} {
+	number, ok := value.(float64)
+	if !ok {
+		return value
+	}
+	for _, rule := range t.convert {
+		if rule.attribute == "" || !match(rule.attribute, key) {
+			continue
+		}
+		if rule.metric == "" || (metricName != "" && match(rule.metric, metricName)) {
+			number *= rule.factor
+		}
+	}
+	return number
+}
+
+func (r dropRule) matchesAttributes(lookup attributeLookup) bool {
+	for key, pattern := range r.attributes {
+		value, ok := lookup(key)
+		if !ok || !match(pattern, fmt.Sprint(value)) {
+			return false
+		}
+	}
+	return true
+}
+
+// convertMetricValue multiplies the value of the numeric and summary metrics. Other metric types are not converted.
+func convertMetricValue(metric protocol.Metric, factor float64) json.RawMessage {
+	var converted interface{}
+	switch metric.Type {
+	case protocol.MetricTypeSummary:
+		value, err := metric.SummaryValue()
+		if err != nil {
+			return metric.Value
+		}
+		value.Min *= factor
+		value.Max *= factor
+		value.Sum *= factor
+		converted = value
+	default:
+		value, err := metric.NumericValue()
+		if err != nil {
+			tlog.WithField("name", metric.Name).WithField("type", metric.Type).Debug("Can't convert metric value.")
+			return metric.Value
+		}
+		converted = value * factor
+	}
+	raw, err := json.Marshal(converted)
+	if err != nil {
+		return metric.Value
+	}
+	return raw
+}
+
+// attributeLookup returns the value of an attribute, and whether it exists.
+type attributeLookup func(key string) (interface{}, bool)
+
+// lookupIn looks for the attributes in the provided maps, in order.
+func lookupIn(maps ...map[string]interface{}) attributeLookup {
+	return func(key string) (interface{}, bool) {
+		for _, m := range maps {
+			if value, ok := m[key]; ok {
+				return value, true
+			}
+		}
+		return nil, false
+	}
+}
+
+func renameKeys(m map[string]interface{}, names map[string]string, skip string) {
+	for oldName, newName := range names {
+		if oldName == skip {
+			continue
+		}
+		if value, ok := m[oldName]; ok {
+			delete(m, oldName)
+			m[newName] = value
+		}
+	}
+}
+
+func setIfAbsent(m map[string]interface{}, key string, value interface{}) {
+	if _, ok := m[key]; !ok {
+		m[key] = value
+	}
+}
+
+func isEntityField(field string) bool {
+	switch field {
+	case "name", "type", "displayName":
+		return true
+	}
+	return strings.HasPrefix(field, metadataPrefix) && len(field) > len(metadataPrefix)
+}
+
+func entityFieldV4(e protocol.Entity, field string) string {
+	switch field {
+	case "name":
+		return e.Name
+	case "type":
+		return e.Type
+	case "displayName":
+		return e.DisplayName
+	}
+	if value, ok := e.Metadata[strings.TrimPrefix(field, metadataPrefix)]; ok {
+		return fmt.Sprint(value)
+	}
+	return ""
+}
+
+// entityFieldV3 returns the v3 entity fields. V3 entities have no display name, and their metadata are the
+// identifying attributes.
+func entityFieldV3(dataSet *protocol.PluginDataSetV3, field string) string {
+	switch field {
+	case "name":
+		return dataSet.Entity.Name
+	case "type":
+		return string(dataSet.Entity.Type)
+	}
+	key := strings.TrimPrefix(field, metadataPrefix)
+	for _, attr := range dataSet.Entity.IDAttributes {
+		if attr.Key == key {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// match returns true if the name matches the glob pattern. Patterns are validated on creation.
+func match(pattern, name string) bool {
+	matched, _ := path.Match(pattern, name)
+	return matched
+}
+
+func validatePatterns(patterns ...string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// units by dimension, with their value in the dimension base unit
+var units = map[string]map[string]float64{
+	"data": {
+		"bytes":     1,
+		"kilobytes": 1e3,
+		"megabytes": 1e6,
+		"gigabytes": 1e9,
+		"terabytes": 1e12,
+		"kibibytes": 1 << 10,
+		"mebibytes": 1 << 20,
+		"gibibytes": 1 << 30,
+		"tebibytes": 1 << 40,
+	},
+	"time": {
+		"nanoseconds":  1e-9,
+		"microseconds": 1e-6,
+		"milliseconds": 1e-3,
+		"seconds":      1,
+		"minutes":      60,
+		"hours":        3600,
+	},
+	"fraction": {
+		"ratio":   1,
+		"percent": 0.01,
+	},
+}
+
+func conversionFactor(rule config.ConvertRule) (float64, error) {
+	if rule.From == "" && rule.To == "" {
+		if rule.Factor == 0 {
+			return 0, errors.New("requires either a 'factor' or both 'from' and 'to' units")
+		}
+		return rule.Factor, nil
+	}
+	if rule.Factor != 0 {
+		return 0, errors.New("only 'factor' or 'from' and 'to' units are allowed, not both at the same time")
+	}
+	for _, dimension := range units {
+		from, fromOk := dimension[rule.From]
+		to, toOk := dimension[rule.To]
+		if fromOk && toOk {
+			return from / to, nil
+		}
+	}
+	return 0, fmt.Errorf("can't convert from %q to %q", rule.From, rule.To)
+}
@@ -0,0 +1,197 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package transform
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/config"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
+)
+
+func newTransformer(t *testing.T, cfg string) *Transformer {
+	var c config.Transform
+	require.NoError(t, yaml.Unmarshal([]byte(cfg), &c))
+	tr, err := New(c)
+	require.NoError(t, err)
+	return tr
+}
+
+func metricNames(metrics []protocol.Metric) (names []string) {
+	for _, m := range metrics {
+		names = append(names, m.Name)
+	}
+	return
+}
+
+func TestNew_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Transform
+	}{
+		{"empty drop rule", config.Transform{Drop: []config.DropRule{{}}}},
+		{"metric and event", config.Transform{Drop: []config.DropRule{{Metric: "a", Event: "b"}}}},
+		{"bad pattern", config.Transform{Drop: []config.DropRule{{Metric: "a["}}}},
+		{"bad attribute pattern", config.Transform{Drop: []config.DropRule{{Attributes: map[string]string{"a": "["}}}}},
+		{"convert without target", config.Transform{Convert: []config.ConvertRule{{Factor: 2}}}},
+		{"convert without factor", config.Transform{Convert: []config.ConvertRule{{Metric: "a"}}}},
+		{"convert factor and units", config.Transform{Convert: []config.ConvertRule{{Metric: "a", Factor: 2, From: "bytes", To: "megabytes"}}}},
+		{"incompatible units", config.Transform{Convert: []config.ConvertRule{{Metric: "a", From: "bytes", To: "seconds"}}}},
+		{"unknown unit", config.Transform{Convert: []config.ConvertRule{{Metric: "a", From: "bytes", To: "bits"}}}},
+		{"unknown entity field", config.Transform{EntityAttributes: map[string]string{"id": "entityId"}}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := New(tc.cfg)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestTransformer_Nil(t *testing.T) {
+	var tr *Transformer
+	dataSet := protocol.Dataset{Metrics: []protocol.Metric{{Name: "a"}}}
+
+	tr.DataSetV4(&dataSet)
+
+	assert.Equal(t, []string{"a"}, metricNames(dataSet.Metrics))
+}
+
+func TestTransformer_DataSetV4(t *testing.T) {
+	// GIVEN a transform configuration
+	tr := newTransformer(t, `
+drop:
+  - metric: jvm.gc.*
+  - metric: http.requests
+    attributes:
+      path: /health*
+  - event: debug
+convert:
+  - metric: "*.bytes"
+    from: bytes
+    to: megabytes
+  - metric: latency
+    factor: 0.001
+  - attribute: durationMs
+    factor: 0.001
+rename_metrics:
+  heap.bytes: heap.megabytes
+rename_attributes:
+  hostName: host
+entity_attributes:
+  displayName: entityDisplayName
+  metadata.team: team
+`)
+
+	ds := protocol.Dataset{
+		Entity: protocol.Entity{Name: "jvm-1", DisplayName: "JVM 1", Metadata: map[string]interface{}{"team": "core"}},
+		Common: protocol.Common{Attributes: map[string]interface{}{"hostName": "h1"}},
+		Metrics: []protocol.Metric{
+			{Name: "jvm.gc.time", Type: protocol.MetricTypeGauge, Value: json.RawMessage("1")},
+			{Name: "http.requests", Type: protocol.MetricTypeCount, Value: json.RawMessage("1"),
+				Attributes: map[string]interface{}{"path": "/healthz"}},
+			{Name: "http.requests", Type: protocol.MetricTypeCount, Value: json.RawMessage("2"),
+				Attributes: map[string]interface{}{"path": "/api", "durationMs": 1500.0}},
+			{Name: "heap.bytes", Type: protocol.MetricTypeGauge, Value: json.RawMessage("2500000")},
+			{Name: "latency", Type: protocol.MetricTypeSummary, Value: json.RawMessage(`{"count":2,"sum":3000,"min":1000,"max":2000}`)},
+		},
+		Events: []protocol.EventData{
+			{"summary": "noise", "category": "debug"},
+			{"summary": "restarted", "category": "notifications", "attributes": map[string]interface{}{"hostName": "h1"}},
+		},
+	}
+
+	// WHEN it's applied to a v4 dataset
+	tr.DataSetV4(&ds)
+
+	// THEN the matching metrics are dropped
+	require.Equal(t, []string{"http.requests", "heap.megabytes", "latency"}, metricNames(ds.Metrics))
+
+	// AND the metric and attribute values are converted
+	assert.Equal(t, "2.5", string(ds.Metrics[1].Value))
+	var summary protocol.SummaryValue
+	require.NoError(t, json.Unmarshal(ds.Metrics[2].Value, &summary))
+	assert.Equal(t, protocol.SummaryValue{Count: 2, Sum: 3, Min: 1, Max: 2}, summary)
+	assert.Equal(t, 1.5, ds.Metrics[0].Attributes["durationMs"])
+	assert.Equal(t, "2", string(ds.Metrics[0].Value))
+
+	// AND the attributes are renamed, and the entity fields copied into the common attributes
+	assert.Equal(t, map[string]interface{}{
+		"host":              "h1",
+		"entityDisplayName": "JVM 1",
+		"team":              "core",
+	}, ds.Common.Attributes)
+
+	// AND the events are dropped and transformed
+	require.Len(t, ds.Events, 1)
+	assert.Equal(t, map[string]interface{}{
+		"host":              "h1",
+		"entityDisplayName": "JVM 1",
+		"team":              "core",
+	}, ds.Events[0]["attributes"])
+}
+
+func TestTransformer_DataSetV3(t *testing.T) {
+	// GIVEN a transform configuration
+	tr := newTransformer(t, `
+drop:
+  - attributes:
+      env: dev
+convert:
+  - metric: StorageSample
+    attribute: "*Bytes"
+    from: bytes
+    to: kibibytes
+rename_metrics:
+  StorageSample: DiskSample
+rename_attributes:
+  usedBytes: usedKiB
+entity_attributes:
+  name: entityName
+  metadata.mount: mountPoint
+`)
+
+	ds := protocol.PluginDataSetV3{
+		PluginDataSet: protocol.PluginDataSet{
+			Entity: entity.Fields{Name: "disk-1", IDAttributes: entity.IDAttributes{{Key: "mount", Value: "/data"}}},
+			Metrics: []protocol.MetricData{
+				{"event_type": "StorageSample", "usedBytes": 2048.0, "env": "prod"},
+				{"event_type": "StorageSample", "usedBytes": 1024.0, "env": "dev"},
+				{"event_type": "NetworkSample", "receivedBytes": 2048.0},
+			},
+			Events: []protocol.EventData{
+				{"summary": "dev event", "category": "notifications", "attributes": map[string]interface{}{"env": "dev"}},
+				{"summary": "prod event", "category": "notifications"},
+			},
+		},
+	}
+
+	// WHEN it's applied to a v3 dataset
+	tr.DataSetV3(&ds)
+
+	// THEN the matching metric samples and events are dropped, and the rest are transformed
+	require.Len(t, ds.Metrics, 2)
+	assert.Equal(t, protocol.MetricData{
+		"event_type": "DiskSample",
+		"usedKiB":    2.0,
+		"env":        "prod",
+		"entityName": "disk-1",
+		"mountPoint": "/data",
+	}, ds.Metrics[0])
+	assert.Equal(t, protocol.MetricData{
+		"event_type":    "NetworkSample",
+		"receivedBytes": 2048.0,
+		"entityName":    "disk-1",
+		"mountPoint":    "/data",
+	}, ds.Metrics[1])
+
+	require.Len(t, ds.Events, 1)
+	assert.Equal(t, "prod event", ds.Events[0]["summary"])
+	assert.Equal(t, map[string]interface{}{"entityName": "disk-1", "mountPoint": "/data"}, ds.Events[0]["attributes"])
+}
@@ -50,7 +50,32 @@ var clog = log.WithComponent("Configuration")
 // Configuration type to Map include_matching_metrics setting env var
 type IncludeMetricsMap map[string][]string
 
-//
+// MetricsTransformMap maps event types to the attribute transformations applied, in order, to their samples.
+type MetricsTransformMap map[string][]MetricsTransform
+
+// MetricsTransform an attribute transformation. Action is one of: drop, rename (to "To"), hash (SHA-256),
+// truncate (to "Length" characters), redact (replaces "Regex" matches by "Replacement") or add (static "Value").
+type MetricsTransform struct {
+	Action      string      `yaml:"action"`
+	Attribute   string      `yaml:"attribute"`
+	To          string      `yaml:"to"`
+	Length      int         `yaml:"length"`
+	Regex       string      `yaml:"regex"`
+	Replacement string      `yaml:"replacement"`
+	Value       interface{} `yaml:"value"`
+}
+
+// FileMonitors files and directories reported by the FileSample sampler.
+type FileMonitors []FileMonitor
+
+// FileMonitor a file, directory or glob pattern reported as a single FileSample. Directories are scanned up to their
+// direct children, or up to MaxDepth levels when Recursive.
+type FileMonitor struct {
+	Path      string `yaml:"path"`
+	Recursive bool   `yaml:"recursive"`
+	MaxDepth  int    `yaml:"max_depth"`
+}
+
 // IMPORTANT NOTE: If you add new config fields, consider checking the ignore list in
 // the plugins/agent_config.go plugin to not send undesired fields as inventory
 //
@@ -322,24 +347,66 @@ type Config struct {
 	// Public: Yes
 	MetricsSystemSampleRate int `yaml:"metrics_system_sample_rate" envconfig:"metrics_system_sample_rate"`
 
+	// MetricsCPUCoreSampleRate Sample rate of CPU Core Samples in seconds, reporting the usage, scaling frequency and
+	// interrupt and softirq rates of each logical CPU. Linux only. Minimum value is 5. The sampler is disabled by
+	// default, or if value is -1.
+	// Default: -1
+	// Public: Yes
+	MetricsCPUCoreSampleRate int `yaml:"metrics_cpu_core_sample_rate" envconfig:"metrics_cpu_core_sample_rate"`
+
 	// MetricsStorageSampleRate Sample rate of Storage Samples in seconds. Minimum value is 5. If value is -1 then
 	// the sampler is disabled.
 	// Default: 5
 	// Public: Yes
 	MetricsStorageSampleRate int `yaml:"metrics_storage_sample_rate" envconfig:"metrics_storage_sample_rate"`
 
+	// MetricsStorageUnmountedDisks When true, StorageSamples are also reported for the block devices that are not
+	// mounted (e.g. raw disks used by databases or LVM physical volumes), with their IO metrics only. For disks with
+	// mounted partitions, only the unmounted partitions are reported. Linux only.
+	// Default: False
+	// Public: Yes
+	MetricsStorageUnmountedDisks bool `yaml:"metrics_storage_unmounted_disks" envconfig:"metrics_storage_unmounted_disks"`
+
 	// MetricsNetworkSampleRate Sample rate of Network Samples in seconds. Minimum value is 10. If value is -1 then
 	// the sampler is disabled.
 	// Default: 5
 	// Public: Yes
 	MetricsNetworkSampleRate int `yaml:"metrics_network_sample_rate" envconfig:"metrics_network_sample_rate"`
 
+	// MetricsNetworkProtocolSampleRate Sample rate of Network Protocol Samples in seconds, with the TCP and UDP
+	// statistics of the host (retransmits, resets, listen queue overflows, UDP buffer errors...). Linux only. Minimum
+	// value is 10. The sampler is disabled by default, or if value is -1.
+	// Default: -1
+	// Public: Yes
+	MetricsNetworkProtocolSampleRate int `yaml:"metrics_network_protocol_sample_rate" envconfig:"metrics_network_protocol_sample_rate"`
+
+	// MetricsSensorSampleRate Sample rate of Sensor Samples in seconds, with the hardware temperatures, fans, voltages
+	// and power supplies found in /sys/class/hwmon, /sys/class/thermal and /sys/class/power_supply. Linux only.
+	// Minimum value is 5. The sampler is disabled by default, or if value is -1.
+	// Default: -1
+	// Public: Yes
+	MetricsSensorSampleRate int `yaml:"metrics_sensor_sample_rate" envconfig:"metrics_sensor_sample_rate"`
+
 	// MetricsProcessSampleRate Sample rate of System Samples in seconds. Minimum value is 20. If value is -1 then
 	// the sampler is disabled.
 	// Default: 20
 	// Public: Yes
 	MetricsProcessSampleRate int `yaml:"metrics_process_sample_rate" envconfig:"metrics_process_sample_rate"`
 
+	// MetricsProcessTopN When greater than 0, only the N processes with the highest CPU usage plus the N processes with
+	// the highest resident memory are reported each interval. The remaining ones are reported as a single aggregated
+	// ProcessSample named "other", with their summed metrics and processCount attribute. The include and exclude
+	// matching metrics rules are applied to the processes before selecting them, not to the aggregated samples.
+	// Default: 0
+	// Public: Yes
+	MetricsProcessTopN int `yaml:"metrics_process_top_n" envconfig:"metrics_process_top_n"`
+
+	// MetricsProcessGroupByCommand When true, processes running the same command (within the same container) are
+	// reported as a single ProcessSample, with their summed metrics and the number of processes as processCount.
+	// Default: False
+	// Public: Yes
+	MetricsProcessGroupByCommand bool `yaml:"metrics_process_group_by_command" envconfig:"metrics_process_group_by_command"`
+
 	// HeartBeatSampleRate Interval in seconds for sending the HeartBeatSample.
 	// Default: False
 	// Public: No
@@ -350,6 +417,81 @@ type Config struct {
 	// Public: No
 	DMSubmissionPeriod int `yaml:"dm_submission_period" envconfig:"dm_submission_period" public:"false"`
 
+	// DMMaxSeriesPerMetric Maximum number of unique time series (combinations of attribute values) reported per day
+	// for each dimensional metric name. Metrics of new time series exceeding the limit are handled according to
+	// dm_cardinality_policy. Zero disables the limit.
+	// Default: 0
+	// Public: Yes
+	DMMaxSeriesPerMetric int `yaml:"dm_max_series_per_metric" envconfig:"dm_max_series_per_metric"`
+
+	// DMMaxSeries Maximum number of unique time series reported per day across all the dimensional metrics. Zero
+	// disables the limit.
+	// Default: 0
+	// Public: Yes
+	DMMaxSeries int `yaml:"dm_max_series" envconfig:"dm_max_series"`
+
+	// DMCardinalityPolicy What to do with the metrics of new time series once a cardinality limit is reached: "drop"
+	// discards them, "strip_attribute" removes the attribute with the most distinct values for the metric name.
+	// Overflows are logged and counted in the newrelic.infra.dm.cardinalityOverflow metric.
+	// Default: drop
+	// Public: Yes
+	DMCardinalityPolicy string `yaml:"dm_cardinality_policy" envconfig:"dm_cardinality_policy"`
+
+	// DMAggregationWindow Interval in seconds for aggregating the dimensional metrics locally before submitting
+	// them. Within each window, gauges are reported as summaries (min, max and average) plus a "<name>.last" gauge,
+	// and counts are summed, per metric name and attributes. Zero disables the aggregation.
+	// Default: 0
+	// Public: Yes
+	DMAggregationWindow int `yaml:"dm_aggregation_window" envconfig:"dm_aggregation_window"`
+
+	// DMAggregateBy List of attributes removed from the dimensional metrics before aggregating them, so the metrics
+	// only differing on these attributes are aggregated together. Only applies when dm_aggregation_window is set.
+	// Default: Empty
+	// Public: Yes
+	DMAggregateBy []string `yaml:"dm_aggregate_by" envconfig:"dm_aggregate_by"`
+
+	// MaxIntegrationPayloadSize Integration payloads bigger than this size, in bytes, are discarded while being
+	// read, logging an error for the integration. Each payload line is still read whole in memory up to this size:
+	// the memory saved comes from decoding it dataset by dataset instead of unmarshalling it at once. Zero disables
+	// the limit.
+	// Default: 104857600
+	// Public: Yes
+	MaxIntegrationPayloadSize int `yaml:"max_integration_payload_size" envconfig:"max_integration_payload_size"`
+
+	// IntegrationsChecksumsFile Path to an allow-list of the sha256 digests of the integration executables, in
+	// the sha256sum output format. Each digest is optionally followed by the path of the executable it applies to.
+	// Executables not in the allow-list are not run, and an integration health error event is reported.
+	// When any verification is enabled, executables writable by their group or other users, or not owned by root
+	// or the agent user, are rejected too, and integrations run with integration_user require absolute paths.
+	// Empty disables the checksums verification.
+	// Default: Empty
+	// Public: Yes
+	IntegrationsChecksumsFile string `yaml:"integrations_checksums_file" envconfig:"integrations_checksums_file"`
+
+	// IntegrationsSignaturePublicKey Path to a PEM encoded ed25519 public key. When set, the integration
+	// executables must have a valid detached signature, in a file beside them with the .sig suffix, unless their
+	// digest is in the integrations_checksums_file allow-list. Empty disables the signatures verification.
+	// Default: Empty
+	// Public: Yes
+	IntegrationsSignaturePublicKey string `yaml:"integrations_signature_public_key" envconfig:"integrations_signature_public_key"`
+
+	// IntegrationsCaptureDir Directory where the raw payloads of each integration are recorded, one per line, for
+	// them to be replayed with the -replay-integration flag. Empty disables the capture.
+	// Default: Empty
+	// Public: Yes
+	IntegrationsCaptureDir string `yaml:"integrations_capture_dir" envconfig:"integrations_capture_dir"`
+
+	// IntegrationsCaptureMaxSize Size in bytes of the integration capture files before they are rotated. Zero
+	// disables the rotation.
+	// Default: 10485760
+	// Public: Yes
+	IntegrationsCaptureMaxSize int `yaml:"integrations_capture_max_size" envconfig:"integrations_capture_max_size"`
+
+	// IntegrationsCaptureMaxFiles Number of capture files kept per integration, including the current one.
+	// Default: 5
+	// Public: Yes
+	IntegrationsCaptureMaxFiles int `yaml:"integrations_capture_max_files" envconfig:"integrations_capture_max_files"`
+
 	// CustomSupportedFileSystems List of filesystems types the agent supports. This value should be a subset of the
 	// default list, items that are not in the default list will be discarded.
 	// Default: Empty
@@ -461,6 +603,44 @@ type Config struct {
 	// Public: Yes
 	SystemdIntervalSec int64 `yaml:"systemd_interval_sec" envconfig:"systemd_interval_sec"`
 
+	// MetricsSystemdUnits List of glob patterns (e.g. nginx.service, php*-fpm.service) of the systemd units reported
+	// as SystemdUnitSamples, with their active and sub states, restarts, memory, CPU and tasks accounting. The
+	// sampler is disabled when empty. Linux only.
+	// Default: Empty
+	// Public: Yes
+	MetricsSystemdUnits []string `yaml:"metrics_systemd_units" envconfig:"metrics_systemd_units"`
+
+	// MetricsSystemdSampleRate Sample rate of Systemd Unit Samples in seconds. Minimum value is 5. If value is -1 then
+	// the sampler is disabled.
+	// Default: 15
+	// Public: Yes
+	MetricsSystemdSampleRate int `yaml:"metrics_systemd_sample_rate" envconfig:"metrics_systemd_sample_rate"`
+
+	// MetricsFileMonitors Files, directories or glob patterns reported as FileSamples, with their existence, size,
+	// number of files and the age of their oldest and newest files. The sampler is disabled when empty.
+	// e.g.
+	//   metrics_file_monitors:
+	//     - path: /var/spool/postfix
+	//       recursive: true
+	//       max_depth: 5
+	//     - path: /backups/*.tar.gz
+	// Recursive scans are limited to 10 levels unless max_depth is set.
+	// Default: Empty
+	// Public: Yes
+	MetricsFileMonitors FileMonitors `yaml:"metrics_file_monitors" envconfig:"metrics_file_monitors"`
+
+	// MetricsFileSampleRate Sample rate of File Samples in seconds. Minimum value is 5. If value is -1 then the
+	// sampler is disabled.
+	// Default: 60
+	// Public: Yes
+	MetricsFileSampleRate int `yaml:"metrics_file_sample_rate" envconfig:"metrics_file_sample_rate"`
+
+	// MetricsFileScanTimeoutSec Maximum time in seconds spent scanning each of the metrics_file_monitors paths. When
+	// exceeded, the FileSample is reported with an error attribute instead of the metrics.
+	// Default: 10
+	// Public: Yes
+	MetricsFileScanTimeoutSec int `yaml:"metrics_file_scan_timeout_sec" envconfig:"metrics_file_scan_timeout_sec"`
+
 	// SysvInitIntervalSec Sampling period / interval in seconds for SysV plugin. Set as value -1 for disabling it.
 	// 10 is the minimum value. This plugin can be activated only in root mode or privileged mode.
 	// Default: 30
@@ -739,6 +919,33 @@ type Config struct {
 	// Public: No
 	FluentBitNRLibPath string `yaml:"fluent_bit_nr_lib_path "envconfig:"fluent_bit_nr_lib_path" public:"false"`
 
+	// LogForwarderMonitoringEnabled turns on the Fluent Bit built-in HTTP monitoring server, listening on localhost.
+	// The agent scrapes its counters periodically and reports them as LogForwarderSample events, including the records
+	// dropped by the rate_limit and sample_rate log source settings, which are not reported when it's disabled.
+	// Default: True
+	// Public: Yes
+	LogForwarderMonitoringEnabled bool `yaml:"log_forwarder_monitoring_enabled" envconfig:"log_forwarder_monitoring_enabled" public:"true"`
+
+	// LogForwarderMonitoringPort is the localhost port the Fluent Bit HTTP monitoring server listens on. It differs
+	// from the Fluent Bit default one, so it doesn't clash with any other Fluent Bit running in the host. The
+	// monitoring is disabled when the port is already in use.
+	// Default: 18020
+	// Public: Yes
+	LogForwarderMonitoringPort int `yaml:"log_forwarder_monitoring_port" envconfig:"log_forwarder_monitoring_port" public:"true"`
+
+	// LogForwarderMonitoringIntervalSec is the interval in seconds between Fluent Bit monitoring scrapes, each of
+	// them emitting a LogForwarderSample.
+	// Default: 30
+	// Public: Yes
+	LogForwarderMonitoringIntervalSec int `yaml:"log_forwarder_monitoring_interval_sec" envconfig:"log_forwarder_monitoring_interval_sec" public:"true"`
+
+	// LogForwarderStuckOutputTimeoutSec is the amount of seconds the Fluent Bit output may stay without delivering
+	// records, while there is input or retry activity, before the agent restarts the log forwarder. Zero disables
+	// the restart. Only applies when LogForwarderMonitoringEnabled is set.
+	// Default: 600
+	// Public: Yes
+	LogForwarderStuckOutputTimeoutSec int `yaml:"log_forwarder_stuck_output_timeout_sec" envconfig:"log_forwarder_stuck_output_timeout_sec" public:"true"`
+
 	// HTTPServerEnabled By setting true this configuration parameter (used only by statsD integration)	the agent will
 	// open an http port (by default, 8001) for receiving data from	New Relic statsD backend.
 	// Default: False
@@ -757,6 +964,46 @@ type Config struct {
 	// Public: Yes
 	HTTPServerPort int `yaml:"http_server_port" envconfig:"http_server_port"`
 
+	// HTTPServerSocket Path of a unix domain socket where the http server listens, instead of the HTTPServerHost and
+	// HTTPServerPort, so only local processes with access to the file can submit data.
+	// Default: Empty
+	// Public: Yes
+	HTTPServerSocket string `yaml:"http_server_socket" envconfig:"http_server_socket"`
+
+	// HTTPServerAuthToken When set, requests to the http server must provide it in a "Authorization: Bearer <token>"
+	// header. Otherwise they are rejected with a 401 status.
+	// Default: Empty
+	// Public: Yes
+	HTTPServerAuthToken string `yaml:"http_server_auth_token" envconfig:"http_server_auth_token"`
+
+	// HTTPServerCertFile Certificate file used by the http server to listen on TLS. It requires HTTPServerKeyFile.
+	// Default: Empty
+	// Public: Yes
+	HTTPServerCertFile string `yaml:"http_server_cert_file" envconfig:"http_server_cert_file"`
+
+	// HTTPServerKeyFile Private key file of the HTTPServerCertFile certificate.
+	// Default: Empty
+	// Public: Yes
+	HTTPServerKeyFile string `yaml:"http_server_key_file" envconfig:"http_server_key_file"`
+
+	// HTTPServerClientCAFile CA bundle file used to verify the client certificates. When set, along with the TLS
+	// certificate, clients must authenticate with a certificate signed by one of its CAs (mutual TLS).
+	// Default: Empty
+	// Public: Yes
+	HTTPServerClientCAFile string `yaml:"http_server_client_ca_file" envconfig:"http_server_client_ca_file"`
+
+	// HTTPServerMaxBodySizeBytes Maximum size of the payloads accepted by the http server. Larger requests are
+	// rejected with a 413 status.
+	// Default: 10485760
+	// Public: Yes
+	HTTPServerMaxBodySizeBytes int `yaml:"http_server_max_body_size_bytes" envconfig:"http_server_max_body_size_bytes"`
+
+	// HTTPServerLabels Labels added to the inventory, events and metrics of the data received by the http server, as
+	// the labels of the integrations configuration.
+	// Default: Empty
+	// Public: Yes
+	HTTPServerLabels map[string]string `yaml:"http_server_labels" envconfig:"http_server_labels"`
+
 	// AppDataDir This option is only for Windows. It defines the path to store data in a different path than the
 	// program files directory.
 	// - %AppDir%/data: used for storing the delta data.
@@ -951,6 +1198,13 @@ type Config struct {
 	// Public: Yes
 	MetricsNFSSampleRate int `yaml:"metrics_nfs_sample_rate" envconfig:"metrics_nfs_sample_rate"`
 
+	// MetricsContainerSampleRate Sample rate of Container Samples in seconds, built from the cgroup of each container
+	// running on the host, whatever its runtime (docker, containerd, podman, CRI-O). Linux only. Minimum value is 5.
+	// The sampler is disabled by default, or if value is -1, as nri-docker already reports ContainerSamples.
+	// Default: -1
+	// Public: Yes
+	MetricsContainerSampleRate int `yaml:"metrics_container_sample_rate" envconfig:"metrics_container_sample_rate"`
+
 	// DetailedNFS when true will provide a complete list of NFS metrics.
 	// Default: False
 	// Public: Yes
@@ -970,15 +1224,53 @@ type Config struct {
 	// Public: Yes
 	EnableProcessMetrics *bool `yaml:"enable_process_metrics" envconfig:"enable_process_metrics"`
 
+	// EnableProcessSocketMetrics Linux only. Adds to each ProcessSample the number of TCP and UDP sockets owned by the
+	// process, by TCP state, and the ports it is listening on. Socket owners are resolved through the file descriptors
+	// of every process, so it has a performance impact on hosts with lots of processes or connections.
+	// Default: False
+	// Public: Yes
+	EnableProcessSocketMetrics bool `yaml:"enable_process_socket_metrics" envconfig:"enable_process_socket_metrics"`
+
 	// IncludeMetricsMatchers Configuration of the metrics matchers that determine which metric data should the agent
 	// send to the New Relic backend.
 	// If no configuration is defined, the previous behaviour is maintained, i.e., every metric data captured is sent.
 	// If a configuration is defined, then only metric data matching the configuration is sent.
 	// Note that ALL DATA NOT MATCHED WILL BE DROPPED.
-	// Also note that at present it ONLY APPLIES to metric data related to processes. All other metric data is still being sent as usual.
+	// The process.name and process.executable keys apply to ProcessSample, taking a literal or a "regex" value.
+	// Any other key is an event type (StorageSample, NetworkSample...) taking rules evaluated against the sample
+	// attributes, such as: mountPoint == "/" or (filesystemType =~ "^ext" and diskUsedPercent > 50).
+	// Event types without matchers are sent as usual. Invalid rules are logged and ignored.
 	// Default: none
 	// Public: Yes
 	IncludeMetricsMatchers IncludeMetricsMap `yaml:"include_matching_metrics" envconfig:"include_matching_metrics"`
+
+	// ExcludeMetricsMatchers Configuration of the metrics matchers that determine which metric data should the agent
+	// drop, following the include_matching_metrics format. For example:
+	//   NetworkSample:
+	//     - interfaceName =~ "^veth"
+	//   ProcessSample:
+	//     - cpuPercent < 1 and memoryResidentSizeBytes < 100MB
+	// Exclusions take precedence over include_matching_metrics.
+	// Default: none
+	// Public: Yes
+	ExcludeMetricsMatchers IncludeMetricsMap `yaml:"exclude_matching_metrics" envconfig:"exclude_matching_metrics"`
+
+	// MetricsTransformations Attribute transformations applied, per event type, to the samples sent to the New
+	// Relic backend, after include_matching_metrics and exclude_matching_metrics are evaluated. For example:
+	//   ProcessSample:
+	//     - action: redact
+	//       attribute: commandLine
+	//       regex: --password=\S+
+	//       replacement: --password=****
+	//     - action: rename
+	//       attribute: processDisplayName
+	//       to: processName
+	//     - action: add
+	//       attribute: team
+	//       value: core
+	// Default: none
+	// Public: Yes
+	MetricsTransformations MetricsTransformMap `yaml:"transform_metrics" envconfig:"transform_metrics"`
 }
 
 // Troubleshoot trobleshoot mode configuration.
@@ -1008,6 +1300,7 @@ type LogForward struct {
 	License      string
 	IsStaging    bool
 	ProxyCfg     LogForwardProxy
+	Monitoring   LogForwardMonitoring
 }
 
 type LogForwardProxy struct {
@@ -1018,6 +1311,14 @@ type LogForwardProxy struct {
 	ValidateCerts     bool
 }
 
+// LogForwardMonitoring log forwarder self-monitoring configuration.
+type LogForwardMonitoring struct {
+	Enabled            bool
+	Port               int
+	Interval           time.Duration
+	StuckOutputTimeout time.Duration
+}
+
 // NewLogForward creates a valid log forwarder config.
 func NewLogForward(config *Config, troubleshoot Troubleshoot) LogForward {
 	return LogForward{
@@ -1033,6 +1334,12 @@ func NewLogForward(config *Config, troubleshoot Troubleshoot) LogForward {
 			CABundleDir:       config.CABundleDir,
 			ValidateCerts:     config.ProxyValidateCerts,
 		},
+		Monitoring: LogForwardMonitoring{
+			Enabled:            config.LogForwarderMonitoringEnabled,
+			Port:               config.LogForwarderMonitoringPort,
+			Interval:           time.Duration(config.LogForwarderMonitoringIntervalSec) * time.Second,
+			StuckOutputTimeout: time.Duration(config.LogForwarderStuckOutputTimeoutSec) * time.Second,
+		},
 	}
 }
 
@@ -1230,6 +1537,7 @@ func NewConfig() *Config {
 		LogFormat:                     defaultLogFormat,
 		HTTPServerHost:                defaultHTTPServerHost,
 		HTTPServerPort:                defaultHTTPServerPort,
+		HTTPServerMaxBodySizeBytes:    defaultHTTPServerMaxBodySizeBytes,
 		DockerApiVersion:              DefaultDockerApiVersion,
 		FingerprintUpdateFreqSec:      defaultFingerprintUpdateFreqSec,
 		CloudMetadataExpiryInSec:      defaultCloudMetadataExpiryInSec,
@@ -1238,6 +1546,10 @@ func NewConfig() *Config {
 		RegisterFrequencySecs:         defaultRegisterFrequencySecs,
 		HeartBeatSampleRate:           DefaultHeartBeatFrequencySecs,
 		DMSubmissionPeriod:            DefaultDMPeriodSecs,
+		DMCardinalityPolicy:           defaultDMCardinalityPolicy,
+		MaxIntegrationPayloadSize:     defaultMaxIntegrationPayloadSize,
+		IntegrationsCaptureMaxSize:    defaultIntegrationsCaptureMaxSize,
+		IntegrationsCaptureMaxFiles:   defaultIntegrationsCaptureMaxFiles,
 		ProxyConfigPlugin:             defaultProxyConfigPlugin,
 		ProxyValidateCerts:            defaultProxyValidateCerts,
 		CloudRetryBackOffSec:          defaultCloudRetryBackOffSec,
@@ -1247,30 +1559,41 @@ func NewConfig() *Config {
 		DnsHostnameResolution:         defaultDnsHostnameResolution,
 		MaxProcs:                      defaultMaxProcs,
 		// At the moment, this is an option that would allow us to rollback to the previous behaviour in case of errors
-		DisableInventorySplit:       defaultDisableInventorySplit,
-		MaxInventorySize:            defaultMaxInventorySize,
-		MaxMetricsBatchSizeBytes:    DefaultMaxMetricsBatchSizeBytes,
-		StartupConnectionRetries:    defaultStartupConnectionRetries,
-		DisableZeroRSSFilter:        defaultDisableZeroRSSFilter,
-		DisableWinSharedWMI:         defaultDisableWinSharedWMI,
-		CompactEnabled:              defaultCompactEnabled,
-		StripCommandLine:            DefaultStripCommandLine,
-		NetworkInterfaceFilters:     defaultNetworkInterfaceFilters,
-		SelinuxEnableSemodule:       defaultSelinuxEnableSemodule,
-		OfflineTimeToReset:          DefaultOfflineTimeToReset,
-		FilesConfigOn:               defaultFilesConfigOn,
-		PayloadCompressionLevel:     defaultPayloadCompressionLevel,
-		EnableWinUpdatePlugin:       defaultWinUpdatePlugin,
-		LogToStdout:                 defaultLogToStdout,
-		IpData:                      defaultIpData,
-		ContainerMetadataCacheLimit: DefaultContainerCacheMetadataLimit,
-		PartitionsTTL:               defaultPartitionsTTL,
-		StartupConnectionTimeout:    defaultStartupConnectionTimeout,
-		MetricsNFSSampleRate:        DefaultMetricsNFSSampleRate,
-		SmartVerboseModeEntryLimit:  DefaultSmartVerboseModeEntryLimit,
-		DefaultIntegrationsTempDir:  defaultIntegrationsTempDir,
-		IncludeMetricsMatchers:      defaultMetricsMatcherConfig,
-		InventoryQueueLen:			 DefaultInventoryQueue,
+		DisableInventorySplit:             defaultDisableInventorySplit,
+		MaxInventorySize:                  defaultMaxInventorySize,
+		MaxMetricsBatchSizeBytes:          DefaultMaxMetricsBatchSizeBytes,
+		StartupConnectionRetries:          defaultStartupConnectionRetries,
+		DisableZeroRSSFilter:              defaultDisableZeroRSSFilter,
+		DisableWinSharedWMI:               defaultDisableWinSharedWMI,
+		CompactEnabled:                    defaultCompactEnabled,
+		StripCommandLine:                  DefaultStripCommandLine,
+		NetworkInterfaceFilters:           defaultNetworkInterfaceFilters,
+		SelinuxEnableSemodule:             defaultSelinuxEnableSemodule,
+		OfflineTimeToReset:                DefaultOfflineTimeToReset,
+		FilesConfigOn:                     defaultFilesConfigOn,
+		PayloadCompressionLevel:           defaultPayloadCompressionLevel,
+		EnableWinUpdatePlugin:             defaultWinUpdatePlugin,
+		LogToStdout:                       defaultLogToStdout,
+		IpData:                            defaultIpData,
+		ContainerMetadataCacheLimit:       DefaultContainerCacheMetadataLimit,
+		PartitionsTTL:                     defaultPartitionsTTL,
+		StartupConnectionTimeout:          defaultStartupConnectionTimeout,
+		MetricsNFSSampleRate:              DefaultMetricsNFSSampleRate,
+		SmartVerboseModeEntryLimit:        DefaultSmartVerboseModeEntryLimit,
+		DefaultIntegrationsTempDir:        defaultIntegrationsTempDir,
+		IncludeMetricsMatchers:            defaultMetricsMatcherConfig,
+		InventoryQueueLen:                 DefaultInventoryQueue,
+		MetricsContainerSampleRate:        DefaultMetricsContainerSampleRate,
+		MetricsNetworkProtocolSampleRate:  DefaultMetricsNetworkProtocolSampleRate,
+		MetricsSensorSampleRate:           DefaultMetricsSensorSampleRate,
+		MetricsCPUCoreSampleRate:          DefaultMetricsCPUCoreSampleRate,
+		MetricsSystemdSampleRate:          DefaultMetricsSystemdSampleRate,
+		MetricsFileSampleRate:             DefaultMetricsFileSampleRate,
+		MetricsFileScanTimeoutSec:         DefaultMetricsFileScanTimeoutSec,
+		LogForwarderMonitoringEnabled:     DefaultLogForwarderMonitoringEnabled,
+		LogForwarderMonitoringPort:        DefaultLogForwarderMonitoringPort,
+		LogForwarderMonitoringIntervalSec: DefaultLogForwarderMonitoringIntervalSec,
+		LogForwarderStuckOutputTimeoutSec: DefaultLogForwarderStuckOutputTimeoutSec,
 	}
 }
 
@@ -1570,6 +1893,11 @@ func NormalizeConfig(cfg *Config, cfgMetadata config_loader.YAMLMetadata) (err e
 	}
 	nlog.WithField("MetricsSystemSampleRate", cfg.MetricsSystemSampleRate).Debug("Metrics System Sample Rate.")
 
+	if cfg.MetricsCPUCoreSampleRate < FREQ_INTERVAL_FLOOR_SYSTEM_METRICS && cfg.MetricsCPUCoreSampleRate > FREQ_DISABLE_SAMPLING {
+		cfg.MetricsCPUCoreSampleRate = FREQ_INTERVAL_FLOOR_SYSTEM_METRICS
+	}
+	nlog.WithField("MetricsCPUCoreSampleRate", cfg.MetricsCPUCoreSampleRate).Debug("Metrics CPU Core Sample Rate.")
+
 	if cfg.MetricsStorageSampleRate < FREQ_INTERVAL_FLOOR_STORAGE_METRICS && cfg.MetricsStorageSampleRate > FREQ_DISABLE_SAMPLING {
 		cfg.MetricsStorageSampleRate = DefaultStorageSamplerRateSecs
 	}
@@ -1585,6 +1913,39 @@ func NormalizeConfig(cfg *Config, cfgMetadata config_loader.YAMLMetadata) (err e
 	}
 	nlog.WithField("MetricsNetworkSampleRate", cfg.MetricsProcessSampleRate).Debug("Metrics Process Sample Rate.")
 
+	if cfg.MetricsNetworkProtocolSampleRate < FREQ_INTERVAL_FLOOR_NETWORK_METRICS && cfg.MetricsNetworkProtocolSampleRate > FREQ_DISABLE_SAMPLING {
+		cfg.MetricsNetworkProtocolSampleRate = FREQ_INTERVAL_FLOOR_NETWORK_METRICS
+	}
+	nlog.WithField("MetricsNetworkProtocolSampleRate", cfg.MetricsNetworkProtocolSampleRate).Debug("Metrics Network Protocol Sample Rate.")
+
+	if cfg.MetricsSensorSampleRate < FREQ_INTERVAL_FLOOR_METRICS && cfg.MetricsSensorSampleRate > FREQ_DISABLE_SAMPLING {
+		cfg.MetricsSensorSampleRate = FREQ_INTERVAL_FLOOR_METRICS
+	}
+	nlog.WithField("MetricsSensorSampleRate", cfg.MetricsSensorSampleRate).Debug("Metrics Sensor Sample Rate.")
+
+	if cfg.MetricsSystemdSampleRate < FREQ_INTERVAL_FLOOR_METRICS && cfg.MetricsSystemdSampleRate > FREQ_DISABLE_SAMPLING {
+		cfg.MetricsSystemdSampleRate = FREQ_INTERVAL_FLOOR_METRICS
+	}
+	nlog.WithField("MetricsSystemdSampleRate", cfg.MetricsSystemdSampleRate).Debug("Metrics Systemd Sample Rate.")
+
+	if cfg.MetricsFileSampleRate < FREQ_INTERVAL_FLOOR_METRICS && cfg.MetricsFileSampleRate > FREQ_DISABLE_SAMPLING {
+		cfg.MetricsFileSampleRate = FREQ_INTERVAL_FLOOR_METRICS
+	}
+	nlog.WithField("MetricsFileSampleRate", cfg.MetricsFileSampleRate).Debug("Metrics File Sample Rate.")
+
+	if cfg.MetricsFileScanTimeoutSec <= 0 {
+		cfg.MetricsFileScanTimeoutSec = DefaultMetricsFileScanTimeoutSec
+	}
+
+	if cfg.HTTPServerMaxBodySizeBytes <= 0 {
+		cfg.HTTPServerMaxBodySizeBytes = defaultHTTPServerMaxBodySizeBytes
+	}
+
+	if cfg.MetricsContainerSampleRate < FREQ_INTERVAL_FLOOR_METRICS && cfg.MetricsContainerSampleRate > FREQ_DISABLE_SAMPLING {
+		cfg.MetricsContainerSampleRate = FREQ_INTERVAL_FLOOR_METRICS
+	}
+	nlog.WithField("MetricsContainerSampleRate", cfg.MetricsContainerSampleRate).Debug("Metrics Container Sample Rate.")
+
 	nlog.WithField("FilesConfigOn", cfg.FilesConfigOn).Debug("Configuration file monitoring.")
 
 	if cfg.NetworkInterfaceFilters == nil || len(cfg.NetworkInterfaceFilters) == 0 {
@@ -1704,6 +2065,25 @@ func (c *CustomAttributeMap) Decode(value string) error {
 	return nil
 }
 
+func (m *MetricsTransformMap) Decode(value string) error {
+	data := []byte(value)
+
+	// Clear current Map
+	for k := range *m {
+		delete(*m, k)
+	}
+
+	if err := yaml.Unmarshal(data, m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (f *FileMonitors) Decode(value string) error {
+	*f = nil
+	return yaml.Unmarshal([]byte(value), f)
+}
+
 func (i *IncludeMetricsMap) Decode(value string) error {
 	data := []byte(value)
 
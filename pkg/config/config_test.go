@@ -526,3 +526,30 @@ func Test_ParseIncludeMatchingRule_EnvVar(t *testing.T) {
 	expected := IncludeMetricsMap{"process.name": []string{"regex \"kube*\""}}
 	assert.True(t, reflect.DeepEqual(cfg.IncludeMetricsMatchers, expected))
 }
+
+func Test_ParseMetricsTransformations(t *testing.T) {
+	configStr := `
+license_key: abc123
+transform_metrics:
+  ProcessSample:
+    - action: redact
+      attribute: commandLine
+      regex: --password=\S+
+    - action: add
+      attribute: team
+      value: core
+`
+	f, err := ioutil.TempFile("", "yaml_config_test")
+	assert.NoError(t, err)
+	f.WriteString(configStr)
+	f.Close()
+	defer os.Remove(f.Name())
+
+	cfg, err := LoadConfig(f.Name())
+	assert.NoError(t, err)
+	expected := MetricsTransformMap{"ProcessSample": []MetricsTransform{
+		{Action: "redact", Attribute: "commandLine", Regex: `--password=\S+`},
+		{Action: "add", Attribute: "team", Value: "core"},
+	}}
+	assert.Equal(t, expected, cfg.MetricsTransformations)
+}
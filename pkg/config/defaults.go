@@ -32,18 +32,29 @@ const (
 // Default configurable values
 var (
 	// public
-	DefaultContainerCacheMetadataLimit = 60
-	DefaultDockerApiVersion            = "1.24" // minimum supported API by Docker 18.09.0
-	DefaultHeartBeatFrequencySecs      = 60
-	DefaultDMPeriodSecs                = 5           // default telemetry SDK value
-	DefaultMaxMetricsBatchSizeBytes    = 1000 * 1000 // Size limit from Vortex collector service (1MB)
-	DefaultMetricsNFSSampleRate        = 20
-	DefaultOfflineTimeToReset          = "24h"
-	DefaultStorageSamplerRateSecs      = 20
-	DefaultStripCommandLine            = true
-	DefaultSmartVerboseModeEntryLimit  = 1000
-	DefaultIntegrationsDir             = "newrelic-integrations"
-	DefaultInventoryQueue              = 0
+	DefaultContainerCacheMetadataLimit       = 60
+	DefaultDockerApiVersion                  = "1.24" // minimum supported API by Docker 18.09.0
+	DefaultHeartBeatFrequencySecs            = 60
+	DefaultDMPeriodSecs                      = 5           // default telemetry SDK value
+	DefaultMaxMetricsBatchSizeBytes          = 1000 * 1000 // Size limit from Vortex collector service (1MB)
+	DefaultMetricsNFSSampleRate              = 20
+	DefaultMetricsContainerSampleRate        = FREQ_DISABLE_SAMPLING
+	DefaultMetricsNetworkProtocolSampleRate  = FREQ_DISABLE_SAMPLING
+	DefaultMetricsSensorSampleRate           = FREQ_DISABLE_SAMPLING
+	DefaultMetricsCPUCoreSampleRate          = FREQ_DISABLE_SAMPLING
+	DefaultMetricsSystemdSampleRate          = 15
+	DefaultMetricsFileSampleRate             = 60
+	DefaultMetricsFileScanTimeoutSec         = 10
+	DefaultOfflineTimeToReset                = "24h"
+	DefaultStorageSamplerRateSecs            = 20
+	DefaultStripCommandLine                  = true
+	DefaultSmartVerboseModeEntryLimit        = 1000
+	DefaultIntegrationsDir                   = "newrelic-integrations"
+	DefaultInventoryQueue                    = 0
+	DefaultLogForwarderMonitoringEnabled     = true
+	DefaultLogForwarderMonitoringPort        = 18020
+	DefaultLogForwarderMonitoringIntervalSec = 30
+	DefaultLogForwarderStuckOutputTimeoutSec = 600
 
 	// private
 	defaultAppDataDir                    = ""
@@ -61,12 +72,17 @@ var (
 	defaultMaxProcs                      = 1
 	defaultHTTPServerHost                = "localhost"
 	defaultHTTPServerPort                = 8001
+	defaultHTTPServerMaxBodySizeBytes    = 10 * 1024 * 1024
 	defaultIpData                        = true
 	defaultTruncTextValues               = true
 	defaultLogToStdout                   = true
 	defaultLogFormat                     = LogFormatText
 	defaultMaxInventorySize              = 1000 * 1000 // Size limit from Vortex collector service (1MB)
-	defaultPayloadCompressionLevel       = 6           // default compression level used in go, higher than this does not show tangible benefits
+	defaultMaxIntegrationPayloadSize     = 100 * 1024 * 1024
+	defaultDMCardinalityPolicy           = "drop"
+	defaultIntegrationsCaptureMaxSize    = 10 * 1024 * 1024
+	defaultIntegrationsCaptureMaxFiles   = 5
+	defaultPayloadCompressionLevel       = 6 // default compression level used in go, higher than this does not show tangible benefits
 	defaultPidFile                       = "/var/run/newrelic-infra/newrelic-infra.pid"
 	defaultPluginActiveConfigsDir        = "integrations.d"
 	defaultSelinuxEnableSemodule         = true
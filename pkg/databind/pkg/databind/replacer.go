@@ -190,6 +190,9 @@ func replaceFields(values []data.Map, val reflect.Value, rc replaceConfig, match
 		}
 		return vals.Addr(), nil
 	case reflect.Interface:
+		if val.IsNil() {
+			return val, nil
+		}
 		vals, err := replaceFields(values, reflect.ValueOf(val.Interface()), rc, matches)
 		if err != nil {
 			return reflect.Value{}, err
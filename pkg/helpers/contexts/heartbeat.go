@@ -39,8 +39,10 @@ func WithHeartBeat(parent context.Context, lifeTime time.Duration) (context.Cont
 func (ctx *heartBeatCtx) heartBeat() {
 	ctx.mutex.Lock()
 	defer ctx.mutex.Unlock()
+	// the timer has already cancelled the context, which can't be extended anymore. AfterFunc timers have no
+	// channel to drain.
 	if !ctx.timer.Stop() {
-		<-ctx.timer.C
+		return
 	}
 	ctx.timer.Reset(ctx.lifeTime)
 }
@@ -130,3 +130,26 @@ func TestContextHolder_RaceConditions(t *testing.T) {
 		require.Fail(t, "error waiting for context to be done")
 	}
 }
+
+func TestContextHolder_HeartbeatAfterTimeout(t *testing.T) {
+	const lifeTime = 10 * time.Millisecond
+
+	// GIVEN a Context whose lifeTime has expired
+	ctx, actuator := WithHeartBeat(context.Background(), lifeTime)
+	<-ctx.Done()
+
+	// WHEN a late heartbeat is received
+	heartBeated := make(chan struct{})
+	go func() {
+		actuator.HeartBeat()
+		close(heartBeated)
+	}()
+
+	// THEN the heartbeat doesn't block
+	select {
+	case <-heartBeated:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "heartbeat blocked after the context was finished")
+	}
+	assert.Equal(t, context.Canceled, ctx.Err())
+}
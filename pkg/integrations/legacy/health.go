@@ -0,0 +1,55 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package legacy
+
+import (
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/executor"
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
+	"github.com/pkg/errors"
+)
+
+// healthEventCategory is the category of the events reporting integration health errors. It matches the one of
+// the v4 integrations events.
+const healthEventCategory = "integrationHealth"
+
+// emitVerificationError reports an integration whose executable hasn't passed the verification, with the same
+// event than the v4 integrations.
+func (ep *externalPlugin) emitVerificationError(executable string, err error) {
+	ep.logger.WithError(err).Error("integration executable verification failed, not running it")
+
+	cause := err
+	var verr *executor.VerificationError
+	if errors.As(err, &verr) {
+		executable = verr.Path
+		cause = verr.Err
+	}
+	ep.emitHealthError("integration executable verification failed", map[string]interface{}{
+		"executable": executable,
+		"error":      cause.Error(),
+	})
+}
+
+// emitHealthError emits an integration health error event attached to the agent entity.
+func (ep *externalPlugin) emitHealthError(summary string, attributes map[string]interface{}) {
+	attributes["integrationName"] = ep.integrationName()
+	attributes["status"] = "error"
+
+	agentKey := ep.Context.AgentIdentifier()
+	event := NormalizeEvent(ep.logger, protocol.EventData{
+		"summary":    summary,
+		"category":   healthEventCategory,
+		"attributes": attributes,
+	}, ep.pluginInstance.Labels, ep.pluginInstance.IntegrationUser, agentKey)
+	if event != nil {
+		ep.EmitEvent(event, entity.Key(agentKey))
+	}
+}
+
+// integrationName returns the name of the integration instance, or the plugin name for unnamed instances.
+func (ep *externalPlugin) integrationName() string {
+	if ep.pluginInstance.Name != "" {
+		return ep.pluginInstance.Name
+	}
+	return ep.pluginInstance.plugin.Name
+}
@@ -28,6 +28,7 @@ import (
 
 	"github.com/golang/groupcache/lru"
 	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/executor"
 	"github.com/newrelic/infrastructure-agent/pkg/backend/http"
 	"github.com/newrelic/infrastructure-agent/pkg/config"
 	"github.com/newrelic/infrastructure-agent/pkg/entity"
@@ -59,6 +60,7 @@ type PluginRunner struct {
 	registry  *PluginRegistry
 	closeWait *sync.WaitGroup
 	agent     iAgent
+	verifier  executor.Verifier
 }
 
 type iAgent interface {
@@ -74,6 +76,12 @@ func NewPluginRunner(registry *PluginRegistry, agent iAgent) *PluginRunner {
 	}
 }
 
+// SetVerifier sets the verifier checking the integration executables before each execution, the same way as the v4
+// integrations ones. Nil disables the verification.
+func (pr *PluginRunner) SetVerifier(verifier executor.Verifier) {
+	pr.verifier = verifier
+}
+
 func newExternalV1Plugin(
 	runner *PluginRunner,
 	instance *PluginV1Instance) (*externalPlugin, error) {
@@ -507,6 +515,11 @@ func (ep *externalPlugin) runCmd(cmd *cmdWrapper, _ time.Duration, _ bool, _ tim
 			"env":  helpers.ObfuscateSensitiveDataFromArray(cmd.cmd.Env),
 		}
 	}).Debug("Running command.")
+	if err := ep.verify(cmd.executable); err != nil {
+		ep.emitVerificationError(cmd.executable, err)
+		ep.logIfHealthCheck("Integration health check finished with some errors")
+		return
+	}
 	cmdOut, err := cmd.cmd.StdoutPipe()
 	if err != nil {
 		ep.logger.WithError(err).Error("getting output pipe")
@@ -555,6 +568,25 @@ func (ep *externalPlugin) runCmd(cmd *cmdWrapper, _ time.Duration, _ bool, _ tim
 	}
 }
 
+// verify runs the verifier of the runner, if any, on the executable. Executables run as another user must be
+// absolute paths, as sudo looks for them in its own secure path instead of the agent one.
+func (ep *externalPlugin) verify(executable string) error {
+	if ep.pluginRunner.verifier == nil {
+		return nil
+	}
+	if !filepath.IsAbs(executable) {
+		if ep.pluginInstance.IntegrationUser != "" {
+			return &executor.VerificationError{Path: executable, Err: executor.ErrRelativeCommand}
+		}
+		path, err := exec.LookPath(executable)
+		if err != nil {
+			return &executor.VerificationError{Path: executable, Err: err}
+		}
+		executable = path
+	}
+	return ep.pluginRunner.verifier.Verify(executable)
+}
+
 // handleOutput reads through the lines of output and processes them. It
 // returns true if all the lines are processed correctly, false if it cannot
 // process any of the lines. In case a line fails, it logs the error and
@@ -851,6 +883,7 @@ type cfgTmp struct {
 //  cmdWrapper wraps exec.Cmd with extra labels (metric annotations) from discovery/databinding
 type cmdWrapper struct {
 	cmd               *exec.Cmd
+	executable        string
 	entityRewrite     []data.EntityRewrite
 	metricAnnotations data.Map
 }
@@ -922,6 +955,7 @@ func (ep *externalPlugin) updateCmdWrappers(pluginDir string) {
 		}
 		ep.cmdWrappers = append(ep.cmdWrappers, &cmdWrapper{
 			cmd:               cmd,
+			executable:        executable,
 			metricAnnotations: icfg.MetricAnnotations,
 			entityRewrite:     icfg.EntityRewrites,
 		})
@@ -32,6 +32,7 @@ import (
 
 	"github.com/newrelic/infrastructure-agent/internal/agent"
 	"github.com/newrelic/infrastructure-agent/internal/agent/mocks"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/executor"
 	"github.com/newrelic/infrastructure-agent/pkg/config"
 	"github.com/newrelic/infrastructure-agent/pkg/entity"
 	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
@@ -872,6 +873,90 @@ func TestEventsPluginRunV1OverloadingStderrBuffer(t *testing.T) {
 
 }
 
+type fakeVerifier struct {
+	verified []string
+	err      error
+}
+
+func (v *fakeVerifier) Verify(path string) error {
+	v.verified = append(v.verified, path)
+	return v.err
+}
+
+func TestExternalPlugin_Verify(t *testing.T) {
+	rejected := errors.New("rejected")
+	tests := []struct {
+		name       string
+		executable string
+		user       string
+		verifier   *fakeVerifier
+		expected   error
+	}{
+		{"no verifier", "/usr/bin/nri-test", "", nil, nil},
+		{"verified", "/usr/bin/nri-test", "", &fakeVerifier{}, nil},
+		{"rejected", "/usr/bin/nri-test", "", &fakeVerifier{err: rejected}, rejected},
+		{"relative with user", "nri-test", "nri-agent", &fakeVerifier{}, executor.ErrRelativeCommand},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, plugin := newFakePluginWithContext(1)
+			plugin.pluginInstance.IntegrationUser = tt.user
+			if tt.verifier != nil {
+				plugin.pluginRunner.SetVerifier(tt.verifier)
+			}
+
+			err := plugin.verify(tt.executable)
+
+			if tt.expected == nil {
+				assert.NoError(t, err)
+				return
+			}
+			var verr *executor.VerificationError
+			if errors.As(err, &verr) {
+				err = verr.Err
+			}
+			assert.Equal(t, tt.expected, err)
+			if tt.expected == rejected {
+				assert.Equal(t, []string{tt.executable}, tt.verifier.verified)
+			}
+		})
+	}
+}
+
+func TestExternalPlugin_RunCmd_VerificationError(t *testing.T) {
+	// GIVEN an integration whose executable doesn't pass the verification
+	ctx := newContext()
+	ctx.ev = make(chan sample.Event, 1)
+	plugin := newFakePlugin(ctx, 1)
+	plugin.logger = plugin.newLogger()
+	plugin.pluginInstance.Name = "nri-test-instance"
+	plugin.pluginRunner.SetVerifier(&fakeVerifier{
+		err: &executor.VerificationError{Path: "/usr/bin/nri-test", Err: executor.ErrWritableByOthers},
+	})
+
+	// WHEN the integration is run
+	plugin.runCmd(&cmdWrapper{cmd: exec.Command("/usr/bin/nri-test"), executable: "/usr/bin/nri-test"}, 0, false, 0)
+
+	// THEN an integration health event is emitted, as for v4 integrations
+	var event map[string]interface{}
+	select {
+	case ev := <-ctx.ev:
+		b, err := json.Marshal(ev)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(b, &event))
+	default:
+		require.Fail(t, "no integration health event emitted")
+	}
+	assert.Equal(t, "integration executable verification failed", event["summary"])
+	assert.Equal(t, "integrationHealth", event["category"])
+	assert.Equal(t, "InfrastructureEvent", event["eventType"])
+	assert.Equal(t, "nri-test-instance", event["integrationName"])
+	assert.Equal(t, "error", event["status"])
+	assert.Equal(t, "/usr/bin/nri-test", event["executable"])
+	assert.Equal(t, executor.ErrWritableByOthers.Error(), event["error"])
+	assert.Equal(t, "fileserver", event["label.role"])
+}
+
 func (rs *RunnerSuite) TestEventsPluginRunCrash(c *C) {
 	_, plugin := newFakePluginWithContext(1)
 
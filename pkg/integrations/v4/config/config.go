@@ -21,6 +21,12 @@ type ConfigEntry struct {
 	Labels   map[string]string `yaml:"labels"`
 	When     EnableConditions  `yaml:"when"`
 
+	// HeartbeatTimeout restarts the integration process if it doesn't emit any payload or heartbeat in time
+	HeartbeatTimeout *time.Duration `yaml:"heartbeat_timeout"`
+
+	// Transform modifies the integration output before it's emitted
+	Transform *Transform `yaml:"transform"`
+
 	// Legacy definition commands
 	Command         string            `yaml:"command"`
 	Arguments       map[string]string `yaml:"arguments"`
@@ -0,0 +1,38 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package config
+
+// Transform modifies the output of an integration before it's emitted. The rules refer to the metric and attribute
+// names as they are emitted by the integration, and they are applied in the following order: drop, convert,
+// rename and entity attributes.
+type Transform struct {
+	// Drop discards the metrics and events matching any of the rules
+	Drop []DropRule `yaml:"drop"`
+	// Convert changes the units of the metrics or attributes matching the rules
+	Convert []ConvertRule `yaml:"convert"`
+	// RenameMetrics maps the metric names to their new names
+	RenameMetrics map[string]string `yaml:"rename_metrics"`
+	// RenameAttributes maps the attribute names to their new names
+	RenameAttributes map[string]string `yaml:"rename_attributes"`
+	// EntityAttributes maps the entity fields ("name", "type", "displayName" or "metadata.<key>") to the names of
+	// the attributes they are copied into, for all the metrics and events of the entity
+	EntityAttributes map[string]string `yaml:"entity_attributes"`
+}
+
+// DropRule matches metrics by name (the event_type of the v3 metric samples), events by category, or both by
+// attributes. All the provided fields must match. Names and values accept glob patterns.
+type DropRule struct {
+	Metric     string            `yaml:"metric"`
+	Event      string            `yaml:"event"`
+	Attributes map[string]string `yaml:"attributes"`
+}
+
+// ConvertRule multiplies the numeric values of the metrics or attributes whose name matches the glob patterns.
+// The factor is either provided or calculated from the From and To units (e.g. bytes to megabytes).
+type ConvertRule struct {
+	Metric    string  `yaml:"metric"`
+	Attribute string  `yaml:"attribute"`
+	From      string  `yaml:"from"`
+	To        string  `yaml:"to"`
+	Factor    float64 `yaml:"factor"`
+}
@@ -0,0 +1,176 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package dm
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/newrelic-forks/newrelic-telemetry-sdk-go/telemetry"
+)
+
+// lastGaugeSuffix is appended to the name of the aggregated gauges, for the gauge holding their last value.
+const lastGaugeSuffix = ".last"
+
+// AggregationConfig local pre-aggregation of the dimensional metrics, before they are submitted.
+type AggregationConfig struct {
+	// Window aggregation period. Zero disables the aggregation.
+	Window time.Duration
+	// DropAttributes attributes removed from the metrics before aggregating them, so the metrics only differing
+	// on them are aggregated together.
+	DropAttributes []string
+}
+
+// aggregator is a metricHarvester that aggregates the metrics with the same name and attributes within a window,
+// forwarding the aggregated metrics to the next harvester when the window is flushed:
+// - gauges become a summary, providing the min, max and average, plus a gauge with the last value (name + ".last").
+// - counts are summed.
+// - summaries are merged.
+type aggregator struct {
+	next           metricHarvester
+	dropAttributes map[string]struct{}
+
+	lock        sync.Mutex
+	windowStart time.Time
+	gauges      map[string]*gaugeAggregate
+	counts      map[string]*telemetry.Count
+	summaries   map[string]*telemetry.Summary
+}
+
+type gaugeAggregate struct {
+	summary telemetry.Summary
+	last    telemetry.Gauge
+}
+
+func newAggregator(next metricHarvester, config AggregationConfig) *aggregator {
+	a := &aggregator{
+		next:           next,
+		dropAttributes: make(map[string]struct{}, len(config.DropAttributes)),
+	}
+	for _, attr := range config.DropAttributes {
+		a.dropAttributes[attr] = struct{}{}
+	}
+	a.reset(time.Now())
+	return a
+}
+
+// run flushes the aggregated metrics at every window. It never returns.
+func (a *aggregator) run(window time.Duration) {
+	ticker := time.NewTicker(window)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		a.flush(now)
+	}
+}
+
+// RecordMetric aggregates the metric into the current window.
+func (a *aggregator) RecordMetric(m telemetry.Metric) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	switch metric := m.(type) {
+	case telemetry.Gauge:
+		metric.Attributes = a.withoutDropped(metric.Attributes)
+		key := metric.Name + "\x00" + seriesKey(metric.Attributes)
+		agg, ok := a.gauges[key]
+		if !ok {
+			agg = &gaugeAggregate{
+				summary: telemetry.Summary{
+					Name:       metric.Name,
+					Attributes: metric.Attributes,
+					Min:        math.Inf(1),
+					Max:        math.Inf(-1),
+				},
+				last: metric,
+			}
+			a.gauges[key] = agg
+		}
+		agg.summary.Count++
+		agg.summary.Sum += metric.Value
+		agg.summary.Min = math.Min(agg.summary.Min, metric.Value)
+		agg.summary.Max = math.Max(agg.summary.Max, metric.Value)
+		if !metric.Timestamp.Before(agg.last.Timestamp) {
+			agg.last = metric
+		}
+	case telemetry.Count:
+		metric.Attributes = a.withoutDropped(metric.Attributes)
+		key := metric.Name + "\x00" + seriesKey(metric.Attributes)
+		agg, ok := a.counts[key]
+		if !ok {
+			a.counts[key] = &metric
+			return
+		}
+		agg.Value += metric.Value
+		agg.Timestamp, agg.Interval = mergeIntervals(agg.Timestamp, agg.Interval, metric.Timestamp, metric.Interval)
+	case telemetry.Summary:
+		metric.Attributes = a.withoutDropped(metric.Attributes)
+		key := metric.Name + "\x00" + seriesKey(metric.Attributes)
+		agg, ok := a.summaries[key]
+		if !ok {
+			a.summaries[key] = &metric
+			return
+		}
+		agg.Count += metric.Count
+		agg.Sum += metric.Sum
+		agg.Min = math.Min(agg.Min, metric.Min)
+		agg.Max = math.Max(agg.Max, metric.Max)
+		agg.Timestamp, agg.Interval = mergeIntervals(agg.Timestamp, agg.Interval, metric.Timestamp, metric.Interval)
+	default:
+		a.next.RecordMetric(m)
+	}
+}
+
+// flush forwards the metrics aggregated in the current window to the next harvester, and starts a new window.
+func (a *aggregator) flush(now time.Time) {
+	a.lock.Lock()
+	windowStart, gauges, counts, summaries := a.windowStart, a.gauges, a.counts, a.summaries
+	a.reset(now)
+	a.lock.Unlock()
+
+	for _, g := range gauges {
+		g.summary.Timestamp = windowStart
+		g.summary.Interval = now.Sub(windowStart)
+		a.next.RecordMetric(g.summary)
+		g.last.Name += lastGaugeSuffix
+		a.next.RecordMetric(g.last)
+	}
+	for _, c := range counts {
+		a.next.RecordMetric(*c)
+	}
+	for _, s := range summaries {
+		a.next.RecordMetric(*s)
+	}
+}
+
+func (a *aggregator) reset(now time.Time) {
+	a.windowStart = now
+	a.gauges = map[string]*gaugeAggregate{}
+	a.counts = map[string]*telemetry.Count{}
+	a.summaries = map[string]*telemetry.Summary{}
+}
+
+func (a *aggregator) withoutDropped(attributes map[string]interface{}) map[string]interface{} {
+	if len(a.dropAttributes) == 0 {
+		return attributes
+	}
+	kept := make(map[string]interface{}, len(attributes))
+	for k, v := range attributes {
+		if _, drop := a.dropAttributes[k]; !drop {
+			kept[k] = v
+		}
+	}
+	return kept
+}
+
+// mergeIntervals returns the smallest interval containing both intervals.
+func mergeIntervals(ts1 time.Time, i1 time.Duration, ts2 time.Time, i2 time.Duration) (time.Time, time.Duration) {
+	start, end := ts1, ts1.Add(i1)
+	if ts2.Before(start) {
+		start = ts2
+	}
+	if ts2.Add(i2).After(end) {
+		end = ts2.Add(i2)
+	}
+	return start, end.Sub(start)
+}
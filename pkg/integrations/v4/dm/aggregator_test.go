@@ -0,0 +1,131 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package dm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newrelic-forks/newrelic-telemetry-sdk-go/telemetry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func metricsByName(metrics []telemetry.Metric) map[string]telemetry.Metric {
+	byName := map[string]telemetry.Metric{}
+	for _, m := range metrics {
+		switch metric := m.(type) {
+		case telemetry.Gauge:
+			byName[metric.Name] = metric
+		case telemetry.Count:
+			byName[metric.Name] = metric
+		case telemetry.Summary:
+			byName[metric.Name] = metric
+		}
+	}
+	return byName
+}
+
+func TestAggregator_Gauges(t *testing.T) {
+	harvester := &mockHarvester{}
+	a := newAggregator(harvester, AggregationConfig{Window: time.Minute})
+	start := a.windowStart
+
+	attrs := map[string]interface{}{"host": "h1"}
+	a.RecordMetric(telemetry.Gauge{Name: "cpu", Value: 4, Attributes: attrs, Timestamp: start.Add(1 * time.Second)})
+	a.RecordMetric(telemetry.Gauge{Name: "cpu", Value: 2, Attributes: attrs, Timestamp: start.Add(3 * time.Second)})
+	a.RecordMetric(telemetry.Gauge{Name: "cpu", Value: 9, Attributes: attrs, Timestamp: start.Add(2 * time.Second)})
+
+	assert.Empty(t, harvester.aggregatedMetrics, "metrics are only forwarded when flushing")
+
+	a.flush(start.Add(time.Minute))
+
+	require.Len(t, harvester.aggregatedMetrics, 2)
+	metrics := metricsByName(harvester.aggregatedMetrics)
+	assert.Equal(t, telemetry.Summary{
+		Name:       "cpu",
+		Attributes: attrs,
+		Count:      3,
+		Sum:        15,
+		Min:        2,
+		Max:        9,
+		Timestamp:  start,
+		Interval:   time.Minute,
+	}, metrics["cpu"])
+	assert.Equal(t, telemetry.Gauge{
+		Name:       "cpu.last",
+		Attributes: attrs,
+		Value:      2,
+		Timestamp:  start.Add(3 * time.Second),
+	}, metrics["cpu.last"])
+}
+
+func TestAggregator_Counts(t *testing.T) {
+	harvester := &mockHarvester{}
+	a := newAggregator(harvester, AggregationConfig{Window: time.Minute, DropAttributes: []string{"pod"}})
+	start := a.windowStart
+
+	a.RecordMetric(telemetry.Count{Name: "requests", Value: 3, Attributes: map[string]interface{}{"pod": "a", "ns": "default"},
+		Timestamp: start, Interval: 10 * time.Second})
+	a.RecordMetric(telemetry.Count{Name: "requests", Value: 4, Attributes: map[string]interface{}{"pod": "b", "ns": "default"},
+		Timestamp: start.Add(20 * time.Second), Interval: 10 * time.Second})
+	a.RecordMetric(telemetry.Count{Name: "requests", Value: 1, Attributes: map[string]interface{}{"pod": "a", "ns": "kube-system"},
+		Timestamp: start, Interval: 10 * time.Second})
+
+	a.flush(start.Add(time.Minute))
+
+	require.Len(t, harvester.aggregatedMetrics, 2)
+	var defaultNs, kubeSystem telemetry.Count
+	for _, m := range harvester.aggregatedMetrics {
+		c := m.(telemetry.Count)
+		if c.Attributes["ns"] == "default" {
+			defaultNs = c
+		} else {
+			kubeSystem = c
+		}
+	}
+	assert.Equal(t, telemetry.Count{
+		Name:       "requests",
+		Attributes: map[string]interface{}{"ns": "default"},
+		Value:      7,
+		Timestamp:  start,
+		Interval:   30 * time.Second,
+	}, defaultNs)
+	assert.Equal(t, 1.0, kubeSystem.Value)
+	assert.NotContains(t, kubeSystem.Attributes, "pod")
+}
+
+func TestAggregator_Summaries(t *testing.T) {
+	harvester := &mockHarvester{}
+	a := newAggregator(harvester, AggregationConfig{Window: time.Minute})
+	start := a.windowStart
+
+	a.RecordMetric(telemetry.Summary{Name: "latency", Count: 2, Sum: 10, Min: 3, Max: 7, Timestamp: start, Interval: time.Second})
+	a.RecordMetric(telemetry.Summary{Name: "latency", Count: 1, Sum: 1, Min: 1, Max: 1, Timestamp: start.Add(time.Second), Interval: time.Second})
+
+	a.flush(start.Add(time.Minute))
+
+	require.Len(t, harvester.aggregatedMetrics, 1)
+	assert.Equal(t, telemetry.Summary{
+		Name:      "latency",
+		Count:     3,
+		Sum:       11,
+		Min:       1,
+		Max:       7,
+		Timestamp: start,
+		Interval:  2 * time.Second,
+	}, harvester.aggregatedMetrics[0])
+}
+
+func TestAggregator_FlushStartsNewWindow(t *testing.T) {
+	harvester := &mockHarvester{}
+	a := newAggregator(harvester, AggregationConfig{Window: time.Minute})
+	start := a.windowStart
+
+	a.RecordMetric(telemetry.Gauge{Name: "cpu", Value: 1, Timestamp: start})
+	a.flush(start.Add(time.Minute))
+	a.flush(start.Add(2 * time.Minute))
+
+	assert.Len(t, harvester.aggregatedMetrics, 2, "empty windows don't forward any metric")
+	assert.Equal(t, start.Add(2*time.Minute), a.windowStart)
+}
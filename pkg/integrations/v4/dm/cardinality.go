@@ -0,0 +1,234 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package dm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/newrelic-forks/newrelic-telemetry-sdk-go/telemetry"
+	"github.com/sirupsen/logrus"
+
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
+)
+
+// CardinalityPolicy defines what happens to the metrics of a new time series when a cardinality limit is reached.
+type CardinalityPolicy string
+
+const (
+	// CardinalityPolicyDrop drops the metrics of the new time series.
+	CardinalityPolicyDrop CardinalityPolicy = "drop"
+	// CardinalityPolicyStripAttribute removes the attribute with the most distinct values from the metrics of the
+	// new time series. The resulting time series are only bound by the global limit.
+	CardinalityPolicyStripAttribute CardinalityPolicy = "strip_attribute"
+)
+
+const (
+	// time series are forgotten after this window, so the limits apply to the unique time series reported per day
+	cardinalityWindow = 24 * time.Hour
+	// min time between two warnings about the same metric name
+	cardinalityWarnPeriod = 5 * time.Minute
+	// CardinalityOverflowMetric self-metric counting the metrics dropped or stripped by the cardinality limits,
+	// with the metric name, attribute and policy as attributes.
+	CardinalityOverflowMetric = "newrelic.infra.dm.cardinalityOverflow"
+)
+
+// CardinalityLimits unique time series limits for the dimensional metrics. Zero disables a limit.
+type CardinalityLimits struct {
+	MaxSeriesPerMetric int
+	MaxSeries          int
+	Policy             CardinalityPolicy
+}
+
+type overflowKey struct {
+	metric    string
+	attribute string
+	policy    CardinalityPolicy
+}
+
+// cardinalityLimiter tracks the unique time series (metric name and attributes) and limits the new ones once the
+// per metric name or the global limits are reached.
+type cardinalityLimiter struct {
+	limits CardinalityLimits
+	now    func() time.Time
+
+	lock        sync.Mutex
+	windowStart time.Time
+	total       int
+	// time series keys per metric name
+	series map[string]map[string]struct{}
+	// distinct values per metric name and attribute, to find the attribute responsible for an overflow
+	values    map[string]map[string]map[string]struct{}
+	overflows map[overflowKey]float64
+	lastFlush time.Time
+	lastWarn  map[string]time.Time
+}
+
+// newCardinalityLimiter returns nil when no limit is enabled.
+func newCardinalityLimiter(limits CardinalityLimits) *cardinalityLimiter {
+	if limits.MaxSeriesPerMetric <= 0 && limits.MaxSeries <= 0 {
+		return nil
+	}
+	if limits.Policy != CardinalityPolicyDrop && limits.Policy != CardinalityPolicyStripAttribute {
+		logger.WithField("policy", limits.Policy).Warn("unknown cardinality overflow policy, metrics exceeding the limits will be dropped")
+		limits.Policy = CardinalityPolicyDrop
+	}
+
+	l := &cardinalityLimiter{
+		limits:   limits,
+		now:      time.Now,
+		lastWarn: map[string]time.Time{},
+	}
+	l.reset(l.now())
+	l.lastFlush = l.windowStart
+	return l
+}
+
+// limit returns the metric to be sent, which might have an attribute stripped, or false if it must be dropped.
+func (l *cardinalityLimiter) limit(metric protocol.Metric) (protocol.Metric, bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.now()
+	if now.Sub(l.windowStart) >= cardinalityWindow {
+		l.reset(now)
+	}
+
+	key := seriesKey(metric.Attributes)
+	if l.known(metric.Name, key) {
+		return metric, true
+	}
+	if l.fits(metric.Name) {
+		l.add(metric.Name, key, metric.Attributes)
+		return metric, true
+	}
+
+	attribute := l.offendingAttribute(metric.Name, metric.Attributes)
+	if l.limits.Policy == CardinalityPolicyStripAttribute && attribute != "" {
+		stripped := metric
+		stripped.Attributes = make(map[string]interface{}, len(metric.Attributes)-1)
+		for k, v := range metric.Attributes {
+			if k != attribute {
+				stripped.Attributes[k] = v
+			}
+		}
+		strippedKey := seriesKey(stripped.Attributes)
+		if l.known(metric.Name, strippedKey) || l.limits.MaxSeries <= 0 || l.total < l.limits.MaxSeries {
+			if !l.known(metric.Name, strippedKey) {
+				l.add(metric.Name, strippedKey, stripped.Attributes)
+			}
+			l.overflow(now, metric.Name, attribute, CardinalityPolicyStripAttribute)
+			return stripped, true
+		}
+	}
+
+	l.overflow(now, metric.Name, attribute, CardinalityPolicyDrop)
+	return metric, false
+}
+
+// flush returns the overflow self-metrics accumulated since the previous flush.
+func (l *cardinalityLimiter) flush() []telemetry.Metric {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.now()
+	var metrics []telemetry.Metric
+	for k, count := range l.overflows {
+		metrics = append(metrics, telemetry.Count{
+			Name: CardinalityOverflowMetric,
+			Attributes: map[string]interface{}{
+				"metricName": k.metric,
+				"attribute":  k.attribute,
+				"policy":     string(k.policy),
+			},
+			Value:     count,
+			Timestamp: l.lastFlush,
+			Interval:  now.Sub(l.lastFlush),
+		})
+	}
+	l.overflows = map[overflowKey]float64{}
+	l.lastFlush = now
+	return metrics
+}
+
+func (l *cardinalityLimiter) reset(now time.Time) {
+	l.windowStart = now
+	l.total = 0
+	l.series = map[string]map[string]struct{}{}
+	l.values = map[string]map[string]map[string]struct{}{}
+	if l.overflows == nil {
+		l.overflows = map[overflowKey]float64{}
+	}
+}
+
+func (l *cardinalityLimiter) known(name, key string) bool {
+	_, ok := l.series[name][key]
+	return ok
+}
+
+func (l *cardinalityLimiter) fits(name string) bool {
+	if l.limits.MaxSeries > 0 && l.total >= l.limits.MaxSeries {
+		return false
+	}
+	return l.limits.MaxSeriesPerMetric <= 0 || len(l.series[name]) < l.limits.MaxSeriesPerMetric
+}
+
+func (l *cardinalityLimiter) add(name, key string, attributes map[string]interface{}) {
+	if l.series[name] == nil {
+		l.series[name] = map[string]struct{}{}
+		l.values[name] = map[string]map[string]struct{}{}
+	}
+	l.series[name][key] = struct{}{}
+	l.total++
+
+	for k, v := range attributes {
+		if l.values[name][k] == nil {
+			l.values[name][k] = map[string]struct{}{}
+		}
+		l.values[name][k][fmt.Sprint(v)] = struct{}{}
+	}
+}
+
+// offendingAttribute returns the attribute with the most distinct values for the metric name, giving precedence
+// to the attributes whose value has never been seen, as they are the ones making the time series new.
+func (l *cardinalityLimiter) offendingAttribute(name string, attributes map[string]interface{}) string {
+	var attribute string
+	bestNew, bestCount := false, -1
+	for k, v := range attributes {
+		values := l.values[name][k]
+		_, seen := values[fmt.Sprint(v)]
+		isNew := !seen
+		if (isNew && !bestNew) || (isNew == bestNew && (len(values) > bestCount || len(values) == bestCount && k < attribute)) {
+			attribute, bestNew, bestCount = k, isNew, len(values)
+		}
+	}
+	return attribute
+}
+
+func (l *cardinalityLimiter) overflow(now time.Time, name, attribute string, policy CardinalityPolicy) {
+	l.overflows[overflowKey{metric: name, attribute: attribute, policy: policy}]++
+
+	if now.Sub(l.lastWarn[name]) < cardinalityWarnPeriod {
+		return
+	}
+	l.lastWarn[name] = now
+	logger.WithFields(logrus.Fields{
+		"name":      name,
+		"attribute": attribute,
+		"policy":    policy,
+		"series":    len(l.series[name]),
+	}).Warn("metric exceeds the cardinality limits")
+}
+
+// seriesKey identifies a time series of a metric name from its attributes.
+func seriesKey(attributes map[string]interface{}) string {
+	pairs := make([]string, 0, len(attributes))
+	for k, v := range attributes {
+		pairs = append(pairs, k+"="+fmt.Sprint(v))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "\x00")
+}
@@ -0,0 +1,184 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package dm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/newrelic-forks/newrelic-telemetry-sdk-go/telemetry"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+)
+
+func gaugeWith(name string, attributes map[string]interface{}) protocol.Metric {
+	ts := time.Now().Unix()
+	return protocol.Metric{
+		Name:       name,
+		Type:       "gauge",
+		Value:      json.RawMessage("1"),
+		Timestamp:  &ts,
+		Attributes: attributes,
+	}
+}
+
+func requestMetrics(name string, amount int) []protocol.Metric {
+	var metrics []protocol.Metric
+	for i := 0; i < amount; i++ {
+		metrics = append(metrics, gaugeWith(name, map[string]interface{}{
+			"path":       "/",
+			"request_id": fmt.Sprintf("id-%d", i),
+		}))
+	}
+	return metrics
+}
+
+func recordedGauges(harvester *mockHarvester) (gauges []telemetry.Gauge, overflows []telemetry.Count) {
+	for _, m := range harvester.aggregatedMetrics {
+		switch metric := m.(type) {
+		case telemetry.Gauge:
+			gauges = append(gauges, metric)
+		case telemetry.Count:
+			if metric.Name == CardinalityOverflowMetric {
+				overflows = append(overflows, metric)
+			}
+		}
+	}
+	return
+}
+
+func TestNewCardinalityLimiter_Disabled(t *testing.T) {
+	assert.Nil(t, newCardinalityLimiter(CardinalityLimits{}))
+}
+
+func TestSender_SendMetrics_CardinalityDrop(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+	hook := new(test.Hook)
+	log.AddHook(hook)
+
+	harvester := &mockHarvester{}
+	s := &sender{
+		harvester:   harvester,
+		cardinality: newCardinalityLimiter(CardinalityLimits{MaxSeriesPerMetric: 3, Policy: CardinalityPolicyDrop}),
+	}
+
+	// WHEN a metric reports more time series than allowed
+	s.SendMetrics(requestMetrics("http.requests", 5))
+	// AND known time series and other metrics are reported afterwards
+	s.SendMetrics([]protocol.Metric{
+		gaugeWith("http.requests", map[string]interface{}{"path": "/", "request_id": "id-0"}),
+		gaugeWith("cpu", map[string]interface{}{"core": "0"}),
+	})
+
+	// THEN the new time series exceeding the limit are dropped
+	gauges, overflows := recordedGauges(harvester)
+	require.Len(t, gauges, 5)
+	for _, g := range gauges[:3] {
+		assert.Equal(t, "http.requests", g.Name)
+	}
+	assert.Equal(t, "id-0", gauges[3].Attributes["request_id"])
+	assert.Equal(t, "cpu", gauges[4].Name)
+
+	// AND the overflows are counted in a self-metric naming the metric and the attribute responsible
+	require.Len(t, overflows, 1)
+	assert.Equal(t, 2.0, overflows[0].Value)
+	assert.Equal(t, map[string]interface{}{
+		"metricName": "http.requests",
+		"attribute":  "request_id",
+		"policy":     "drop",
+	}, overflows[0].Attributes)
+
+	// AND a single warning is logged
+	var warnings []*logrus.Entry
+	for _, entry := range hook.AllEntries() {
+		if entry.Message == "metric exceeds the cardinality limits" {
+			warnings = append(warnings, entry)
+		}
+	}
+	require.Len(t, warnings, 1)
+	assert.Equal(t, "http.requests", warnings[0].Data["name"])
+	assert.Equal(t, "request_id", warnings[0].Data["attribute"])
+}
+
+func TestSender_SendMetrics_CardinalityStripAttribute(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	harvester := &mockHarvester{}
+	s := &sender{
+		harvester:   harvester,
+		cardinality: newCardinalityLimiter(CardinalityLimits{MaxSeriesPerMetric: 2, Policy: CardinalityPolicyStripAttribute}),
+	}
+
+	s.SendMetrics(requestMetrics("http.requests", 4))
+
+	// THEN the time series over the limit are reported without the offending attribute
+	gauges, overflows := recordedGauges(harvester)
+	require.Len(t, gauges, 4)
+	assert.Equal(t, "id-1", gauges[1].Attributes["request_id"])
+	assert.Equal(t, map[string]interface{}{"path": "/"}, gauges[2].Attributes)
+	assert.Equal(t, map[string]interface{}{"path": "/"}, gauges[3].Attributes)
+
+	require.Len(t, overflows, 1)
+	assert.Equal(t, 2.0, overflows[0].Value)
+	assert.Equal(t, "strip_attribute", overflows[0].Attributes["policy"])
+}
+
+func TestCardinalityLimiter_GlobalLimit(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	l := newCardinalityLimiter(CardinalityLimits{MaxSeries: 2, Policy: CardinalityPolicyStripAttribute})
+
+	_, ok := l.limit(gaugeWith("a", map[string]interface{}{"k": "1"}))
+	assert.True(t, ok)
+	_, ok = l.limit(gaugeWith("b", map[string]interface{}{"k": "1"}))
+	assert.True(t, ok)
+
+	// stripped time series are bound by the global limit too
+	_, ok = l.limit(gaugeWith("c", map[string]interface{}{"k": "1"}))
+	assert.False(t, ok)
+	_, ok = l.limit(gaugeWith("a", map[string]interface{}{"k": "2"}))
+	assert.False(t, ok)
+
+	// known time series are still accepted
+	_, ok = l.limit(gaugeWith("a", map[string]interface{}{"k": "1"}))
+	assert.True(t, ok)
+}
+
+func TestCardinalityLimiter_Window(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	l := newCardinalityLimiter(CardinalityLimits{MaxSeries: 1, Policy: CardinalityPolicyDrop})
+	now := l.windowStart
+	l.now = func() time.Time { return now }
+
+	_, ok := l.limit(gaugeWith("a", map[string]interface{}{"k": "1"}))
+	assert.True(t, ok)
+	_, ok = l.limit(gaugeWith("a", map[string]interface{}{"k": "2"}))
+	assert.False(t, ok)
+
+	// WHEN the window expires, the time series are forgotten
+	now = now.Add(cardinalityWindow)
+	_, ok = l.limit(gaugeWith("a", map[string]interface{}{"k": "2"}))
+	assert.True(t, ok)
+}
+
+func TestCardinalityLimiter_UnknownPolicy(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	l := newCardinalityLimiter(CardinalityLimits{MaxSeries: 1, Policy: "unknown"})
+	assert.Equal(t, CardinalityPolicyDrop, l.limits.Policy)
+}
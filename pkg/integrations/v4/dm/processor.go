@@ -23,7 +23,12 @@ func (p *IntegrationProcessor) ProcessMetrics(
 	entity protocol.Entity) []protocol.Metric {
 	now := time.Now().Unix()
 
-	for _, m := range metrics {
+	for i := range metrics {
+		// decorated in place, so the timestamp and interval are kept in the returned metrics
+		m := &metrics[i]
+		if m.Attributes == nil {
+			m.Attributes = map[string]interface{}{}
+		}
 		p.addTimestamp(m, common.Timestamp, &now)
 		p.addInterval(m, common.Interval)
 		p.addAttributes(m, common.Attributes)
@@ -38,7 +43,7 @@ func (p *IntegrationProcessor) ProcessMetrics(
 // If metric doesn't have its own timestamp, add timestamp from common block (of present)
 // or now
 func (p *IntegrationProcessor) addTimestamp(
-	metric protocol.Metric, commonTimestamp *int64, now *int64) {
+	metric *protocol.Metric, commonTimestamp *int64, now *int64) {
 	if metric.Timestamp == nil {
 		if commonTimestamp != nil {
 			metric.Timestamp = commonTimestamp
@@ -50,7 +55,7 @@ func (p *IntegrationProcessor) addTimestamp(
 
 // it potentially adds interval to metric from common block or integration metadata (in this order
 // of precedence) when count or summary don't provide specific value.
-func (p *IntegrationProcessor) addInterval(m protocol.Metric, commonBlockInterval *int64) {
+func (p *IntegrationProcessor) addInterval(m *protocol.Metric, commonBlockInterval *int64) {
 	if m.Interval != nil || !m.Type.HasInterval() {
 		return
 	}
@@ -58,7 +63,7 @@ func (p *IntegrationProcessor) addInterval(m protocol.Metric, commonBlockInterva
 	if commonBlockInterval != nil {
 		m.Interval = commonBlockInterval
 	} else {
-		i := int64(p.IntegrationInterval * time.Millisecond)
+		i := int64(p.IntegrationInterval / time.Millisecond)
 		m.Interval = &i
 	}
 }
@@ -66,7 +71,7 @@ func (p *IntegrationProcessor) addInterval(m protocol.Metric, commonBlockInterva
 // Add attributes to a metric. If a key is already defined at the metric level,
 // it won't be overridden
 func (p *IntegrationProcessor) addAttributes(
-	metric protocol.Metric, attributes map[string]interface{}) {
+	metric *protocol.Metric, attributes map[string]interface{}) {
 	for k, v := range attributes {
 		if _, ok := metric.Attributes[k]; !ok {
 			metric.Attributes[k] = v
@@ -75,7 +80,7 @@ func (p *IntegrationProcessor) addAttributes(
 }
 
 // Add integration labels to a metric, prefixing the key with label.
-func (p *IntegrationProcessor) addLabels(metric protocol.Metric) {
+func (p *IntegrationProcessor) addLabels(metric *protocol.Metric) {
 	for k, v := range p.IntegrationLabels {
 		metric.Attributes[labelPrefix+k] = v
 	}
@@ -83,7 +88,7 @@ func (p *IntegrationProcessor) addLabels(metric protocol.Metric) {
 
 // Add integration extra attributes to a metric. If a key is already defined
 // at the metric level, it won't be overridden
-func (p *IntegrationProcessor) addExtraAnnotations(metric protocol.Metric) {
+func (p *IntegrationProcessor) addExtraAnnotations(metric *protocol.Metric) {
 	for k, v := range p.IntegrationExtraAnnotations {
 		if _, ok := metric.Attributes[k]; !ok {
 			metric.Attributes[k] = v
@@ -34,6 +34,10 @@ type MetricsSenderConfig struct {
 	LicenseKey       string
 	MetricApiURL     string
 	SubmissionPeriod time.Duration
+	// CardinalityLimits unique time series limits. Disabled by default.
+	CardinalityLimits CardinalityLimits
+	// Aggregation local pre-aggregation of the metrics. Disabled by default.
+	Aggregation AggregationConfig
 }
 
 func NewConfig(staging bool, licenseKey string, submissionPeriod time.Duration) MetricsSenderConfig {
@@ -54,12 +58,19 @@ func NewConfig(staging bool, licenseKey string, submissionPeriod time.Duration)
 // NewDMSender creates a Dimensional Metrics sender.
 func NewDMSender(config MetricsSenderConfig, transport http.RoundTripper, idContext *id.Context) (s MetricsSender, err error) {
 	harvester, err := newTelemetryHarverster(config, transport, idContext.AgentIdentity)
+	var recorder metricHarvester = harvester
+	if err == nil && config.Aggregation.Window > 0 {
+		agg := newAggregator(harvester, config.Aggregation)
+		go agg.run(config.Aggregation.Window)
+		recorder = agg
+	}
 	s = &sender{
-		harvester: harvester,
+		harvester: recorder,
 		calculator: Calculator{
 			rate:  rate.NewRateCalculator(),
 			delta: cumulative.NewDeltaCalculator(),
 		},
+		cardinality: newCardinalityLimiter(config.CardinalityLimits),
 	}
 	return
 }
@@ -67,6 +78,8 @@ func NewDMSender(config MetricsSenderConfig, transport http.RoundTripper, idCont
 type sender struct {
 	harvester  metricHarvester
 	calculator Calculator
+	// nil when no cardinality limit is enabled
+	cardinality *cardinalityLimiter
 }
 
 type Calculator struct {
@@ -123,6 +136,8 @@ func (s *sender) SendMetrics(metrics []protocol.Metric) {
 			c = Conversion{Count{}}
 		case "summary":
 			c = Conversion{Summary{}}
+		case "histogram":
+			c = Conversion{Histogram{}}
 		case "rate":
 			c = Conversion{Gauge{calculate: &Rate{get: s.calculator.rate.GetRate}}}
 		case "cumulative-rate":
@@ -134,7 +149,14 @@ func (s *sender) SendMetrics(metrics []protocol.Metric) {
 			continue
 		}
 
-		recMetric, err := c.convert(metric)
+		if s.cardinality != nil {
+			var ok bool
+			if metric, ok = s.cardinality.limit(metric); !ok {
+				continue
+			}
+		}
+
+		recMetrics, err := c.convertAll(metric)
 
 		if err != nil {
 			if err != errNoCalculation {
@@ -143,6 +165,14 @@ func (s *sender) SendMetrics(metrics []protocol.Metric) {
 			continue
 		}
 
-		s.harvester.RecordMetric(recMetric)
+		for _, recMetric := range recMetrics {
+			s.harvester.RecordMetric(recMetric)
+		}
+	}
+
+	if s.cardinality != nil {
+		for _, overflow := range s.cardinality.flush() {
+			s.harvester.RecordMetric(overflow)
+		}
 	}
 }
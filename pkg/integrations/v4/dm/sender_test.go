@@ -121,6 +121,57 @@ func Test_sender_SendMetrics(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "histogram",
+			fields: fields{
+				harvester: &mockHarvester{},
+			},
+			args: args{
+				metrics: []protocol.Metric{
+					{
+						Name:       "HistogramMetric",
+						Type:       "histogram",
+						Attributes: map[string]interface{}{"att_key": "att_value"},
+						Timestamp:  &cannedDateUnix,
+						Interval:   &cannedDurationInt,
+						Value:      json.RawMessage(`{ "sum": 50, "max": 20, "boundaries": [5, 10], "counts": [0, 4, 2], "percentiles": [50] }`),
+					},
+				},
+			},
+			expectedMetrics: []telemetry.Metric{
+				telemetry.Summary{
+					Name:       "HistogramMetric",
+					Attributes: map[string]interface{}{"att_key": "att_value"},
+					Count:      float64(6),
+					Sum:        float64(50),
+					Min:        float64(5),
+					Max:        float64(20),
+					Timestamp:  cannedDate,
+					Interval:   cannedDuration,
+				},
+				telemetry.Gauge{
+					Name:       "HistogramMetric.percentiles",
+					Attributes: map[string]interface{}{"att_key": "att_value", "percentile": float64(50)},
+					Value:      float64(8.75),
+					Timestamp:  cannedDate,
+				},
+			},
+		},
+		{
+			name: "invalid histogram",
+			fields: fields{
+				harvester: &mockHarvester{},
+			},
+			args: args{
+				metrics: []protocol.Metric{
+					{
+						Name:  "HistogramMetric",
+						Type:  "histogram",
+						Value: json.RawMessage(`{ "boundaries": [5, 10], "counts": [1, 2] }`),
+					},
+				},
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
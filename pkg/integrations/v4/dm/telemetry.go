@@ -75,10 +75,28 @@ func (c *Conversion) convert(metric protocol.Metric) (telemetry.Metric, error) {
 	return c.toTelemetry.from(metric)
 }
 
+// convertAll converts the metric into one or more telemetry metrics, depending on the converter.
+func (c *Conversion) convertAll(metric protocol.Metric) ([]telemetry.Metric, error) {
+	if multi, ok := c.toTelemetry.(MultiConverter); ok {
+		return multi.fromAll(metric)
+	}
+
+	m, err := c.convert(metric)
+	if err != nil {
+		return nil, err
+	}
+	return []telemetry.Metric{m}, nil
+}
+
 type Converter interface {
 	from(metric protocol.Metric) (telemetry.Metric, error)
 }
 
+// MultiConverter is implemented by the converters producing several telemetry metrics from a single one.
+type MultiConverter interface {
+	fromAll(metric protocol.Metric) ([]telemetry.Metric, error)
+}
+
 type Count struct {
 	calculate *Cumulative
 }
@@ -151,6 +169,63 @@ func (Summary) from(metric protocol.Metric) (telemetry.Metric, error) {
 	}, nil
 }
 
+// Histogram converts histograms into a summary with their count, sum, min and max, plus a gauge for each of the
+// requested percentiles, named <metric>.percentiles and identified by the percentile attribute.
+type Histogram struct{}
+
+const (
+	percentilesSuffix   = ".percentiles"
+	percentileAttribute = "percentile"
+)
+
+// from returns the summary of the histogram, without its percentiles.
+func (h Histogram) from(metric protocol.Metric) (telemetry.Metric, error) {
+	metrics, err := h.fromAll(metric)
+	if err != nil {
+		return nil, err
+	}
+
+	return metrics[0], nil
+}
+
+func (h Histogram) fromAll(metric protocol.Metric) ([]telemetry.Metric, error) {
+	value, err := metric.HistogramValue()
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := []telemetry.Metric{h.summary(metric, value)}
+	for _, percentile := range value.Percentiles {
+		attributes := make(map[string]interface{}, len(metric.Attributes)+1)
+		for k, v := range metric.Attributes {
+			attributes[k] = v
+		}
+		attributes[percentileAttribute] = percentile
+
+		metrics = append(metrics, telemetry.Gauge{
+			Name:       metric.Name + percentilesSuffix,
+			Attributes: attributes,
+			Value:      value.Percentile(percentile),
+			Timestamp:  metric.Time(),
+		})
+	}
+	return metrics, nil
+}
+
+func (Histogram) summary(metric protocol.Metric, value protocol.HistogramValue) telemetry.Summary {
+	min, max := value.MinMax()
+	return telemetry.Summary{
+		Name:       metric.Name,
+		Attributes: metric.Attributes,
+		Count:      value.Count,
+		Sum:        value.Sum,
+		Min:        min,
+		Max:        max,
+		Timestamp:  metric.Time(),
+		Interval:   metric.IntervalDuration(),
+	}
+}
+
 type Rate struct {
 	get func(name string,
 		attributes map[string]interface{},
@@ -0,0 +1,61 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package v4
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/runner"
+	config2 "github.com/newrelic/infrastructure-agent/pkg/integrations/v4/config"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/emitter"
+)
+
+// NoIntegrationsToRunErr is returned when all the integrations of a dry run are disabled by their feature conditions.
+var NoIntegrationsToRunErr = errors.New("no integrations to run, check the 'when: feature' conditions")
+
+// DryRun runs the integrations of a single configuration file, including the discovery and the variables binding,
+// forwarding their payloads to the provided emitter. It's meant to debug integration configurations with an emitter
+// that doesn't submit the data, such as emitter.Printer.
+// If name is not empty, only the integrations with that name are run. If once is true, it returns after executing
+// the integrations a single time. Otherwise, they are executed at their intervals until the context is cancelled.
+func DryRun(ctx context.Context, cfg Configuration, cfgPath, name string, once bool, em emitter.Emitter) error {
+	yml, err := loadConfig(cfgPath)
+	if err != nil {
+		return err
+	}
+
+	if name != "" {
+		var entries []config2.ConfigEntry
+		for _, entry := range yml.Integrations {
+			if entry.Name == name {
+				entries = append(entries, entry)
+			}
+		}
+		if len(entries) == 0 {
+			return fmt.Errorf("no integration named %q in %s", name, cfgPath)
+		}
+		yml.Integrations = entries
+	}
+
+	f := runner.NewFeatures(cfg.AgentFeatures, nil)
+	gr, _, err := runner.NewGroup(runner.LoadFrom(yml, f), defaultInstancesLookup(cfg), cfg.PassthroughEnvironment, em, cfgPath)
+	if err != nil {
+		return err
+	}
+
+	ctx = contextWithVerbose(ctx, cfg.Verbose)
+	if once {
+		if !gr.RunOnce(ctx) {
+			return NoIntegrationsToRunErr
+		}
+		return nil
+	}
+
+	if !gr.Run(ctx) {
+		return NoIntegrationsToRunErr
+	}
+	<-ctx.Done()
+	return nil
+}
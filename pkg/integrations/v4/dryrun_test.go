@@ -0,0 +1,59 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package v4
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/testhelp/testemit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDryRun_Once(t *testing.T) {
+	// GIVEN a configuration file with two integrations
+	dir, err := tempFiles(map[string]string{"v4-integrations.yaml": v4File})
+	require.NoError(t, err)
+	defer removeTempFiles(t, dir)
+
+	// WHEN only one of them is dry-run once
+	emitter := &testemit.Emitter{}
+	err = DryRun(context.Background(), Configuration{}, filepath.Join(dir, "v4-integrations.yaml"), "hello-test", true, emitter)
+	require.NoError(t, err)
+
+	// THEN only the selected integration emits its data
+	metric := expectOneMetric(t, emitter, "hello-test")
+	assert.Equal(t, "hello", metric["value"])
+	assert.NoError(t, emitter.ExpectTimeout("goodbye-test", 100*time.Millisecond))
+}
+
+func TestDryRun_UnknownName(t *testing.T) {
+	dir, err := tempFiles(map[string]string{"v4-integrations.yaml": v4File})
+	require.NoError(t, err)
+	defer removeTempFiles(t, dir)
+
+	err = DryRun(context.Background(), Configuration{}, filepath.Join(dir, "v4-integrations.yaml"), "unknown", true, &testemit.Emitter{})
+	assert.EqualError(t, err, `no integration named "unknown" in `+filepath.Join(dir, "v4-integrations.yaml"))
+}
+
+func TestDryRun_DisabledFeature(t *testing.T) {
+	dir, err := tempFiles(map[string]string{"docker.yaml": v4FileWithNriDockerNameAndDockerFF})
+	require.NoError(t, err)
+	defer removeTempFiles(t, dir)
+
+	cfg := Configuration{AgentFeatures: map[string]bool{"docker_enabled": false}}
+	err = DryRun(context.Background(), cfg, filepath.Join(dir, "docker.yaml"), "", true, &testemit.Emitter{})
+	assert.Equal(t, NoIntegrationsToRunErr, err)
+}
+
+func TestDryRun_LegacyConfig(t *testing.T) {
+	dir, err := tempFiles(map[string]string{"v3-config.yaml": v3File})
+	require.NoError(t, err)
+	defer removeTempFiles(t, dir)
+
+	err = DryRun(context.Background(), Configuration{}, filepath.Join(dir, "v3-config.yaml"), "", true, &testemit.Emitter{})
+	assert.Equal(t, legacyYAML, err)
+}
@@ -0,0 +1,232 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package emitter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/integration"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+)
+
+// captureExtension of the capture files. Rotated files get an additional numeric suffix: name.jsonl.1, ...
+const captureExtension = ".jsonl"
+
+// Payloads may contain credentials or other sensitive data, so the captures are only readable by the agent user.
+const (
+	captureDirMode  = 0700
+	captureFileMode = 0600
+)
+
+var (
+	clog = log.WithComponent("integrations.emitter.Capturer")
+
+	// characters not allowed in the capture file names
+	unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9._-]`)
+	// rotation suffix of the capture files
+	rotationSuffix = regexp.MustCompile(`\.[0-9]+$`)
+)
+
+// Capturer is an Emitter that records the raw payloads of each integration, one per line, into a capture file
+// before forwarding them to the next Emitter. Capture files are rotated when they reach the max size. They can be
+// fed again through an Emitter with Replay.
+type Capturer struct {
+	next Emitter
+	dir  string
+	// maxSize of a capture file in bytes. Zero disables the rotation.
+	maxSize int64
+	// maxFiles kept per integration, including the current one.
+	maxFiles int
+	// MaxPayloadSize payloads bigger than this size, in bytes, are not captured, as they are discarded by the
+	// emitter. Zero disables the limit.
+	MaxPayloadSize int
+
+	mutex sync.Mutex
+	files map[string]*captureFile
+}
+
+type captureFile struct {
+	file *os.File
+	size int64
+}
+
+// NewCapturer returns a Capturer writing the capture files into the provided directory, which is created if it
+// doesn't exist.
+func NewCapturer(next Emitter, dir string, maxSize int64, maxFiles int) (*Capturer, error) {
+	if err := os.MkdirAll(dir, captureDirMode); err != nil {
+		return nil, err
+	}
+	if maxFiles < 1 {
+		maxFiles = 1
+	}
+	return &Capturer{
+		next:     next,
+		dir:      dir,
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+		files:    map[string]*captureFile{},
+	}, nil
+}
+
+// Emit captures the payload and forwards it to the next Emitter. Capture errors are logged, but they don't
+// prevent the payload from being emitted. Payloads are captured compacted into a single line.
+func (c *Capturer) Emit(metadata integration.Definition, extraLabels data.Map, entityRewrite []data.EntityRewrite, integrationJSON []byte) error {
+	if err := c.capture(metadata.Name, integrationJSON); err != nil {
+		clog.WithError(err).WithField("integration_name", metadata.Name).Warn("can't capture integration payload")
+	}
+	return c.next.Emit(metadata, extraLabels, entityRewrite, integrationJSON)
+}
+
+// Uncaptured returns the Emitter wrapped by the provided one when it's a Capturer, so the payloads generated by the
+// agent itself, instead of by the integrations, are not recorded nor replayed.
+func Uncaptured(em Emitter) Emitter {
+	if c, ok := em.(*Capturer); ok {
+		return c.next
+	}
+	return em
+}
+
+// Close closes all the capture files.
+func (c *Capturer) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var err error
+	for name, cf := range c.files {
+		if closeErr := cf.file.Close(); closeErr != nil {
+			err = closeErr
+		}
+		delete(c.files, name)
+	}
+	return err
+}
+
+// CaptureFile returns the path of the current capture file of an integration.
+func (c *Capturer) CaptureFile(integrationName string) string {
+	return filepath.Join(c.dir, unsafeFileChars.ReplaceAllString(integrationName, "_")+captureExtension)
+}
+
+func (c *Capturer) capture(integrationName string, payload []byte) error {
+	if protocol.CheckPayloadSize(payload, c.MaxPayloadSize) != nil {
+		return nil
+	}
+	line := captureLine(payload)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	cf, ok := c.files[integrationName]
+	if ok && c.maxSize > 0 && cf.size > 0 && cf.size+int64(len(line)) > c.maxSize {
+		if err := c.rotate(integrationName, cf); err != nil {
+			return err
+		}
+		ok = false
+	}
+	if !ok {
+		var err error
+		if cf, err = openCaptureFile(c.CaptureFile(integrationName)); err != nil {
+			return err
+		}
+		c.files[integrationName] = cf
+	}
+
+	n, err := cf.file.Write(line)
+	cf.size += int64(n)
+	return err
+}
+
+// captureLine returns a copy of the payload in a single line, as the payload is forwarded to the next Emitter.
+// Payloads that are not valid JSON are captured anyway, replacing their line breaks by spaces.
+func captureLine(payload []byte) []byte {
+	buf := bytes.Buffer{}
+	if err := json.Compact(&buf, payload); err != nil {
+		buf.Reset()
+		buf.Write(bytes.Map(func(r rune) rune {
+			if r == '\n' || r == '\r' {
+				return ' '
+			}
+			return r
+		}, bytes.TrimSpace(payload)))
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+// rotate closes the current capture file and shifts the rotated ones, removing the oldest.
+func (c *Capturer) rotate(integrationName string, cf *captureFile) error {
+	delete(c.files, integrationName)
+	if err := cf.file.Close(); err != nil {
+		return err
+	}
+
+	current := c.CaptureFile(integrationName)
+	rotated := func(i int) string {
+		if i == 0 {
+			return current
+		}
+		return current + "." + strconv.Itoa(i)
+	}
+	if err := os.Remove(rotated(c.maxFiles - 1)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for i := c.maxFiles - 2; i >= 0; i-- {
+		if err := os.Rename(rotated(i), rotated(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func openCaptureFile(path string) (*captureFile, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, captureFileMode)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &captureFile{file: file, size: info.Size()}, nil
+}
+
+// CapturedIntegrationName returns the integration name of a capture file.
+func CapturedIntegrationName(captureFile string) string {
+	name := rotationSuffix.ReplaceAllString(filepath.Base(captureFile), "")
+	return strings.TrimSuffix(name, captureExtension)
+}
+
+// Replay feeds the captured payloads, one per line, through the provided Emitter, as if they were emitted by the
+// given integration. Payloads that can't be emitted don't stop the replay. It returns the number of replayed
+// payloads.
+func Replay(capture io.Reader, metadata integration.Definition, em Emitter) (int, error) {
+	reader := bufio.NewReader(capture)
+	var payloads int
+	for {
+		line, err := reader.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			payloads++
+			if emitErr := em.Emit(metadata, nil, nil, line); emitErr != nil {
+				clog.WithError(emitErr).WithField("payload", payloads).Debug("Can't emit replayed payload.")
+			}
+		}
+		if err == io.EOF {
+			return payloads, nil
+		}
+		if err != nil {
+			return payloads, fmt.Errorf("can't read capture: %s", err)
+		}
+	}
+}
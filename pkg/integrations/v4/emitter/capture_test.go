@@ -0,0 +1,161 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package emitter
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/integration"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingEmitter struct {
+	payloads []string
+}
+
+func (r *recordingEmitter) Emit(_ integration.Definition, _ data.Map, _ []data.EntityRewrite, integrationJSON []byte) error {
+	r.payloads = append(r.payloads, string(integrationJSON))
+	return nil
+}
+
+func TestCapturer_Emit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "capture")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	next := &recordingEmitter{}
+	c, err := NewCapturer(next, filepath.Join(dir, "capture"), 0, 1)
+	require.NoError(t, err)
+
+	// WHEN payloads are emitted by different integrations
+	require.NoError(t, c.Emit(integration.Definition{Name: "nri-redis"}, nil, nil, []byte(`{"a":1}`+"\n")))
+	require.NoError(t, c.Emit(integration.Definition{Name: "nri/other"}, nil, nil, []byte(`{"b":2}`)))
+	require.NoError(t, c.Emit(integration.Definition{Name: "nri-redis"}, nil, nil, []byte(`{"a":3}`)))
+	require.NoError(t, c.Close())
+
+	// THEN they are forwarded unmodified
+	assert.Equal(t, []string{`{"a":1}` + "\n", `{"b":2}`, `{"a":3}`}, next.payloads)
+
+	// AND they are captured one per line, in a file per integration
+	content, err := ioutil.ReadFile(c.CaptureFile("nri-redis"))
+	require.NoError(t, err)
+	assert.Equal(t, "{\"a\":1}\n{\"a\":3}\n", string(content))
+	content, err = ioutil.ReadFile(filepath.Join(dir, "capture", "nri_other.jsonl"))
+	require.NoError(t, err)
+	assert.Equal(t, "{\"b\":2}\n", string(content))
+
+	// AND only the agent user can access them
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(filepath.Join(dir, "capture"))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(captureDirMode), info.Mode().Perm())
+		info, err = os.Stat(c.CaptureFile("nri-redis"))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(captureFileMode), info.Mode().Perm())
+	}
+}
+
+func TestCapturer_Emit_SingleLine(t *testing.T) {
+	dir, err := ioutil.TempDir("", "capture")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// GIVEN a capturer discarding payloads bigger than 30 bytes
+	c, err := NewCapturer(&recordingEmitter{}, dir, 0, 1)
+	require.NoError(t, err)
+	c.MaxPayloadSize = 30
+
+	// WHEN pretty-printed, malformed and oversized payloads are emitted
+	require.NoError(t, c.Emit(integration.Definition{Name: "nri-test"}, nil, nil, []byte("{\n  \"a\": 1\n}\n")))
+	require.NoError(t, c.Emit(integration.Definition{Name: "nri-test"}, nil, nil, []byte("{\"b\":\r\n")))
+	require.NoError(t, c.Emit(integration.Definition{Name: "nri-test"}, nil, nil, []byte(`{"c":"`+strings.Repeat("x", 30)+`"}`)))
+	require.NoError(t, c.Close())
+
+	// THEN each payload is captured in a single line, skipping the oversized ones
+	content, err := ioutil.ReadFile(c.CaptureFile("nri-test"))
+	require.NoError(t, err)
+	assert.Equal(t, "{\"a\":1}\n{\"b\":\n", string(content))
+}
+
+func TestUncaptured(t *testing.T) {
+	dir, err := ioutil.TempDir("", "capture")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	next := &recordingEmitter{}
+	c, err := NewCapturer(next, dir, 0, 1)
+	require.NoError(t, err)
+	defer c.Close()
+
+	// WHEN a payload is emitted bypassing the capturer
+	require.NoError(t, Uncaptured(c).Emit(integration.Definition{Name: "nri-test"}, nil, nil, []byte(`{"a":1}`)))
+
+	// THEN it's forwarded but not captured
+	assert.Equal(t, []string{`{"a":1}`}, next.payloads)
+	_, err = os.Stat(c.CaptureFile("nri-test"))
+	assert.True(t, os.IsNotExist(err))
+
+	// AND other emitters are returned as they are
+	assert.Equal(t, next, Uncaptured(next))
+}
+
+func TestCapturer_Rotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "capture")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// GIVEN a capturer keeping 3 files of 10 bytes
+	c, err := NewCapturer(&recordingEmitter{}, dir, 10, 3)
+	require.NoError(t, err)
+	defer c.Close()
+
+	// WHEN more payloads are captured than fit in the files
+	for _, payload := range []string{"{\"n\":1}", "{\"n\":2}", "{\"n\":3}", "{\"n\":4}"} {
+		require.NoError(t, c.Emit(integration.Definition{Name: "nri-test"}, nil, nil, []byte(payload)))
+	}
+
+	// THEN the files are rotated, and the oldest removed
+	current := c.CaptureFile("nri-test")
+	for suffix, expected := range map[string]string{"": "{\"n\":4}\n", ".1": "{\"n\":3}\n", ".2": "{\"n\":2}\n"} {
+		content, err := ioutil.ReadFile(current + suffix)
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(content))
+	}
+	_, err = os.Stat(current + ".3")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCapturedIntegrationName(t *testing.T) {
+	assert.Equal(t, "nri-redis", CapturedIntegrationName(filepath.Join("capture", "nri-redis.jsonl")))
+	assert.Equal(t, "nri-redis", CapturedIntegrationName(filepath.Join("capture", "nri-redis.jsonl.2")))
+}
+
+func TestReplay(t *testing.T) {
+	capture := strings.NewReader(`{"protocol_version":"4","integration":{"name":"nri-test","version":"1"},"data":[{"entity":{"name":"e1","type":"test"},"metrics":[{"name":"requests","type":"gauge","value":10}]}]}
+
+{"protocol_version":"3","data":{}}
+{"protocol_version":"4","integration":{"name":"nri-test","version":"1"},"data":[{"entity":{"name":"e1","type":"test"},"metrics":[{"name":"requests","type":"gauge","value":20}]}]}`)
+	out := &bytes.Buffer{}
+
+	// WHEN a capture is replayed through a Printer
+	payloads, err := Replay(capture, integration.Definition{Name: "nri-test"}, newTestPrinter(out))
+
+	// THEN all the payloads are replayed, even after a malformed one
+	require.NoError(t, err)
+	assert.Equal(t, 3, payloads)
+	assert.Equal(t, `=== integration: nri-test
+  metric requests (gauge): 10
+=== integration: nri-test
+error: malformed integration payload: data field must be an array of datasets
+=== integration: nri-test
+  metric requests (gauge): 20
+`, out.String())
+}
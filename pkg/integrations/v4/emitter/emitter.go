@@ -39,6 +39,7 @@ func NewIntegrationEmitter(a *agent.Agent, dmSender dm.MetricsSender, ffRetrieve
 		MetricsSender:       dmSender,
 		ForceProtocolV2ToV3: true,
 		FFRetriever:         ffRetriever,
+		MaxPayloadSize:      a.Context.Config().MaxIntegrationPayloadSize,
 	}
 }
 
@@ -49,9 +50,22 @@ type Legacy struct {
 	MetricsSender       dm.MetricsSender
 	ForceProtocolV2ToV3 bool
 	FFRetriever         feature_flags.Retriever
+	// MaxPayloadSize payloads bigger than this size, in bytes, are discarded before being decoded. Zero disables
+	// the limit.
+	MaxPayloadSize int
 }
 
+// Emit decodes the integration payload dataset by dataset, emitting each of them as soon as it's decoded. Payloads
+// that are not valid JSON are rejected as a whole, before emitting any dataset.
 func (e *Legacy) Emit(metadata integration.Definition, extraLabels data.Map, entityRewrite []data.EntityRewrite, integrationJSON []byte) error {
+	if err := protocol.CheckPayloadSize(integrationJSON, e.MaxPayloadSize); err != nil {
+		elog.
+			WithError(err).
+			WithField("integration_name", metadata.Name).
+			Warn("discarding integration payload")
+		return err
+	}
+
 	protocolVersion, err := protocol.VersionFromPayload(integrationJSON, e.ForceProtocolV2ToV3)
 	if err != nil {
 		elog.
@@ -64,43 +78,39 @@ func (e *Legacy) Emit(metadata integration.Definition, extraLabels data.Map, ent
 
 	// dimensional metrics
 	if protocolVersion == protocol.V4 {
-		pluginDataV4, err := ParsePayloadV4(integrationJSON, e.FFRetriever)
-		if err != nil {
-			elog.WithError(err).WithField("output", string(integrationJSON)).Warn("can't parse v4 integration output")
-			return err
-		}
-
-		return e.EmitV4(metadata, extraLabels, entityRewrite, pluginDataV4)
+		return e.emitV4(metadata, extraLabels, entityRewrite, integrationJSON)
 	}
 
-	pluginDataV3, err := protocol.ParsePayload(integrationJSON, protocolVersion)
-	if err != nil {
-		elog.WithError(err).WithField("output", string(integrationJSON)).Warn("can't parse integration output")
-		return err
-	}
-
-	return e.emitV3(metadata, extraLabels, entityRewrite, pluginDataV3, protocolVersion)
+	return e.emitV3(metadata, extraLabels, entityRewrite, integrationJSON, protocolVersion)
 }
 
 func (e *Legacy) emitV3(
 	metadata integration.Definition,
 	extraLabels data.Map,
 	entityRewrite []data.EntityRewrite,
-	pluginData protocol.PluginDataV3,
+	integrationJSON []byte,
 	protocolVersion int) error {
-	var emitErrs []error
+	pluginID, err := protocol.ParseIdentifier(integrationJSON)
+	if err != nil {
+		elog.WithError(err).WithField("output", string(integrationJSON)).Warn("can't parse integration output")
+		return err
+	}
 
-	pgId := metadata.PluginID(pluginData.Name)
+	pgId := metadata.PluginID(pluginID.Name)
 	plugin := agent.NewExternalPluginCommon(pgId, e.Context, metadata.Name)
 
 	labels, extraAnnotations := metadata.LabelsAndExtraAnnotations(extraLabels)
 
-	for _, dataset := range pluginData.DataSets {
+	var emitErrs []error
+	var dataSets int
+	err = protocol.ForEachDataSetV3(integrationJSON, protocolVersion, func(dataset protocol.PluginDataSetV3) {
+		dataSets++
+		metadata.Transform.DataSetV3(&dataset)
 		err := legacy.EmitDataSet(
 			e.Context,
 			&plugin,
-			pluginData.Name,
-			pluginData.IntegrationVersion,
+			pluginID.Name,
+			pluginID.IntegrationVersion,
 			metadata.ExecutorConfig.User,
 			dataset,
 			extraAnnotations,
@@ -110,9 +120,13 @@ func (e *Legacy) emitV3(
 		if err != nil {
 			emitErrs = append(emitErrs, err)
 		}
+	})
+	if err != nil {
+		elog.WithError(err).WithField("integration_name", metadata.Name).Warn("can't decode integration datasets")
+		return err
 	}
 
-	return composeEmitError(emitErrs, len(pluginData.DataSets))
+	return composeEmitError(emitErrs, dataSets)
 }
 
 // Returns a composed error which describes all the errors found during the emit process of each data set
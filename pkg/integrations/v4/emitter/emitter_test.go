@@ -513,6 +513,66 @@ func TestProtocolV4_Emit_WithFFDisabled(t *testing.T) {
 	require.Error(t, err)
 }
 
+func TestLegacy_Emit_PayloadTooLarge(t *testing.T) {
+	ma := mockAgent()
+	em := &Legacy{
+		Context:        ma,
+		FFRetriever:    feature_flags.NewManager(map[string]bool{handler.FlagProtocolV4: true}),
+		MaxPayloadSize: 10,
+	}
+
+	err := em.Emit(integration.Definition{}, data.Map{}, []data.EntityRewrite{}, []byte(integrationJsonV4Output))
+	require.IsType(t, &protocol.PayloadTooLargeError{}, err)
+	ma.AssertNotCalled(t, "SendData", mock.Anything)
+}
+
+func TestLegacy_Emit_MalformedDataSet(t *testing.T) {
+	integrationJSON := []byte(`{"name":"com.newrelic.test","protocol_version":"3","integration_version":"1.0.0","data":[
+		{"entity":{"name":"valid","type":"test"},"inventory":{"key":{"value":"v"}}},
+		{"entity":{"name":{"not":"a string"},"type":"test"},"inventory":{"key":{"value":"v"}}}
+	]}`)
+
+	ma := mockAgent()
+	em := &Legacy{
+		Context:     ma,
+		FFRetriever: feature_flags.NewManager(map[string]bool{}),
+	}
+
+	err := em.Emit(integration.Definition{}, data.Map{}, []data.EntityRewrite{}, integrationJSON)
+	require.IsType(t, &protocol.MalformedPayloadError{}, err)
+
+	// the valid dataset is still emitted
+	ma.AssertCalled(t, "SendData", mock.AnythingOfType("agent.PluginOutput"))
+}
+
+func TestLegacy_Emit_TruncatedPayload(t *testing.T) {
+	payloads := map[string]string{
+		"v3": `{"name":"com.newrelic.test","protocol_version":"3","integration_version":"1.0.0","data":[
+		{"entity":{"name":"valid","type":"test"},"inventory":{"key":{"value":"v"}}},
+		{"entity":{"name":"truncated","type":"test"},"inven`,
+		"v4": integrationJsonV4Output[:len(integrationJsonV4Output)-10],
+	}
+	for name, payload := range payloads {
+		t.Run(name, func(t *testing.T) {
+			ma := mockAgent()
+			ms := mockMetricSender()
+			em := &Legacy{
+				Context:       ma,
+				MetricsSender: ms,
+				FFRetriever:   feature_flags.NewManager(map[string]bool{handler.FlagProtocolV4: true}),
+			}
+
+			err := em.Emit(integration.Definition{Name: "nri-test"}, data.Map{}, []data.EntityRewrite{}, []byte(payload))
+
+			// the whole payload is rejected, without emitting any dataset
+			require.Error(t, err)
+			ma.AssertNotCalled(t, "SendData", mock.Anything)
+			ma.AssertNotCalled(t, "SendEvent", mock.Anything, mock.Anything)
+			ms.AssertNotCalled(t, "SendMetrics", mock.Anything)
+		})
+	}
+}
+
 func TestParsePayloadV4(t *testing.T) {
 	ffm := feature_flags.NewManager(map[string]bool{handler.FlagProtocolV4: true})
 
@@ -19,26 +19,38 @@ import (
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/dm"
 )
 
-func (e *Legacy) EmitV4(
+func (e *Legacy) emitV4(
 	metadata integration.Definition,
 	extraLabels data.Map,
 	entityRewrite []data.EntityRewrite,
-	integrationData protocol.DataV4) error {
-	var emitErrs []error
+	integrationJSON []byte) error {
+	if enabled, ok := e.FFRetriever.GetFeatureFlag(handler.FlagProtocolV4); !ok || !enabled {
+		elog.WithError(ProtocolV4NotEnabledErr).WithField("integration_name", metadata.Name).Warn("can't parse v4 integration output")
+		return ProtocolV4NotEnabledErr
+	}
 
-	pluginId := metadata.PluginID(integrationData.Integration.Name)
+	integrationMetadata, err := protocol.ParseIntegrationMetadata(integrationJSON)
+	if err != nil {
+		elog.WithError(err).WithField("output", string(integrationJSON)).Warn("can't parse v4 integration output")
+		return err
+	}
+
+	pluginId := metadata.PluginID(integrationMetadata.Name)
 	plugin := agent.NewExternalPluginCommon(pluginId, e.Context, metadata.Name)
 
 	labels, extraAnnotations := metadata.LabelsAndExtraAnnotations(extraLabels)
 
-	var err error
-	for _, dataset := range integrationData.DataSets {
-		if err = emitV4DataSet(
+	var emitErrs []error
+	var dataSets int
+	err = protocol.ForEachDataSetV4(integrationJSON, func(dataset protocol.Dataset) {
+		dataSets++
+		metadata.Transform.DataSetV4(&dataset)
+		if err := emitV4DataSet(
 			e.Context.IDLookup(),
 			e.MetricsSender,
 			&plugin,
 			metadata,
-			integrationData.Integration,
+			integrationMetadata,
 			dataset,
 			labels,
 			extraAnnotations,
@@ -46,9 +58,13 @@ func (e *Legacy) EmitV4(
 		); err != nil {
 			emitErrs = append(emitErrs, err)
 		}
+	})
+	if err != nil {
+		elog.WithError(err).WithField("integration_name", metadata.Name).Warn("can't decode v4 integration datasets")
+		return err
 	}
 
-	return composeEmitError(emitErrs, len(integrationData.DataSets))
+	return composeEmitError(emitErrs, dataSets)
 }
 
 func emitV4DataSet(
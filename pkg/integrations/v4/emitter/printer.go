@@ -0,0 +1,131 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package emitter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/internal/feature_flags"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/integration"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
+	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+	"github.com/newrelic/infrastructure-agent/pkg/sysinfo/hostname"
+)
+
+// Printer is an Emitter that writes the integrations data in a human readable format, instead of submitting it.
+// It's meant for debugging integration configurations. Payloads go through the same emitter than the agent
+// ones, so the printed inventory, events and metrics are normalized as they would be submitted.
+type Printer struct {
+	out     io.Writer
+	emitter Emitter
+	// buf holds the output of the payload being emitted, so payloads of concurrent integrations don't interleave
+	buf   bytes.Buffer
+	mutex sync.Mutex
+}
+
+// NewPrinter returns a Printer writing into the provided writer. The agent identifiers are used for resolving
+// the entity keys and replacing loopback addresses, as the agent does.
+func NewPrinter(out io.Writer, cfg *config.Config, idLookup agent.IDLookup, ffRetriever feature_flags.Retriever) *Printer {
+	p := &Printer{out: out}
+	agentKey, _ := idLookup.AgentKey()
+	p.emitter = &Legacy{
+		Context: &printerContext{
+			buf:      &p.buf,
+			cfg:      cfg,
+			idLookup: idLookup,
+			agentKey: agentKey,
+		},
+		MetricsSender:       &printerMetricsSender{buf: &p.buf},
+		ForceProtocolV2ToV3: true,
+		FFRetriever:         ffRetriever,
+		MaxPayloadSize:      cfg.MaxIntegrationPayloadSize,
+	}
+	return p
+}
+
+// Emit prints every inventory item, event and metric the agent would submit for the integration payload.
+func (p *Printer) Emit(metadata integration.Definition, extraLabels data.Map, entityRewrite []data.EntityRewrite, integrationJSON []byte) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.buf.Reset()
+	fmt.Fprintf(&p.buf, "=== integration: %s\n", metadata.Name)
+	err := p.emitter.Emit(metadata, extraLabels, entityRewrite, integrationJSON)
+	if err != nil {
+		fmt.Fprintf(&p.buf, "error: %s\n", err)
+	}
+
+	if _, writeErr := p.buf.WriteTo(p.out); writeErr != nil {
+		return writeErr
+	}
+	return err
+}
+
+// printerContext is the agent context of the Printer emitter: it prints the inventory and events that the agent
+// would submit.
+type printerContext struct {
+	buf      *bytes.Buffer
+	cfg      *config.Config
+	idLookup agent.IDLookup
+	agentKey string
+}
+
+func (c *printerContext) SendData(output agent.PluginOutput) {
+	sort.Sort(output.Data)
+	for _, item := range output.Data {
+		fmt.Fprintf(c.buf, "  inventory %s: %s\n", output.EntityKey, toJSON(item))
+	}
+}
+
+func (c *printerContext) SendEvent(event sample.Event, key entity.Key) {
+	fmt.Fprintf(c.buf, "  event %s: %s\n", key, toJSON(event))
+}
+
+func (c *printerContext) Unregister(ids.PluginID)                 {}
+func (c *printerContext) AddReconnecting(agent.Plugin)            {}
+func (c *printerContext) Reconnect()                              {}
+func (c *printerContext) Config() *config.Config                  { return c.cfg }
+func (c *printerContext) AgentIdentifier() string                 { return c.agentKey }
+func (c *printerContext) Version() string                         { return "" }
+func (c *printerContext) CacheServicePids(string, map[int]string) {}
+func (c *printerContext) GetServiceForPid(int) (string, bool)     { return "", false }
+func (c *printerContext) ActiveEntitiesChannel() chan string      { return nil }
+func (c *printerContext) HostnameResolver() hostname.Resolver     { return nil }
+func (c *printerContext) IDLookup() agent.IDLookup                { return c.idLookup }
+
+// printerMetricsSender prints the dimensional metrics that the agent would submit.
+type printerMetricsSender struct {
+	buf *bytes.Buffer
+}
+
+func (s *printerMetricsSender) SendMetrics(metrics []protocol.Metric) {
+	for _, metric := range metrics {
+		fmt.Fprintf(s.buf, "  metric %s (%s): %s", metric.Name, metric.Type, metric.Value)
+		if metric.Interval != nil {
+			fmt.Fprintf(s.buf, " interval.ms=%d", *metric.Interval)
+		}
+		if len(metric.Attributes) > 0 {
+			fmt.Fprintf(s.buf, " %s", toJSON(metric.Attributes))
+		}
+		s.buf.WriteByte('\n')
+	}
+}
+
+// toJSON encodes the value as a single line JSON, with the map keys sorted.
+func toJSON(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(b)
+}
@@ -0,0 +1,122 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package emitter
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/internal/agent/cmdchannel/handler"
+	"github.com/newrelic/infrastructure-agent/internal/feature_flags"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/integration"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/transform"
+	agentConfig "github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/config"
+	"github.com/newrelic/infrastructure-agent/pkg/sysinfo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPrinter(out io.Writer) *Printer {
+	idLookup := agent.IDLookup{
+		sysinfo.HOST_SOURCE_HOSTNAME:       "agent-host.example.com",
+		sysinfo.HOST_SOURCE_HOSTNAME_SHORT: "agent-host",
+	}
+	ffRetriever := feature_flags.NewManager(map[string]bool{handler.FlagProtocolV4: true})
+	return NewPrinter(out, &agentConfig.Config{}, idLookup, ffRetriever)
+}
+
+func TestPrinter_Emit_V3(t *testing.T) {
+	out := &bytes.Buffer{}
+	p := newTestPrinter(out)
+
+	payload := `{"name":"com.newrelic.redis","protocol_version":"3","integration_version":"1.0.0","data":[{
+		"entity":{"name":"localhost:6379","type":"instance"},
+		"metrics":[{"event_type":"RedisSample","db.connections":3,"timestamp":1}],
+		"inventory":{"config/port":{"value":"6379"}}
+	}]}`
+	metadata := integration.Definition{Name: "nri-redis", Labels: map[string]string{"env": "test"}}
+
+	err := p.Emit(metadata, data.Map{"label.role": "cache", "annotation": "value"}, nil, []byte(payload))
+	require.NoError(t, err)
+
+	// THEN the data is normalized as the agent submits it: loopback replaced, labels and annotations decorated
+	assert.Equal(t, `=== integration: nri-redis
+  inventory instance:agent-host:6379: {"id":"config/port","value":"6379"}
+  inventory instance:agent-host:6379: {"entityKey":"instance:agent-host:6379","id":"labels/env","value":"test"}
+  inventory instance:agent-host:6379: {"entityKey":"instance:agent-host:6379","id":"labels/role","value":"cache"}
+  event instance:agent-host:6379: {"annotation":"value","db.connections":3,"displayName":"instance:agent-host:6379","entityKey":"instance:agent-host:6379","entityName":"instance:agent-host:6379","eventType":"RedisSample","event_type":"RedisSample","integrationName":"com.newrelic.redis","integrationVersion":"1.0.0","label.env":"test","label.role":"cache","reportingAgent":"agent-host.example.com","timestamp":1}
+`, out.String())
+}
+
+func TestPrinter_Emit_V4(t *testing.T) {
+	out := &bytes.Buffer{}
+	p := newTestPrinter(out)
+
+	payload := `{"protocol_version":"4","integration":{"name":"nri-test","version":"1"},"data":[{
+		"common":{"attributes":{"host":"h1"}},
+		"entity":{"name":"old-name","type":"test"},
+		"metrics":[{"name":"requests","type":"count","value":10,"interval.ms":15000,"attributes":{"path":"/"}}]
+	}]}`
+	entityRewrite := []data.EntityRewrite{{Action: "replace", Match: "old-name", ReplaceField: "new-name"}}
+
+	err := p.Emit(integration.Definition{Name: "nri-test", Labels: map[string]string{"env": "test"}}, nil, entityRewrite, []byte(payload))
+	require.NoError(t, err)
+
+	// THEN the common attributes and labels are decorated into the metrics
+	assert.Equal(t, `=== integration: nri-test
+  metric requests (count): 10 interval.ms=15000 {"host":"h1","label.env":"test","path":"/"}
+`, out.String())
+}
+
+func TestPrinter_Emit_Transform(t *testing.T) {
+	out := &bytes.Buffer{}
+	p := newTestPrinter(out)
+
+	payload := `{"protocol_version":"4","integration":{"name":"nri-test","version":"1"},"data":[{
+		"entity":{"name":"e1","type":"test"},
+		"metrics":[
+			{"name":"requests","type":"gauge","value":10,"attributes":{"path":"/"}},
+			{"name":"gc.time","type":"gauge","value":1}
+		]
+	}]}`
+	tr, err := transform.New(config.Transform{
+		Drop:             []config.DropRule{{Metric: "gc.*"}},
+		RenameMetrics:    map[string]string{"requests": "http.requests"},
+		RenameAttributes: map[string]string{"path": "http.path"},
+	})
+	require.NoError(t, err)
+
+	err = p.Emit(integration.Definition{Name: "nri-test", Transform: tr}, nil, nil, []byte(payload))
+	require.NoError(t, err)
+
+	assert.Equal(t, `=== integration: nri-test
+  metric http.requests (gauge): 10 {"http.path":"/"}
+`, out.String())
+}
+
+func TestPrinter_Emit_ProtocolV4Disabled(t *testing.T) {
+	out := &bytes.Buffer{}
+	p := NewPrinter(out, &agentConfig.Config{}, agent.IDLookup{}, feature_flags.NewManager(nil))
+
+	payload := `{"protocol_version":"4","integration":{"name":"nri-test","version":"1"},"data":[]}`
+
+	// WHEN the agent has protocol v4 disabled
+	err := p.Emit(integration.Definition{Name: "nri-test"}, nil, nil, []byte(payload))
+
+	// THEN the payload isn't printed, as the agent wouldn't submit it
+	require.Equal(t, ProtocolV4NotEnabledErr, err)
+	assert.Equal(t, "=== integration: nri-test\nerror: "+ProtocolV4NotEnabledErr.Error()+"\n", out.String())
+}
+
+func TestPrinter_Emit_Malformed(t *testing.T) {
+	out := &bytes.Buffer{}
+	p := newTestPrinter(out)
+
+	err := p.Emit(integration.Definition{Name: "nri-test"}, nil, nil, []byte(`{"protocol_version":"3","data":{}}`))
+	require.Error(t, err)
+	assert.Contains(t, out.String(), "error: malformed integration payload")
+}
@@ -12,6 +12,7 @@ import (
 	"strconv"
 	"strings"
 	"text/template"
+	"unicode"
 
 	"github.com/newrelic/infrastructure-agent/pkg/license"
 	"github.com/newrelic/infrastructure-agent/pkg/log"
@@ -26,6 +27,10 @@ const (
 	logRecordModifierSource = "nri-agent"
 	defaultBufferMaxSize    = 128
 	fluentBitDbName         = "fb.db"
+	fbMonitoringListen      = "127.0.0.1"
+	// fbAgentFilterAlias aliases the filter adding the agent attributes, telling the agent log forwarder apart from
+	// any other Fluent Bit serving the monitoring API on the same port.
+	fbAgentFilterAlias = "nr_agent_attributes"
 )
 
 // FluentBit INPUT plugin types
@@ -41,6 +46,19 @@ const (
 const (
 	fbFilterTypeGrep           = "grep"
 	fbFilterTypeRecordModifier = "record_modifier"
+	fbFilterTypeThrottle       = "throttle"
+	fbFilterTypeModify         = "modify"
+	fbFilterTypeLua            = "lua"
+)
+
+// Rate limiting and sampling filters, aliased so their dropped records can be told apart on the monitoring API.
+const (
+	fbThrottleAliasPrefix  = "nr_throttle_"
+	fbSamplingAliasPrefix  = "nr_sample_"
+	fbThrottleInterval     = "1s"
+	fbSamplingRateField    = "nr.sample_rate"
+	fbSamplingLuaCall      = "nr_sample"
+	samplingScriptFileName = "nr_fb_sampling.lua"
 )
 
 // Syslog plugin valid formats
@@ -75,17 +93,20 @@ type YAML struct {
 
 // LogCfg logging integration config from customer defined YAML.
 type LogCfg struct {
-	Name       string            `yaml:"name"`
-	File       string            `yaml:"file"`        // ...
-	MaxLineKb  int               `yaml:"max_line_kb"` // Setup the max value of the buffer while reading lines.
-	Folder     string            `yaml:"folder"`      // ...
-	Systemd    string            `yaml:"systemd"`     // ...
-	EventLog   string            `yaml:"eventlog"`
-	Pattern    string            `yaml:"pattern"`
-	Attributes map[string]string `yaml:"attributes"`
-	Syslog     *LogSyslogCfg     `yaml:"syslog"`
-	Tcp        *LogTcpCfg        `yaml:"tcp"`
-	Fluentbit  *LogExternalFBCfg `yaml:"fluentbit"`
+	Name        string            `yaml:"name"`
+	File        string            `yaml:"file"`        // ...
+	MaxLineKb   int               `yaml:"max_line_kb"` // Setup the max value of the buffer while reading lines.
+	Folder      string            `yaml:"folder"`      // ...
+	Systemd     string            `yaml:"systemd"`     // ...
+	EventLog    string            `yaml:"eventlog"`
+	Pattern     string            `yaml:"pattern"`
+	Attributes  map[string]string `yaml:"attributes"`
+	Syslog      *LogSyslogCfg     `yaml:"syslog"`
+	Tcp         *LogTcpCfg        `yaml:"tcp"`
+	Fluentbit   *LogExternalFBCfg `yaml:"fluentbit"`
+	RateLimit   *LogRateLimitCfg  `yaml:"rate_limit"`
+	SampleRate  int               `yaml:"sample_rate"`          // Keep 1 out of every N records.
+	SampleAllow string            `yaml:"sample_allow_pattern"` // Records matching it are never sampled out.
 }
 
 // LogSyslogCfg logging integration config from customer defined YAML, specific for the Syslog input plugin
@@ -101,6 +122,12 @@ type LogTcpCfg struct {
 	Separator string `yaml:"separator"`
 }
 
+// LogRateLimitCfg caps the amount of records per second forwarded for a log source.
+type LogRateLimitCfg struct {
+	RecordsPerSec int `yaml:"records_per_sec"`
+	Burst         int `yaml:"burst"` // Defaults to RecordsPerSec.
+}
+
 type LogExternalFBCfg struct {
 	CfgPath     string `yaml:"config_file"`
 	ParsersPath string `yaml:"parsers_file"`
@@ -108,15 +135,36 @@ type LogExternalFBCfg struct {
 
 // IsValid validates struct as there's no constructor to enforce it.
 func (l *LogCfg) IsValid() bool {
-	return l.Name != "" && (l.File != "" || l.Folder != "" || l.Systemd != "" || l.EventLog != "" || l.Syslog != nil || l.Tcp != nil || l.Fluentbit != nil)
+	return l.Name != "" && l.hasSource()
+}
+
+// hasLimits returns whether records might be dropped by rate limiting or sampling.
+func (l *LogCfg) hasLimits() bool {
+	return l.SampleRate > 1 || (l.RateLimit != nil && l.RateLimit.RecordsPerSec > 0)
+}
+
+func (l *LogCfg) hasSource() bool {
+	return l.File != "" || l.Folder != "" || l.Systemd != "" || l.EventLog != "" || l.Syslog != nil || l.Tcp != nil || l.Fluentbit != nil
 }
 
 // FBCfg FluentBit automatically generated configuration.
 type FBCfg struct {
-	Inputs      []FBCfgInput
-	Parsers     []FBCfgParser
-	ExternalCfg FBCfgExternal
-	Output      FBCfgOutput
+	Service            FBCfgService
+	Inputs             []FBCfgInput
+	Parsers            []FBCfgParser
+	ExternalCfg        FBCfgExternal
+	Output             FBCfgOutput
+	SamplingScriptPath string // lua script used by sampling filters, written along with the config file
+}
+
+// IsSampling returns whether any of the filters requires the sampling script.
+func (c FBCfg) IsSampling() bool {
+	for _, p := range c.Parsers {
+		if p.Name == fbFilterTypeLua {
+			return true
+		}
+	}
+	return false
 }
 
 // Format will return the FBCfg in the fluent bit config file format.
@@ -134,6 +182,17 @@ func (c FBCfg) Format() (result string, externalCfg FBCfgExternal, err error) {
 	return buf.String(), c.ExternalCfg, nil
 }
 
+// FBCfgService FluentBit Service config block, only used to enable the built-in HTTP monitoring server.
+//  [SERVICE]
+//    HTTP_Server  On
+//    HTTP_Listen  127.0.0.1
+//    HTTP_Port    18020
+type FBCfgService struct {
+	HTTPServer bool
+	HTTPListen string
+	HTTPPort   int
+}
+
 // FBCfgInput FluentBit Input config block for either "tail", "systemd", "winlog" or "syslog" plugins.
 // Tail plugin expected shape:
 //  [INPUT]
@@ -170,16 +229,24 @@ type FBCfgInput struct {
 	TcpBufferSize         int    // plugin: tcp (note that the "tcp" plugin uses Buffer_Size (without "k"s!) instead of Buffer_Max_Size (with "k"s!))
 }
 
-// FBCfgParser FluentBit Parser config block, only "grep" plugin supported.
+// FBCfgParser FluentBit Parser config block, "grep", "record_modifier", "throttle", "modify" and "lua" plugins
+// supported.
 //  [FILTER]
 //    Name   grep
 //    Match  nri-service
 //    Regex  MESSAGE info
 type FBCfgParser struct {
-	Name    string
-	Match   string
-	Regex   string            // plugin: grep
-	Records map[string]string // plugin: record_modifier
+	Name      string
+	Match     string
+	Alias     string
+	Regex     string            // plugin: grep
+	Records   map[string]string // plugin: record_modifier
+	Rate      int               // plugin: throttle
+	Window    int               // plugin: throttle
+	Interval  string            // plugin: throttle
+	Condition string            // plugin: modify
+	Set       string            // plugin: modify
+	Call      string            // plugin: lua
 }
 
 // FBCfgOutput FluentBit Output config block, supporting NR output plugin.
@@ -210,7 +277,12 @@ func NewFBConf(loggingCfgs LogsCfg, logFwdCfg *config.LogForward, entityGUID, ho
 		Parsers: []FBCfgParser{},
 	}
 
+	monitoring := logFwdCfg.Monitoring.Enabled
 	for _, block := range loggingCfgs {
+		if block.hasLimits() && !monitoring {
+			cfgLogger.WithField("name", block.Name).Warn("Log forwarder monitoring is disabled, the records dropped by rate limiting and sampling won't be reported.")
+		}
+
 		input, filters, external, err := parseConfigBlock(block, logFwdCfg.HomeDir)
 		if err != nil {
 			return
@@ -234,7 +306,7 @@ func NewFBConf(loggingCfgs LogsCfg, logFwdCfg *config.LogForward, entityGUID, ho
 	}
 
 	// This record_modifier FILTER adds common attributes for all the log records
-	fb.Parsers = append(fb.Parsers, FBCfgParser{
+	agentFilter := FBCfgParser{
 		Name:  fbFilterTypeRecordModifier,
 		Match: "*",
 		Records: map[string]string{
@@ -242,7 +314,12 @@ func NewFBConf(loggingCfgs LogsCfg, logFwdCfg *config.LogForward, entityGUID, ho
 			rAttPluginType: logRecordModifierSource,
 			rAttHostname:   hostname,
 		},
-	})
+	}
+	if monitoring {
+		fb.Service = newMonitoringService(logFwdCfg.Monitoring.Port)
+		agentFilter.Alias = fbAgentFilterAlias
+	}
+	fb.Parsers = append(fb.Parsers, agentFilter)
 
 	// Newrelic OUTPUT plugin will send all the collected logs to Vortex
 	fb.Output = newNROutput(logFwdCfg)
@@ -289,6 +366,7 @@ func parseFileInput(l LogCfg, dbPath string) (input FBCfgInput, filters []FBCfgP
 	input = newFileInput(l.File, dbPath, l.Name, getBufferMaxSize(l))
 	filters = append(filters, newRecordModifierFilterForInput(l.Name, fbInputTypeTail, l.Attributes))
 	filters = parsePattern(l, fbGrepFieldForTail, filters)
+	filters = parseLimits(l, fbGrepFieldForTail, filters)
 	return input, filters
 }
 
@@ -299,6 +377,7 @@ func parseFolderInput(l LogCfg, dbPath string) (input FBCfgInput, filters []FBCf
 	input = newFileInput(folderPath, dbPath, l.Name, getBufferMaxSize(l))
 	filters = append(filters, newRecordModifierFilterForInput(l.Name, fbInputTypeTail, l.Attributes))
 	filters = parsePattern(l, fbGrepFieldForTail, filters)
+	filters = parseLimits(l, fbGrepFieldForTail, filters)
 	return input, filters
 }
 
@@ -307,6 +386,7 @@ func parseSystemdInput(l LogCfg, dbPath string) (input FBCfgInput, filters []FBC
 	input = newSystemdInput(l.Systemd, dbPath, l.Name)
 	filters = append(filters, newRecordModifierFilterForInput(l.Name, fbInputTypeSystemd, l.Attributes))
 	filters = parsePattern(l, fbGrepFieldForSystemd, filters)
+	filters = parseLimits(l, fbGrepFieldForSystemd, filters)
 	return input, filters
 }
 
@@ -314,6 +394,7 @@ func parseSystemdInput(l LogCfg, dbPath string) (input FBCfgInput, filters []FBC
 func parseEventLogInput(l LogCfg, dbPath string) (input FBCfgInput, filters []FBCfgParser) {
 	input = newWindowsEventlogInput(l.EventLog, dbPath, l.Name)
 	filters = append(filters, newRecordModifierFilterForInput(l.Name, fbInputTypeWinlog, l.Attributes))
+	filters = parseLimits(l, "", filters)
 	return input, filters
 }
 
@@ -326,6 +407,7 @@ func parseSyslogInput(l LogCfg) (input FBCfgInput, filters []FBCfgParser, err er
 	input = slIn
 	filters = append(filters, newRecordModifierFilterForInput(l.Name, fbInputTypeSyslog, l.Attributes))
 	filters = parsePattern(l, fbGrepFieldForSyslog, filters)
+	filters = parseLimits(l, fbGrepFieldForSyslog, filters)
 	return input, filters, nil
 }
 
@@ -340,6 +422,9 @@ func parseTcpInput(l LogCfg) (input FBCfgInput, filters []FBCfgParser, err error
 	filters = append(filters, newRecordModifierFilterForInput(l.Name, fbInputTypeTcp, l.Attributes))
 	if l.Tcp.Format == "none" {
 		filters = parsePattern(l, fbGrepFieldForTcpPlain, filters)
+		filters = parseLimits(l, fbGrepFieldForTcpPlain, filters)
+	} else {
+		filters = parseLimits(l, "", filters)
 	}
 	return input, filters, nil
 }
@@ -351,6 +436,26 @@ func parsePattern(l LogCfg, fluentBitGrepField string, filters []FBCfgParser) []
 	return filters
 }
 
+// parseLimits appends the sampling and rate limiting filters, in that order. The sampling allow pattern is evaluated
+// against the message field, when the input provides a known one.
+func parseLimits(l LogCfg, fluentBitMessageField string, filters []FBCfgParser) []FBCfgParser {
+	if l.SampleRate > 1 {
+		if err := validateSampleAllowPattern(l.SampleAllow); err != nil {
+			// sampling out the records meant to be kept is worse than not sampling at all
+			cfgLogger.WithError(err).WithField("name", l.Name).Warn("invalid sample_allow_pattern, sampling will be disabled")
+		} else {
+			if l.SampleAllow != "" && fluentBitMessageField == "" {
+				cfgLogger.WithField("name", l.Name).Warn("sample_allow_pattern is not supported for this input and will be ignored")
+			}
+			filters = append(filters, newSamplingFilters(l, fluentBitMessageField)...)
+		}
+	}
+	if l.RateLimit != nil && l.RateLimit.RecordsPerSec > 0 {
+		filters = append(filters, newThrottleFilter(l.Name, *l.RateLimit))
+	}
+	return filters
+}
+
 func newFBExternalConfig(l LogExternalFBCfg) FBCfgExternal {
 	return FBCfgExternal{
 		CfgFilePath:     l.CfgPath,
@@ -481,6 +586,58 @@ func newGrepFilter(l LogCfg, fluentBitGrepField string) FBCfgParser {
 	}
 }
 
+// newThrottleFilter limits the average rate over a window of 1 second intervals, the window size allows the burst.
+func newThrottleFilter(tag string, r LogRateLimitCfg) FBCfgParser {
+	window := 1
+	if r.Burst > r.RecordsPerSec {
+		window = (r.Burst + r.RecordsPerSec - 1) / r.RecordsPerSec
+	}
+
+	return FBCfgParser{
+		Name:     fbFilterTypeThrottle,
+		Match:    tag,
+		Alias:    fbThrottleAliasPrefix + tag,
+		Rate:     r.RecordsPerSec,
+		Window:   window,
+		Interval: fbThrottleInterval,
+	}
+}
+
+// newSamplingFilters flags the records to be sampled with the sampling rate, which is read and removed by the lua
+// script. When an allow pattern is provided, only records not matching it get flagged.
+func newSamplingFilters(l LogCfg, fluentBitMessageField string) []FBCfgParser {
+	flag := FBCfgParser{
+		Name:  fbFilterTypeModify,
+		Match: l.Name,
+		Set:   fmt.Sprintf("%s %d", fbSamplingRateField, l.SampleRate),
+	}
+	if l.SampleAllow != "" && fluentBitMessageField != "" {
+		flag.Condition = fmt.Sprintf("Key_value_does_not_match %s %s", fluentBitMessageField, l.SampleAllow)
+	}
+
+	return []FBCfgParser{
+		flag,
+		{
+			Name:  fbFilterTypeLua,
+			Match: l.Name,
+			Alias: fbSamplingAliasPrefix + l.Name,
+			Call:  fbSamplingLuaCall,
+		},
+	}
+}
+
+// validateSampleAllowPattern checks the sampling allow pattern is a valid regular expression that can be written
+// into a single Fluent Bit configuration entry, which splits its values by whitespace.
+func validateSampleAllowPattern(pattern string) error {
+	if strings.IndexFunc(pattern, unicode.IsSpace) >= 0 {
+		return fmt.Errorf("sample_allow_pattern cannot contain whitespace, use \\s instead")
+	}
+	if _, err := regexp.Compile(pattern); err != nil {
+		return fmt.Errorf("invalid sample_allow_pattern: %s", err)
+	}
+	return nil
+}
+
 func newNROutput(cfg *config.LogForward) FBCfgOutput {
 	ret := FBCfgOutput{
 		Name:              "newrelic",
@@ -504,6 +661,14 @@ func newNROutput(cfg *config.LogForward) FBCfgOutput {
 	return ret
 }
 
+func newMonitoringService(port int) FBCfgService {
+	return FBCfgService{
+		HTTPServer: true,
+		HTTPListen: fbMonitoringListen,
+		HTTPPort:   port,
+	}
+}
+
 func getBufferMaxSize(l LogCfg) int {
 	bufferSize := l.MaxLineKb
 	if bufferSize == 0 {
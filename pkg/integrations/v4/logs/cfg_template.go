@@ -2,7 +2,14 @@
 // SPDX-License-Identifier: Apache-2.0
 package logs
 
-var fbConfigFormat = `{{- range .Inputs }}
+var fbConfigFormat = `{{- if .Service.HTTPServer }}
+[SERVICE]
+    HTTP_Server  On
+    HTTP_Listen  {{ .Service.HTTPListen }}
+    HTTP_Port    {{ .Service.HTTPPort }}
+{{ end -}}
+
+{{- range .Inputs }}
 [INPUT]
     Name {{ .Name }}
     {{- if .Path }}
@@ -72,9 +79,27 @@ var fbConfigFormat = `{{- range .Inputs }}
     {{- if .Match }}
     Match {{ .Match }}
     {{- end }}
+    {{- if .Alias }}
+    Alias {{ .Alias }}
+    {{- end }}
     {{- if .Regex }}
     Regex {{ .Regex }}
     {{- end }}
+    {{- if .Rate }}
+    Rate     {{ .Rate }}
+    Window   {{ .Window }}
+    Interval {{ .Interval }}
+    {{- end }}
+    {{- if .Condition }}
+    Condition {{ .Condition }}
+    {{- end }}
+    {{- if .Set }}
+    Set {{ .Set }}
+    {{- end }}
+    {{- if .Call }}
+    script {{ $.SamplingScriptPath }}
+    call   {{ .Call }}
+    {{- end }}
     {{- if .Records }}
         {{- range $key, $value := .Records }}
     Record {{ $key }} {{ $value }}
@@ -112,3 +137,22 @@ var fbConfigFormat = `{{- range .Inputs }}
 {{- if .ExternalCfg.CfgFilePath }}
 @INCLUDE {{ .ExternalCfg.CfgFilePath }}
 {{ end -}}`
+
+// fbSamplingScript keeps 1 out of every N records flagged with the sampling rate field, per tag.
+var fbSamplingScript = `local counters = {}
+
+function nr_sample(tag, timestamp, record)
+    local rate = tonumber(record["nr.sample_rate"])
+    if rate == nil then
+        return 0, timestamp, record
+    end
+    record["nr.sample_rate"] = nil
+
+    local count = counters[tag] or 0
+    counters[tag] = count + 1
+    if count % rate ~= 0 then
+        return -1, timestamp, record
+    end
+    return 1, timestamp, record
+end
+`
@@ -22,6 +22,7 @@ func TestNewFBConf(t *testing.T) {
 			CABundleDir:       "/cabundles",
 			ValidateCerts:     true,
 		},
+		Monitoring: config.LogForwardMonitoring{Port: 2020},
 	}
 
 	parserEntityBlock := FBCfgParser{
@@ -659,6 +660,110 @@ func TestNewFBConf(t *testing.T) {
 			},
 			Output: outputBlock,
 		}},
+		{"input file + pattern + sampling + rate limit", LogsCfg{
+			{
+				Name:        "log-file",
+				File:        "file.path",
+				Pattern:     "foo",
+				SampleRate:  10,
+				SampleAllow: "ERROR",
+				RateLimit:   &LogRateLimitCfg{RecordsPerSec: 100, Burst: 250},
+			},
+		}, FBCfg{
+			Inputs: []FBCfgInput{
+				{
+					Name:          "tail",
+					Tag:           "log-file",
+					DB:            dbDbPath,
+					Path:          "file.path",
+					BufferMaxSize: "128k",
+					SkipLongLines: "On",
+				},
+			},
+			Parsers: []FBCfgParser{
+				inputRecordModifier("tail", "log-file"),
+				{
+					Name:  "grep",
+					Match: "log-file",
+					Regex: "log foo",
+				},
+				{
+					Name:      "modify",
+					Match:     "log-file",
+					Condition: "Key_value_does_not_match log ERROR",
+					Set:       "nr.sample_rate 10",
+				},
+				{
+					Name:  "lua",
+					Match: "log-file",
+					Alias: "nr_sample_log-file",
+					Call:  "nr_sample",
+				},
+				{
+					Name:     "throttle",
+					Match:    "log-file",
+					Alias:    "nr_throttle_log-file",
+					Rate:     100,
+					Window:   3,
+					Interval: "1s",
+				},
+				parserEntityBlock,
+			},
+			Output: outputBlock,
+		}},
+		{"input file + sampling with invalid allow pattern", LogsCfg{
+			{
+				Name:        "log-file",
+				File:        "file.path",
+				SampleRate:  10,
+				SampleAllow: "ERROR\n    Name grep",
+			},
+		}, FBCfg{
+			Inputs: []FBCfgInput{
+				{
+					Name:          "tail",
+					Tag:           "log-file",
+					DB:            dbDbPath,
+					Path:          "file.path",
+					BufferMaxSize: "128k",
+					SkipLongLines: "On",
+				},
+			},
+			Parsers: []FBCfgParser{
+				inputRecordModifier("tail", "log-file"),
+				parserEntityBlock,
+			},
+			Output: outputBlock,
+		}},
+		{"input systemd + rate limit without burst", LogsCfg{
+			{
+				Name:      "some_system",
+				Systemd:   "service_name",
+				RateLimit: &LogRateLimitCfg{RecordsPerSec: 50},
+			},
+		}, FBCfg{
+			Inputs: []FBCfgInput{
+				{
+					Name:           "systemd",
+					Tag:            "some_system",
+					DB:             dbDbPath,
+					Systemd_Filter: "_SYSTEMD_UNIT=service_name.service",
+				},
+			},
+			Parsers: []FBCfgParser{
+				inputRecordModifier("systemd", "some_system"),
+				{
+					Name:     "throttle",
+					Match:    "some_system",
+					Alias:    "nr_throttle_some_system",
+					Rate:     50,
+					Window:   1,
+					Interval: "1s",
+				},
+				parserEntityBlock,
+			},
+			Output: outputBlock,
+		}},
 	}
 
 	for _, tt := range tests {
@@ -670,6 +775,35 @@ func TestNewFBConf(t *testing.T) {
 	}
 }
 
+func TestNewFBConf_Monitoring(t *testing.T) {
+	logsCfg := LogsCfg{{Name: "log-file", File: "file.path"}}
+
+	tests := []struct {
+		name        string
+		enabled     bool
+		wantService FBCfgService
+		wantAlias   string
+	}{
+		{"enabled", true, newMonitoringService(18020), fbAgentFilterAlias},
+		{"disabled", false, FBCfgService{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logFwdCfg := &config.LogForward{
+				License:    "licenseKey",
+				Monitoring: config.LogForwardMonitoring{Enabled: tt.enabled, Port: 18020},
+			}
+
+			fbConf, err := NewFBConf(logsCfg, logFwdCfg, "0", "")
+			assert.NoError(t, err)
+
+			assert.Equal(t, tt.wantService, fbConf.Service)
+			// the agent attributes filter is the last one
+			assert.Equal(t, tt.wantAlias, fbConf.Parsers[len(fbConf.Parsers)-1].Alias)
+		})
+	}
+}
+
 func TestFBCfgFormat(t *testing.T) {
 	expected := `
 [INPUT]
@@ -908,6 +1042,106 @@ func TestFBCfgFormatWithHostname(t *testing.T) {
 	assert.Equal(t, expected, result)
 }
 
+func TestFBCfgFormatWithMonitoring(t *testing.T) {
+	expected := `
+[SERVICE]
+    HTTP_Server  On
+    HTTP_Listen  127.0.0.1
+    HTTP_Port    2020
+
+[INPUT]
+    Name tail
+    Path file.foo
+    Tag  some-file
+
+[OUTPUT]
+    Name                newrelic
+    Match               *
+    licenseKey          licenseKey
+    validateProxyCerts  false
+`
+
+	fbCfg := FBCfg{
+		Service: newMonitoringService(2020),
+		Inputs: []FBCfgInput{
+			{
+				Name: "tail",
+				Tag:  "some-file",
+				Path: "file.foo",
+			},
+		},
+		Output: FBCfgOutput{
+			Name:       "newrelic",
+			Match:      "*",
+			LicenseKey: "licenseKey",
+		},
+	}
+
+	result, _, err := fbCfg.Format()
+	assert.Empty(t, err)
+	assert.Equal(t, expected, result)
+}
+
+func TestFBCfgFormatWithLimits(t *testing.T) {
+	expected := `
+[INPUT]
+    Name tail
+    Path file.foo
+    Tag  some-file
+
+[FILTER]
+    Name  modify
+    Match some-file
+    Set nr.sample_rate 5
+
+[FILTER]
+    Name  lua
+    Match some-file
+    Alias nr_sample_some-file
+    script /tmp/nr_fb_sampling
+    call   nr_sample
+
+[FILTER]
+    Name  throttle
+    Match some-file
+    Alias nr_throttle_some-file
+    Rate     10
+    Window   2
+    Interval 1s
+
+[OUTPUT]
+    Name                newrelic
+    Match               *
+    licenseKey          licenseKey
+    validateProxyCerts  false
+`
+
+	fbCfg := FBCfg{
+		Inputs: []FBCfgInput{
+			{
+				Name: "tail",
+				Tag:  "some-file",
+				Path: "file.foo",
+			},
+		},
+		Parsers: append(
+			newSamplingFilters(LogCfg{Name: "some-file", SampleRate: 5}, ""),
+			newThrottleFilter("some-file", LogRateLimitCfg{RecordsPerSec: 10, Burst: 20}),
+		),
+		Output: FBCfgOutput{
+			Name:       "newrelic",
+			Match:      "*",
+			LicenseKey: "licenseKey",
+		},
+		SamplingScriptPath: "/tmp/nr_fb_sampling",
+	}
+
+	result, _, err := fbCfg.Format()
+	assert.Empty(t, err)
+	assert.Equal(t, expected, result)
+	assert.True(t, fbCfg.IsSampling())
+}
+
 func TestSyslogCorrectFormat(t *testing.T) {
 	tests := []struct {
 		name      string
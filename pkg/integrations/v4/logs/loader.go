@@ -5,7 +5,10 @@ package logs
 import (
 	"errors"
 	"io/ioutil"
+	"net"
 	"path/filepath"
+	"strconv"
+	"sync/atomic"
 
 	"github.com/newrelic/infrastructure-agent/pkg/log"
 
@@ -27,6 +30,7 @@ type CfgLoader struct {
 	loadFilesFn      fs.FilesInFolderFn
 	agentIDFn        id.Provide
 	hostnameResolver hostname.Resolver
+	monitoring       int32 // whether the last loaded config enables the monitoring server, accessed atomically
 }
 
 func NewFolderLoader(c config.LogForward, agentIDFn id.Provide, hostnameResolver hostname.Resolver) *CfgLoader {
@@ -42,6 +46,16 @@ func (l *CfgLoader) GetConfigDir() string {
 	return l.config.ConfigsDir
 }
 
+func (l *CfgLoader) GetMonitoringCfg() config.LogForwardMonitoring {
+	return l.config.Monitoring
+}
+
+// IsMonitoring returns whether the last loaded config enables the Fluent Bit monitoring server, as it's disabled
+// when its port is not available.
+func (l *CfgLoader) IsMonitoring() bool {
+	return atomic.LoadInt32(&l.monitoring) == 1
+}
+
 // LoadAll loads and parses the logging configuration. It returns ok=false in case an error occurred, which should block
 // the start of the log forwarding feature.
 func (l *CfgLoader) LoadAll() (c FBCfg, ok bool) {
@@ -162,9 +176,50 @@ func (l *CfgLoader) LoadAndFormat() (string, FBCfgExternal, error) {
 	if !ok {
 		return "", FBCfgExternal{}, errors.New("failed to load log configs")
 	}
+
+	var monitoring int32
+	if fbConfig.Service.HTTPServer {
+		if err := checkPortAvailable(fbConfig.Service.HTTPListen, fbConfig.Service.HTTPPort); err != nil {
+			loaderLogger.WithError(err).WithField("port", fbConfig.Service.HTTPPort).
+				Error("log forwarder monitoring port is not available, disabling the monitoring: please set log_forwarder_monitoring_port to a free port")
+			fbConfig.Service = FBCfgService{}
+		} else {
+			monitoring = 1
+		}
+	}
+	atomic.StoreInt32(&l.monitoring, monitoring)
+
+	if fbConfig.IsSampling() {
+		scriptPath, err := saveSamplingScript(l.config.HomeDir)
+		if err != nil {
+			return "", FBCfgExternal{}, err
+		}
+		fbConfig.SamplingScriptPath = scriptPath
+	}
+
 	return fbConfig.Format()
 }
 
+// checkPortAvailable returns an error when the address can't be bound, so Fluent Bit monitoring server would fail to
+// start, or another process would be scraped instead.
+func checkPortAvailable(listen string, port int) error {
+	l, err := net.Listen("tcp", net.JoinHostPort(listen, strconv.Itoa(port)))
+	if err != nil {
+		return err
+	}
+	return l.Close()
+}
+
+// saveSamplingScript writes the lua sampling script into the logging home dir, along with the Fluent Bit DB,
+// returning its path. The script doesn't depend on the configuration, so the same file is overwritten on each load.
+func saveSamplingScript(logsHomeDir string) (string, error) {
+	scriptPath := filepath.Join(logsHomeDir, samplingScriptFileName)
+	if err := ioutil.WriteFile(scriptPath, []byte(fbSamplingScript), 0644); err != nil {
+		return "", err
+	}
+	return scriptPath, nil
+}
+
 func (l *CfgLoader) parseYAML(content []byte) (c LogsCfg, err error) {
 	var y YAML
 	if err = yaml.Unmarshal(content, &y); err != nil {
@@ -4,8 +4,10 @@ package logs
 
 import (
 	"io/ioutil"
+	"net"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/newrelic/infrastructure-agent/pkg/config"
@@ -416,6 +418,74 @@ logs:
 	}
 }
 
+func TestCfgLoader_LoadAndFormat_IsMonitoring(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-load-monitoring")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	addFile(t, dir, "logs.yml", "logs:\n  - name: foo\n    file: /file/path\n    rate_limit:\n      records_per_sec: 10\n")
+
+	// GIVEN a log source setting a rate limit
+	conf := newTestConf(dir, disabledTroubleshootCfg)
+	for _, enabled := range []bool{false, true} {
+		// WHEN the monitoring is enabled or disabled in the agent config
+		conf.Monitoring.Enabled = enabled
+		loader := NewFolderLoader(conf, idnProvide, hostnameProvider)
+		cfg, _, err := loader.LoadAndFormat()
+		require.NoError(t, err)
+
+		// THEN the agent config is respected, even if the dropped records are not reported
+		assert.Equal(t, enabled, loader.IsMonitoring())
+		assert.Equal(t, enabled, strings.Contains(cfg, "HTTP_Server  On"))
+	}
+}
+
+func TestCfgLoader_LoadAndFormat_MonitoringPortInUse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-load-monitoring")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	addFile(t, dir, "logs.yml", "logs:\n  - name: foo\n    file: /file/path\n")
+
+	// GIVEN the monitoring port is already bound by another process
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	conf := newTestConf(dir, disabledTroubleshootCfg)
+	conf.Monitoring.Enabled = true
+	conf.Monitoring.Port = l.Addr().(*net.TCPAddr).Port
+	loader := NewFolderLoader(conf, idnProvide, hostnameProvider)
+
+	// WHEN the config is loaded
+	cfg, _, err := loader.LoadAndFormat()
+	require.NoError(t, err)
+
+	// THEN the Fluent Bit monitoring server is not enabled, so the other process is not scraped
+	assert.False(t, loader.IsMonitoring())
+	assert.NotContains(t, cfg, "HTTP_Server")
+}
+
+func TestSaveSamplingScript(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logging")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// WHEN the sampling script is saved on several config loads
+	first, err := saveSamplingScript(dir)
+	require.NoError(t, err)
+	second, err := saveSamplingScript(dir)
+	require.NoError(t, err)
+
+	// THEN the same file in the logging home dir is reused
+	assert.Equal(t, filepath.Join(dir, "nr_fb_sampling.lua"), first)
+	assert.Equal(t, first, second)
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+	content, err := ioutil.ReadFile(first)
+	require.NoError(t, err)
+	assert.Equal(t, fbSamplingScript, string(content))
+}
+
 func newTestConf(folder string, troubleCfg config.Troubleshoot) config.LogForward {
 	cfg := &config.Config{
 		LoggingBinDir:     "/var/db/newrelic-infra/newrelic-integrations/logging",
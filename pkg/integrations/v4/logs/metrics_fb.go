@@ -0,0 +1,157 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package logs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// FluentBit monitoring API, served by the built-in HTTP server.
+const (
+	fbMetricsPath    = "/api/v1/metrics"
+	fbMetricsTimeout = 5 * time.Second
+)
+
+// ErrForeignFBMonitoring is returned when the monitoring API doesn't belong to the agent log forwarder, but to
+// another Fluent Bit listening on the same port.
+var ErrForeignFBMonitoring = errors.New("log-forwarder monitoring API is served by a Fluent Bit not run by the agent, please set log_forwarder_monitoring_port to a free port")
+
+// FBMetrics FluentBit counters per plugin instance (e.g. "tail.0", "newrelic.0"), as exposed by the monitoring API.
+// Counters are cumulative since the FluentBit process started.
+type FBMetrics struct {
+	Input  map[string]FBInputMetrics  `json:"input"`
+	Filter map[string]FBFilterMetrics `json:"filter"`
+	Output map[string]FBOutputMetrics `json:"output"`
+}
+
+// FBInputMetrics counters for a single input plugin instance.
+type FBInputMetrics struct {
+	Records uint64 `json:"records"`
+	Bytes   uint64 `json:"bytes"`
+}
+
+// FBFilterMetrics counters for a single filter plugin instance.
+type FBFilterMetrics struct {
+	DropRecords uint64 `json:"drop_records"`
+	AddRecords  uint64 `json:"add_records"`
+}
+
+// FBOutputMetrics counters for a single output plugin instance.
+type FBOutputMetrics struct {
+	ProcRecords    uint64 `json:"proc_records"`
+	ProcBytes      uint64 `json:"proc_bytes"`
+	Errors         uint64 `json:"errors"`
+	Retries        uint64 `json:"retries"`
+	RetriesFailed  uint64 `json:"retries_failed"`
+	DroppedRecords uint64 `json:"dropped_records"`
+	RetriedRecords uint64 `json:"retried_records"`
+}
+
+// FBMetricsTotals FluentBit counters aggregated across all the plugin instances.
+type FBMetricsTotals struct {
+	RecordsIn         uint64
+	BytesIn           uint64
+	RecordsOut        uint64
+	BytesOut          uint64
+	Errors            uint64
+	Retries           uint64
+	RetriesFailed     uint64
+	DroppedRecords    uint64
+	ThrottledRecords  uint64 // dropped by rate limiting
+	SampledOutRecords uint64 // dropped by sampling
+}
+
+// Totals aggregates the counters of every plugin instance. Only the agent rate limiting and sampling filters are
+// considered, any other filter is expected to drop records on purpose.
+func (m FBMetrics) Totals() (t FBMetricsTotals) {
+	for _, in := range m.Input {
+		t.RecordsIn += in.Records
+		t.BytesIn += in.Bytes
+	}
+	for name, filter := range m.Filter {
+		if strings.HasPrefix(name, fbThrottleAliasPrefix) {
+			t.ThrottledRecords += filter.DropRecords
+		} else if strings.HasPrefix(name, fbSamplingAliasPrefix) {
+			t.SampledOutRecords += filter.DropRecords
+		}
+	}
+	for _, out := range m.Output {
+		t.RecordsOut += out.ProcRecords
+		t.BytesOut += out.ProcBytes
+		t.Errors += out.Errors
+		t.Retries += out.Retries
+		t.RetriesFailed += out.RetriesFailed
+		t.DroppedRecords += out.DroppedRecords
+	}
+	return
+}
+
+// Sub returns the counters increase from a previous snapshot. As counters restart from zero along with FluentBit,
+// whenever any of them decreases the previous snapshot is discarded and the current values are returned.
+func (t FBMetricsTotals) Sub(prev FBMetricsTotals) FBMetricsTotals {
+	if t.RecordsIn < prev.RecordsIn || t.BytesIn < prev.BytesIn ||
+		t.RecordsOut < prev.RecordsOut || t.BytesOut < prev.BytesOut ||
+		t.Errors < prev.Errors || t.Retries < prev.Retries ||
+		t.RetriesFailed < prev.RetriesFailed || t.DroppedRecords < prev.DroppedRecords ||
+		t.ThrottledRecords < prev.ThrottledRecords || t.SampledOutRecords < prev.SampledOutRecords {
+		return t
+	}
+	return FBMetricsTotals{
+		RecordsIn:         t.RecordsIn - prev.RecordsIn,
+		BytesIn:           t.BytesIn - prev.BytesIn,
+		RecordsOut:        t.RecordsOut - prev.RecordsOut,
+		BytesOut:          t.BytesOut - prev.BytesOut,
+		Errors:            t.Errors - prev.Errors,
+		Retries:           t.Retries - prev.Retries,
+		RetriesFailed:     t.RetriesFailed - prev.RetriesFailed,
+		DroppedRecords:    t.DroppedRecords - prev.DroppedRecords,
+		ThrottledRecords:  t.ThrottledRecords - prev.ThrottledRecords,
+		SampledOutRecords: t.SampledOutRecords - prev.SampledOutRecords,
+	}
+}
+
+// FBMetricsFetcher retrieves the current FluentBit counters.
+type FBMetricsFetcher func() (FBMetrics, error)
+
+// NewFBMetricsFetcher returns a fetcher querying the FluentBit monitoring API listening on the provided local port.
+// Metrics not including the agent filters are rejected, as they come from a FluentBit not run by the agent.
+func NewFBMetricsFetcher(port int) FBMetricsFetcher {
+	client := &http.Client{Timeout: fbMetricsTimeout}
+	url := fmt.Sprintf("http://%s:%d%s", fbMonitoringListen, port, fbMetricsPath)
+
+	return func() (FBMetrics, error) {
+		resp, err := client.Get(url)
+		if err != nil {
+			return FBMetrics{}, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return FBMetrics{}, fmt.Errorf("unexpected monitoring API status code: %d", resp.StatusCode)
+		}
+
+		m, err := ParseFBMetrics(resp.Body)
+		if err != nil {
+			return FBMetrics{}, err
+		}
+		if _, ok := m.Filter[fbAgentFilterAlias]; !ok {
+			return FBMetrics{}, ErrForeignFBMonitoring
+		}
+		return m, nil
+	}
+}
+
+// ParseFBMetrics decodes a FluentBit monitoring API metrics payload.
+func ParseFBMetrics(r io.Reader) (m FBMetrics, err error) {
+	if err = json.NewDecoder(r).Decode(&m); err != nil {
+		err = errors.Wrap(err, "cannot decode log-forwarder metrics")
+	}
+	return
+}
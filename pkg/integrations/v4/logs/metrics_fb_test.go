@@ -0,0 +1,112 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package logs
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fbMetricsPayload = `{
+  "input": {
+    "tail.0": {"records": 120, "bytes": 4096},
+    "systemd.1": {"records": 30, "bytes": 1024}
+  },
+  "filter": {
+    "grep.0": {"drop_records": 10, "add_records": 0},
+    "nr_throttle_app": {"drop_records": 4, "add_records": 0},
+    "nr_sample_app": {"drop_records": 9, "add_records": 0},
+    "nr_agent_attributes": {"drop_records": 0, "add_records": 0}
+  },
+  "output": {
+    "newrelic.0": {
+      "proc_records": 100,
+      "proc_bytes": 3000,
+      "errors": 2,
+      "retries": 5,
+      "retries_failed": 1,
+      "dropped_records": 3,
+      "retried_records": 7
+    }
+  }
+}`
+
+func TestParseFBMetrics(t *testing.T) {
+	m, err := ParseFBMetrics(strings.NewReader(fbMetricsPayload))
+	require.NoError(t, err)
+
+	assert.Equal(t, FBInputMetrics{Records: 120, Bytes: 4096}, m.Input["tail.0"])
+	assert.Equal(t, FBOutputMetrics{
+		ProcRecords:    100,
+		ProcBytes:      3000,
+		Errors:         2,
+		Retries:        5,
+		RetriesFailed:  1,
+		DroppedRecords: 3,
+		RetriedRecords: 7,
+	}, m.Output["newrelic.0"])
+
+	assert.Equal(t, FBMetricsTotals{
+		RecordsIn:      150,
+		BytesIn:        5120,
+		RecordsOut:     100,
+		BytesOut:       3000,
+		Errors:         2,
+		Retries:        5,
+		RetriesFailed:  1,
+		DroppedRecords: 3,
+
+		ThrottledRecords:  4,
+		SampledOutRecords: 9,
+	}, m.Totals())
+}
+
+func TestParseFBMetrics_Malformed(t *testing.T) {
+	_, err := ParseFBMetrics(strings.NewReader(`{"input":`))
+	assert.Error(t, err)
+}
+
+func TestNewFBMetricsFetcher(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		wantErr error
+	}{
+		{"agent log forwarder", fbMetricsPayload, nil},
+		{"foreign Fluent Bit", `{"input":{"tail.0":{"records":1,"bytes":1}},"filter":{},"output":{}}`, ErrForeignFBMonitoring},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, fbMetricsPath, r.URL.Path)
+				_, _ = w.Write([]byte(tt.payload))
+			}))
+			defer server.Close()
+
+			fetch := NewFBMetricsFetcher(server.Listener.Addr().(*net.TCPAddr).Port)
+			m, err := fetch()
+
+			assert.Equal(t, tt.wantErr, err)
+			if tt.wantErr == nil {
+				assert.Equal(t, uint64(150), m.Totals().RecordsIn)
+			}
+		})
+	}
+}
+
+func TestFBMetricsTotals_Sub(t *testing.T) {
+	prev := FBMetricsTotals{RecordsIn: 10, RecordsOut: 8, Retries: 1}
+
+	delta := FBMetricsTotals{RecordsIn: 15, RecordsOut: 12, Retries: 1}.Sub(prev)
+	assert.Equal(t, FBMetricsTotals{RecordsIn: 5, RecordsOut: 4}, delta)
+
+	// counters were restarted along with FluentBit
+	restarted := FBMetricsTotals{RecordsIn: 3, RecordsOut: 2}
+	assert.Equal(t, restarted, restarted.Sub(prev))
+}
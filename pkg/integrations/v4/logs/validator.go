@@ -0,0 +1,208 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package logs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/fs"
+	"gopkg.in/yaml.v2"
+)
+
+// Placeholders for values not available nor relevant on dry-runs.
+const (
+	maskedLicenseKey          = "<license-key>"
+	samplingScriptPlaceholder = "<sampling-script>"
+)
+
+// CfgError is a validation issue found on a logging configuration file or entry.
+type CfgError struct {
+	File string
+	Name string // empty for file level issues
+	Err  error
+}
+
+func (e CfgError) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("%s: %s", e.File, e.Err)
+	}
+	return fmt.Sprintf("%s: log %q: %s", e.File, e.Name, e.Err)
+}
+
+// Validate checks the entry contents against the host: pattern compilation, input formats and the
+// existence and readability of the referred files.
+func (l *LogCfg) Validate() (errs []error) {
+	if l.Name == "" {
+		errs = append(errs, fmt.Errorf("missing name"))
+	}
+	if !l.hasSource() {
+		errs = append(errs, fmt.Errorf("no log source defined (file, folder, systemd, eventlog, syslog, tcp or fluentbit)"))
+		return
+	}
+
+	if l.Pattern != "" {
+		if _, err := regexp.Compile(l.Pattern); err != nil {
+			errs = append(errs, fmt.Errorf("invalid pattern: %s", err))
+		}
+	}
+
+	if l.SampleRate < 0 {
+		errs = append(errs, fmt.Errorf("sample_rate cannot be negative"))
+	}
+	if err := validateSampleAllowPattern(l.SampleAllow); err != nil {
+		errs = append(errs, err)
+	}
+	if l.RateLimit != nil && (l.RateLimit.RecordsPerSec <= 0 || l.RateLimit.Burst < 0) {
+		errs = append(errs, fmt.Errorf("rate_limit: records_per_sec must be positive and burst cannot be negative"))
+	}
+
+	if l.File != "" {
+		errs = append(errs, validateFilePath(l.File)...)
+	}
+	if l.Folder != "" {
+		if err := validateReadable(l.Folder, true); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if l.Syslog != nil {
+		if _, err := newSyslogInput(*l.Syslog, l.Name, getBufferMaxSize(*l)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if l.Tcp != nil {
+		if _, err := newTcpInput(*l.Tcp, l.Name, getBufferMaxSize(*l)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if l.Fluentbit != nil {
+		if l.Fluentbit.CfgPath == "" {
+			errs = append(errs, fmt.Errorf("fluentbit: missing config_file"))
+		} else if err := validateReadable(l.Fluentbit.CfgPath, false); err != nil {
+			errs = append(errs, err)
+		}
+		if l.Fluentbit.ParsersPath != "" {
+			if err := validateReadable(l.Fluentbit.ParsersPath, false); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return
+}
+
+// ValidateAll loads every logging configuration file, reporting all the issues found instead of skipping invalid
+// entries, and returns the FluentBit configuration generated out of the valid ones.
+func (l *CfgLoader) ValidateAll() (fbCfg FBCfg, errs []error) {
+	if l.config.ConfigsDir == "" {
+		return FBCfg{}, []error{fmt.Errorf("invalid config, lacking config folder")}
+	}
+
+	files, err := l.loadFilesFn(l.config.ConfigsDir)
+	if err != nil && err != fs.ErrFilesNotFound {
+		return FBCfg{}, []error{CfgError{File: l.config.ConfigsDir, Err: err}}
+	}
+
+	var valid LogsCfg
+	namesByFile := map[string]string{}
+	for _, f := range files {
+		if ext := filepath.Ext(f); ext != ".yml" && ext != ".yaml" {
+			continue
+		}
+
+		content, err := ioutil.ReadFile(f)
+		if err != nil {
+			errs = append(errs, CfgError{File: f, Err: err})
+			continue
+		}
+
+		var y YAML
+		if err = yaml.Unmarshal(content, &y); err != nil {
+			errs = append(errs, CfgError{File: f, Err: err})
+			continue
+		}
+
+		for _, cfg := range y.Logs {
+			cfgErrs := cfg.Validate()
+			if prevFile, ok := namesByFile[cfg.Name]; ok && cfg.Name != "" {
+				cfgErrs = append(cfgErrs, fmt.Errorf("duplicate name, already defined in %s", prevFile))
+			} else {
+				namesByFile[cfg.Name] = f
+			}
+
+			for _, e := range cfgErrs {
+				errs = append(errs, CfgError{File: f, Name: cfg.Name, Err: e})
+			}
+			if len(cfgErrs) == 0 {
+				valid = append(valid, cfg)
+			}
+		}
+	}
+
+	if len(valid) == 0 {
+		return
+	}
+
+	_, shortHostName, _ := l.hostnameResolver.Query()
+	fbCfg, err = NewFBConf(valid, &(l.config), l.agentIDFn().GUID.String(), shortHostName)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	fbCfg.Output.LicenseKey = maskedLicenseKey
+	if fbCfg.IsSampling() {
+		fbCfg.SamplingScriptPath = samplingScriptPlaceholder
+	}
+
+	return
+}
+
+// validateFilePath checks a tail input path, which might contain wildcards.
+func validateFilePath(path string) []error {
+	if !strings.ContainsAny(path, "*?[") {
+		if err := validateReadable(path, false); err != nil {
+			return []error{err}
+		}
+		return nil
+	}
+
+	matches, err := filepath.Glob(path)
+	if err != nil {
+		return []error{fmt.Errorf("invalid file pattern %s: %s", path, err)}
+	}
+	if len(matches) == 0 {
+		return []error{fmt.Errorf("no files match %s", path)}
+	}
+
+	var errs []error
+	for _, m := range matches {
+		if err := validateReadable(m, false); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// validateReadable checks the path exists, is a file or folder as expected, and can be read by the agent.
+func validateReadable(path string, isDir bool) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if isDir && !info.IsDir() {
+		return fmt.Errorf("%s is not a folder", path)
+	}
+	if !isDir && info.IsDir() {
+		return fmt.Errorf("%s is a folder", path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,92 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package logs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogCfg_Validate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-validate")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	addFile(t, dir, "app.log", "")
+	existingFile := filepath.Join(dir, "app.log")
+
+	tests := []struct {
+		name       string
+		cfg        LogCfg
+		wantErrors int
+	}{
+		{"valid file", LogCfg{Name: "foo", File: existingFile}, 0},
+		{"valid file wildcard", LogCfg{Name: "foo", File: filepath.Join(dir, "*.log")}, 0},
+		{"valid folder", LogCfg{Name: "foo", Folder: dir}, 0},
+		{"valid systemd", LogCfg{Name: "foo", Systemd: "cron", Pattern: "^ERR.*"}, 0},
+		{"valid syslog", LogCfg{Name: "foo", Syslog: &LogSyslogCfg{URI: "tcp://0.0.0.0:5140"}}, 0},
+		{"missing name", LogCfg{File: existingFile}, 1},
+		{"missing source", LogCfg{Name: "foo"}, 1},
+		{"non existing file", LogCfg{Name: "foo", File: "/non/existing/file.log"}, 1},
+		{"no file matches wildcard", LogCfg{Name: "foo", File: filepath.Join(dir, "*.txt")}, 1},
+		{"folder is a file", LogCfg{Name: "foo", Folder: existingFile}, 1},
+		{"bad pattern", LogCfg{Name: "foo", Systemd: "cron", Pattern: "(unclosed"}, 1},
+		{"bad syslog uri", LogCfg{Name: "foo", Syslog: &LogSyslogCfg{URI: "http://0.0.0.0:5140"}}, 1},
+		{"bad tcp uri", LogCfg{Name: "foo", Tcp: &LogTcpCfg{Uri: "tcp://localhost"}}, 1},
+		{"fluentbit without config file", LogCfg{Name: "foo", Fluentbit: &LogExternalFBCfg{}}, 1},
+		{"valid limits", LogCfg{Name: "foo", Systemd: "cron", SampleRate: 10, SampleAllow: "^ERR", RateLimit: &LogRateLimitCfg{RecordsPerSec: 10}}, 0},
+		{"bad sample allow pattern", LogCfg{Name: "foo", Systemd: "cron", SampleRate: 10, SampleAllow: "(unclosed"}, 1},
+		{"sample allow pattern with whitespace", LogCfg{Name: "foo", Systemd: "cron", SampleRate: 10, SampleAllow: "ERR\nName grep"}, 1},
+		{"bad rate limit", LogCfg{Name: "foo", Systemd: "cron", RateLimit: &LogRateLimitCfg{Burst: 10}}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Len(t, tt.cfg.Validate(), tt.wantErrors)
+		})
+	}
+}
+
+func TestCfgLoader_ValidateAll(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-validate-all")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	addFile(t, dir, "app.log", "")
+	logFile := filepath.Join(dir, "app.log")
+	addFile(t, dir, "valid.yml", fmt.Sprintf(`
+logs:
+  - name: app
+    file: %s
+`, logFile))
+	addFile(t, dir, "duplicated.yml", fmt.Sprintf(`
+logs:
+  - name: app
+    file: %s
+  - name: broken-syslog
+    syslog:
+      uri: tcp://nowhere
+`, logFile))
+	addFile(t, dir, "malformed.yaml", "logs: [")
+	addFile(t, dir, "ignored.yml.example", "logs: [")
+
+	fbCfg, errs := NewFolderLoader(newTestConf(dir, disabledTroubleshootCfg), idnProvide, hostnameProvider).ValidateAll()
+
+	require.Len(t, errs, 3)
+	var msgs []string
+	for _, e := range errs {
+		msgs = append(msgs, e.Error())
+	}
+	// files are loaded in alphabetical order
+	assert.Contains(t, msgs[0], `log "broken-syslog"`)
+	assert.Contains(t, msgs[1], "malformed.yaml")
+	assert.Contains(t, msgs[2], "valid.yml: log \"app\": duplicate name")
+
+	require.Len(t, fbCfg.Inputs, 1)
+	assert.Equal(t, logFile, fbCfg.Inputs[0].Path)
+	assert.Equal(t, maskedLicenseKey, fbCfg.Output.LicenseKey)
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/fs"
 
 	"github.com/fsnotify/fsnotify"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/executor"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/files"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/integration"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/runner"
@@ -160,6 +161,11 @@ type Configuration struct {
 	Verbose int
 	// PassthroughEnvironment holds a copy of its homonym in config.Config.
 	PassthroughEnvironment []string
+	// Verifier checks the integration executables before running them. Optional.
+	Verifier executor.Verifier
+	// MaxPayloadSize integration payloads bigger than this size, in bytes, are discarded while being read. Zero
+	// disables the limit.
+	MaxPayloadSize int
 }
 
 func NewConfig(verbose int, features map[string]bool, passthroughEnvs, configFolders, definitionFolders []string) Configuration {
@@ -510,8 +516,11 @@ func defaultInstancesLookup(cfg Configuration) integration.InstancesLookup {
 		Verbose:           cfg.Verbose,
 	})
 	return integration.InstancesLookup{
-		Legacy: legacyDefinedCommands.NewDefinitionCommand,
-		ByName: files.Executables{Folders: execFolders}.Path,
+		Legacy:   legacyDefinedCommands.NewDefinitionCommand,
+		ByName:   files.Executables{Folders: execFolders}.Path,
+		Verifier: cfg.Verifier,
+
+		MaxPayloadSize: cfg.MaxPayloadSize,
 	}
 }
 
@@ -0,0 +1,251 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// Exponential buckets scale limits, as defined by OpenTelemetry.
+const (
+	minExponentialScale = -10
+	maxExponentialScale = 20
+)
+
+// Histogram validation errors
+var (
+	HistogramNoBucketsErr       = errors.New("histogram requires either explicit or exponential buckets")
+	HistogramBothBucketsErr     = errors.New("histogram can't have both explicit and exponential buckets")
+	HistogramBucketsLengthErr   = errors.New("histogram counts must have one more item than boundaries")
+	HistogramBoundariesOrderErr = errors.New("histogram boundaries must be in increasing order")
+	HistogramNegativeCountErr   = errors.New("histogram counts can't be negative")
+	HistogramCountMismatchErr   = errors.New("histogram count doesn't match the sum of the bucket counts")
+	HistogramMinMaxErr          = errors.New("histogram min can't be greater than max")
+	HistogramPercentileErr      = errors.New("histogram percentiles must be in the (0, 100] range")
+	HistogramScaleErr           = fmt.Errorf("histogram exponential scale must be in the [%d, %d] range", minExponentialScale, maxExponentialScale)
+)
+
+// HistogramValue distribution of the values observed for a metric. Buckets are either explicit, or exponential.
+//
+// With explicit buckets, Counts has one more item than Boundaries: the bucket i counts the values within
+// (Boundaries[i-1], Boundaries[i]], the first one the values lower or equal than Boundaries[0] and the last one
+// the values greater than the last boundary. For example:
+//
+//   {"count": 6, "sum": 42.5, "boundaries": [5, 10], "counts": [1, 3, 2], "percentiles": [50, 99]}
+//
+// The count can be omitted, being the sum of the bucket counts. When min or max are omitted they are estimated
+// from the buckets. Percentiles are the ones reported by the agent, estimated from the buckets.
+type HistogramValue struct {
+	Count       float64             `json:"count"`
+	Sum         float64             `json:"sum"`
+	Min         *float64            `json:"min"`
+	Max         *float64            `json:"max"`
+	Boundaries  []float64           `json:"boundaries"`
+	Counts      []float64           `json:"counts"`
+	Exponential *ExponentialBuckets `json:"exponential"`
+	Percentiles []float64           `json:"percentiles"`
+}
+
+// ExponentialBuckets buckets of exponentially growing boundaries, as defined by OpenTelemetry: the bucket at index
+// i counts the values within (base^i, base^(i+1)], where base = 2^(2^-scale). Counts start at the Offset index,
+// and ZeroCount counts the zero values. Negative values are not supported.
+type ExponentialBuckets struct {
+	Scale     int       `json:"scale"`
+	Offset    int       `json:"offset"`
+	ZeroCount float64   `json:"zero_count"`
+	Counts    []float64 `json:"counts"`
+}
+
+// HistogramValue decodes and validates the value of a histogram metric.
+func (m *Metric) HistogramValue() (HistogramValue, error) {
+	if m.Type != MetricTypeHistogram {
+		return HistogramValue{}, fmt.Errorf("metric type %v is not histogram", m.Type)
+	}
+
+	var value HistogramValue
+	if err := json.Unmarshal(m.Value, &value); err != nil {
+		return value, err
+	}
+	if value.Count == 0 {
+		for _, c := range value.bucketCounts() {
+			value.Count += c
+		}
+	}
+	return value, value.Validate()
+}
+
+// dropInvalidHistograms returns the metrics without the histograms whose value is not valid, which are reported in
+// the returned InvalidMetricsError.
+func dropInvalidHistograms(metrics []Metric) ([]Metric, error) {
+	var errs []string
+	valid := metrics[:0]
+	for _, metric := range metrics {
+		if metric.Type == MetricTypeHistogram {
+			if _, err := metric.HistogramValue(); err != nil {
+				errs = append(errs, fmt.Sprintf("metric %s: %v", metric.Name, err))
+				continue
+			}
+		}
+		valid = append(valid, metric)
+	}
+	if len(errs) > 0 {
+		return valid, &InvalidMetricsError{Errs: errs}
+	}
+	return valid, nil
+}
+
+// Validate checks the histogram buckets are consistent with the count, min, max and percentiles.
+func (h *HistogramValue) Validate() error {
+	if len(h.Counts) == 0 && h.Exponential == nil {
+		return HistogramNoBucketsErr
+	}
+	if (len(h.Counts) > 0 || len(h.Boundaries) > 0) && h.Exponential != nil {
+		return HistogramBothBucketsErr
+	}
+
+	if h.Exponential != nil {
+		if h.Exponential.Scale < minExponentialScale || h.Exponential.Scale > maxExponentialScale {
+			return HistogramScaleErr
+		}
+	} else {
+		if len(h.Boundaries) == 0 {
+			return HistogramNoBucketsErr
+		}
+		if len(h.Counts) != len(h.Boundaries)+1 {
+			return HistogramBucketsLengthErr
+		}
+		for i := 1; i < len(h.Boundaries); i++ {
+			if !(h.Boundaries[i] > h.Boundaries[i-1]) {
+				return HistogramBoundariesOrderErr
+			}
+		}
+	}
+
+	var total float64
+	for _, c := range h.bucketCounts() {
+		if c < 0 || math.IsNaN(c) {
+			return HistogramNegativeCountErr
+		}
+		total += c
+	}
+	if total != h.Count {
+		return HistogramCountMismatchErr
+	}
+
+	if h.Min != nil && h.Max != nil && *h.Min > *h.Max {
+		return HistogramMinMaxErr
+	}
+	for _, p := range h.Percentiles {
+		if !(p > 0 && p <= 100) {
+			return HistogramPercentileErr
+		}
+	}
+	return nil
+}
+
+func (h *HistogramValue) bucketCounts() []float64 {
+	if h.Exponential != nil {
+		return append([]float64{h.Exponential.ZeroCount}, h.Exponential.Counts...)
+	}
+	return h.Counts
+}
+
+// ExplicitBuckets returns the histogram boundaries and counts, converting the exponential buckets into explicit
+// ones.
+func (h *HistogramValue) ExplicitBuckets() (boundaries []float64, counts []float64) {
+	e := h.Exponential
+	if e == nil {
+		return h.Boundaries, h.Counts
+	}
+
+	// zero values, then the (0, base^offset] bucket
+	boundaries = []float64{0}
+	counts = []float64{e.ZeroCount, 0}
+	base := math.Pow(2, math.Pow(2, float64(-e.Scale)))
+	for i := 0; i <= len(e.Counts); i++ {
+		boundaries = append(boundaries, math.Pow(base, float64(e.Offset+i)))
+	}
+	counts = append(counts, e.Counts...)
+	// values above the last bucket
+	counts = append(counts, 0)
+	return boundaries, counts
+}
+
+// MinMax returns the histogram min and max values, estimating them from the lowest and highest non-empty buckets
+// when not provided.
+func (h *HistogramValue) MinMax() (min float64, max float64) {
+	boundaries, counts := h.ExplicitBuckets()
+	first, last := -1, -1
+	for i, c := range counts {
+		if c > 0 {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+
+	if h.Min != nil {
+		min = *h.Min
+	} else if first >= 0 {
+		min = bucketLowerBound(boundaries, first)
+	}
+	if h.Max != nil {
+		max = *h.Max
+	} else if last >= 0 {
+		max = bucketUpperBound(boundaries, last)
+	}
+	return min, max
+}
+
+// Percentile estimates the value below which the given percentage of the observed values fall, interpolating
+// linearly within the bucket containing it. Unbounded buckets are limited by the min and max values.
+func (h *HistogramValue) Percentile(percentile float64) float64 {
+	boundaries, counts := h.ExplicitBuckets()
+	min, max := h.MinMax()
+	if h.Count == 0 {
+		return 0
+	}
+
+	rank := percentile / 100 * h.Count
+	var cumulative float64
+	for i, c := range counts {
+		if c == 0 || cumulative+c < rank {
+			cumulative += c
+			continue
+		}
+		lower, upper := math.Inf(-1), math.Inf(1)
+		if i > 0 {
+			lower = boundaries[i-1]
+		}
+		if i < len(boundaries) {
+			upper = boundaries[i]
+		}
+		lower, upper = math.Max(lower, min), math.Min(upper, max)
+		if upper < lower {
+			return lower
+		}
+		return lower + (upper-lower)*(rank-cumulative)/c
+	}
+	return max
+}
+
+// bucketLowerBound returns the lower boundary of the bucket, or its upper one for the first, unbounded, bucket.
+func bucketLowerBound(boundaries []float64, bucket int) float64 {
+	if bucket == 0 {
+		return boundaries[0]
+	}
+	return boundaries[bucket-1]
+}
+
+// bucketUpperBound returns the upper boundary of the bucket, or its lower one for the last, unbounded, bucket.
+func bucketUpperBound(boundaries []float64, bucket int) float64 {
+	if bucket >= len(boundaries) {
+		return boundaries[len(boundaries)-1]
+	}
+	return boundaries[bucket]
+}
@@ -0,0 +1,122 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package protocol
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func histogramMetric(value string) Metric {
+	return Metric{Name: "latency", Type: MetricTypeHistogram, Value: json.RawMessage(value)}
+}
+
+func TestMetric_HistogramValue(t *testing.T) {
+	m := histogramMetric(`{"sum": 42.5, "min": 1, "max": 20, "boundaries": [5, 10], "counts": [1, 3, 2], "percentiles": [50, 99]}`)
+
+	value, err := m.HistogramValue()
+	require.NoError(t, err)
+
+	assert.Equal(t, 6.0, value.Count, "count is the sum of the buckets when omitted")
+	assert.Equal(t, 42.5, value.Sum)
+	assert.Equal(t, []float64{5, 10}, value.Boundaries)
+	assert.Equal(t, []float64{1, 3, 2}, value.Counts)
+	assert.Equal(t, []float64{50, 99}, value.Percentiles)
+}
+
+func TestMetric_HistogramValue_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		err   error
+	}{
+		{"no buckets", `{"count": 1}`, HistogramNoBucketsErr},
+		{"no boundaries", `{"counts": [1]}`, HistogramNoBucketsErr},
+		{"both buckets", `{"boundaries": [1], "counts": [1, 1], "exponential": {"counts": [1]}}`, HistogramBothBucketsErr},
+		{"counts length", `{"boundaries": [1, 2], "counts": [1, 1]}`, HistogramBucketsLengthErr},
+		{"boundaries order", `{"boundaries": [2, 1], "counts": [1, 1, 1]}`, HistogramBoundariesOrderErr},
+		{"repeated boundaries", `{"boundaries": [1, 1], "counts": [1, 1, 1]}`, HistogramBoundariesOrderErr},
+		{"negative count", `{"boundaries": [1], "counts": [2, -1]}`, HistogramNegativeCountErr},
+		{"count mismatch", `{"count": 3, "boundaries": [1], "counts": [1, 1]}`, HistogramCountMismatchErr},
+		{"min max", `{"min": 3, "max": 1, "boundaries": [1], "counts": [1, 1]}`, HistogramMinMaxErr},
+		{"percentile zero", `{"boundaries": [1], "counts": [1, 1], "percentiles": [0]}`, HistogramPercentileErr},
+		{"percentile above 100", `{"boundaries": [1], "counts": [1, 1], "percentiles": [101]}`, HistogramPercentileErr},
+		{"exponential scale", `{"exponential": {"scale": 21, "counts": [1]}}`, HistogramScaleErr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := histogramMetric(tt.value)
+			_, err := m.HistogramValue()
+			assert.Equal(t, tt.err, err)
+		})
+	}
+}
+
+func TestMetric_HistogramValue_WrongType(t *testing.T) {
+	m := Metric{Type: MetricTypeSummary, Value: json.RawMessage(`{"boundaries": [1], "counts": [1, 1]}`)}
+
+	_, err := m.HistogramValue()
+	assert.EqualError(t, err, "metric type summary is not histogram")
+}
+
+func TestHistogramValue_ExplicitBuckets_Exponential(t *testing.T) {
+	m := histogramMetric(`{"exponential": {"scale": 0, "offset": 1, "zero_count": 1, "counts": [2, 3]}}`)
+	value, err := m.HistogramValue()
+	require.NoError(t, err)
+
+	boundaries, counts := value.ExplicitBuckets()
+
+	// base 2: zero values, (0, 2], (2, 4], (4, 8], above 8
+	assert.Equal(t, []float64{0, 2, 4, 8}, boundaries)
+	assert.Equal(t, []float64{1, 0, 2, 3, 0}, counts)
+	assert.Equal(t, 6.0, value.Count)
+}
+
+func TestHistogramValue_MinMax(t *testing.T) {
+	estimated := histogramMetric(`{"boundaries": [5, 10, 20], "counts": [0, 3, 2, 0]}`)
+	value, err := estimated.HistogramValue()
+	require.NoError(t, err)
+	min, max := value.MinMax()
+	assert.Equal(t, 5.0, min)
+	assert.Equal(t, 20.0, max)
+
+	provided := histogramMetric(`{"min": 6, "max": 18, "boundaries": [5, 10, 20], "counts": [0, 3, 2, 0]}`)
+	value, err = provided.HistogramValue()
+	require.NoError(t, err)
+	min, max = value.MinMax()
+	assert.Equal(t, 6.0, min)
+	assert.Equal(t, 18.0, max)
+}
+
+func TestHistogramValue_Percentile(t *testing.T) {
+	m := histogramMetric(`{"min": 1, "max": 40, "boundaries": [10, 20, 30], "counts": [10, 20, 60, 10]}`)
+	value, err := m.HistogramValue()
+	require.NoError(t, err)
+
+	tests := []struct {
+		percentile float64
+		expected   float64
+	}{
+		{5, 5.5},    // first bucket, bounded by min
+		{10, 10},    // first bucket boundary
+		{20, 15},    // middle of the second bucket
+		{60, 25},    // middle of the third bucket
+		{95, 35},    // last bucket, bounded by max
+		{100, 40},   // max
+		{0.1, 1.09}, // near the min
+	}
+	for _, tt := range tests {
+		assert.InDelta(t, tt.expected, value.Percentile(tt.percentile), 0.001, "percentile %v", tt.percentile)
+	}
+}
+
+func TestHistogramValue_Percentile_Empty(t *testing.T) {
+	m := histogramMetric(`{"boundaries": [10], "counts": [0, 0]}`)
+	value, err := m.HistogramValue()
+	require.NoError(t, err)
+
+	assert.Equal(t, 0.0, value.Percentile(50))
+}
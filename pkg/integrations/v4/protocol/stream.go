@@ -0,0 +1,206 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// dataSetsKey is the payload field containing the datasets, for protocols 2 to 4.
+const dataSetsKey = "data"
+
+// Errors
+var (
+	DataSetsNotArrayErr = errors.New("data field must be an array of datasets")
+)
+
+// PayloadTooLargeError is returned for payloads exceeding the max payload size, before they are decoded.
+type PayloadTooLargeError struct {
+	Size    int
+	MaxSize int
+}
+
+func (e *PayloadTooLargeError) Error() string {
+	return fmt.Sprintf("payload of %d bytes exceeds the max payload size of %d bytes", e.Size, e.MaxSize)
+}
+
+// MalformedPayloadError is returned for payloads that are not valid JSON, or whose datasets can't be decoded.
+type MalformedPayloadError struct {
+	Err error
+}
+
+func (e *MalformedPayloadError) Error() string {
+	return "malformed integration payload: " + e.Err.Error()
+}
+
+// InvalidMetricsError is returned for the metrics of a dataset whose value can't be decoded according to their type.
+// These metrics are dropped, while the rest of the dataset is still processed.
+type InvalidMetricsError struct {
+	Errs []string
+}
+
+func (e *InvalidMetricsError) Error() string {
+	return strings.Join(e.Errs, "; ")
+}
+
+// CheckPayloadSize returns a PayloadTooLargeError if the payload exceeds the max size. Zero or negative max sizes
+// don't limit the payload.
+func CheckPayloadSize(raw []byte, maxSize int) error {
+	if maxSize > 0 && len(raw) > maxSize {
+		return &PayloadTooLargeError{Size: len(raw), MaxSize: maxSize}
+	}
+	return nil
+}
+
+// ParseIdentifier parses the fields identifying a v1 to v3 payload, skipping its datasets.
+func ParseIdentifier(raw []byte) (id PluginOutputIdentifier, err error) {
+	if len(raw) == 0 {
+		return id, EmptyPayloadErr
+	}
+	if err = json.Unmarshal(raw, &id); err != nil {
+		err = &MalformedPayloadError{Err: err}
+	}
+	return
+}
+
+// ParseIntegrationMetadata parses the integration metadata of a v4 payload, skipping its datasets.
+func ParseIntegrationMetadata(raw []byte) (IntegrationMetadata, error) {
+	var header struct {
+		Integration IntegrationMetadata `json:"integration"`
+	}
+	if len(raw) == 0 {
+		return header.Integration, EmptyPayloadErr
+	}
+	if err := json.Unmarshal(raw, &header); err != nil {
+		return header.Integration, &MalformedPayloadError{Err: err}
+	}
+	return header.Integration, nil
+}
+
+// ForEachDataSetV3 decodes the datasets of a v1 to v3 payload one by one, calling the handler after decoding each
+// of them, so the whole payload is never unmarshalled at once. Datasets that can't be decoded are skipped, and
+// reported in the returned error.
+func ForEachDataSetV3(raw []byte, protocolVersion int, handle func(dataSet PluginDataSetV3)) error {
+	if protocolVersion == V1 {
+		// v1 payloads are a single dataset
+		dataV3, err := ParsePayload(raw, protocolVersion)
+		if err != nil {
+			return &MalformedPayloadError{Err: err}
+		}
+		for _, dataSet := range dataV3.DataSets {
+			handle(dataSet)
+		}
+		return nil
+	}
+
+	return forEachDataSet(raw, func(dec *json.Decoder) error {
+		var dataSet PluginDataSetV3
+		if err := dec.Decode(&dataSet); err != nil {
+			return err
+		}
+		handle(dataSet)
+		return nil
+	})
+}
+
+// ForEachDataSetV4 decodes the datasets of a v4 payload one by one, calling the handler after decoding each of them,
+// so the whole payload is never unmarshalled at once. Datasets that can't be decoded are skipped, and reported in
+// the returned error. Histogram metrics with invalid values are removed from their dataset, and reported as well.
+func ForEachDataSetV4(raw []byte, handle func(dataSet Dataset)) error {
+	return forEachDataSet(raw, func(dec *json.Decoder) error {
+		var dataSet Dataset
+		if err := dec.Decode(&dataSet); err != nil {
+			return err
+		}
+		var err error
+		dataSet.Metrics, err = dropInvalidHistograms(dataSet.Metrics)
+		handle(dataSet)
+		return err
+	})
+}
+
+// forEachDataSet tokenizes the top level payload object, skipping all the fields but the datasets array, and calls
+// decodeNext for each of its items. Payloads that are not valid JSON are rejected before decoding any dataset, so
+// malformed or truncated payloads are never partially handled.
+func forEachDataSet(raw []byte, decodeNext func(dec *json.Decoder) error) error {
+	if len(raw) == 0 {
+		return EmptyPayloadErr
+	}
+	if !json.Valid(raw) {
+		// unmarshalling invalid JSON fails before decoding anything, returning the syntax error details
+		var skip json.RawMessage
+		return &MalformedPayloadError{Err: json.Unmarshal(raw, &skip)}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if err := expectDelim(dec, '{'); err != nil {
+		return &MalformedPayloadError{Err: err}
+	}
+
+	var dataSetErrs []string
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return &MalformedPayloadError{Err: err}
+		}
+		if key != dataSetsKey {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return &MalformedPayloadError{Err: err}
+			}
+			continue
+		}
+
+		token, err := dec.Token()
+		if err != nil {
+			return &MalformedPayloadError{Err: err}
+		}
+		if token == nil {
+			continue
+		}
+		if delim, ok := token.(json.Delim); !ok || delim != '[' {
+			return &MalformedPayloadError{Err: DataSetsNotArrayErr}
+		}
+		for i := 0; dec.More(); i++ {
+			if err := decodeNext(dec); err != nil {
+				// type errors and invalid metrics only affect the current dataset, which has been fully read
+				if !isDataSetError(err) {
+					return &MalformedPayloadError{Err: err}
+				}
+				dataSetErrs = append(dataSetErrs, fmt.Sprintf("dataset %d: %v", i, err))
+			}
+		}
+		if err := expectDelim(dec, ']'); err != nil {
+			return &MalformedPayloadError{Err: err}
+		}
+	}
+
+	if len(dataSetErrs) > 0 {
+		return &MalformedPayloadError{Err: errors.New(strings.Join(dataSetErrs, "; "))}
+	}
+	return nil
+}
+
+func isDataSetError(err error) bool {
+	switch err.(type) {
+	case *json.UnmarshalTypeError, *InvalidMetricsError:
+		return true
+	}
+	return false
+}
+
+func expectDelim(dec *json.Decoder, expected json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != expected {
+		return fmt.Errorf("expected %v, found %v", expected, token)
+	}
+	return nil
+}
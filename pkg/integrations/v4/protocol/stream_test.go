@@ -0,0 +1,155 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package protocol
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// datasets before the identifier fields, to verify the header doesn't depend on the fields order
+const payloadV3 = `{"data":[` +
+	`{"entity":{"name":"e1","type":"t"},"metrics":[{"event_type":"ESample","value":1}]},` +
+	`{"entity":{"name":"e2","type":"t"},"inventory":{"k":{"value":"v"}}}` +
+	`],"name":"com.newrelic.test","protocol_version":"3","integration_version":"1.0.0","extra":{"ignored":[1,2]}}`
+
+func TestCheckPayloadSize(t *testing.T) {
+	assert.NoError(t, CheckPayloadSize([]byte("12345"), 5))
+	assert.NoError(t, CheckPayloadSize([]byte("12345"), 0), "zero disables the limit")
+
+	err := CheckPayloadSize([]byte("123456"), 5)
+	require.Error(t, err)
+	assert.Equal(t, &PayloadTooLargeError{Size: 6, MaxSize: 5}, err)
+	assert.Equal(t, "payload of 6 bytes exceeds the max payload size of 5 bytes", err.Error())
+}
+
+func TestParseIdentifier(t *testing.T) {
+	id, err := ParseIdentifier([]byte(payloadV3))
+	require.NoError(t, err)
+
+	assert.Equal(t, "com.newrelic.test", id.Name)
+	assert.Equal(t, "1.0.0", id.IntegrationVersion)
+}
+
+func TestParseIdentifier_Malformed(t *testing.T) {
+	_, err := ParseIdentifier([]byte(`{"name":"com.newrelic.test","data":[`))
+	require.IsType(t, &MalformedPayloadError{}, err)
+
+	_, err = ParseIdentifier(nil)
+	assert.Equal(t, EmptyPayloadErr, err)
+}
+
+func TestForEachDataSetV3(t *testing.T) {
+	var dataSets []PluginDataSetV3
+	err := ForEachDataSetV3([]byte(payloadV3), V3, func(dataSet PluginDataSetV3) {
+		dataSets = append(dataSets, dataSet)
+	})
+	require.NoError(t, err)
+
+	require.Len(t, dataSets, 2)
+	assert.Equal(t, "e1", dataSets[0].Entity.Name)
+	assert.Equal(t, "ESample", dataSets[0].Metrics[0]["event_type"])
+	assert.Equal(t, "e2", dataSets[1].Entity.Name)
+	assert.Equal(t, "v", dataSets[1].Inventory["k"]["value"])
+}
+
+func TestForEachDataSetV3_V1(t *testing.T) {
+	var dataSets []PluginDataSetV3
+	err := ForEachDataSetV3([]byte(`{"name":"test","protocol_version":"1","metrics":[{"event_type":"ESample"}]}`), V1,
+		func(dataSet PluginDataSetV3) {
+			dataSets = append(dataSets, dataSet)
+		})
+	require.NoError(t, err)
+
+	require.Len(t, dataSets, 1)
+	assert.Equal(t, "ESample", dataSets[0].Metrics[0]["event_type"])
+}
+
+func TestForEachDataSetV4(t *testing.T) {
+	payload := `{"protocol_version":"4","integration":{"name":"test","version":"1"},"data":[` +
+		`{"entity":{"name":"e1"},"metrics":[{"name":"m1","type":"gauge","value":1}]},` +
+		`{"entity":{"name":"e2"},"metrics":[{"name":"m2","type":"count","value":2}]}]}`
+
+	metadata, err := ParseIntegrationMetadata([]byte(payload))
+	require.NoError(t, err)
+	assert.Equal(t, IntegrationMetadata{Name: "test", Version: "1"}, metadata)
+
+	var entities []string
+	err = ForEachDataSetV4([]byte(payload), func(dataSet Dataset) {
+		entities = append(entities, dataSet.Entity.Name)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"e1", "e2"}, entities)
+}
+
+func TestForEachDataSet_MalformedDataSet(t *testing.T) {
+	payload := `{"protocol_version":"3","data":[` +
+		`{"entity":{"name":"e1"}},` +
+		`{"entity":{"name":["not","a","string"]}},` +
+		`{"entity":{"name":"e3"}}]}`
+
+	var entities []string
+	err := ForEachDataSetV3([]byte(payload), V3, func(dataSet PluginDataSetV3) {
+		entities = append(entities, dataSet.Entity.Name)
+	})
+
+	require.IsType(t, &MalformedPayloadError{}, err)
+	assert.Contains(t, err.Error(), "dataset 1:")
+	assert.Equal(t, []string{"e1", "e3"}, entities, "valid datasets are still decoded")
+}
+
+func TestForEachDataSetV4_InvalidHistogram(t *testing.T) {
+	payload := `{"protocol_version":"4","integration":{"name":"test","version":"1"},"data":[` +
+		`{"entity":{"name":"e1"},"metrics":[` +
+		`{"name":"valid","type":"histogram","value":{"sum":3,"boundaries":[1],"counts":[1,2]}},` +
+		`{"name":"invalid","type":"histogram","value":{"sum":3,"boundaries":[1],"counts":[1]}},` +
+		`{"name":"gauge","type":"gauge","value":1}]}]}`
+
+	var metrics []string
+	err := ForEachDataSetV4([]byte(payload), func(dataSet Dataset) {
+		for _, metric := range dataSet.Metrics {
+			metrics = append(metrics, metric.Name)
+		}
+	})
+
+	require.IsType(t, &MalformedPayloadError{}, err)
+	assert.Contains(t, err.Error(), "dataset 0: metric invalid: "+HistogramBucketsLengthErr.Error())
+	assert.Equal(t, []string{"valid", "gauge"}, metrics, "the rest of the dataset is still decoded")
+}
+
+func TestForEachDataSet_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+	}{
+		{"not an object", `["data"]`},
+		{"data not an array", `{"data":{"entity":{}}}`},
+		{"truncated", `{"data":[{"entity":{"name":"e1"}},`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ForEachDataSetV3([]byte(tt.payload), V3, func(PluginDataSetV3) {})
+			assert.IsType(t, &MalformedPayloadError{}, err)
+		})
+	}
+}
+
+func TestForEachDataSet_TruncatedHandlesNothing(t *testing.T) {
+	payload := `{"data":[{"entity":{"name":"e1"}},{"entity":{"name":"e2"}},`
+
+	var handled int
+	err := ForEachDataSetV3([]byte(payload), V3, func(PluginDataSetV3) { handled++ })
+
+	require.IsType(t, &MalformedPayloadError{}, err)
+	assert.Zero(t, handled, "datasets preceding the truncation are not handled")
+}
+
+func TestForEachDataSet_NullData(t *testing.T) {
+	var dataSets int
+	err := ForEachDataSetV4([]byte(`{"protocol_version":"4","data":null}`), func(Dataset) { dataSets++ })
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, dataSets)
+}
@@ -18,6 +18,8 @@ const (
 	MetricTypeSummary MetricType = "summary"
 	MetricTypeGauge   MetricType = "gauge"
 	MetricTypeRate    MetricType = "rate"
+	// MetricTypeHistogram distribution of the values observed during the interval. See HistogramValue.
+	MetricTypeHistogram MetricType = "histogram"
 )
 
 const millisSinceJanuaryFirst1978 = 252489600000
@@ -143,7 +145,7 @@ func (i InventoryData) SortKey() string {
 
 // HasInterval does metric type support interval.
 func (t MetricType) HasInterval() bool {
-	return t == MetricTypeCount || t == MetricTypeSummary
+	return t == MetricTypeCount || t == MetricTypeSummary || t == MetricTypeHistogram
 }
 
 // Converts timestamp to a Time object, accepting timestamps in both
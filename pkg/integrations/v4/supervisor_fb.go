@@ -61,7 +61,7 @@ func NewFBSupervisor(fbIntCfg FBSupervisorConfig, cfgLoader *logs.CfgLoader, age
 	return &Supervisor{
 		listenAgentIDChanges:   agentIDNotifier,
 		hostnameChangeNotifier: notifier,
-		listenRestartRequests:  listenRestartRequests(cfgLoader),
+		listenRestartRequests:  listenRestartRequests(cfgLoader, sendEventFn),
 		getBackOffTimer:        time.NewTimer,
 		handleErrs:             handleErrors(sFBLogger),
 		buildExecutor:          buildFbExecutor(fbIntCfg, cfgLoader),
@@ -156,9 +156,13 @@ func NewSupervisorEvent(eventSummary string, status cmdExitStatus) *SupervisorEv
 	}
 }
 
-func listenRestartRequests(cfgLoader *logs.CfgLoader) func(ctx ctx2.Context, signalRestart chan<- struct{}) {
+func listenRestartRequests(cfgLoader *logs.CfgLoader, sendEventFn SendEventFn) func(ctx ctx2.Context, signalRestart chan<- struct{}) {
 	cw := logs.NewConfigChangesWatcher(cfgLoader.GetConfigDir())
+	monitor := newFBMonitor(cfgLoader.GetMonitoringCfg(), cfgLoader.IsMonitoring, sendEventFn)
 	return func(ctx ctx2.Context, signalRestart chan<- struct{}) {
 		cw.Watch(ctx, signalRestart)
+		if monitor != nil {
+			monitor.Watch(ctx, signalRestart)
+		}
 	}
 }
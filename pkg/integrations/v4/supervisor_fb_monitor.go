@@ -0,0 +1,154 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package v4
+
+import (
+	ctx2 "context"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/logs"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+// LogForwarderSample reports the Fluent Bit activity during the last monitoring interval.
+type LogForwarderSample struct {
+	sample.BaseEvent
+	RecordsIn      uint64 `json:"recordsIn"`
+	BytesIn        uint64 `json:"bytesIn"`
+	RecordsOut     uint64 `json:"recordsOut"`
+	BytesOut       uint64 `json:"bytesOut"`
+	Errors         uint64 `json:"errors"`
+	Retries        uint64 `json:"retries"`
+	RetriesFailed  uint64 `json:"retriesFailed"`
+	DroppedRecords uint64 `json:"droppedRecords"`
+
+	ThrottledRecords  uint64 `json:"throttledRecords"`
+	SampledOutRecords uint64 `json:"sampledOutRecords"`
+}
+
+// NewLogForwarderSample creates a LogForwarderSample from the counters increase within an interval.
+func NewLogForwarderSample(delta logs.FBMetricsTotals) *LogForwarderSample {
+	return &LogForwarderSample{
+		BaseEvent: sample.BaseEvent{
+			EventType: "LogForwarderSample",
+			Timestmp:  time.Now().Unix(),
+		},
+		RecordsIn:      delta.RecordsIn,
+		BytesIn:        delta.BytesIn,
+		RecordsOut:     delta.RecordsOut,
+		BytesOut:       delta.BytesOut,
+		Errors:         delta.Errors,
+		Retries:        delta.Retries,
+		RetriesFailed:  delta.RetriesFailed,
+		DroppedRecords: delta.DroppedRecords,
+
+		ThrottledRecords:  delta.ThrottledRecords,
+		SampledOutRecords: delta.SampledOutRecords,
+	}
+}
+
+// fbMonitor scrapes the Fluent Bit monitoring API, reports its counters and requests a restart whenever the output
+// stays stuck: no records delivered while there is input or retry activity.
+type fbMonitor struct {
+	fetch        logs.FBMetricsFetcher
+	enabled      func() bool
+	interval     time.Duration
+	stuckTimeout time.Duration
+	sendEventFn  SendEventFn
+	now          func() time.Time
+	log          log.Entry
+
+	prev         *logs.FBMetricsTotals
+	lastProgress time.Time
+	pending      bool // input or retries happened since last output progress
+}
+
+// newFBMonitor returns nil when the monitoring interval is not valid. The Fluent Bit monitoring server is only
+// scraped while enabledFn returns true, as the loaded config disables it when its port is not available.
+func newFBMonitor(cfg config.LogForwardMonitoring, enabledFn func() bool, sendEventFn SendEventFn) *fbMonitor {
+	if cfg.Interval <= 0 {
+		return nil
+	}
+
+	return &fbMonitor{
+		fetch:        logs.NewFBMetricsFetcher(cfg.Port),
+		enabled:      enabledFn,
+		interval:     cfg.Interval,
+		stuckTimeout: cfg.StuckOutputTimeout,
+		sendEventFn:  sendEventFn,
+		now:          time.Now,
+		log:          sFBLogger.WithField("function", "monitor"),
+	}
+}
+
+// Watch scrapes Fluent Bit periodically in background, pushing a notification when a restart is required.
+func (m *fbMonitor) Watch(ctx ctx2.Context, signalRestart chan<- struct{}) {
+	go func() {
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if !m.enabled() {
+					m.prev = nil
+					continue
+				}
+				if m.scrape() {
+					select {
+					case signalRestart <- struct{}{}:
+					default:
+					}
+				}
+			}
+		}
+	}()
+}
+
+// scrape submits a LogForwarderSample and returns whether the output is considered stuck.
+func (m *fbMonitor) scrape() (stuck bool) {
+	metrics, err := m.fetch()
+	if err == logs.ErrForeignFBMonitoring {
+		m.log.WithError(err).Warn("Cannot retrieve log-forwarder metrics.")
+		return false
+	}
+	if err != nil {
+		m.log.WithError(err).Debug("Cannot retrieve log-forwarder metrics.")
+		return false
+	}
+
+	now := m.now()
+	totals := metrics.Totals()
+	if m.prev == nil {
+		m.prev = &logs.FBMetricsTotals{}
+		m.lastProgress = now
+	}
+	delta := totals.Sub(*m.prev)
+	m.prev = &totals
+
+	m.sendEventFn(NewLogForwarderSample(delta), entity.EmptyKey)
+
+	if delta.RecordsOut > 0 {
+		m.lastProgress = now
+		m.pending = false
+		return false
+	}
+
+	if delta.RecordsIn > 0 || delta.Retries > 0 || delta.Errors > 0 {
+		m.pending = true
+	}
+
+	if m.stuckTimeout <= 0 || !m.pending || now.Sub(m.lastProgress) < m.stuckTimeout {
+		return false
+	}
+
+	m.log.WithField("stuckFor", now.Sub(m.lastProgress)).Warn("Log forwarder output is not delivering records, restarting it.")
+	m.prev = nil
+	m.pending = false
+	return true
+}
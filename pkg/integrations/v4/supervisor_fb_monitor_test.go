@@ -0,0 +1,111 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package v4
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/logs"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+	"github.com/stretchr/testify/assert"
+)
+
+type fbMetricsStub struct {
+	metrics logs.FBMetrics
+	err     error
+}
+
+func (f *fbMetricsStub) set(recordsIn, recordsOut, retries uint64) {
+	f.metrics = logs.FBMetrics{
+		Input:  map[string]logs.FBInputMetrics{"tail.0": {Records: recordsIn}},
+		Output: map[string]logs.FBOutputMetrics{"newrelic.0": {ProcRecords: recordsOut, Retries: retries}},
+	}
+}
+
+func newTestFBMonitor(stub *fbMetricsStub, now *time.Time, samples *[]*LogForwarderSample) *fbMonitor {
+	return &fbMonitor{
+		fetch: func() (logs.FBMetrics, error) {
+			return stub.metrics, stub.err
+		},
+		interval:     time.Minute,
+		stuckTimeout: 5 * time.Minute,
+		sendEventFn: func(event sample.Event, _ entity.Key) {
+			*samples = append(*samples, event.(*LogForwarderSample))
+		},
+		now: func() time.Time { return *now },
+		log: sFBLogger,
+	}
+}
+
+func TestFBMonitor_ReportsDeltas(t *testing.T) {
+	stub := &fbMetricsStub{}
+	now := time.Now()
+	var samples []*LogForwarderSample
+	m := newTestFBMonitor(stub, &now, &samples)
+
+	stub.set(10, 8, 0)
+	assert.False(t, m.scrape())
+	stub.set(25, 20, 2)
+	assert.False(t, m.scrape())
+
+	assert.Len(t, samples, 2)
+	assert.Equal(t, "LogForwarderSample", samples[0].EventType)
+	assert.Equal(t, uint64(10), samples[0].RecordsIn)
+	assert.Equal(t, uint64(8), samples[0].RecordsOut)
+	assert.Equal(t, uint64(15), samples[1].RecordsIn)
+	assert.Equal(t, uint64(12), samples[1].RecordsOut)
+	assert.Equal(t, uint64(2), samples[1].Retries)
+}
+
+func TestFBMonitor_FetchErrorSkipsSample(t *testing.T) {
+	stub := &fbMetricsStub{err: errors.New("connection refused")}
+	now := time.Now()
+	var samples []*LogForwarderSample
+	m := newTestFBMonitor(stub, &now, &samples)
+
+	assert.False(t, m.scrape())
+	assert.Empty(t, samples)
+}
+
+func TestFBMonitor_StuckOutput(t *testing.T) {
+	stub := &fbMetricsStub{}
+	now := time.Now()
+	var samples []*LogForwarderSample
+	m := newTestFBMonitor(stub, &now, &samples)
+
+	stub.set(10, 10, 0)
+	assert.False(t, m.scrape())
+
+	// input keeps growing while output is retrying
+	for i := uint64(1); i <= 4; i++ {
+		now = now.Add(time.Minute)
+		stub.set(10+i*10, 10, i)
+		assert.False(t, m.scrape(), "not stuck yet after %d minutes", i)
+	}
+
+	now = now.Add(time.Minute)
+	stub.set(60, 10, 5)
+	assert.True(t, m.scrape())
+
+	// after the restart counters start from zero and the timer is reset
+	now = now.Add(time.Minute)
+	stub.set(5, 0, 0)
+	assert.False(t, m.scrape())
+	assert.Equal(t, uint64(5), samples[len(samples)-1].RecordsIn)
+}
+
+func TestFBMonitor_IdleIsNotStuck(t *testing.T) {
+	stub := &fbMetricsStub{}
+	now := time.Now()
+	var samples []*LogForwarderSample
+	m := newTestFBMonitor(stub, &now, &samples)
+
+	stub.set(10, 10, 0)
+	for i := 0; i < 10; i++ {
+		assert.False(t, m.scrape())
+		now = now.Add(time.Minute)
+	}
+}
@@ -0,0 +1,293 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package container
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+var cslog = log.WithComponent("ContainerSampler")
+
+// maxDockerClientRetries bounds the attempts to connect to the Docker API, as containers might be handled by
+// another runtime (containerd, podman, CRI-O...) without any Docker socket.
+const maxDockerClientRetries = 100
+
+// Container runtimes, as inferred from the cgroup names.
+const (
+	runtimeDocker     = "docker"
+	runtimeContainerd = "containerd"
+	runtimeCrio       = "crio"
+	runtimePodman     = "podman"
+)
+
+type Sampler struct {
+	context             agent.AgentContext
+	sampleRate          time.Duration
+	cgroupRoot          string
+	dockerAPIVersion    string
+	dockerClient        helpers.Docker
+	dockerClientRetries int
+	lastSamples         map[string]statsCache
+}
+
+type statsCache struct {
+	last    cgroupStats
+	lastRun time.Time
+}
+
+// cgroupStats raw cgroup (v1 or v2) counters for a container, normalized to the same units.
+type cgroupStats struct {
+	CPUUsageNs          uint64
+	CPUPeriods          uint64
+	CPUThrottledPeriods uint64
+	CPUThrottledNs      uint64
+	CPULimitCores       *float64 // nil when unlimited
+
+	MemoryUsageBytes uint64
+	MemoryLimitBytes *uint64 // nil when unlimited
+	MemoryCacheBytes uint64
+	MemoryRSSBytes   uint64
+	MemoryOOMKills   *uint64 // nil when not provided by the kernel
+
+	IOReadBytes  uint64
+	IOWriteBytes uint64
+	IOReadOps    uint64
+	IOWriteOps   uint64
+
+	Pids      uint64
+	PidsLimit *uint64 // nil when unlimited
+}
+
+// cgroupContainer is a container found on the cgroup hierarchy.
+type cgroupContainer struct {
+	ID      string
+	Runtime string
+	stats   cgroupStats
+}
+
+type Sample struct {
+	sample.BaseEvent
+
+	ContainerID        string `json:"containerId"`
+	ContainerName      string `json:"containerName,omitempty"`
+	ContainerImage     string `json:"containerImage,omitempty"`
+	ContainerImageName string `json:"containerImageName,omitempty"`
+	// Container runtime inferred from the cgroup name (docker, containerd, crio, podman)
+	Runtime string `json:"containerRuntime,omitempty"`
+
+	// Number of cores used since the last sample
+	CPUUsedCores *float64 `json:"cpuUsedCores,omitempty"`
+	// Percentage of the CPU limit used since the last sample
+	CPUUsedCoresPercent *float64 `json:"cpuUsedCoresPercent,omitempty"`
+	// Cores the container is allowed to use, from its CFS quota
+	CPULimitCores *float64 `json:"cpuLimitCores,omitempty"`
+	// Total number of CFS periods the container was throttled
+	CPUThrottledPeriods *uint64 `json:"cpuThrottledPeriods,omitempty"`
+	// Percentage of the elapsed CFS periods the container was throttled since the last sample
+	CPUThrottledPeriodsPercent *float64 `json:"cpuThrottledPeriodsPercent,omitempty"`
+	// Total time the container was throttled
+	CPUThrottledTimeSeconds *float64 `json:"cpuThrottledTimeSeconds,omitempty"`
+
+	// Memory used, including page cache
+	MemoryUsageBytes *uint64 `json:"memoryUsageBytes,omitempty"`
+	// Memory limit, absent when unlimited
+	MemoryLimitBytes *uint64 `json:"memoryLimitBytes,omitempty"`
+	// Percentage of the memory limit used
+	MemoryUsageLimitPercent *float64 `json:"memoryUsageLimitPercent,omitempty"`
+	// Page cache memory
+	MemoryCacheBytes *uint64 `json:"memoryCacheBytes,omitempty"`
+	// Anonymous (resident set) memory
+	MemoryRSSBytes *uint64 `json:"memoryResidentSizeBytes,omitempty"`
+	// Total number of processes killed by the OOM killer within the container
+	MemoryOOMKills *uint64 `json:"memoryOOMKills,omitempty"`
+
+	// Total number of bytes read
+	IOTotalReadBytes *uint64 `json:"ioTotalReadBytes,omitempty"`
+	// Total number of bytes written
+	IOTotalWriteBytes *uint64 `json:"ioTotalWriteBytes,omitempty"`
+	// Number of bytes read per second
+	IOReadBytesPerSec *float64 `json:"ioReadBytesPerSecond,omitempty"`
+	// Number of bytes written per second
+	IOWriteBytesPerSec *float64 `json:"ioWriteBytesPerSecond,omitempty"`
+	// Number of read operations per second
+	IOReadsPerSec *float64 `json:"ioReadCountPerSecond,omitempty"`
+	// Number of write operations per second
+	IOWritesPerSec *float64 `json:"ioWriteCountPerSecond,omitempty"`
+
+	// Number of processes and threads within the container
+	ProcessCount *uint64 `json:"processCount,omitempty"`
+	// Maximum number of processes and threads, absent when unlimited
+	ProcessCountLimit *uint64 `json:"processCountLimit,omitempty"`
+}
+
+func (s *Sampler) OnStartup() {}
+
+func (s *Sampler) Name() string {
+	return "ContainerSampler"
+}
+
+func (s *Sampler) Interval() time.Duration {
+	return s.sampleRate
+}
+
+func (s *Sampler) Disabled() bool {
+	return s.Interval() <= config.FREQ_DISABLE_SAMPLING
+}
+
+func (s *Sampler) Sample() (eventBatch sample.EventBatch, err error) {
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			err = fmt.Errorf("Panic in container.Sampler: %v\nStack: %s", panicErr, debug.Stack())
+		}
+	}()
+
+	containers, err := findContainers(s.cgroupRoot)
+	if err != nil {
+		cslog.WithError(err).Debug("Unable to retrieve container cgroups.")
+		return nil, nil
+	}
+
+	metadata := s.dockerMetadata()
+	checkTime := time.Now()
+	seen := make(map[string]statsCache, len(containers))
+	for _, c := range containers {
+		ss := newSample(c, s.lastSamples[c.ID], checkTime)
+		if m, ok := metadata[c.ID]; ok {
+			decorate(ss, m)
+		}
+		ss.Type("ContainerSample")
+		eventBatch = append(eventBatch, ss)
+		seen[c.ID] = statsCache{last: c.stats, lastRun: checkTime}
+	}
+	// removing the cached stats of the containers that are gone
+	s.lastSamples = seen
+
+	return eventBatch, nil
+}
+
+// dockerMetadata returns the running Docker containers by ID, if the Docker API is available.
+func (s *Sampler) dockerMetadata() map[string]types.Container {
+	if s.dockerClient == nil {
+		if s.dockerClientRetries >= maxDockerClientRetries {
+			return nil
+		}
+		s.dockerClientRetries++
+
+		client := &helpers.DockerClient{}
+		if err := client.Initialize(s.dockerAPIVersion); err != nil {
+			cslog.WithError(err).Debug("Unable to initialize docker client.")
+			return nil
+		}
+		s.dockerClient = client
+	}
+
+	containers, err := s.dockerClient.Containers()
+	if err != nil {
+		cslog.WithError(err).Debug("Unable to list docker containers.")
+		return nil
+	}
+
+	metadata := make(map[string]types.Container, len(containers))
+	for _, c := range containers {
+		metadata[c.ID] = c
+	}
+	return metadata
+}
+
+func decorate(s *Sample, c types.Container) {
+	if len(c.Names) > 0 {
+		s.ContainerName = strings.TrimPrefix(c.Names[0], "/")
+	}
+	s.ContainerImage = c.ImageID
+	s.ContainerImageName = c.Image
+	if s.Runtime == "" {
+		s.Runtime = runtimeDocker
+	}
+}
+
+// newSample builds the sample from the current container stats, calculating the rates from the cached ones.
+func newSample(c cgroupContainer, prev statsCache, checkTime time.Time) *Sample {
+	st := c.stats
+	s := &Sample{
+		ContainerID:             c.ID,
+		Runtime:                 c.Runtime,
+		CPULimitCores:           st.CPULimitCores,
+		CPUThrottledPeriods:     &st.CPUThrottledPeriods,
+		CPUThrottledTimeSeconds: floatPtr(float64(st.CPUThrottledNs) / float64(time.Second)),
+		MemoryUsageBytes:        &st.MemoryUsageBytes,
+		MemoryLimitBytes:        st.MemoryLimitBytes,
+		MemoryCacheBytes:        &st.MemoryCacheBytes,
+		MemoryRSSBytes:          &st.MemoryRSSBytes,
+		MemoryOOMKills:          st.MemoryOOMKills,
+		IOTotalReadBytes:        &st.IOReadBytes,
+		IOTotalWriteBytes:       &st.IOWriteBytes,
+		ProcessCount:            &st.Pids,
+		ProcessCountLimit:       st.PidsLimit,
+	}
+	if st.MemoryLimitBytes != nil && *st.MemoryLimitBytes > 0 {
+		s.MemoryUsageLimitPercent = floatPtr(float64(st.MemoryUsageBytes) * 100 / float64(*st.MemoryLimitBytes))
+	}
+
+	elapsed := checkTime.Sub(prev.lastRun).Seconds()
+	if prev.lastRun.IsZero() || elapsed <= 0 {
+		return s
+	}
+	last := prev.last
+
+	if st.CPUUsageNs >= last.CPUUsageNs {
+		cores := float64(st.CPUUsageNs-last.CPUUsageNs) / float64(time.Second) / elapsed
+		s.CPUUsedCores = &cores
+		if st.CPULimitCores != nil && *st.CPULimitCores > 0 {
+			s.CPUUsedCoresPercent = floatPtr(cores * 100 / *st.CPULimitCores)
+		}
+	}
+	if st.CPUPeriods > last.CPUPeriods && st.CPUThrottledPeriods >= last.CPUThrottledPeriods {
+		throttled := float64(st.CPUThrottledPeriods - last.CPUThrottledPeriods)
+		s.CPUThrottledPeriodsPercent = floatPtr(throttled * 100 / float64(st.CPUPeriods-last.CPUPeriods))
+	}
+	s.IOReadBytesPerSec = ratePerSec(last.IOReadBytes, st.IOReadBytes, elapsed)
+	s.IOWriteBytesPerSec = ratePerSec(last.IOWriteBytes, st.IOWriteBytes, elapsed)
+	s.IOReadsPerSec = ratePerSec(last.IOReadOps, st.IOReadOps, elapsed)
+	s.IOWritesPerSec = ratePerSec(last.IOWriteOps, st.IOWriteOps, elapsed)
+
+	return s
+}
+
+// ratePerSec returns nil if the counter was reset.
+func ratePerSec(last, current uint64, elapsedSecs float64) *float64 {
+	if current < last {
+		return nil
+	}
+	return floatPtr(float64(current-last) / elapsedSecs)
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func NewSampler(context agent.AgentContext) *Sampler {
+	sampleRateSec := config.DefaultMetricsContainerSampleRate
+	apiVersion := config.DefaultDockerApiVersion
+	if context != nil {
+		sampleRateSec = context.Config().MetricsContainerSampleRate
+		apiVersion = context.Config().DockerApiVersion
+	}
+
+	return &Sampler{
+		context:          context,
+		sampleRate:       time.Second * time.Duration(sampleRateSec),
+		cgroupRoot:       helpers.HostSys("fs", "cgroup"),
+		dockerAPIVersion: apiVersion,
+		lastSamples:      map[string]statsCache{},
+	}
+}
@@ -0,0 +1,9 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build darwin
+
+package container
+
+func findContainers(root string) ([]cgroupContainer, error) {
+	return nil, nil
+}
@@ -0,0 +1,326 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build linux
+
+package container
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Container cgroups are named after the 64 hex chars container ID, optionally prefixed by the runtime and suffixed
+// with the systemd scope (e.g. docker-<id>.scope, cri-containerd-<id>.scope, libpod-<id>.scope).
+var containerCgroupRegex = regexp.MustCompile(`^(?:([a-z-]+)-)?([0-9a-f]{64})(?:\.scope)?$`)
+
+var runtimeByPrefix = map[string]string{
+	"docker":         runtimeDocker,
+	"cri-containerd": runtimeContainerd,
+	"crio":           runtimeCrio,
+	"libpod":         runtimePodman,
+}
+
+// cgroup v1 reports unlimited memory as the max page counter value, which depends on the page size.
+const cgroupV1UnlimitedMemory = uint64(1) << 62
+
+// cgroup v1 cpuacct.stat is expressed in USER_HZ, which is 100 on every supported architecture.
+const userHZ = 100
+
+// findContainers walks the cgroup hierarchy mounted at root, returning the container cgroups and their stats.
+func findContainers(root string) ([]cgroupContainer, error) {
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err == nil {
+		return collect(root, func(path string) (cgroupStats, bool) {
+			return readCgroupV2(filepath.Join(root, path))
+		})
+	}
+
+	for _, controller := range []string{"memory", "cpuacct", "pids"} {
+		if _, err := os.Stat(filepath.Join(root, controller)); err == nil {
+			return collect(filepath.Join(root, controller), func(path string) (cgroupStats, bool) {
+				return readCgroupV1(root, path)
+			})
+		}
+	}
+
+	return nil, fmt.Errorf("no cgroup hierarchy found at %s", root)
+}
+
+// collect walks base looking for container cgroups, retrieving their stats by their path relative to base.
+func collect(base string, readStats func(path string) (cgroupStats, bool)) ([]cgroupContainer, error) {
+	var containers []cgroupContainer
+	seen := map[string]bool{}
+
+	err := filepath.Walk(base, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// cgroups might be removed while walking the hierarchy
+			if path == base {
+				return err
+			}
+			return nil
+		}
+		if !info.IsDir() {
+			return nil
+		}
+
+		matches := containerCgroupRegex.FindStringSubmatch(info.Name())
+		if matches == nil {
+			return nil
+		}
+		prefix, id := matches[1], matches[2]
+		if strings.HasSuffix(prefix, "conmon") {
+			// podman and CRI-O monitor processes
+			return filepath.SkipDir
+		}
+		if seen[id] {
+			return filepath.SkipDir
+		}
+
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			return nil
+		}
+		stats, ok := readStats(rel)
+		if !ok {
+			return filepath.SkipDir
+		}
+
+		seen[id] = true
+		containers = append(containers, cgroupContainer{
+			ID:      id,
+			Runtime: inferRuntime(prefix, filepath.Base(filepath.Dir(path))),
+			stats:   stats,
+		})
+		// nested cgroups belong to the container
+		return filepath.SkipDir
+	})
+
+	return containers, err
+}
+
+func inferRuntime(prefix, parent string) string {
+	if r, ok := runtimeByPrefix[prefix]; ok {
+		return r
+	}
+	if parent == "docker" {
+		return runtimeDocker
+	}
+	return ""
+}
+
+// readCgroupV1 reads the stats from every v1 controller hierarchy. Returns false if the cgroup could not be read.
+func readCgroupV1(root, path string) (s cgroupStats, ok bool) {
+	file := func(controller, name string) string {
+		return filepath.Join(root, controller, path, name)
+	}
+
+	if v, err := readUint(file("cpuacct", "cpuacct.usage")); err == nil {
+		s.CPUUsageNs, ok = v, true
+	} else if stat, err := readKeyValues(file("cpuacct", "cpuacct.stat")); err == nil {
+		s.CPUUsageNs, ok = (stat["user"]+stat["system"])*(1e9/userHZ), true
+	}
+	if stat, err := readKeyValues(file("cpu", "cpu.stat")); err == nil {
+		s.CPUPeriods = stat["nr_periods"]
+		s.CPUThrottledPeriods = stat["nr_throttled"]
+		s.CPUThrottledNs = stat["throttled_time"]
+	}
+	quota, errQ := readInt(file("cpu", "cpu.cfs_quota_us"))
+	period, errP := readInt(file("cpu", "cpu.cfs_period_us"))
+	if errQ == nil && errP == nil && quota > 0 && period > 0 {
+		s.CPULimitCores = floatPtr(float64(quota) / float64(period))
+	}
+
+	if v, err := readUint(file("memory", "memory.usage_in_bytes")); err == nil {
+		s.MemoryUsageBytes, ok = v, true
+	}
+	if v, err := readUint(file("memory", "memory.limit_in_bytes")); err == nil && v < cgroupV1UnlimitedMemory {
+		s.MemoryLimitBytes = &v
+	}
+	if stat, err := readKeyValues(file("memory", "memory.stat")); err == nil {
+		s.MemoryCacheBytes = firstOf(stat, "total_cache", "cache")
+		s.MemoryRSSBytes = firstOf(stat, "total_rss", "rss")
+	}
+	if oom, err := readKeyValues(file("memory", "memory.oom_control")); err == nil {
+		if v, found := oom["oom_kill"]; found {
+			s.MemoryOOMKills = &v
+		}
+	}
+
+	if bytes, err := readBlkioV1(file("blkio", "blkio.throttle.io_service_bytes")); err == nil {
+		s.IOReadBytes, s.IOWriteBytes = bytes["Read"], bytes["Write"]
+	}
+	if ops, err := readBlkioV1(file("blkio", "blkio.throttle.io_serviced")); err == nil {
+		s.IOReadOps, s.IOWriteOps = ops["Read"], ops["Write"]
+	}
+
+	if v, err := readUint(file("pids", "pids.current")); err == nil {
+		s.Pids, ok = v, true
+	}
+	s.PidsLimit = readLimit(file("pids", "pids.max"))
+
+	return s, ok
+}
+
+// readCgroupV2 reads the stats from the unified hierarchy. Returns false if the cgroup could not be read.
+func readCgroupV2(dir string) (s cgroupStats, ok bool) {
+	file := func(name string) string {
+		return filepath.Join(dir, name)
+	}
+
+	if stat, err := readKeyValues(file("cpu.stat")); err == nil {
+		ok = true
+		s.CPUUsageNs = stat["usage_usec"] * 1000
+		s.CPUPeriods = stat["nr_periods"]
+		s.CPUThrottledPeriods = stat["nr_throttled"]
+		s.CPUThrottledNs = stat["throttled_usec"] * 1000
+	}
+	if content, err := ioutil.ReadFile(file("cpu.max")); err == nil {
+		fields := strings.Fields(string(content))
+		if len(fields) == 2 && fields[0] != "max" {
+			quota, errQ := strconv.ParseFloat(fields[0], 64)
+			period, errP := strconv.ParseFloat(fields[1], 64)
+			if errQ == nil && errP == nil && period > 0 {
+				s.CPULimitCores = floatPtr(quota / period)
+			}
+		}
+	}
+
+	if v, err := readUint(file("memory.current")); err == nil {
+		s.MemoryUsageBytes, ok = v, true
+	}
+	s.MemoryLimitBytes = readLimit(file("memory.max"))
+	if stat, err := readKeyValues(file("memory.stat")); err == nil {
+		s.MemoryCacheBytes = stat["file"]
+		s.MemoryRSSBytes = stat["anon"]
+	}
+	if events, err := readKeyValues(file("memory.events")); err == nil {
+		if v, found := events["oom_kill"]; found {
+			s.MemoryOOMKills = &v
+		}
+	}
+
+	if devices, err := readIOStatV2(file("io.stat")); err == nil {
+		s.IOReadBytes = devices["rbytes"]
+		s.IOWriteBytes = devices["wbytes"]
+		s.IOReadOps = devices["rios"]
+		s.IOWriteOps = devices["wios"]
+	}
+
+	if v, err := readUint(file("pids.current")); err == nil {
+		s.Pids, ok = v, true
+	}
+	s.PidsLimit = readLimit(file("pids.max"))
+
+	return s, ok
+}
+
+func readUint(path string) (uint64, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+}
+
+func readInt(path string) (int64, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+}
+
+// readLimit returns nil when the limit is "max" or cannot be read.
+func readLimit(path string) *uint64 {
+	v, err := readUint(path)
+	if err != nil {
+		return nil
+	}
+	return &v
+}
+
+// readKeyValues parses flat keyed files formatted as "<key> <value>" lines.
+func readKeyValues(path string) (map[string]uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := map[string]uint64{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if v, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			values[fields[0]] = v
+		}
+	}
+	return values, scanner.Err()
+}
+
+// readBlkioV1 sums per operation the "<major>:<minor> <operation> <value>" lines of every device.
+func readBlkioV1(path string) (map[string]uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := map[string]uint64{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 {
+			continue
+		}
+		if v, err := strconv.ParseUint(fields[2], 10, 64); err == nil {
+			values[fields[1]] += v
+		}
+	}
+	return values, scanner.Err()
+}
+
+// readIOStatV2 sums per key the "<major>:<minor> <key>=<value>..." lines of every device.
+func readIOStatV2(path string) (map[string]uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := map[string]uint64{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			if v, err := strconv.ParseUint(kv[1], 10, 64); err == nil {
+				values[kv[0]] += v
+			}
+		}
+	}
+	return values, scanner.Err()
+}
+
+func firstOf(values map[string]uint64, keys ...string) uint64 {
+	for _, k := range keys {
+		if v, ok := values[k]; ok {
+			return v
+		}
+	}
+	return 0
+}
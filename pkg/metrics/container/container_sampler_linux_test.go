@@ -0,0 +1,186 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build linux
+
+package container
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	dockerID  = strings.Repeat("a", 64)
+	podmanID  = strings.Repeat("b", 64)
+	kubeID    = strings.Repeat("c", 64)
+	conmonID  = strings.Repeat("d", 64)
+	nestedDir = "init.scope"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	for name, content := range files {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+}
+
+func sortByID(containers []cgroupContainer) {
+	sort.Slice(containers, func(i, j int) bool {
+		return containers[i].ID < containers[j].ID
+	})
+}
+
+func TestFindContainers_CgroupV2(t *testing.T) {
+	root, err := ioutil.TempDir("", "cgroup")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	writeFiles(t, root, map[string]string{"cgroup.controllers": "cpu io memory pids"})
+	containerFiles := map[string]string{
+		"cpu.stat":       "usage_usec 2000000\nuser_usec 1500000\nsystem_usec 500000\nnr_periods 100\nnr_throttled 10\nthrottled_usec 300000\n",
+		"cpu.max":        "50000 100000\n",
+		"memory.current": "1048576\n",
+		"memory.max":     "2097152\n",
+		"memory.stat":    "anon 524288\nfile 262144\nkernel_stack 16384\n",
+		"memory.events":  "low 0\nhigh 0\nmax 4\noom 2\noom_kill 1\n",
+		"io.stat":        "8:0 rbytes=1000 wbytes=2000 rios=10 wios=20 dbytes=0 dios=0\n8:16 rbytes=500 wbytes=0 rios=5 wios=0 dbytes=0 dios=0\n",
+		"pids.current":   "3\n",
+		"pids.max":       "max\n",
+	}
+	writeFiles(t, filepath.Join(root, "system.slice", "docker-"+dockerID+".scope"), containerFiles)
+	writeFiles(t, filepath.Join(root, "system.slice", "docker-"+dockerID+".scope", nestedDir), containerFiles)
+	writeFiles(t, filepath.Join(root, "machine.slice", "libpod-"+podmanID+".scope"), map[string]string{
+		"cpu.stat":     "usage_usec 10\n",
+		"cpu.max":      "max 100000\n",
+		"memory.max":   "max\n",
+		"pids.current": "1\n",
+	})
+	writeFiles(t, filepath.Join(root, "machine.slice", "libpod-conmon-"+conmonID+".scope"), containerFiles)
+	writeFiles(t, filepath.Join(root, "user.slice"), map[string]string{"cpu.stat": "usage_usec 10\n"})
+
+	containers, err := findContainers(root)
+	require.NoError(t, err)
+	require.Len(t, containers, 2)
+	sortByID(containers)
+
+	docker := containers[0]
+	assert.Equal(t, dockerID, docker.ID)
+	assert.Equal(t, runtimeDocker, docker.Runtime)
+	s := docker.stats
+	assert.Equal(t, uint64(2000000000), s.CPUUsageNs)
+	assert.Equal(t, uint64(100), s.CPUPeriods)
+	assert.Equal(t, uint64(10), s.CPUThrottledPeriods)
+	assert.Equal(t, uint64(300000000), s.CPUThrottledNs)
+	require.NotNil(t, s.CPULimitCores)
+	assert.Equal(t, 0.5, *s.CPULimitCores)
+	assert.Equal(t, uint64(1048576), s.MemoryUsageBytes)
+	require.NotNil(t, s.MemoryLimitBytes)
+	assert.Equal(t, uint64(2097152), *s.MemoryLimitBytes)
+	assert.Equal(t, uint64(262144), s.MemoryCacheBytes)
+	assert.Equal(t, uint64(524288), s.MemoryRSSBytes)
+	require.NotNil(t, s.MemoryOOMKills)
+	assert.Equal(t, uint64(1), *s.MemoryOOMKills)
+	assert.Equal(t, uint64(1500), s.IOReadBytes)
+	assert.Equal(t, uint64(2000), s.IOWriteBytes)
+	assert.Equal(t, uint64(15), s.IOReadOps)
+	assert.Equal(t, uint64(20), s.IOWriteOps)
+	assert.Equal(t, uint64(3), s.Pids)
+	assert.Nil(t, s.PidsLimit)
+
+	podman := containers[1]
+	assert.Equal(t, podmanID, podman.ID)
+	assert.Equal(t, runtimePodman, podman.Runtime)
+	assert.Nil(t, podman.stats.CPULimitCores)
+	assert.Nil(t, podman.stats.MemoryLimitBytes)
+	assert.Nil(t, podman.stats.MemoryOOMKills)
+}
+
+func TestFindContainers_CgroupV1(t *testing.T) {
+	root, err := ioutil.TempDir("", "cgroup")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	writeFiles(t, filepath.Join(root, "cpuacct", "docker", dockerID), map[string]string{
+		"cpuacct.usage": "3000000000\n",
+	})
+	writeFiles(t, filepath.Join(root, "cpu", "docker", dockerID), map[string]string{
+		"cpu.stat":          "nr_periods 200\nnr_throttled 50\nthrottled_time 1000000000\n",
+		"cpu.cfs_quota_us":  "200000\n",
+		"cpu.cfs_period_us": "100000\n",
+	})
+	writeFiles(t, filepath.Join(root, "memory", "docker", dockerID), map[string]string{
+		"memory.usage_in_bytes": "4096\n",
+		"memory.limit_in_bytes": "9223372036854771712\n",
+		"memory.stat":           "cache 10\nrss 20\ntotal_cache 100\ntotal_rss 200\n",
+		"memory.oom_control":    "oom_kill_disable 0\nunder_oom 0\noom_kill 3\n",
+	})
+	writeFiles(t, filepath.Join(root, "blkio", "docker", dockerID), map[string]string{
+		"blkio.throttle.io_service_bytes": "8:0 Read 100\n8:0 Write 200\n8:0 Total 300\n8:16 Read 1\n8:16 Write 2\nTotal 303\n",
+		"blkio.throttle.io_serviced":      "8:0 Read 1\n8:0 Write 2\n8:0 Total 3\nTotal 3\n",
+	})
+	writeFiles(t, filepath.Join(root, "pids", "docker", dockerID), map[string]string{
+		"pids.current": "7\n",
+		"pids.max":     "100\n",
+	})
+	kubeDir := filepath.Join("kubepods", "burstable", "pod1234", kubeID)
+	writeFiles(t, filepath.Join(root, "cpuacct", kubeDir), map[string]string{
+		"cpuacct.stat": "user 150\nsystem 50\n",
+	})
+	writeFiles(t, filepath.Join(root, "memory", kubeDir), map[string]string{
+		"memory.usage_in_bytes": "8192\n",
+		"memory.limit_in_bytes": "16384\n",
+	})
+
+	containers, err := findContainers(root)
+	require.NoError(t, err)
+	require.Len(t, containers, 2)
+	sortByID(containers)
+
+	docker := containers[0]
+	assert.Equal(t, dockerID, docker.ID)
+	assert.Equal(t, runtimeDocker, docker.Runtime)
+	s := docker.stats
+	assert.Equal(t, uint64(3000000000), s.CPUUsageNs)
+	assert.Equal(t, uint64(200), s.CPUPeriods)
+	assert.Equal(t, uint64(50), s.CPUThrottledPeriods)
+	assert.Equal(t, uint64(1000000000), s.CPUThrottledNs)
+	require.NotNil(t, s.CPULimitCores)
+	assert.Equal(t, 2.0, *s.CPULimitCores)
+	assert.Equal(t, uint64(4096), s.MemoryUsageBytes)
+	assert.Nil(t, s.MemoryLimitBytes)
+	assert.Equal(t, uint64(100), s.MemoryCacheBytes)
+	assert.Equal(t, uint64(200), s.MemoryRSSBytes)
+	require.NotNil(t, s.MemoryOOMKills)
+	assert.Equal(t, uint64(3), *s.MemoryOOMKills)
+	assert.Equal(t, uint64(101), s.IOReadBytes)
+	assert.Equal(t, uint64(202), s.IOWriteBytes)
+	assert.Equal(t, uint64(1), s.IOReadOps)
+	assert.Equal(t, uint64(2), s.IOWriteOps)
+	assert.Equal(t, uint64(7), s.Pids)
+	require.NotNil(t, s.PidsLimit)
+	assert.Equal(t, uint64(100), *s.PidsLimit)
+
+	kube := containers[1]
+	assert.Equal(t, kubeID, kube.ID)
+	assert.Empty(t, kube.Runtime)
+	assert.Equal(t, uint64(2000000000), kube.stats.CPUUsageNs)
+	assert.Nil(t, kube.stats.CPULimitCores)
+	require.NotNil(t, kube.stats.MemoryLimitBytes)
+	assert.Equal(t, uint64(16384), *kube.stats.MemoryLimitBytes)
+}
+
+func TestFindContainers_NoHierarchy(t *testing.T) {
+	root, err := ioutil.TempDir("", "cgroup")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	_, err = findContainers(root)
+	assert.Error(t, err)
+}
@@ -0,0 +1,115 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package container
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeDocker struct {
+	containers []types.Container
+}
+
+func (f *fakeDocker) Initialize(apiVersion string) error { return nil }
+
+func (f *fakeDocker) Containers() ([]types.Container, error) { return f.containers, nil }
+
+func (f *fakeDocker) ContainerTop(containerID string) ([]string, [][]string, error) {
+	return nil, nil, nil
+}
+
+func uintPtr(u uint64) *uint64 {
+	return &u
+}
+
+func TestNewSample_FirstRun(t *testing.T) {
+	c := cgroupContainer{
+		ID: "abc",
+		stats: cgroupStats{
+			CPUUsageNs:       1e9,
+			MemoryUsageBytes: 50,
+			MemoryLimitBytes: uintPtr(200),
+			IOReadBytes:      10,
+		},
+	}
+
+	s := newSample(c, statsCache{}, time.Now())
+
+	assert.Equal(t, "abc", s.ContainerID)
+	assert.Nil(t, s.CPUUsedCores)
+	assert.Nil(t, s.IOReadBytesPerSec)
+	require.NotNil(t, s.MemoryUsageLimitPercent)
+	assert.Equal(t, 25.0, *s.MemoryUsageLimitPercent)
+	assert.Equal(t, uint64(10), *s.IOTotalReadBytes)
+}
+
+func TestNewSample_Rates(t *testing.T) {
+	now := time.Now()
+	limit := 2.0
+	prev := statsCache{
+		lastRun: now.Add(-10 * time.Second),
+		last: cgroupStats{
+			CPUUsageNs:          5e9,
+			CPUPeriods:          100,
+			CPUThrottledPeriods: 10,
+			IOReadBytes:         1000,
+			IOWriteBytes:        500,
+			IOReadOps:           10,
+			IOWriteOps:          100,
+		},
+	}
+	c := cgroupContainer{
+		ID: "abc",
+		stats: cgroupStats{
+			CPUUsageNs:          15e9,
+			CPUPeriods:          200,
+			CPUThrottledPeriods: 35,
+			CPULimitCores:       &limit,
+			IOReadBytes:         2000,
+			IOWriteBytes:        100, // counter reset
+			IOReadOps:           30,
+			IOWriteOps:          100,
+		},
+	}
+
+	s := newSample(c, prev, now)
+
+	require.NotNil(t, s.CPUUsedCores)
+	assert.Equal(t, 1.0, *s.CPUUsedCores)
+	require.NotNil(t, s.CPUUsedCoresPercent)
+	assert.Equal(t, 50.0, *s.CPUUsedCoresPercent)
+	require.NotNil(t, s.CPUThrottledPeriodsPercent)
+	assert.Equal(t, 25.0, *s.CPUThrottledPeriodsPercent)
+	assert.Equal(t, 100.0, *s.IOReadBytesPerSec)
+	assert.Nil(t, s.IOWriteBytesPerSec)
+	assert.Equal(t, 2.0, *s.IOReadsPerSec)
+	assert.Equal(t, 0.0, *s.IOWritesPerSec)
+}
+
+func TestSampler_DockerMetadata(t *testing.T) {
+	s := &Sampler{dockerClient: &fakeDocker{containers: []types.Container{
+		{ID: "abc", Names: []string{"/web"}, Image: "nginx:latest", ImageID: "sha256:123"},
+	}}}
+
+	metadata := s.dockerMetadata()
+	require.Contains(t, metadata, "abc")
+
+	sample := newSample(cgroupContainer{ID: "abc"}, statsCache{}, time.Now())
+	decorate(sample, metadata["abc"])
+	assert.Equal(t, "web", sample.ContainerName)
+	assert.Equal(t, "nginx:latest", sample.ContainerImageName)
+	assert.Equal(t, "sha256:123", sample.ContainerImage)
+	assert.Equal(t, runtimeDocker, sample.Runtime)
+}
+
+func TestSampler_DockerClientRetriesBounded(t *testing.T) {
+	s := &Sampler{dockerClientRetries: maxDockerClientRetries}
+
+	assert.Nil(t, s.dockerMetadata())
+	assert.Equal(t, maxDockerClientRetries, s.dockerClientRetries)
+}
@@ -0,0 +1,261 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/cpu"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/acquire"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+// Softirq types reported individually by the CpuCoreSample, as named in /proc/softirqs.
+const (
+	softirqNetRx = "NET_RX"
+	softirqNetTx = "NET_TX"
+	softirqTimer = "TIMER"
+	softirqBlock = "BLOCK"
+	softirqSched = "SCHED"
+)
+
+// CPUCoreSample usage of a single logical CPU. Usage and rates are calculated since the previous sample, so the first
+// sample of each core is not reported.
+type CPUCoreSample struct {
+	sample.BaseEvent
+	*CPUSample
+
+	// Logical CPU, as named by the kernel: cpu0, cpu1...
+	Core string `json:"core"`
+	// Current scaling frequency, when cpufreq is available
+	FrequencyMHz *float64 `json:"cpuFrequencyMHz,omitempty"`
+
+	InterruptsPerSec   *float64 `json:"interruptsPerSecond,omitempty"`
+	SoftirqsPerSec     *float64 `json:"softirqsPerSecond,omitempty"`
+	SoftirqNetRxPerSec *float64 `json:"softirqNetRxPerSecond,omitempty"`
+	SoftirqNetTxPerSec *float64 `json:"softirqNetTxPerSecond,omitempty"`
+	SoftirqTimerPerSec *float64 `json:"softirqTimerPerSecond,omitempty"`
+	SoftirqBlockPerSec *float64 `json:"softirqBlockPerSecond,omitempty"`
+	SoftirqSchedPerSec *float64 `json:"softirqSchedPerSecond,omitempty"`
+}
+
+// coreCounters interrupts and softirqs served by a logical CPU since boot.
+type coreCounters struct {
+	interrupts uint64
+	softirqs   map[string]uint64
+}
+
+// CPUCoreSampler reports a CpuCoreSample for each logical CPU, from the per CPU times, the /proc/interrupts and
+// /proc/softirqs counters and the cpufreq scaling frequency.
+type CPUCoreSampler struct {
+	context      agent.AgentContext
+	sampleRate   time.Duration
+	procDir      string
+	cpuDir       string
+	cpuTimes     func(bool) ([]cpu.TimesStat, error)
+	lastTimes    map[string]cpu.TimesStat
+	lastCounters map[string]*coreCounters
+	lastRun      time.Time
+}
+
+func NewCPUCoreSampler(context agent.AgentContext) *CPUCoreSampler {
+	sampleRateSec := config.DefaultMetricsCPUCoreSampleRate
+	if context != nil {
+		sampleRateSec = context.Config().MetricsCPUCoreSampleRate
+	}
+
+	return &CPUCoreSampler{
+		context:    context,
+		sampleRate: time.Second * time.Duration(sampleRateSec),
+		procDir:    helpers.HostProc(),
+		cpuDir:     helpers.HostSys("devices", "system", "cpu"),
+		cpuTimes:   cpu.Times,
+	}
+}
+
+func (s *CPUCoreSampler) Name() string { return "CPUCoreSampler" }
+
+func (s *CPUCoreSampler) Interval() time.Duration {
+	return s.sampleRate
+}
+
+func (s *CPUCoreSampler) Disabled() bool {
+	return s.Interval() <= config.FREQ_DISABLE_SAMPLING
+}
+
+func (s *CPUCoreSampler) OnStartup() {}
+
+func (s *CPUCoreSampler) Sample() (results sample.EventBatch, err error) {
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			err = fmt.Errorf("Panic in CPUCoreSampler.Sample: %v\nStack: %s", panicErr, debug.Stack())
+		}
+	}()
+
+	times, err := s.cpuTimes(true)
+	if err != nil {
+		return nil, err
+	}
+	counters, err := readCoreCounters(s.procDir)
+	if err != nil {
+		syslog.WithError(err).Debug("Unable to read interrupts and softirqs.")
+	}
+
+	now := time.Now()
+	elapsed := now.Sub(s.lastRun).Seconds()
+	lastTimes, lastCounters := s.lastTimes, s.lastCounters
+	s.lastTimes, s.lastCounters, s.lastRun = make(map[string]cpu.TimesStat, len(times)), counters, now
+
+	for i := range times {
+		current := times[i]
+		s.lastTimes[current.CPU] = current
+		last, ok := lastTimes[current.CPU]
+		if !ok {
+			continue
+		}
+
+		coreSample := &CPUCoreSample{
+			CPUSample:    newCPUSample(cpuDelta(&current, &last)),
+			Core:         current.CPU,
+			FrequencyMHz: readFrequencyMHz(s.cpuDir, current.CPU),
+		}
+		if c, ok := counters[current.CPU]; ok {
+			if l, ok := lastCounters[current.CPU]; ok {
+				populateCoreRates(coreSample, c, l, elapsed)
+			}
+		}
+		coreSample.Type("CpuCoreSample")
+		results = append(results, coreSample)
+	}
+	return results, nil
+}
+
+// populateCoreRates sets the interrupts and softirqs rates of the sample.
+func populateCoreRates(s *CPUCoreSample, current, last *coreCounters, elapsedSecs float64) {
+	rate := func(c, l uint64) *float64 {
+		r := acquire.CalculateSafeDelta(c, l, elapsedSecs)
+		return &r
+	}
+	softirqRate := func(name string) *float64 {
+		c, ok := current.softirqs[name]
+		if !ok {
+			return nil
+		}
+		return rate(c, last.softirqs[name])
+	}
+
+	var softirqs, lastSoftirqs uint64
+	for name, c := range current.softirqs {
+		softirqs += c
+		lastSoftirqs += last.softirqs[name]
+	}
+
+	s.InterruptsPerSec = rate(current.interrupts, last.interrupts)
+	if len(current.softirqs) > 0 {
+		s.SoftirqsPerSec = rate(softirqs, lastSoftirqs)
+	}
+	s.SoftirqNetRxPerSec = softirqRate(softirqNetRx)
+	s.SoftirqNetTxPerSec = softirqRate(softirqNetTx)
+	s.SoftirqTimerPerSec = softirqRate(softirqTimer)
+	s.SoftirqBlockPerSec = softirqRate(softirqBlock)
+	s.SoftirqSchedPerSec = softirqRate(softirqSched)
+}
+
+// readCoreCounters reads the interrupts and softirqs per logical CPU. Returns an empty map if the files are not
+// available (e.g. non Linux systems).
+func readCoreCounters(procDir string) (map[string]*coreCounters, error) {
+	counters := map[string]*coreCounters{}
+	get := func(core string) *coreCounters {
+		c, ok := counters[core]
+		if !ok {
+			c = &coreCounters{softirqs: map[string]uint64{}}
+			counters[core] = c
+		}
+		return c
+	}
+
+	err := readPerCPUTable(filepath.Join(procDir, "interrupts"), func(_, core string, value uint64) {
+		get(core).interrupts += value
+	})
+	if err != nil {
+		return counters, err
+	}
+	err = readPerCPUTable(filepath.Join(procDir, "softirqs"), func(name, core string, value uint64) {
+		get(core).softirqs[name] = value
+	})
+	return counters, err
+}
+
+// readPerCPUTable parses the tables of the /proc/interrupts and /proc/softirqs files, where the header names the
+// online CPUs and each line holds a counter per CPU, optionally followed by a description:
+//
+//	                  CPU0       CPU1
+//	         HI:          0          1
+//	24:          1          0  IO-APIC   5-edge      ACPI:Ged
+//
+// Lines without a value for each CPU (e.g. ERR, MIS) are skipped.
+func readPerCPUTable(file string, fn func(name, core string, value uint64)) error {
+	lines, err := acquire.ReadLines(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+
+	cores := strings.Fields(lines[0])
+	for i := range cores {
+		cores[i] = strings.ToLower(cores[i])
+	}
+	values := make([]uint64, len(cores))
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) < len(cores)+1 {
+			continue
+		}
+		valid := true
+		for i := range cores {
+			if values[i], err = strconv.ParseUint(fields[i+1], 10, 64); err != nil {
+				valid = false
+				break
+			}
+		}
+		if !valid {
+			continue
+		}
+		name := strings.TrimSuffix(fields[0], ":")
+		for i, core := range cores {
+			fn(name, core, values[i])
+		}
+	}
+	return nil
+}
+
+// readFrequencyMHz returns the current scaling frequency of a CPU, or nil if cpufreq is not available.
+func readFrequencyMHz(cpuDir, core string) *float64 {
+	content, err := ioutil.ReadFile(filepath.Join(cpuDir, core, "cpufreq", "scaling_cur_freq"))
+	if err != nil {
+		return nil
+	}
+	khz, err := strconv.ParseFloat(strings.TrimSpace(string(content)), 64)
+	if err != nil {
+		return nil
+	}
+	mhz := khz / 1000
+	return &mhz
+}
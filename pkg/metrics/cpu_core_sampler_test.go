@@ -0,0 +1,122 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/cpu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	interruptsFixture = `           CPU0       CPU1
+  0:         10          0   IO-APIC   2-edge      timer
+ 24:        100        200   PCI-MSI 65536-edge      eth0
+NMI:          1          2   Non-maskable interrupts
+ERR:          0
+MIS:          0
+`
+	softirqsFixture = `                    CPU0       CPU1
+          HI:          0          1
+       TIMER:       1000       2000
+      NET_TX:         10         20
+      NET_RX:        100        200
+       BLOCK:          5          0
+       SCHED:        300        400
+`
+)
+
+func writeCPUCoreFixtures(t *testing.T, procDir, cpuDir, interrupts, softirqs string) {
+	require.NoError(t, ioutil.WriteFile(filepath.Join(procDir, "interrupts"), []byte(interrupts), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(procDir, "softirqs"), []byte(softirqs), 0644))
+	freqDir := filepath.Join(cpuDir, "cpu0", "cpufreq")
+	require.NoError(t, os.MkdirAll(freqDir, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(freqDir, "scaling_cur_freq"), []byte("2400000\n"), 0644))
+}
+
+func TestReadCoreCounters(t *testing.T) {
+	dir, err := ioutil.TempDir("", "proc")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	writeCPUCoreFixtures(t, dir, dir, interruptsFixture, softirqsFixture)
+
+	counters, err := readCoreCounters(dir)
+	require.NoError(t, err)
+
+	require.Len(t, counters, 2)
+	assert.Equal(t, uint64(111), counters["cpu0"].interrupts)
+	assert.Equal(t, uint64(202), counters["cpu1"].interrupts)
+	assert.Equal(t, uint64(100), counters["cpu0"].softirqs[softirqNetRx])
+	assert.Equal(t, uint64(2000), counters["cpu1"].softirqs[softirqTimer])
+}
+
+func TestReadCoreCounters_Unavailable(t *testing.T) {
+	counters, err := readCoreCounters("/non/existing/proc")
+	assert.NoError(t, err)
+	assert.Empty(t, counters)
+}
+
+func TestCPUCoreSampler_Sample(t *testing.T) {
+	dir, err := ioutil.TempDir("", "proc")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	writeCPUCoreFixtures(t, dir, dir, interruptsFixture, softirqsFixture)
+
+	times := []cpu.TimesStat{
+		{CPU: "cpu0", User: 10, System: 10, Idle: 80},
+		{CPU: "cpu1", User: 0, System: 0, Idle: 100},
+	}
+	sampler := &CPUCoreSampler{
+		procDir: dir,
+		cpuDir:  dir,
+		cpuTimes: func(perCPU bool) ([]cpu.TimesStat, error) {
+			assert.True(t, perCPU)
+			return times, nil
+		},
+	}
+
+	results, err := sampler.Sample()
+	require.NoError(t, err)
+	assert.Empty(t, results, "usage requires a previous reading")
+
+	// pretend the previous reading happened 10 seconds ago
+	sampler.lastRun = time.Now().Add(-10 * time.Second)
+	times = []cpu.TimesStat{
+		{CPU: "cpu0", User: 100, System: 10, Idle: 80},
+		{CPU: "cpu1", User: 0, System: 0, Idle: 200, Iowait: 100},
+	}
+	writeCPUCoreFixtures(t, dir, dir,
+		`           CPU0       CPU1
+ 24:        600        200   PCI-MSI 65536-edge      eth0
+`,
+		`                    CPU0       CPU1
+      NET_RX:       1100        200
+`)
+
+	results, err = sampler.Sample()
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	cpu0 := results[0].(*CPUCoreSample)
+	assert.Equal(t, "CpuCoreSample", cpu0.EventType)
+	assert.Equal(t, "cpu0", cpu0.Core)
+	assert.Equal(t, 100.0, cpu0.CPUPercent)
+	assert.Equal(t, 100.0, cpu0.CPUUserPercent)
+	assert.Equal(t, 2400.0, *cpu0.FrequencyMHz)
+	assert.InDelta(t, 48.9, *cpu0.InterruptsPerSec, 0.1)
+	assert.InDelta(t, 100, *cpu0.SoftirqNetRxPerSec, 1)
+	assert.Nil(t, cpu0.SoftirqTimerPerSec)
+
+	cpu1 := results[1].(*CPUCoreSample)
+	assert.Equal(t, "cpu1", cpu1.Core)
+	assert.Equal(t, 50.0, cpu1.CPUIOWaitPercent)
+	assert.Equal(t, 50.0, cpu1.CPUIdlePercent)
+	assert.Nil(t, cpu1.FrequencyMHz)
+	assert.Equal(t, 0.0, *cpu1.InterruptsPerSec, "counter reset")
+}
@@ -0,0 +1,264 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package file
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+var fslog = log.WithComponent("FileSampler")
+
+// defaultMaxDepth levels scanned by the recursive monitors without an explicit max_depth.
+const defaultMaxDepth = 10
+
+// Values of the error attribute, reported instead of the metrics when a path can't be fully scanned.
+const (
+	errTimeout        = "scan timed out"
+	errScanInProgress = "previous scan still in progress"
+	errBadPattern     = "invalid path pattern"
+)
+
+// Sample aggregated metrics of the files matching a monitored path. For directories, their contained files are
+// reported instead.
+type Sample struct {
+	sample.BaseEvent
+
+	Path   string `json:"path"`
+	Exists string `json:"exists"`
+	// Regular files found
+	FileCount *uint64 `json:"fileCount,omitempty"`
+	// Directories found below the monitored ones
+	DirectoryCount *uint64 `json:"directoryCount,omitempty"`
+	// Sum of the sizes of the regular files
+	SizeBytes *uint64 `json:"sizeBytes,omitempty"`
+	// Seconds since the modification of the oldest and newest regular files
+	OldestFileAgeSeconds *float64 `json:"oldestFileAgeSeconds,omitempty"`
+	NewestFileAgeSeconds *float64 `json:"newestFileAgeSeconds,omitempty"`
+	Error                string   `json:"error,omitempty"`
+}
+
+// Sampler reports a FileSample for each configured file monitor. Each path is scanned in its own goroutine, bounded
+// by the scan timeout, so slow or huge trees can't block the metrics sender.
+type Sampler struct {
+	context    agent.AgentContext
+	sampleRate time.Duration
+	timeout    time.Duration
+	monitors   config.FileMonitors
+	// paths whose scan is still running, after timing out
+	scanning     map[string]bool
+	scanningLock sync.Mutex
+}
+
+func NewSampler(context agent.AgentContext) *Sampler {
+	sampleRateSec := config.DefaultMetricsFileSampleRate
+	timeoutSec := config.DefaultMetricsFileScanTimeoutSec
+	var monitors config.FileMonitors
+	if context != nil {
+		cfg := context.Config()
+		sampleRateSec = cfg.MetricsFileSampleRate
+		monitors = cfg.MetricsFileMonitors
+		if cfg.MetricsFileScanTimeoutSec > 0 {
+			timeoutSec = cfg.MetricsFileScanTimeoutSec
+		}
+	}
+
+	return &Sampler{
+		context:    context,
+		sampleRate: time.Second * time.Duration(sampleRateSec),
+		timeout:    time.Second * time.Duration(timeoutSec),
+		monitors:   monitors,
+		scanning:   map[string]bool{},
+	}
+}
+
+func (s *Sampler) Name() string { return "FileSampler" }
+
+func (s *Sampler) Interval() time.Duration {
+	return s.sampleRate
+}
+
+func (s *Sampler) Disabled() bool {
+	return s.Interval() <= config.FREQ_DISABLE_SAMPLING || len(s.monitors) == 0
+}
+
+func (s *Sampler) OnStartup() {}
+
+func (s *Sampler) Sample() (results sample.EventBatch, err error) {
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			err = fmt.Errorf("Panic in FileSampler.Sample: %v\nStack: %s", panicErr, debug.Stack())
+		}
+	}()
+
+	for _, monitor := range s.monitors {
+		fileSample := s.scan(monitor)
+		fileSample.Type("FileSample")
+		results = append(results, fileSample)
+	}
+	return results, nil
+}
+
+// scan returns the sample of a monitor, or a sample only with the error attribute if it can't be scanned before the
+// timeout. Timed out scans are cancelled, and the path is not scanned again until they finish.
+func (s *Sampler) scan(monitor config.FileMonitor) *Sample {
+	if !s.startScan(monitor.Path) {
+		return &Sample{Path: monitor.Path, Error: errScanInProgress}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	done := make(chan *Sample, 1)
+	go func() {
+		defer s.endScan(monitor.Path)
+		done <- scanPath(ctx, monitor, time.Now())
+	}()
+
+	select {
+	case fileSample := <-done:
+		return fileSample
+	case <-ctx.Done():
+		fslog.WithField("path", monitor.Path).Warn("Scan timed out.")
+		return &Sample{Path: monitor.Path, Error: errTimeout}
+	}
+}
+
+func (s *Sampler) startScan(path string) bool {
+	s.scanningLock.Lock()
+	defer s.scanningLock.Unlock()
+	if s.scanning[path] {
+		return false
+	}
+	s.scanning[path] = true
+	return true
+}
+
+func (s *Sampler) endScan(path string) {
+	s.scanningLock.Lock()
+	defer s.scanningLock.Unlock()
+	delete(s.scanning, path)
+}
+
+// scanStats metrics accumulated while scanning a monitor.
+type scanStats struct {
+	files       uint64
+	directories uint64
+	sizeBytes   uint64
+	oldest      time.Time
+	newest      time.Time
+}
+
+func (st *scanStats) addFile(info os.FileInfo) {
+	st.files++
+	st.sizeBytes += uint64(info.Size())
+	mtime := info.ModTime()
+	if st.oldest.IsZero() || mtime.Before(st.oldest) {
+		st.oldest = mtime
+	}
+	if mtime.After(st.newest) {
+		st.newest = mtime
+	}
+}
+
+// scanPath scans the files and directories matching the monitor path, stopping when the context is done.
+func scanPath(ctx context.Context, monitor config.FileMonitor, now time.Time) *Sample {
+	fileSample := &Sample{Path: monitor.Path, Exists: "false"}
+
+	matches, err := filepath.Glob(monitor.Path)
+	if err != nil {
+		fileSample.Error = errBadPattern
+		return fileSample
+	}
+
+	maxDepth := 1
+	if monitor.Recursive {
+		maxDepth = defaultMaxDepth
+		if monitor.MaxDepth > 0 {
+			maxDepth = monitor.MaxDepth
+		}
+	}
+
+	stats := &scanStats{}
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil {
+			continue
+		}
+		fileSample.Exists = "true"
+		if !info.IsDir() {
+			if info.Mode().IsRegular() {
+				stats.addFile(info)
+			}
+			continue
+		}
+		if err := scanDir(ctx, match, maxDepth, stats); err != nil {
+			fileSample.Error = errTimeout
+			return fileSample
+		}
+	}
+	if fileSample.Exists == "false" {
+		return fileSample
+	}
+
+	fileSample.FileCount = &stats.files
+	fileSample.DirectoryCount = &stats.directories
+	fileSample.SizeBytes = &stats.sizeBytes
+	if stats.files > 0 {
+		oldestAge := age(now, stats.oldest)
+		newestAge := age(now, stats.newest)
+		fileSample.OldestFileAgeSeconds = &oldestAge
+		fileSample.NewestFileAgeSeconds = &newestAge
+	}
+	return fileSample
+}
+
+// scanDir accumulates the stats of the directory entries, descending up to depth levels. Symbolic links are not
+// followed. Returns the context error if it's done before finishing.
+func scanDir(ctx context.Context, dir string, depth int, stats *scanStats) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		fslog.WithError(err).WithField("dir", dir).Debug("Can't read directory.")
+		return nil
+	}
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		switch {
+		case entry.IsDir():
+			stats.directories++
+			if depth > 1 {
+				if err := scanDir(ctx, filepath.Join(dir, entry.Name()), depth-1, stats); err != nil {
+					return err
+				}
+			}
+		case entry.Mode().IsRegular():
+			stats.addFile(entry)
+		}
+	}
+	return nil
+}
+
+// age returns the seconds elapsed since the given time, or 0 for times in the future.
+func age(now, t time.Time) float64 {
+	seconds := now.Sub(t).Seconds()
+	if seconds < 0 {
+		return 0
+	}
+	return seconds
+}
@@ -0,0 +1,156 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package file
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent/mocks"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+)
+
+// writeTree creates the files, relative to root, modified the given time ago.
+func writeTree(t *testing.T, root string, files map[string]time.Duration) {
+	now := time.Now()
+	for name, ago := range files {
+		path := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte("0123456789"), 0644))
+		mtime := now.Add(-ago)
+		require.NoError(t, os.Chtimes(path, mtime, mtime))
+	}
+}
+
+func TestScanPath(t *testing.T) {
+	root, err := ioutil.TempDir("", "files")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	writeTree(t, root, map[string]time.Duration{
+		"spool/a":            time.Hour,
+		"spool/b":            time.Minute,
+		"spool/deferred/c":   2 * time.Hour,
+		"spool/deferred/x/d": 3 * time.Hour,
+		"backups/1.tar.gz":   48 * time.Hour,
+		"backups/2.tar.gz":   24 * time.Hour,
+		"backups/notes.txt":  time.Second,
+	})
+	now := time.Now()
+
+	testCases := []struct {
+		name        string
+		monitor     config.FileMonitor
+		files       uint64
+		directories uint64
+		oldest      time.Duration
+		newest      time.Duration
+	}{
+		{"direct children", config.FileMonitor{Path: filepath.Join(root, "spool")}, 2, 1, time.Hour, time.Minute},
+		{"recursive", config.FileMonitor{Path: filepath.Join(root, "spool"), Recursive: true}, 4, 2, 3 * time.Hour, time.Minute},
+		{"max depth", config.FileMonitor{Path: filepath.Join(root, "spool"), Recursive: true, MaxDepth: 2}, 3, 2, 2 * time.Hour, time.Minute},
+		{"glob", config.FileMonitor{Path: filepath.Join(root, "backups", "*.tar.gz")}, 2, 0, 48 * time.Hour, 24 * time.Hour},
+		{"single file", config.FileMonitor{Path: filepath.Join(root, "spool", "a")}, 1, 0, time.Hour, time.Hour},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := scanPath(context.Background(), tc.monitor, now)
+
+			assert.Equal(t, tc.monitor.Path, s.Path)
+			assert.Equal(t, "true", s.Exists)
+			assert.Empty(t, s.Error)
+			assert.Equal(t, tc.files, *s.FileCount)
+			assert.Equal(t, tc.directories, *s.DirectoryCount)
+			assert.Equal(t, tc.files*10, *s.SizeBytes)
+			assert.InDelta(t, tc.oldest.Seconds(), *s.OldestFileAgeSeconds, 2)
+			assert.InDelta(t, tc.newest.Seconds(), *s.NewestFileAgeSeconds, 2)
+		})
+	}
+}
+
+func TestScanPath_Missing(t *testing.T) {
+	s := scanPath(context.Background(), config.FileMonitor{Path: "/non/existing/path/*"}, time.Now())
+
+	assert.Equal(t, "false", s.Exists)
+	assert.Nil(t, s.FileCount)
+	assert.Nil(t, s.SizeBytes)
+}
+
+func TestScanPath_EmptyDir(t *testing.T) {
+	root, err := ioutil.TempDir("", "files")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	s := scanPath(context.Background(), config.FileMonitor{Path: root}, time.Now())
+
+	assert.Equal(t, "true", s.Exists)
+	assert.Equal(t, uint64(0), *s.FileCount)
+	assert.Nil(t, s.OldestFileAgeSeconds)
+}
+
+func TestScanPath_Cancelled(t *testing.T) {
+	root, err := ioutil.TempDir("", "files")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+	writeTree(t, root, map[string]time.Duration{"a": 0})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s := scanPath(ctx, config.FileMonitor{Path: root}, time.Now())
+
+	assert.Equal(t, errTimeout, s.Error)
+	assert.Nil(t, s.FileCount)
+}
+
+func TestSampler(t *testing.T) {
+	root, err := ioutil.TempDir("", "files")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+	writeTree(t, root, map[string]time.Duration{"inbox/a": 0, "inbox/b": 0})
+
+	ctx := new(mocks.AgentContext)
+	ctx.On("Config").Return(&config.Config{MetricsFileSampleRate: 60})
+	assert.True(t, NewSampler(ctx).Disabled(), "no monitors configured")
+
+	ctx = new(mocks.AgentContext)
+	ctx.On("Config").Return(&config.Config{
+		MetricsFileSampleRate: 60,
+		MetricsFileMonitors: config.FileMonitors{
+			{Path: filepath.Join(root, "inbox")},
+			{Path: filepath.Join(root, "outbox")},
+		},
+	})
+	sampler := NewSampler(ctx)
+	assert.False(t, sampler.Disabled())
+	assert.Equal(t, time.Duration(config.DefaultMetricsFileScanTimeoutSec)*time.Second, sampler.timeout)
+
+	results, err := sampler.Sample()
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	inbox := results[0].(*Sample)
+	assert.Equal(t, "FileSample", inbox.EventType)
+	assert.Equal(t, uint64(2), *inbox.FileCount)
+	outbox := results[1].(*Sample)
+	assert.Equal(t, "false", outbox.Exists)
+}
+
+func TestSampler_ScanInProgress(t *testing.T) {
+	sampler := &Sampler{
+		monitors: config.FileMonitors{{Path: "/var/spool"}},
+		scanning: map[string]bool{"/var/spool": true},
+		timeout:  time.Second,
+	}
+
+	results, err := sampler.Sample()
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, errScanInProgress, results[0].(*Sample).Error)
+}
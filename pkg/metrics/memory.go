@@ -21,8 +21,18 @@ type MemorySample struct {
 	SwapUsed  float64 `json:"swapUsedBytes"`
 }
 
+// MemoryDetailSample breakdown of the memory usage. Only available on Linux.
+type MemoryDetailSample struct {
+	MemoryCached  float64 `json:"memoryCachedBytes"`
+	MemoryBuffers float64 `json:"memoryBuffersBytes"`
+	MemorySlab    float64 `json:"memorySlabBytes"`
+	MemoryShared  float64 `json:"memorySharedBytes"`
+	MemoryDirty   float64 `json:"memoryDirtyBytes"`
+}
+
 type MemoryMonitor struct {
-	vmHarvest func() (*mem.VirtualMemoryStat, error)
+	vmHarvest      func() (*mem.VirtualMemoryStat, error)
+	detailsHarvest func() (*MemoryDetailSample, error)
 }
 
 func (mm *MemoryMonitor) Sample() (result *MemorySample, err error) {
@@ -61,3 +71,17 @@ func (mm *MemoryMonitor) Sample() (result *MemorySample, err error) {
 		SwapFree:  float64(swap.Free),
 	}, nil
 }
+
+// SampleDetails returns nil if the memory breakdown is not supported by the platform.
+func (mm *MemoryMonitor) SampleDetails() (result *MemoryDetailSample, err error) {
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			err = fmt.Errorf("Panic in MemoryMonitor.SampleDetails: %v\nStack: %s", panicErr, debug.Stack())
+		}
+	}()
+
+	if mm.detailsHarvest == nil {
+		return nil, nil
+	}
+	return mm.detailsHarvest()
+}
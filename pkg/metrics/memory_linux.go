@@ -3,6 +3,7 @@
 package metrics
 
 import (
+	"io"
 	"strconv"
 	"strings"
 
@@ -18,7 +19,7 @@ import (
 // If consistentMemory is false, it reports the free memory as the Available Memory, dependant on the current kernel
 // or library implementations.
 func NewMemoryMonitor(ignoreReclaimable bool) *MemoryMonitor {
-	mm := &MemoryMonitor{}
+	mm := &MemoryMonitor{detailsHarvest: meminfoDetails}
 	if ignoreReclaimable {
 		mm.vmHarvest = reclaimableAsFree
 	} else {
@@ -135,3 +136,47 @@ parse:
 
 	return ret, nil
 }
+
+// Returns the memory breakdown, where Shared memory is reported by the kernel as Shmem.
+func meminfoDetails() (*MemoryDetailSample, error) {
+	filename := helpers.HostProc("meminfo")
+	lines, err := acquire.ReadLines(filename)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	ret := &MemoryDetailSample{}
+	for _, line := range lines {
+		fields := strings.Split(line, ":")
+		if len(fields) != 2 {
+			continue
+		}
+		key := strings.TrimSpace(fields[0])
+		value := strings.TrimSpace(fields[1])
+		value = strings.Replace(value, " kB", "", -1)
+
+		var field *float64
+		switch key {
+		case "Cached":
+			field = &ret.MemoryCached
+		case "Buffers":
+			field = &ret.MemoryBuffers
+		case "Slab":
+			field = &ret.MemorySlab
+		case "Shmem":
+			field = &ret.MemoryShared
+		case "Dirty":
+			field = &ret.MemoryDirty
+		default:
+			continue
+		}
+
+		t, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		*field = float64(t * 1024)
+	}
+
+	return ret, nil
+}
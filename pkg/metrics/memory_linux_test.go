@@ -3,6 +3,9 @@
 package metrics
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -47,3 +50,24 @@ func TestMemoryMonitor_ReclaimableValues(t *testing.T) {
 		"%v (MemoryFree without reclaimable) should be > %v (MemoryFree with reclaimable)", sf.MemoryFree, su.MemoryFree)
 
 }
+
+func TestMemoryMonitor_SampleDetails(t *testing.T) {
+	dir, err := ioutil.TempDir("", "proc")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	meminfo := "MemTotal:       16000000 kB\nMemFree:         1000000 kB\nBuffers:           20000 kB\nCached:          3000000 kB\n" +
+		"Shmem:             40000 kB\nSlab:             500000 kB\nDirty:                12 kB\n"
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "meminfo"), []byte(meminfo), 0644))
+	os.Setenv("HOST_PROC", dir)
+	defer os.Unsetenv("HOST_PROC")
+
+	sample, err := NewMemoryMonitor(false).SampleDetails()
+	require.NoError(t, err)
+	require.NotNil(t, sample)
+
+	assert.Equal(t, float64(3000000*1024), sample.MemoryCached)
+	assert.Equal(t, float64(20000*1024), sample.MemoryBuffers)
+	assert.Equal(t, float64(500000*1024), sample.MemorySlab)
+	assert.Equal(t, float64(40000*1024), sample.MemoryShared)
+	assert.Equal(t, float64(12*1024), sample.MemoryDirty)
+}
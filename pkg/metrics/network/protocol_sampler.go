@@ -0,0 +1,200 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package network
+
+import (
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/acquire"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+var nplog = log.WithComponent("NetworkProtocolSampler")
+
+// protocolCounters the TCP and UDP kernel statistics, keyed as <section>.<name> (e.g. Tcp.RetransSegs,
+// TcpExt.ListenDrops or sockstat.TCP.tw).
+type protocolCounters map[string]uint64
+
+// Kernel statistics reported by the NetworkProtocolSampler.
+const (
+	tcpActiveOpens     = "Tcp.ActiveOpens"
+	tcpPassiveOpens    = "Tcp.PassiveOpens"
+	tcpAttemptFails    = "Tcp.AttemptFails"
+	tcpEstabResets     = "Tcp.EstabResets"
+	tcpCurrEstab       = "Tcp.CurrEstab"
+	tcpInSegs          = "Tcp.InSegs"
+	tcpOutSegs         = "Tcp.OutSegs"
+	tcpRetransSegs     = "Tcp.RetransSegs"
+	tcpInErrs          = "Tcp.InErrs"
+	tcpOutRsts         = "Tcp.OutRsts"
+	tcpListenOverflows = "TcpExt.ListenOverflows"
+	tcpListenDrops     = "TcpExt.ListenDrops"
+	tcpTimeouts        = "TcpExt.TCPTimeouts"
+	udpInDatagrams     = "Udp.InDatagrams"
+	udpOutDatagrams    = "Udp.OutDatagrams"
+	udpNoPorts         = "Udp.NoPorts"
+	udpInErrors        = "Udp.InErrors"
+	udpRcvbufErrors    = "Udp.RcvbufErrors"
+	udpSndbufErrors    = "Udp.SndbufErrors"
+	sockstatUsed       = "sockstat.sockets.used"
+	sockstatTCPInUse   = "sockstat.TCP.inuse"
+	sockstatTCPOrphan  = "sockstat.TCP.orphan"
+	sockstatTCPTw      = "sockstat.TCP.tw"
+	sockstatTCPAlloc   = "sockstat.TCP.alloc"
+	sockstatUDPInUse   = "sockstat.UDP.inuse"
+)
+
+// NetworkProtocolSample host wide TCP and UDP statistics. Counters are reported as rates since the previous sample,
+// so they are absent in the first one.
+type NetworkProtocolSample struct {
+	sample.BaseEvent
+
+	TCPActiveOpensPerSec           *float64 `json:"tcpActiveOpensPerSecond,omitempty"`
+	TCPPassiveOpensPerSec          *float64 `json:"tcpPassiveOpensPerSecond,omitempty"`
+	TCPAttemptFailsPerSec          *float64 `json:"tcpAttemptFailsPerSecond,omitempty"`
+	TCPEstablishedResetsPerSec     *float64 `json:"tcpEstablishedResetsPerSecond,omitempty"`
+	TCPCurrentEstablished          *uint64  `json:"tcpCurrentEstablished,omitempty"`
+	TCPSegmentsReceivedPerSec      *float64 `json:"tcpSegmentsReceivedPerSecond,omitempty"`
+	TCPSegmentsSentPerSec          *float64 `json:"tcpSegmentsSentPerSecond,omitempty"`
+	TCPRetransmittedSegmentsPerSec *float64 `json:"tcpRetransmittedSegmentsPerSecond,omitempty"`
+	// Percentage of the sent segments that were retransmissions
+	TCPRetransmitPercent       *float64 `json:"tcpRetransmitPercent,omitempty"`
+	TCPReceiveErrorsPerSec     *float64 `json:"tcpReceiveErrorsPerSecond,omitempty"`
+	TCPResetsSentPerSec        *float64 `json:"tcpResetsSentPerSecond,omitempty"`
+	TCPListenOverflowsPerSec   *float64 `json:"tcpListenOverflowsPerSecond,omitempty"`
+	TCPListenDropsPerSec       *float64 `json:"tcpListenDropsPerSecond,omitempty"`
+	TCPTimeoutsPerSec          *float64 `json:"tcpTimeoutsPerSecond,omitempty"`
+	TCPSocketsInUse            *uint64  `json:"tcpSocketsInUse,omitempty"`
+	TCPSocketsOrphaned         *uint64  `json:"tcpSocketsOrphaned,omitempty"`
+	TCPSocketsTimeWait         *uint64  `json:"tcpSocketsTimeWait,omitempty"`
+	TCPSocketsAllocated        *uint64  `json:"tcpSocketsAllocated,omitempty"`
+	UDPDatagramsReceivedPerSec *float64 `json:"udpDatagramsReceivedPerSecond,omitempty"`
+	UDPDatagramsSentPerSec     *float64 `json:"udpDatagramsSentPerSecond,omitempty"`
+	UDPNoPortsPerSec           *float64 `json:"udpNoPortsPerSecond,omitempty"`
+	UDPReceiveErrorsPerSec     *float64 `json:"udpReceiveErrorsPerSecond,omitempty"`
+	// UDP datagrams dropped because the socket receive buffer was full
+	UDPReceiveBufferErrorsPerSec *float64 `json:"udpReceiveBufferErrorsPerSecond,omitempty"`
+	UDPSendBufferErrorsPerSec    *float64 `json:"udpSendBufferErrorsPerSecond,omitempty"`
+	UDPSocketsInUse              *uint64  `json:"udpSocketsInUse,omitempty"`
+	SocketsUsed                  *uint64  `json:"socketsUsed,omitempty"`
+}
+
+// ProtocolSampler reports the NetworkProtocolSample from the /proc/net/snmp, /proc/net/netstat and
+// /proc/net/sockstat kernel statistics.
+type ProtocolSampler struct {
+	context        agent.AgentContext
+	sampleInterval time.Duration
+	netDir         string
+	last           protocolCounters
+	lastRun        time.Time
+}
+
+func NewProtocolSampler(context agent.AgentContext) *ProtocolSampler {
+	samplerIntervalSec := config.DefaultMetricsNetworkProtocolSampleRate
+	if context != nil {
+		samplerIntervalSec = context.Config().MetricsNetworkProtocolSampleRate
+	}
+
+	return &ProtocolSampler{
+		context:        context,
+		sampleInterval: time.Second * time.Duration(samplerIntervalSec),
+		netDir:         helpers.HostProc("net"),
+	}
+}
+
+func (ps *ProtocolSampler) Name() string { return "NetworkProtocolSampler" }
+
+func (ps *ProtocolSampler) Interval() time.Duration {
+	return ps.sampleInterval
+}
+
+func (ps *ProtocolSampler) Disabled() bool {
+	return ps.Interval() <= config.FREQ_DISABLE_SAMPLING
+}
+
+func (ps *ProtocolSampler) OnStartup() {}
+
+func (ps *ProtocolSampler) Sample() (results sample.EventBatch, err error) {
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			err = fmt.Errorf("Panic in ProtocolSampler.Sample: %v\nStack: %s", panicErr, debug.Stack())
+		}
+	}()
+
+	current, err := readProtocolCounters(ps.netDir)
+	if err != nil {
+		nplog.WithError(err).Debug("Unable to read network protocol statistics.")
+		return nil, nil
+	}
+	if len(current) == 0 {
+		return nil, nil
+	}
+
+	now := time.Now()
+	s := newProtocolSample(current, ps.last, now.Sub(ps.lastRun).Seconds())
+	ps.last, ps.lastRun = current, now
+
+	s.Type("NetworkProtocolSample")
+	return sample.EventBatch{s}, nil
+}
+
+// newProtocolSample builds the sample from the current counters, calculating the rates from the last ones, if any.
+func newProtocolSample(current, last protocolCounters, elapsedSecs float64) *NetworkProtocolSample {
+	gauge := func(name string) *uint64 {
+		if v, ok := current[name]; ok {
+			return &v
+		}
+		return nil
+	}
+	rate := func(name string) *float64 {
+		c, ok := current[name]
+		if !ok || last == nil {
+			return nil
+		}
+		r := acquire.CalculateSafeDelta(c, last[name], elapsedSecs)
+		return &r
+	}
+
+	s := &NetworkProtocolSample{
+		TCPActiveOpensPerSec:           rate(tcpActiveOpens),
+		TCPPassiveOpensPerSec:          rate(tcpPassiveOpens),
+		TCPAttemptFailsPerSec:          rate(tcpAttemptFails),
+		TCPEstablishedResetsPerSec:     rate(tcpEstabResets),
+		TCPCurrentEstablished:          gauge(tcpCurrEstab),
+		TCPSegmentsReceivedPerSec:      rate(tcpInSegs),
+		TCPSegmentsSentPerSec:          rate(tcpOutSegs),
+		TCPRetransmittedSegmentsPerSec: rate(tcpRetransSegs),
+		TCPReceiveErrorsPerSec:         rate(tcpInErrs),
+		TCPResetsSentPerSec:            rate(tcpOutRsts),
+		TCPListenOverflowsPerSec:       rate(tcpListenOverflows),
+		TCPListenDropsPerSec:           rate(tcpListenDrops),
+		TCPTimeoutsPerSec:              rate(tcpTimeouts),
+		TCPSocketsInUse:                gauge(sockstatTCPInUse),
+		TCPSocketsOrphaned:             gauge(sockstatTCPOrphan),
+		TCPSocketsTimeWait:             gauge(sockstatTCPTw),
+		TCPSocketsAllocated:            gauge(sockstatTCPAlloc),
+		UDPDatagramsReceivedPerSec:     rate(udpInDatagrams),
+		UDPDatagramsSentPerSec:         rate(udpOutDatagrams),
+		UDPNoPortsPerSec:               rate(udpNoPorts),
+		UDPReceiveErrorsPerSec:         rate(udpInErrors),
+		UDPReceiveBufferErrorsPerSec:   rate(udpRcvbufErrors),
+		UDPSendBufferErrorsPerSec:      rate(udpSndbufErrors),
+		UDPSocketsInUse:                gauge(sockstatUDPInUse),
+		SocketsUsed:                    gauge(sockstatUsed),
+	}
+
+	if s.TCPSegmentsSentPerSec != nil && s.TCPRetransmittedSegmentsPerSec != nil {
+		percent := float64(0)
+		if *s.TCPSegmentsSentPerSec > 0 {
+			percent = *s.TCPRetransmittedSegmentsPerSec * 100 / *s.TCPSegmentsSentPerSec
+		}
+		s.TCPRetransmitPercent = &percent
+	}
+	return s
+}
@@ -0,0 +1,9 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build darwin
+
+package network
+
+func readProtocolCounters(netDir string) (protocolCounters, error) {
+	return nil, nil
+}
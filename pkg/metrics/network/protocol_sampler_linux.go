@@ -0,0 +1,81 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build linux
+
+package network
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/acquire"
+)
+
+// readProtocolCounters reads the snmp, netstat and sockstat files of the given /proc/net directory.
+// Missing files are skipped, as netstat is not provided by every kernel.
+func readProtocolCounters(netDir string) (protocolCounters, error) {
+	counters := protocolCounters{}
+	for _, file := range []string{"snmp", "netstat"} {
+		if err := readSNMPFile(filepath.Join(netDir, file), counters); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	if err := readSockstat(filepath.Join(netDir, "sockstat"), counters); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return counters, nil
+}
+
+// readSNMPFile parses the snmp and netstat files, formatted as pairs of header and values lines:
+// Tcp: RtoAlgorithm RtoMin RtoMax MaxConn ActiveOpens ...
+// Tcp: 1 200 120000 -1 3513 ...
+func readSNMPFile(file string, counters protocolCounters) error {
+	lines, err := acquire.ReadLines(file)
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	for i := 0; i+1 < len(lines); i += 2 {
+		names := strings.Fields(lines[i])
+		values := strings.Fields(lines[i+1])
+		if len(names) == 0 || len(names) != len(values) || names[0] != values[0] {
+			return fmt.Errorf("unexpected format of %s at line %d", file, i+1)
+		}
+		section := strings.TrimSuffix(names[0], ":")
+		for j := 1; j < len(names); j++ {
+			// negative values (e.g. Tcp MaxConn) are not counters
+			if v, err := strconv.ParseUint(values[j], 10, 64); err == nil {
+				counters[section+"."+names[j]] = v
+			}
+		}
+	}
+	return nil
+}
+
+// readSockstat parses the sockstat file, formatted as:
+// sockets: used 290
+// TCP: inuse 5 orphan 0 tw 2 alloc 7 mem 1
+func readSockstat(file string, counters protocolCounters) error {
+	lines, err := acquire.ReadLines(file)
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		section := strings.TrimSuffix(fields[0], ":")
+		for j := 1; j+1 < len(fields); j += 2 {
+			if v, err := strconv.ParseUint(fields[j+1], 10, 64); err == nil {
+				counters["sockstat."+section+"."+fields[j]] = v
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build linux
+
+package network
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	snmpFixture = `Ip: Forwarding DefaultTTL InReceives
+Ip: 1 64 1000
+Tcp: RtoAlgorithm RtoMin RtoMax MaxConn ActiveOpens PassiveOpens AttemptFails EstabResets CurrEstab InSegs OutSegs RetransSegs InErrs OutRsts InCsumErrors
+Tcp: 1 200 120000 -1 100 50 3 7 12 10000 8000 40 2 9 0
+Udp: InDatagrams NoPorts InErrors OutDatagrams RcvbufErrors SndbufErrors InCsumErrors IgnoredMulti
+Udp: 500 4 6 400 5 1 0 0
+`
+	netstatFixture = `TcpExt: SyncookiesSent ListenOverflows ListenDrops TCPTimeouts
+TcpExt: 0 11 13 17
+IpExt: InNoRoutes InTruncatedPkts
+IpExt: 0 0
+`
+	sockstatFixture = `sockets: used 290
+TCP: inuse 5 orphan 1 tw 2 alloc 7 mem 1
+UDP: inuse 3 mem 2
+UDPLITE: inuse 0
+RAW: inuse 0
+FRAG: inuse 0 memory 0
+`
+)
+
+func writeNetFixture(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "procnet")
+	require.NoError(t, err)
+	for name, content := range files {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	return dir
+}
+
+func TestReadProtocolCounters(t *testing.T) {
+	dir := writeNetFixture(t, map[string]string{
+		"snmp":     snmpFixture,
+		"netstat":  netstatFixture,
+		"sockstat": sockstatFixture,
+	})
+	defer os.RemoveAll(dir)
+
+	counters, err := readProtocolCounters(dir)
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(100), counters[tcpActiveOpens])
+	assert.Equal(t, uint64(40), counters[tcpRetransSegs])
+	assert.Equal(t, uint64(5), counters[udpRcvbufErrors])
+	assert.Equal(t, uint64(13), counters[tcpListenDrops])
+	assert.Equal(t, uint64(2), counters[sockstatTCPTw])
+	assert.Equal(t, uint64(290), counters[sockstatUsed])
+	assert.Equal(t, uint64(3), counters[sockstatUDPInUse])
+	assert.NotContains(t, counters, "Tcp.MaxConn")
+}
+
+func TestReadProtocolCounters_MissingFiles(t *testing.T) {
+	dir := writeNetFixture(t, map[string]string{"snmp": snmpFixture})
+	defer os.RemoveAll(dir)
+
+	counters, err := readProtocolCounters(dir)
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(50), counters[tcpPassiveOpens])
+	assert.NotContains(t, counters, tcpListenOverflows)
+}
+
+func TestReadProtocolCounters_InvalidFormat(t *testing.T) {
+	dir := writeNetFixture(t, map[string]string{"snmp": "Tcp: ActiveOpens PassiveOpens\nUdp: 1 2\n"})
+	defer os.RemoveAll(dir)
+
+	_, err := readProtocolCounters(dir)
+	assert.Error(t, err)
+}
+
+func TestProtocolSampler_Sample(t *testing.T) {
+	dir := writeNetFixture(t, map[string]string{
+		"snmp":     snmpFixture,
+		"netstat":  netstatFixture,
+		"sockstat": sockstatFixture,
+	})
+	defer os.RemoveAll(dir)
+	ps := NewProtocolSampler(nil)
+	ps.netDir = dir
+
+	// first sample only has gauges
+	result, err := ps.Sample()
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	s := result[0].(*NetworkProtocolSample)
+	assert.Equal(t, "NetworkProtocolSample", s.EventType)
+	assert.Equal(t, uint64(12), *s.TCPCurrentEstablished)
+	assert.Equal(t, uint64(2), *s.TCPSocketsTimeWait)
+	assert.Nil(t, s.TCPRetransmittedSegmentsPerSec)
+
+	result, err = ps.Sample()
+	require.NoError(t, err)
+	s = result[0].(*NetworkProtocolSample)
+	require.NotNil(t, s.TCPRetransmittedSegmentsPerSec)
+	assert.Equal(t, 0.0, *s.TCPRetransmittedSegmentsPerSec)
+}
@@ -0,0 +1,38 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package network
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProtocolSample_Rates(t *testing.T) {
+	last := protocolCounters{
+		tcpOutSegs:         1000,
+		tcpRetransSegs:     10,
+		tcpListenOverflows: 5,
+		udpRcvbufErrors:    100,
+		tcpCurrEstab:       3,
+	}
+	current := protocolCounters{
+		tcpOutSegs:         3000,
+		tcpRetransSegs:     50,
+		tcpListenOverflows: 5,
+		udpRcvbufErrors:    50, // counter reset
+		tcpCurrEstab:       8,
+	}
+
+	s := newProtocolSample(current, last, 10)
+
+	assert.Equal(t, 200.0, *s.TCPSegmentsSentPerSec)
+	assert.Equal(t, 4.0, *s.TCPRetransmittedSegmentsPerSec)
+	require.NotNil(t, s.TCPRetransmitPercent)
+	assert.Equal(t, 2.0, *s.TCPRetransmitPercent)
+	assert.Equal(t, 0.0, *s.TCPListenOverflowsPerSec)
+	assert.Equal(t, 0.0, *s.UDPReceiveBufferErrorsPerSec)
+	assert.Equal(t, uint64(8), *s.TCPCurrentEstablished)
+	assert.Nil(t, s.TCPListenDropsPerSec)
+}
@@ -0,0 +1,151 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/acquire"
+)
+
+// PressureSample Pressure Stall Information (PSI): share of time in which some (or all, for "full") non-idle tasks
+// were stalled on a given resource. Averages are percentages over the last 10, 60 and 300 seconds, totals are the
+// accumulated stall time in microseconds.
+type PressureSample struct {
+	CPUSomeAvg10  *float64 `json:"cpuPressureSomeAvg10,omitempty"`
+	CPUSomeAvg60  *float64 `json:"cpuPressureSomeAvg60,omitempty"`
+	CPUSomeAvg300 *float64 `json:"cpuPressureSomeAvg300,omitempty"`
+	CPUSomeTotal  *uint64  `json:"cpuPressureSomeTotalUsec,omitempty"`
+
+	MemorySomeAvg10  *float64 `json:"memoryPressureSomeAvg10,omitempty"`
+	MemorySomeAvg60  *float64 `json:"memoryPressureSomeAvg60,omitempty"`
+	MemorySomeAvg300 *float64 `json:"memoryPressureSomeAvg300,omitempty"`
+	MemorySomeTotal  *uint64  `json:"memoryPressureSomeTotalUsec,omitempty"`
+	MemoryFullAvg10  *float64 `json:"memoryPressureFullAvg10,omitempty"`
+	MemoryFullAvg60  *float64 `json:"memoryPressureFullAvg60,omitempty"`
+	MemoryFullAvg300 *float64 `json:"memoryPressureFullAvg300,omitempty"`
+	MemoryFullTotal  *uint64  `json:"memoryPressureFullTotalUsec,omitempty"`
+
+	IOSomeAvg10  *float64 `json:"ioPressureSomeAvg10,omitempty"`
+	IOSomeAvg60  *float64 `json:"ioPressureSomeAvg60,omitempty"`
+	IOSomeAvg300 *float64 `json:"ioPressureSomeAvg300,omitempty"`
+	IOSomeTotal  *uint64  `json:"ioPressureSomeTotalUsec,omitempty"`
+	IOFullAvg10  *float64 `json:"ioPressureFullAvg10,omitempty"`
+	IOFullAvg60  *float64 `json:"ioPressureFullAvg60,omitempty"`
+	IOFullAvg300 *float64 `json:"ioPressureFullAvg300,omitempty"`
+	IOFullTotal  *uint64  `json:"ioPressureFullTotalUsec,omitempty"`
+}
+
+// PressureMonitor reads the PSI files. Kernels without PSI support (< 4.20, or booted with psi=0) are skipped.
+type PressureMonitor struct {
+	path        string
+	unsupported bool
+}
+
+// psiLine a "some" or "full" line from a PSI file.
+type psiLine struct {
+	avg10, avg60, avg300 float64
+	total                uint64
+}
+
+func NewPressureMonitor() *PressureMonitor {
+	return &PressureMonitor{path: helpers.HostProc("pressure")}
+}
+
+// Sample returns nil if PSI is not available in the host.
+func (pm *PressureMonitor) Sample() (sample *PressureSample, err error) {
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			err = fmt.Errorf("Panic in PressureMonitor.Sample: %v\nStack: %s", panicErr, debug.Stack())
+		}
+	}()
+
+	if pm.unsupported {
+		return nil, nil
+	}
+	if _, err := os.Stat(pm.path); err != nil {
+		syslog.WithError(err).Debug("Pressure Stall Information not available, skipping it.")
+		pm.unsupported = true
+		return nil, nil
+	}
+
+	cpu, err := readPSI(filepath.Join(pm.path, "cpu"))
+	if err != nil {
+		// psi=0 kernels provide the files, but reading them fails
+		syslog.WithError(err).Debug("Cannot read Pressure Stall Information, skipping it.")
+		pm.unsupported = true
+		return nil, nil
+	}
+	memory, _ := readPSI(filepath.Join(pm.path, "memory"))
+	ioPSI, _ := readPSI(filepath.Join(pm.path, "io"))
+
+	sample = &PressureSample{}
+	if some, ok := cpu["some"]; ok {
+		sample.CPUSomeAvg10, sample.CPUSomeAvg60, sample.CPUSomeAvg300, sample.CPUSomeTotal = some.values()
+	}
+	if some, ok := memory["some"]; ok {
+		sample.MemorySomeAvg10, sample.MemorySomeAvg60, sample.MemorySomeAvg300, sample.MemorySomeTotal = some.values()
+	}
+	if full, ok := memory["full"]; ok {
+		sample.MemoryFullAvg10, sample.MemoryFullAvg60, sample.MemoryFullAvg300, sample.MemoryFullTotal = full.values()
+	}
+	if some, ok := ioPSI["some"]; ok {
+		sample.IOSomeAvg10, sample.IOSomeAvg60, sample.IOSomeAvg300, sample.IOSomeTotal = some.values()
+	}
+	if full, ok := ioPSI["full"]; ok {
+		sample.IOFullAvg10, sample.IOFullAvg60, sample.IOFullAvg300, sample.IOFullTotal = full.values()
+	}
+	return sample, nil
+}
+
+func (l psiLine) values() (avg10, avg60, avg300 *float64, total *uint64) {
+	return &l.avg10, &l.avg60, &l.avg300, &l.total
+}
+
+// readPSI parses the lines of a PSI file, formatted as:
+// some avg10=0.00 avg60=0.00 avg300=0.00 total=0
+// full avg10=0.00 avg60=0.00 avg300=0.00 total=0
+func readPSI(file string) (map[string]psiLine, error) {
+	lines, err := acquire.ReadLines(file)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	psi := map[string]psiLine{}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 5 {
+			continue
+		}
+		var l psiLine
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			var err error
+			switch kv[0] {
+			case "avg10":
+				l.avg10, err = strconv.ParseFloat(kv[1], 64)
+			case "avg60":
+				l.avg60, err = strconv.ParseFloat(kv[1], 64)
+			case "avg300":
+				l.avg300, err = strconv.ParseFloat(kv[1], 64)
+			case "total":
+				l.total, err = strconv.ParseUint(kv[1], 10, 64)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid PSI value %q in %s: %s", field, file, err)
+			}
+		}
+		psi[fields[0]] = l
+	}
+	return psi, nil
+}
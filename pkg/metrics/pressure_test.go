@@ -0,0 +1,64 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPressureMonitor_Sample(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pressure")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"cpu":    "some avg10=1.50 avg60=0.75 avg300=0.20 total=123456\n",
+		"memory": "some avg10=0.00 avg60=0.10 avg300=0.30 total=100\nfull avg10=0.00 avg60=0.05 avg300=0.10 total=50\n",
+		"io":     "some avg10=12.00 avg60=8.00 avg300=4.00 total=999\nfull avg10=6.00 avg60=4.00 avg300=2.00 total=500\n",
+	}
+	for name, content := range files {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	pm := &PressureMonitor{path: dir}
+	sample, err := pm.Sample()
+	require.NoError(t, err)
+	require.NotNil(t, sample)
+
+	assert.Equal(t, 1.5, *sample.CPUSomeAvg10)
+	assert.Equal(t, 0.75, *sample.CPUSomeAvg60)
+	assert.Equal(t, 0.2, *sample.CPUSomeAvg300)
+	assert.Equal(t, uint64(123456), *sample.CPUSomeTotal)
+	assert.Equal(t, 0.3, *sample.MemorySomeAvg300)
+	assert.Equal(t, uint64(50), *sample.MemoryFullTotal)
+	assert.Equal(t, 12.0, *sample.IOSomeAvg10)
+	assert.Equal(t, 2.0, *sample.IOFullAvg300)
+	assert.Equal(t, uint64(500), *sample.IOFullTotal)
+}
+
+func TestPressureMonitor_Unsupported(t *testing.T) {
+	pm := &PressureMonitor{path: filepath.Join(os.TempDir(), "non-existing-pressure")}
+
+	sample, err := pm.Sample()
+	assert.NoError(t, err)
+	assert.Nil(t, sample)
+	assert.True(t, pm.unsupported)
+}
+
+func TestReadPSI_Invalid(t *testing.T) {
+	file, err := ioutil.TempFile("", "pressure")
+	require.NoError(t, err)
+	defer os.Remove(file.Name())
+	_, err = file.WriteString("some avg10=abc avg60=0.00 avg300=0.00 total=0\n")
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	_, err = readPSI(file.Name())
+	assert.Error(t, err)
+}
@@ -26,6 +26,17 @@ type processSampler struct {
 	hasAlreadyRun    bool
 	interval         time.Duration
 	cache            *cache
+	topN             int  // when > 0, only the top N processes by CPU and by memory are reported
+	groupByCommand   bool // processes running the same command are reported as a single sample
+	sockets          *socketCollector
+	// includeSample evaluates the agent include/exclude matchers, which are applied to the processes before
+	// aggregating them, so the aggregated samples match the reported ones.
+	includeSample sampler.IncludeSampleMatchFn
+}
+
+// eventMatcher is implemented by the agent contexts applying the include/exclude matchers to the sent samples.
+type eventMatcher interface {
+	IncludeEvent(event interface{}) bool
 }
 
 var (
@@ -40,11 +51,23 @@ func NewProcessSampler(ctx agent.AgentContext) sampler.Sampler {
 	ttlSecs := config.DefaultContainerCacheMetadataLimit
 	apiVersion := ""
 	interval := config.FREQ_INTERVAL_FLOOR_PROCESS_METRICS
+	var topN int
+	var groupByCommand bool
+	var sockets *socketCollector
 	if hasConfig {
 		cfg := ctx.Config()
 		ttlSecs = cfg.ContainerMetadataCacheLimit
 		apiVersion = cfg.DockerApiVersion
 		interval = cfg.MetricsProcessSampleRate
+		topN = cfg.MetricsProcessTopN
+		groupByCommand = cfg.MetricsProcessGroupByCommand
+		if cfg.EnableProcessSocketMetrics {
+			sockets = newSocketCollector()
+		}
+	}
+	var includeSample sampler.IncludeSampleMatchFn
+	if matcher, ok := ctx.(eventMatcher); ok {
+		includeSample = matcher.IncludeEvent
 	}
 	cache := newCache()
 	harvest := newHarvester(ctx, &cache)
@@ -55,6 +78,10 @@ func NewProcessSampler(ctx agent.AgentContext) sampler.Sampler {
 		containerSampler: dockerSampler,
 		cache:            &cache,
 		interval:         time.Second * time.Duration(interval),
+		topN:             topN,
+		groupByCommand:   groupByCommand,
+		sockets:          sockets,
+		includeSample:    includeSample,
 	}
 
 }
@@ -74,6 +101,7 @@ func (ps *processSampler) Disabled() bool {
 }
 
 // Sample returns samples for all the running processes, decorated with Docker runtime information, if applies.
+// Samples are grouped by command and limited to the top N processes when configured.
 func (ps *processSampler) Sample() (results sample.EventBatch, err error) {
 	var elapsedMs int64
 	var elapsedSeconds float64
@@ -107,6 +135,11 @@ func (ps *processSampler) Sample() (results sample.EventBatch, err error) {
 		}
 	}
 
+	if ps.sockets != nil {
+		ps.sockets.reset()
+	}
+
+	var samples []*types.ProcessSample
 	for _, pid := range pids {
 		var sample *types.ProcessSample
 		var err error
@@ -117,10 +150,27 @@ func (ps *processSampler) Sample() (results sample.EventBatch, err error) {
 			continue
 		}
 
+		if ps.sockets != nil {
+			if err := ps.sockets.populate(sample); err != nil {
+				mplog.WithError(err).WithField("pid", pid).Debug("Cannot read process sockets.")
+			}
+		}
+
 		if dockerDecorator != nil {
 			dockerDecorator.Decorate(sample)
 		}
 
+		samples = append(samples, sample)
+	}
+
+	if ps.groupByCommand || ps.topN > 0 {
+		samples = ps.matching(samples)
+	}
+	if ps.groupByCommand {
+		samples = groupByCommand(samples)
+	}
+	samples = topN(samples, ps.topN)
+	for _, sample := range samples {
 		results = append(results, ps.normalizeSample(sample))
 	}
 
@@ -129,6 +179,20 @@ func (ps *processSampler) Sample() (results sample.EventBatch, err error) {
 	return results, nil
 }
 
+// matching returns the samples passing the agent include/exclude matchers.
+func (ps *processSampler) matching(samples []*types.ProcessSample) []*types.ProcessSample {
+	if ps.includeSample == nil {
+		return samples
+	}
+	var included []*types.ProcessSample
+	for _, s := range samples {
+		if ps.includeSample(ps.normalizeSample(s)) {
+			included = append(included, s)
+		}
+	}
+	return included
+}
+
 func (self *processSampler) normalizeSample(s *types.ProcessSample) sample.Event {
 	if len(s.ContainerLabels) > 0 {
 		sb, err := json.Marshal(s)
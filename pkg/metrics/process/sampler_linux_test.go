@@ -65,6 +65,85 @@ func TestProcessSampler_DockerDecorator(t *testing.T) {
 	}
 }
 
+func TestProcessSampler_TopNGroupedByCommand(t *testing.T) {
+	ctx := new(mocks.AgentContext)
+	ctx.On("Config").Return(&config.Config{MetricsProcessTopN: 1, MetricsProcessGroupByCommand: true})
+	ctx.On("GetServiceForPid", mock.Anything).Return("", false)
+	ps := NewProcessSampler(ctx).(*processSampler)
+	ps.harvest = &harvesterMock{samples: map[int32]*types.ProcessSample{
+		1: {ProcessID: 1, CommandName: "java", CPUPercent: 90, MemoryRSSBytes: 10},
+		2: {ProcessID: 2, CommandName: "chrome", CPUPercent: 5, MemoryRSSBytes: 500},
+		3: {ProcessID: 3, CommandName: "chrome", CPUPercent: 5, MemoryRSSBytes: 500},
+		4: {ProcessID: 4, CommandName: "bash", CPUPercent: 1, MemoryRSSBytes: 5},
+		5: {ProcessID: 5, CommandName: "sshd", CPUPercent: 0, MemoryRSSBytes: 8},
+	}}
+	ps.containerSampler = &fakeContainerSampler{}
+
+	samples, err := ps.Sample()
+	require.NoError(t, err)
+
+	require.Len(t, samples, 3)
+	counts := map[string]int{}
+	for i := range samples {
+		switch sample := samples[i].(type) {
+		case *FlatProcessSample:
+			counts[(*sample)["commandName"].(string)] = int((*sample)["processCount"].(float64))
+		case *types.ProcessSample:
+			// aggregated samples are not decorated with the container labels
+			counts[sample.CommandName] = *sample.ProcessCount
+		}
+	}
+	assert.Equal(t, map[string]int{"java": 1, "chrome": 2, otherProcesses: 2}, counts)
+}
+
+func TestProcessSampler_TopNAfterMatchers(t *testing.T) {
+	ctx := &matchingAgentContext{
+		dummyAgentContext: dummyAgentContext{&config.Config{MetricsProcessTopN: 1}},
+		exclude:           "java",
+	}
+	ps := NewProcessSampler(ctx).(*processSampler)
+	ps.harvest = &harvesterMock{samples: map[int32]*types.ProcessSample{
+		1: {ProcessID: 1, CommandName: "java", CPUPercent: 90, MemoryRSSBytes: 1000},
+		2: {ProcessID: 2, CommandName: "chrome", CPUPercent: 5, MemoryRSSBytes: 500},
+		3: {ProcessID: 3, CommandName: "bash", CPUPercent: 1, MemoryRSSBytes: 5},
+		4: {ProcessID: 4, CommandName: "sshd", CPUPercent: 0, MemoryRSSBytes: 8},
+	}}
+	ps.containerSampler = &noContainerSampler{}
+
+	samples, err := ps.Sample()
+	require.NoError(t, err)
+
+	// the excluded process is neither selected nor aggregated
+	require.Len(t, samples, 2)
+	var commands []string
+	for _, s := range samples {
+		commands = append(commands, s.(*types.ProcessSample).CommandName)
+	}
+	assert.Equal(t, []string{"chrome", otherProcesses}, commands)
+	assert.Equal(t, 2, *samples[1].(*types.ProcessSample).ProcessCount)
+}
+
+// matchingAgentContext excludes the samples of a command, as the agent include/exclude matchers would.
+type matchingAgentContext struct {
+	dummyAgentContext
+	exclude string
+}
+
+func (m *matchingAgentContext) IncludeEvent(event interface{}) bool {
+	s, ok := event.(*types.ProcessSample)
+	return !ok || s.CommandName != m.exclude
+}
+
+type noContainerSampler struct{}
+
+func (*noContainerSampler) Enabled() bool {
+	return false
+}
+
+func (*noContainerSampler) NewDecorator() (metrics.ProcessDecorator, error) {
+	return nil, nil
+}
+
 type harvesterMock struct {
 	samples map[int32]*types.ProcessSample
 }
@@ -0,0 +1,219 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build linux
+
+package process
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/acquire"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/types"
+)
+
+// TCP states, as reported in the "st" column of /proc/net/tcp
+const (
+	tcpEstablished = "01"
+	tcpSynSent     = "02"
+	tcpCloseWait   = "08"
+	tcpListen      = "0A"
+)
+
+const socketLinkPrefix = "socket:["
+
+// socket an entry of the /proc/<pid>/net/{tcp,tcp6,udp,udp6} tables.
+type socket struct {
+	protocol string // tcp or udp
+	state    string
+	port     uint64
+	remote   bool // whether the socket has a remote address
+}
+
+// socketTable maps socket inodes to their entries.
+type socketTable map[uint64]socket
+
+// socketCollector populates the process samples with the sockets owned by each process, mapping the socket inodes
+// of the process file descriptors to the network namespace socket tables. Tables are read once per network namespace
+// and sampling cycle.
+type socketCollector struct {
+	tables map[string]socketTable
+}
+
+func newSocketCollector() *socketCollector {
+	return &socketCollector{tables: map[string]socketTable{}}
+}
+
+// reset discards the socket tables read in the previous sampling cycle.
+func (sc *socketCollector) reset() {
+	sc.tables = map[string]socketTable{}
+}
+
+// populate fills the socket metrics of the process sample.
+func (sc *socketCollector) populate(sample *types.ProcessSample) error {
+	pidDir := helpers.HostProc(strconv.Itoa(int(sample.ProcessID)))
+
+	inodes, err := socketInodes(filepath.Join(pidDir, "fd"))
+	if err != nil {
+		return err
+	}
+	table, err := sc.table(pidDir)
+	if err != nil {
+		return err
+	}
+
+	var tcpCount, established, listen, synSent, closeWait, udpCount int
+	ports := map[socket]bool{}
+	for _, inode := range inodes {
+		s, ok := table[inode]
+		if !ok {
+			continue
+		}
+		switch s.protocol {
+		case "tcp":
+			tcpCount++
+			switch s.state {
+			case tcpEstablished:
+				established++
+			case tcpSynSent:
+				synSent++
+			case tcpCloseWait:
+				closeWait++
+			case tcpListen:
+				listen++
+				ports[socket{protocol: s.protocol, port: s.port}] = true
+			}
+		case "udp":
+			udpCount++
+			if !s.remote {
+				ports[socket{protocol: s.protocol, port: s.port}] = true
+			}
+		}
+	}
+
+	sample.TCPSocketCount = &tcpCount
+	sample.TCPEstablishedCount = &established
+	sample.TCPListenCount = &listen
+	sample.TCPSynSentCount = &synSent
+	sample.TCPCloseWaitCount = &closeWait
+	sample.UDPSocketCount = &udpCount
+	sample.ListeningPorts = joinPorts(ports)
+	return nil
+}
+
+// table returns the socket table of the network namespace the process belongs to.
+func (sc *socketCollector) table(pidDir string) (socketTable, error) {
+	netNS, err := os.Readlink(filepath.Join(pidDir, "ns", "net"))
+	if err != nil {
+		// namespace not readable, the process network view is used without caching it
+		return readSocketTables(filepath.Join(pidDir, "net"))
+	}
+	if table, ok := sc.tables[netNS]; ok {
+		return table, nil
+	}
+	table, err := readSocketTables(filepath.Join(pidDir, "net"))
+	if err != nil {
+		return nil, err
+	}
+	sc.tables[netNS] = table
+	return table, nil
+}
+
+// socketInodes returns the inodes of the sockets open by a process, from the links of its fd directory.
+func socketInodes(fdDir string) ([]uint64, error) {
+	d, err := os.Open(fdDir)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+	fds, err := d.Readdirnames(-1)
+	if err != nil {
+		return nil, err
+	}
+
+	var inodes []uint64
+	for _, fd := range fds {
+		link, err := os.Readlink(filepath.Join(fdDir, fd))
+		if err != nil || !strings.HasPrefix(link, socketLinkPrefix) {
+			continue
+		}
+		inode, err := strconv.ParseUint(strings.TrimSuffix(link[len(socketLinkPrefix):], "]"), 10, 64)
+		if err == nil {
+			inodes = append(inodes, inode)
+		}
+	}
+	return inodes, nil
+}
+
+// readSocketTables reads the IPv4 and IPv6 TCP and UDP socket tables of a network directory.
+func readSocketTables(netDir string) (socketTable, error) {
+	table := socketTable{}
+	for _, file := range []string{"tcp", "tcp6", "udp", "udp6"} {
+		protocol := strings.TrimSuffix(file, "6")
+		if err := readSocketTable(filepath.Join(netDir, file), protocol, table); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return table, nil
+}
+
+// readSocketTable parses a /proc/net/{tcp,udp} formatted file:
+//   sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+//    0: 0100007F:0277 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 12345 ...
+func readSocketTable(file, protocol string, table socketTable) error {
+	lines, err := acquire.ReadLines(file)
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 10 || fields[0] == "sl" {
+			continue
+		}
+		inode, err := strconv.ParseUint(fields[9], 10, 64)
+		if err != nil || inode == 0 {
+			continue
+		}
+		local := strings.Split(fields[1], ":")
+		if len(local) != 2 {
+			continue
+		}
+		port, err := strconv.ParseUint(local[1], 16, 16)
+		if err != nil {
+			continue
+		}
+		table[inode] = socket{
+			protocol: protocol,
+			state:    fields[3],
+			port:     port,
+			remote:   strings.Trim(fields[2], "0:") != "",
+		}
+	}
+	return nil
+}
+
+// joinPorts returns the sorted, comma separated, list of listening ports, formatted as <protocol>:<port>.
+func joinPorts(ports map[socket]bool) string {
+	list := make([]socket, 0, len(ports))
+	for port := range ports {
+		list = append(list, port)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].protocol != list[j].protocol {
+			return list[i].protocol < list[j].protocol
+		}
+		return list[i].port < list[j].port
+	})
+	formatted := make([]string, 0, len(list))
+	for _, port := range list {
+		formatted = append(formatted, fmt.Sprintf("%s:%d", port.protocol, port.port))
+	}
+	return strings.Join(formatted, ",")
+}
@@ -0,0 +1,95 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package process
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/types"
+)
+
+const (
+	tcpTable = `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:0016 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1001 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:1F90 0100007F:D431 01 00000000:00000000 00:00000000 00000000     0        0 1002 1 0000000000000000 20 4 30 10 -1
+   2: 0100007F:D431 0100007F:1F90 08 00000000:00000000 00:00000000 00000000     0        0 1003 1 0000000000000000 20 4 30 10 -1
+   3: 0100007F:D432 0100007F:1F90 06 00000000:00000000 03:00000000 00000000     0        0 0 3 0000000000000000
+`
+	tcp6Table = `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000000000000000000000000000:0050 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1004 1 0000000000000000 100 0 0 10 0
+`
+	udpTable = `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops
+  100: 00000000:0035 00000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 1005 2 0000000000000000 0
+  101: 0100007F:A000 0100007F:0035 01 00000000:00000000 00:00000000 00000000     0        0 1006 2 0000000000000000 0
+`
+)
+
+func writeProcFixture(t *testing.T, root, pid string, fds map[string]string, net map[string]string) {
+	pidDir := filepath.Join(root, pid)
+	require.NoError(t, os.MkdirAll(filepath.Join(pidDir, "fd"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(pidDir, "ns"), 0755))
+	require.NoError(t, os.Symlink("net:[4026531992]", filepath.Join(pidDir, "ns", "net")))
+	for fd, link := range fds {
+		require.NoError(t, os.Symlink(link, filepath.Join(pidDir, "fd", fd)))
+	}
+	if net != nil {
+		require.NoError(t, os.MkdirAll(filepath.Join(pidDir, "net"), 0755))
+		for name, content := range net {
+			require.NoError(t, ioutil.WriteFile(filepath.Join(pidDir, "net", name), []byte(content), 0644))
+		}
+	}
+}
+
+func TestSocketCollector(t *testing.T) {
+	root, err := ioutil.TempDir("", "proc")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+	os.Setenv("HOST_PROC", root)
+	defer os.Unsetenv("HOST_PROC")
+
+	writeProcFixture(t, root, "100", map[string]string{
+		"0":  "/dev/null",
+		"3":  "socket:[1001]",
+		"4":  "socket:[1002]",
+		"5":  "socket:[1003]",
+		"6":  "socket:[1004]",
+		"7":  "socket:[1005]",
+		"8":  "socket:[1006]",
+		"9":  "socket:[9999]",
+		"10": "pipe:[1007]",
+	}, map[string]string{"tcp": tcpTable, "tcp6": tcp6Table, "udp": udpTable})
+	// same network namespace, so its table is not read again
+	writeProcFixture(t, root, "200", map[string]string{"3": "socket:[1002]"}, nil)
+
+	sc := newSocketCollector()
+	s := &types.ProcessSample{ProcessID: 100}
+	require.NoError(t, sc.populate(s))
+
+	assert.Equal(t, 4, *s.TCPSocketCount)
+	assert.Equal(t, 1, *s.TCPEstablishedCount)
+	assert.Equal(t, 2, *s.TCPListenCount)
+	assert.Equal(t, 0, *s.TCPSynSentCount)
+	assert.Equal(t, 1, *s.TCPCloseWaitCount)
+	assert.Equal(t, 2, *s.UDPSocketCount)
+	assert.Equal(t, "tcp:22,tcp:80,udp:53", s.ListeningPorts)
+
+	s = &types.ProcessSample{ProcessID: 200}
+	require.NoError(t, sc.populate(s))
+	assert.Equal(t, 1, *s.TCPSocketCount)
+	assert.Equal(t, 1, *s.TCPEstablishedCount)
+	assert.Empty(t, s.ListeningPorts)
+
+	// tables are read again after a reset
+	sc.reset()
+	s = &types.ProcessSample{ProcessID: 200}
+	require.NoError(t, sc.populate(s))
+	assert.Equal(t, 0, *s.TCPSocketCount)
+
+	assert.Error(t, sc.populate(&types.ProcessSample{ProcessID: 300}))
+}
@@ -0,0 +1,200 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build linux
+
+package process
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/types"
+)
+
+// otherProcesses is the name of the sample aggregating the processes out of the top N.
+const otherProcesses = "other"
+
+// groupByCommand merges the samples of the processes running the same command within the same container. The
+// resulting sample keeps the metadata of the process with the highest CPU usage, but the process IDs when several
+// processes are merged. Samples returned by the harvester are cached, so merged samples are always new copies.
+func groupByCommand(samples []*types.ProcessSample) []*types.ProcessSample {
+	sortByCPU(samples)
+
+	type groupKey struct{ command, containerID string }
+	groups := map[groupKey]*types.ProcessSample{}
+	var grouped []*types.ProcessSample
+	for _, s := range samples {
+		key := groupKey{command: s.CommandName, containerID: s.ContainerID}
+		group, ok := groups[key]
+		if !ok {
+			group = newAggregate(s)
+			groups[key] = group
+			grouped = append(grouped, group)
+			continue
+		}
+		aggregate(group, s)
+	}
+	return grouped
+}
+
+// topN returns the n samples with the highest CPU usage plus the n samples with the highest resident memory, and a
+// single sample aggregating the rest of processes, if any.
+func topN(samples []*types.ProcessSample, n int) []*types.ProcessSample {
+	if n <= 0 || len(samples) <= 2*n {
+		return samples
+	}
+
+	selected := make(map[*types.ProcessSample]bool, 2*n)
+	var top []*types.ProcessSample
+	pick := func() {
+		for _, s := range samples[:n] {
+			if !selected[s] {
+				selected[s] = true
+				top = append(top, s)
+			}
+		}
+	}
+	sortByCPU(samples)
+	pick()
+	sort.SliceStable(samples, func(i, j int) bool {
+		return samples[i].MemoryRSSBytes > samples[j].MemoryRSSBytes
+	})
+	pick()
+
+	var other *types.ProcessSample
+	for _, s := range samples {
+		if selected[s] {
+			continue
+		}
+		if other == nil {
+			other = &types.ProcessSample{
+				ProcessDisplayName: otherProcesses,
+				CommandName:        otherProcesses,
+			}
+			other.Type("ProcessSample")
+			count := 0
+			other.ProcessCount = &count
+		}
+		aggregate(other, s)
+	}
+	if other != nil {
+		top = append(top, other)
+	}
+	return top
+}
+
+func sortByCPU(samples []*types.ProcessSample) {
+	sort.SliceStable(samples, func(i, j int) bool {
+		return samples[i].CPUPercent > samples[j].CPUPercent
+	})
+}
+
+// newAggregate returns a copy of the sample, counting a single process.
+func newAggregate(s *types.ProcessSample) *types.ProcessSample {
+	agg := *s
+	count := processCount(s)
+	agg.ProcessCount = &count
+	return &agg
+}
+
+// aggregate sums the metrics of the sample into the aggregated one. Pointers are always replaced, as they may be
+// shared with the cached samples.
+func aggregate(agg, s *types.ProcessSample) {
+	count := *agg.ProcessCount + processCount(s)
+	agg.ProcessCount = &count
+	// process IDs of any of the aggregated processes would misattribute the sample
+	agg.Aggregated = true
+	agg.ProcessID = 0
+	agg.ParentProcessID = 0
+	agg.CPUPercent += s.CPUPercent
+	agg.CPUUserPercent += s.CPUUserPercent
+	agg.CPUSystemPercent += s.CPUSystemPercent
+	agg.MemoryRSSBytes += s.MemoryRSSBytes
+	agg.MemoryVMSBytes += s.MemoryVMSBytes
+	agg.ThreadCount += s.ThreadCount
+	if s.FdCount != nil {
+		fds := *s.FdCount
+		if agg.FdCount != nil {
+			fds += *agg.FdCount
+		}
+		agg.FdCount = &fds
+	}
+	agg.IOReadCountPerSecond = sumFloat(agg.IOReadCountPerSecond, s.IOReadCountPerSecond)
+	agg.IOWriteCountPerSecond = sumFloat(agg.IOWriteCountPerSecond, s.IOWriteCountPerSecond)
+	agg.IOReadBytesPerSecond = sumFloat(agg.IOReadBytesPerSecond, s.IOReadBytesPerSecond)
+	agg.IOWriteBytesPerSecond = sumFloat(agg.IOWriteBytesPerSecond, s.IOWriteBytesPerSecond)
+	agg.IOTotalReadCount = sumUint(agg.IOTotalReadCount, s.IOTotalReadCount)
+	agg.IOTotalWriteCount = sumUint(agg.IOTotalWriteCount, s.IOTotalWriteCount)
+	agg.IOTotalReadBytes = sumUint(agg.IOTotalReadBytes, s.IOTotalReadBytes)
+	agg.IOTotalWriteBytes = sumUint(agg.IOTotalWriteBytes, s.IOTotalWriteBytes)
+	agg.TCPSocketCount = sumInt(agg.TCPSocketCount, s.TCPSocketCount)
+	agg.TCPEstablishedCount = sumInt(agg.TCPEstablishedCount, s.TCPEstablishedCount)
+	agg.TCPListenCount = sumInt(agg.TCPListenCount, s.TCPListenCount)
+	agg.TCPSynSentCount = sumInt(agg.TCPSynSentCount, s.TCPSynSentCount)
+	agg.TCPCloseWaitCount = sumInt(agg.TCPCloseWaitCount, s.TCPCloseWaitCount)
+	agg.UDPSocketCount = sumInt(agg.UDPSocketCount, s.UDPSocketCount)
+	agg.ListeningPorts = mergePorts(agg.ListeningPorts, s.ListeningPorts)
+}
+
+// processCount returns the number of processes represented by a sample.
+func processCount(s *types.ProcessSample) int {
+	if s.ProcessCount != nil {
+		return *s.ProcessCount
+	}
+	return 1
+}
+
+// mergePorts returns the union of two comma separated lists of listening ports.
+func mergePorts(a, b string) string {
+	if b == "" || a == b {
+		return a
+	}
+	if a == "" {
+		return b
+	}
+	ports := strings.Split(a, ",")
+	known := make(map[string]bool, len(ports))
+	for _, port := range ports {
+		known[port] = true
+	}
+	for _, port := range strings.Split(b, ",") {
+		if !known[port] {
+			known[port] = true
+			ports = append(ports, port)
+		}
+	}
+	return strings.Join(ports, ",")
+}
+
+func sumInt(a, b *int) *int {
+	if b == nil {
+		return a
+	}
+	sum := *b
+	if a != nil {
+		sum += *a
+	}
+	return &sum
+}
+
+func sumFloat(a, b *float64) *float64 {
+	if b == nil {
+		return a
+	}
+	sum := *b
+	if a != nil {
+		sum += *a
+	}
+	return &sum
+}
+
+func sumUint(a, b *uint64) *uint64 {
+	if b == nil {
+		return a
+	}
+	sum := *b
+	if a != nil {
+		sum += *a
+	}
+	return &sum
+}
@@ -0,0 +1,97 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package process
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/types"
+)
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+// assertNoProcessID asserts that the sample is reported without the process IDs, as it aggregates several processes.
+func assertNoProcessID(t *testing.T, s *types.ProcessSample) {
+	t.Helper()
+	assert.True(t, s.Aggregated)
+	b, err := json.Marshal(s)
+	require.NoError(t, err)
+	var reported map[string]interface{}
+	require.NoError(t, json.Unmarshal(b, &reported))
+	assert.NotContains(t, reported, "processId")
+	assert.NotContains(t, reported, "parentProcessId")
+	assert.Contains(t, reported, "cpuPercent")
+}
+
+func TestGroupByCommand(t *testing.T) {
+	readBytes := 10.0
+	samples := []*types.ProcessSample{
+		{ProcessID: 1, CommandName: "php-fpm", CPUPercent: 1, MemoryRSSBytes: 100, ThreadCount: 1, IOReadBytesPerSecond: &readBytes},
+		{ProcessID: 2, CommandName: "php-fpm", CPUPercent: 3, MemoryRSSBytes: 200, ThreadCount: 2, IOReadBytesPerSecond: floatPtr(5)},
+		{ProcessID: 3, CommandName: "php-fpm", ContainerID: "abc", CPUPercent: 2, MemoryRSSBytes: 50},
+		{ProcessID: 4, CommandName: "nginx", CPUPercent: 0.5, MemoryRSSBytes: 10},
+	}
+
+	grouped := groupByCommand(samples)
+
+	require.Len(t, grouped, 3)
+	fpm := grouped[0]
+	assert.Equal(t, "php-fpm", fpm.CommandName, "metadata of the process with the highest CPU is kept")
+	assertNoProcessID(t, fpm)
+	assert.Equal(t, 4.0, fpm.CPUPercent)
+	assert.Equal(t, int64(300), fpm.MemoryRSSBytes)
+	assert.Equal(t, int32(3), fpm.ThreadCount)
+	assert.Equal(t, 15.0, *fpm.IOReadBytesPerSecond)
+	assert.Equal(t, 2, *fpm.ProcessCount)
+
+	assert.Equal(t, "abc", grouped[1].ContainerID)
+	assert.Equal(t, int32(3), grouped[1].ProcessID)
+	assert.False(t, grouped[1].Aggregated)
+	assert.Equal(t, 1, *grouped[1].ProcessCount)
+	assert.Equal(t, "nginx", grouped[2].CommandName)
+
+	// harvested samples are cached, so they must not be modified
+	assert.Equal(t, 10.0, readBytes)
+	assert.Nil(t, samples[0].ProcessCount)
+}
+
+func TestTopN(t *testing.T) {
+	samples := []*types.ProcessSample{
+		{ProcessID: 1, CPUPercent: 50, MemoryRSSBytes: 10},
+		{ProcessID: 2, CPUPercent: 40, MemoryRSSBytes: 1000},
+		{ProcessID: 3, CPUPercent: 1, MemoryRSSBytes: 2000},
+		{ProcessID: 4, CPUPercent: 30, MemoryRSSBytes: 5, ProcessCount: func() *int { c := 3; return &c }()},
+		{ProcessID: 5, CPUPercent: 2, MemoryRSSBytes: 20},
+		{ProcessID: 6, CPUPercent: 0, MemoryRSSBytes: 1},
+	}
+
+	top := topN(samples, 2)
+
+	require.Len(t, top, 4)
+	var pids []int32
+	for _, s := range top[:3] {
+		pids = append(pids, s.ProcessID)
+	}
+	assert.ElementsMatch(t, []int32{1, 2, 3}, pids)
+
+	other := top[3]
+	assert.Equal(t, otherProcesses, other.ProcessDisplayName)
+	assert.Equal(t, "ProcessSample", other.EventType)
+	assert.Equal(t, 32.0, other.CPUPercent)
+	assert.Equal(t, int64(26), other.MemoryRSSBytes)
+	assert.Equal(t, 5, *other.ProcessCount)
+	assertNoProcessID(t, other)
+}
+
+func TestTopN_Disabled(t *testing.T) {
+	samples := []*types.ProcessSample{{ProcessID: 1}, {ProcessID: 2}, {ProcessID: 3}}
+
+	assert.Equal(t, samples, topN(samples, 0))
+	assert.Equal(t, samples, topN(samples, 2))
+}
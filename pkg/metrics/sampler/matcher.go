@@ -6,19 +6,21 @@ package sampler
 import (
 	"fmt"
 	"github.com/newrelic/infrastructure-agent/pkg/log"
-	"reflect"
 	"regexp"
 	"strings"
 
 	"github.com/newrelic/infrastructure-agent/internal/agent/cmdchannel/handler"
 	"github.com/newrelic/infrastructure-agent/internal/feature_flags"
 	"github.com/newrelic/infrastructure-agent/pkg/config"
-	"github.com/newrelic/infrastructure-agent/pkg/metrics/types"
 	"github.com/newrelic/infrastructure-agent/pkg/trace"
 )
 
-var (
-	typesToEvaluate = map[string]bool{"ProcessSample": true}
+// processSampleType is the event type the process dimensions (process.name, process.executable) apply to.
+const (
+	processSampleType      = "ProcessSample"
+	processDimensionPrefix = "process."
+	// processCountAttribute is only reported by the process samples aggregating several processes.
+	processCountAttribute = "processCount"
 )
 
 var mlog = log.WithComponent("SamplerMatcher")
@@ -34,7 +36,15 @@ type ExpressionMatcher interface {
 	Evaluate(event interface{}) bool
 }
 
-type attributeCache map[string]string
+// attributeCache maps the process dimensions to their ProcessSample attribute.
+type attributeCache map[string]processAttribute
+
+// processAttribute identifies a ProcessSample attribute by its field name, shown in the matcher traces, and by its
+// JSON name, also valid for the samples already decorated as maps.
+type processAttribute struct {
+	field string
+	name  string
+}
 
 var attrCache attributeCache
 
@@ -44,25 +54,22 @@ var regexCache regexCompiledCache
 
 func init() {
 	attrCache = attributeCache{
-		"process.name":       "ProcessDisplayName",
-		"process.executable": "CmdLine",
+		"process.name":       {field: "ProcessDisplayName", name: "processDisplayName"},
+		"process.executable": {field: "CmdLine", name: "commandLine"},
 	}
 	regexCache = regexCompiledCache{}
 }
 
 type matcher struct {
 	PropertyName  string
+	attributeName string
 	ExpectedValue interface{}
 	Evaluator     func(expected interface{}, actual interface{}) bool
 }
 
 func (p matcher) Evaluate(event interface{}) bool {
-	if skipSample(event, typesToEvaluate) {
-		return true
-	}
-
-	actualValue := getFieldValue(event, p.PropertyName)
-	if actualValue == nil {
+	actualValue, found := attributeValue(event, p.attributeName)
+	if !found {
 		return false
 	}
 	isMatch := p.Evaluator(p.ExpectedValue, actualValue)
@@ -70,32 +77,6 @@ func (p matcher) Evaluate(event interface{}) bool {
 	return isMatch
 }
 
-func getFieldValue(object interface{}, fieldName string) interface{} {
-	v := reflect.ValueOf(object)
-	if v.Kind() == reflect.Ptr {
-		v = v.Elem()
-	}
-	fv := v.FieldByName(fieldName)
-	if fv.IsValid() && fv.CanInterface() {
-		fieldValue := fv.Interface()
-		return fieldValue
-	}
-
-	trace.MetricMatch("field '%v' does NOT exist in sample", fieldName)
-	return nil
-}
-
-// determine is this is a sample that should be evaluated
-// we are only checking ProcessSamples at this point, so only those should be evaluated
-func skipSample(sample interface{}, typesToEvaluate map[string]bool) bool {
-	v := reflect.TypeOf(sample)
-	if v.Kind() == reflect.Ptr {
-		v = v.Elem()
-	}
-	// skip if not "registered"
-	return !typesToEvaluate[v.Name()]
-}
-
 func literalExpressionEvaluator(expected interface{}, actual interface{}) bool {
 	return expected == actual
 }
@@ -106,9 +87,22 @@ func regularExpressionEvaluator(expected interface{}, actual interface{}) bool {
 	return regex.MatchString(fmt.Sprintf("%v", actual))
 }
 
-//newExpressionMatcher returns a new ExpressionMatcher
-func newExpressionMatcher(dimensionName string, expr string) ExpressionMatcher {
-	return build(dimensionName, expr)
+//newExpressionMatcher returns a new ExpressionMatcher. Process dimensions take a literal value or a regex, any other
+// key is an event type taking a rule expression. Invalid rules return an error, as a matcher never matching would drop
+// every sample of the event type.
+func newExpressionMatcher(dimensionName string, expr string) (ExpressionMatcher, error) {
+	if strings.HasPrefix(dimensionName, processDimensionPrefix) {
+		return build(dimensionName, expr), nil
+	}
+	return newRuleMatcher(expr)
+}
+
+// ruleEventType returns the event type a matchers configuration key applies to.
+func ruleEventType(key string) string {
+	if strings.HasPrefix(key, processDimensionPrefix) {
+		return processSampleType
+	}
+	return key
 }
 
 func build(dimensionName string, expr string) ExpressionMatcher {
@@ -121,7 +115,8 @@ func build(dimensionName string, expr string) ExpressionMatcher {
 	}
 
 	eval := matcher{
-		PropertyName: mappedAttributeName,
+		PropertyName:  mappedAttributeName.field,
+		attributeName: mappedAttributeName.name,
 	}
 
 	if strings.HasPrefix(expr, "regex") {
@@ -162,9 +157,14 @@ func cacheRegex(pattern string) error {
 // - process.executable
 //   - "/bin/test"
 //   - regex "^/opt/newrelic/"
-// will create an evaluator chain with 2 entries. The first one will have 1 evaluator. The second 2 evaluators
+// - StorageSample
+//   - mountPoint == "/" or filesystemType =~ "^ext"
+// will create an evaluator chain with 3 entries. The first and third ones will have 1 evaluator. The second 2 evaluators
+// Matchers only apply to samples of their event type: process dimensions to ProcessSample, other keys are the event
+// type itself.
 type MatcherChain struct {
 	Matchers map[string][]ExpressionMatcher
+	Excludes map[string][]ExpressionMatcher
 	Enabled  bool
 }
 
@@ -172,38 +172,66 @@ type MatcherChain struct {
 // Each expression will generate an matcher that gets added to the chain
 // While the chain will be matched for each "sample", it terminates as soon as 1 match is matched (result = true)
 func NewMatcherChain(expressions config.IncludeMetricsMap) MatcherChain {
-	chain := MatcherChain{Matchers: map[string][]ExpressionMatcher{}, Enabled: false}
+	return NewMatcherChainWithExcludes(expressions, nil)
+}
 
-	// no matchers means the chain will be disabled
-	if len(expressions) == 0 {
-		return chain
+// NewMatcherChainWithExcludes creates a new chain of matchers that also drops the samples matching any of the exclude
+// expressions.
+func NewMatcherChainWithExcludes(includes config.IncludeMetricsMap, excludes config.IncludeMetricsMap) MatcherChain {
+	chain := MatcherChain{
+		Matchers: buildMatchers(includes),
+		Excludes: buildMatchers(excludes),
 	}
+	// no matchers means the chain will be disabled
+	chain.Enabled = len(chain.Matchers) > 0 || len(chain.Excludes) > 0
+	return chain
+}
 
-	chain.Enabled = true
+// buildMatchers skips the invalid rules, so they don't apply to their event type.
+func buildMatchers(expressions config.IncludeMetricsMap) map[string][]ExpressionMatcher {
+	matchers := map[string][]ExpressionMatcher{}
 	for prop, exprs := range expressions {
-		if _, ok := chain.Matchers[prop]; !ok {
-			chain.Matchers[prop] = []ExpressionMatcher{}
-		}
-
-		evs := chain.Matchers[prop]
+		evs := matchers[prop]
 		for _, expr := range exprs {
-			e := newExpressionMatcher(prop, expr)
-			evs = append(evs, e)
+			ev, err := newExpressionMatcher(prop, expr)
+			if err != nil {
+				mlog.WithError(err).WithField("eventType", prop).Error(fmt.Sprintf("ignoring invalid rule matcher: '%s'", expr))
+				continue
+			}
+			evs = append(evs, ev)
+		}
+		if len(evs) > 0 {
+			matchers[prop] = evs
 		}
-		chain.Matchers[prop] = evs
 	}
-
-	return chain
+	return matchers
 }
 
 // Evaluate returns the result of compare an event with a chain of matching rules
 // return:
+//  - false, if event matches any exclude criteria of its event type
 //  - true, if event match with evaluator criteria chain
 //  - false, if event do not match with evaluator criteria chain
-// If there is no matchers will return true.
+// If there is no matchers for the event type will return true.
 func (ec MatcherChain) Evaluate(event interface{}) bool {
+	eventType := eventTypeOf(event)
+
+	for prop, es := range ec.Excludes {
+		if ruleEventType(prop) != eventType {
+			continue
+		}
+		for _, e := range es {
+			if e.Evaluate(event) {
+				return false
+			}
+		}
+	}
+
 	var result = true
-	for _, es := range ec.Matchers {
+	for prop, es := range ec.Matchers {
+		if ruleEventType(prop) != eventType {
+			continue
+		}
 		for _, e := range es {
 			result = e.Evaluate(event)
 			if result {
@@ -214,6 +242,16 @@ func (ec MatcherChain) Evaluate(event interface{}) bool {
 	return result
 }
 
+// HasIncludes returns whether there are include matchers for the provided event type.
+func (ec MatcherChain) HasIncludes(eventType string) bool {
+	for prop := range ec.Matchers {
+		if ruleEventType(prop) == eventType {
+			return true
+		}
+	}
+	return false
+}
+
 type constantMatcher struct {
 	value bool
 }
@@ -227,49 +265,63 @@ func (ne constantMatcher) String() string {
 
 // NewSampleMatchFn creates new includeSampleMatchFn func, enableProcessMetrics might be nil when
 // value was not set.
-func NewSampleMatchFn(enableProcessMetrics *bool, includeMetricsMatchers config.IncludeMetricsMap, ffRetriever feature_flags.Retriever) IncludeSampleMatchFn {
+func NewSampleMatchFn(enableProcessMetrics *bool, includeMetricsMatchers config.IncludeMetricsMap, excludeMetricsMatchers config.IncludeMetricsMap, ffRetriever feature_flags.Retriever) IncludeSampleMatchFn {
+	ec := NewMatcherChainWithExcludes(includeMetricsMatchers, excludeMetricsMatchers)
+	includeProcesses := processSamplesMatchFn(enableProcessMetrics, ec.HasIncludes(processSampleType), ffRetriever)
+
+	return func(sample interface{}) bool {
+		if eventTypeOf(sample) == processSampleType {
+			if !includeProcesses() {
+				return false
+			}
+			// the aggregated processes were already evaluated by the process sampler
+			if isAggregatedProcessSample(sample) {
+				return true
+			}
+		}
+		return !ec.Enabled || ec.Evaluate(sample)
+	}
+}
+
+// isAggregatedProcessSample returns whether the process sample aggregates several processes, either grouped by
+// command or out of the top N.
+func isAggregatedProcessSample(sample interface{}) bool {
+	_, ok := attributeValue(sample, processCountAttribute)
+	return ok
+}
+
+// processSamplesMatchFn returns whether process samples are submitted, before applying the matchers.
+func processSamplesMatchFn(enableProcessMetrics *bool, hasProcessMatchers bool, ffRetriever feature_flags.Retriever) func() bool {
 	// configuration option always takes precedence over FF and matchers configuration
 	if enableProcessMetrics != nil {
 		if *enableProcessMetrics == false {
 			trace.MetricMatch("EnableProcessMetrics is FALSE, process metrics will be DISABLED")
-			return func(sample interface{}) bool {
+			return func() bool {
 				// no process samples are included
-				_, isProcessSample := sample.(*types.ProcessSample)
-				return !isProcessSample
+				return false
 			}
+		}
+		if hasProcessMatchers {
+			trace.MetricMatch("EnableProcessMetrics is TRUE and rules ARE defined, process metrics will be ENABLED for matching processes")
 		} else {
-			ec := NewMatcherChain(includeMetricsMatchers)
-			if ec.Enabled {
-				trace.MetricMatch("EnableProcessMetrics is TRUE and rules ARE defined, process metrics will be ENABLED for matching processes")
-				return func(sample interface{}) bool {
-					return ec.Evaluate(sample)
-				}
-			}
-
 			trace.MetricMatch("EnableProcessMetrics is TRUE and rules are NOT defined, ALL process metrics will be ENABLED")
-			return func(sample interface{}) bool {
-				// all process samples are included
-				return true
-			}
+		}
+		return func() bool {
+			return true
 		}
 	}
 
 	// if config option is not set, check if we have rules defined. those take precedence over the FF
-	ec := NewMatcherChain(includeMetricsMatchers)
-	if ec.Enabled {
+	if hasProcessMatchers {
 		trace.MetricMatch("EnableProcessMetrics is EMPTY and rules ARE defined, process metrics will be ENABLED for matching processes")
-		return func(sample interface{}) bool {
-			return ec.Evaluate(sample)
+		return func() bool {
+			return true
 		}
 	}
 
 	// configuration option is not defined and feature flag is present, FF determines, otherwise
 	// all process samples will be excluded
-	return func(sample interface{}) bool {
-		if _, isProcessSample := sample.(*types.ProcessSample); !isProcessSample {
-			return true
-		}
-
+	return func() bool {
 		enabled, exists := ffRetriever.GetFeatureFlag(handler.FlagFullProcess)
 		return exists && enabled
 	}
@@ -546,6 +546,80 @@ func Test_EvaluatorChain_LogTraceMatcher(t *testing.T) {
 	assert.Equal(t, logrus.TraceLevel, entry.Level)
 }
 
+func Test_EvaluatorChain_WithEventTypeRules(t *testing.T) {
+	rootFS := &storage.BaseSample{MountPoint: "/", FileSystemType: "ext4"}
+	rootFS.Type("StorageSample")
+	tmpFS := &storage.BaseSample{MountPoint: "/run", FileSystemType: "tmpfs"}
+	tmpFS.Type("StorageSample")
+	eth0 := &network.NetworkSample{InterfaceName: "eth0"}
+	eth0.Type("NetworkSample")
+	veth := &network.NetworkSample{InterfaceName: "veth1234"}
+	veth.Type("NetworkSample")
+	java := &types.ProcessSample{ProcessDisplayName: "java", CPUPercent: 10}
+	java.Type("ProcessSample")
+	idle := &types.ProcessSample{ProcessDisplayName: "sleep", CPUPercent: 0}
+	idle.Type("ProcessSample")
+	labeled := &map[string]interface{}{"eventType": "ProcessSample", "processDisplayName": "nginx", "containerLabel_app": "web"}
+
+	includes := config.IncludeMetricsMap{
+		"StorageSample": {`filesystemType != tmpfs`},
+		"process.name":  {"java"},
+		"ProcessSample": {`containerLabel_app == web`},
+	}
+	excludes := config.IncludeMetricsMap{
+		"NetworkSample": {`interfaceName =~ "^veth"`},
+	}
+	ec := sampler.NewMatcherChainWithExcludes(includes, excludes)
+	require.True(t, ec.Enabled)
+
+	assert.True(t, ec.Evaluate(rootFS))
+	assert.False(t, ec.Evaluate(tmpFS))
+	assert.True(t, ec.Evaluate(eth0))
+	assert.False(t, ec.Evaluate(veth))
+	assert.True(t, ec.Evaluate(java))
+	assert.False(t, ec.Evaluate(idle))
+	assert.True(t, ec.Evaluate(labeled))
+	assert.True(t, ec.Evaluate(&metrics.SystemSample{}), "event types without matchers are included")
+}
+
+func Test_EvaluatorChain_ExcludesTakePrecedence(t *testing.T) {
+	java := &types.ProcessSample{ProcessDisplayName: "java", CPUPercent: 0.5}
+	java.Type("ProcessSample")
+
+	ec := sampler.NewMatcherChainWithExcludes(
+		config.IncludeMetricsMap{"process.name": {"java"}},
+		config.IncludeMetricsMap{"ProcessSample": {`cpuPercent < 1`}},
+	)
+
+	assert.False(t, ec.Evaluate(java))
+	assert.True(t, ec.HasIncludes("ProcessSample"))
+	assert.False(t, ec.HasIncludes("StorageSample"))
+}
+
+func Test_EvaluatorChain_InvalidRule(t *testing.T) {
+	storageSample := &storage.BaseSample{MountPoint: "/"}
+	storageSample.Type("StorageSample")
+
+	tmpFS := &storage.BaseSample{MountPoint: "/run", FileSystemType: "tmpfs"}
+	tmpFS.Type("StorageSample")
+
+	// WHEN the only rule of an event type is invalid
+	ec := sampler.NewMatcherChain(config.IncludeMetricsMap{"StorageSample": {`mountPoint ==`}})
+
+	// THEN it's ignored instead of dropping every sample of the event type
+	assert.False(t, ec.Enabled)
+	assert.False(t, ec.HasIncludes("StorageSample"))
+	assert.True(t, ec.Evaluate(storageSample))
+
+	// WHEN the event type has other valid rules
+	ec = sampler.NewMatcherChain(config.IncludeMetricsMap{"StorageSample": {`mountPoint ==`, `filesystemType == tmpfs`}})
+
+	// THEN only the valid rules apply
+	require.Len(t, ec.Matchers["StorageSample"], 1)
+	assert.False(t, ec.Evaluate(storageSample))
+	assert.True(t, ec.Evaluate(tmpFS))
+}
+
 type enabledFFRetriever struct{}
 
 func (e *enabledFFRetriever) GetFeatureFlag(name string) (enabled bool, exists bool) {
@@ -562,6 +636,7 @@ func TestNewSampleMatchFn(t *testing.T) {
 	trueVar := true
 	falseVar := false
 	emptyMatchers := config.IncludeMetricsMap{}
+	processCount := 3
 
 	type args struct {
 		enableProcessMetrics   *bool
@@ -634,6 +709,26 @@ func TestNewSampleMatchFn(t *testing.T) {
 			},
 			include: true,
 		},
+		{
+			name: "non process samples rules do not enable process samples",
+			args: args{
+				enableProcessMetrics:   nil,
+				includeMetricsMatchers: config.IncludeMetricsMap{"NetworkSample": []string{"interfaceName == eth0"}},
+				ffRetriever:            testFF.EmptyFFRetriever,
+				sample:                 &fixture.ProcessSample,
+			},
+			include: false,
+		},
+		{
+			name: "non process samples are filtered by their rules",
+			args: args{
+				enableProcessMetrics:   &falseVar,
+				includeMetricsMatchers: config.IncludeMetricsMap{"NetworkSample": []string{"interfaceName == does-not-match"}},
+				ffRetriever:            testFF.EmptyFFRetriever,
+				sample:                 &fixture.NetworkSample,
+			},
+			include: false,
+		},
 		{
 			name: "process samples not matching rules are not included",
 			args: args{
@@ -644,10 +739,30 @@ func TestNewSampleMatchFn(t *testing.T) {
 			},
 			include: false,
 		},
+		{
+			name: "aggregated process samples are included, as their processes were already matched",
+			args: args{
+				enableProcessMetrics:   &trueVar,
+				includeMetricsMatchers: config.IncludeMetricsMap{"process.name": []string{"regex \"bar*\""}},
+				ffRetriever:            testFF.EmptyFFRetriever,
+				sample:                 &types.ProcessSample{ProcessDisplayName: "other", ProcessCount: &processCount},
+			},
+			include: true,
+		},
+		{
+			name: "aggregated process samples are not included when process metrics are disabled",
+			args: args{
+				enableProcessMetrics:   &falseVar,
+				includeMetricsMatchers: emptyMatchers,
+				ffRetriever:            testFF.EmptyFFRetriever,
+				sample:                 &types.ProcessSample{ProcessDisplayName: "other", ProcessCount: &processCount},
+			},
+			include: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			matchFn := sampler.NewSampleMatchFn(tt.args.enableProcessMetrics, tt.args.includeMetricsMatchers, tt.args.ffRetriever)
+			matchFn := sampler.NewSampleMatchFn(tt.args.enableProcessMetrics, tt.args.includeMetricsMatchers, nil, tt.args.ffRetriever)
 			assert.Equal(t, tt.include, matchFn(tt.args.sample))
 		})
 	}
@@ -0,0 +1,481 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package sampler
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/newrelic/infrastructure-agent/pkg/trace"
+)
+
+// eventTypeAttribute is the JSON attribute holding the event type of every sample.
+const eventTypeAttribute = "eventType"
+
+// Comparison operators supported by the rules.
+const (
+	opEqual       = "=="
+	opNotEqual    = "!="
+	opGreater     = ">"
+	opGreaterEq   = ">="
+	opLess        = "<"
+	opLessEq      = "<="
+	opRegex       = "=~"
+	opNotRegex    = "!~"
+	keywordAnd    = "and"
+	keywordOr     = "or"
+	operatorChars = "=!<>~"
+)
+
+// Size suffixes accepted by numeric values, e.g. 100MB.
+var sizeUnits = map[string]float64{
+	"kb": 1 << 10,
+	"mb": 1 << 20,
+	"gb": 1 << 30,
+	"tb": 1 << 40,
+}
+
+var numberWithUnitRegex = regexp.MustCompile(`^(-?[0-9]+(?:\.[0-9]+)?)([a-zA-Z]{2})?$`)
+
+// ruleMatcher evaluates a rule expression against the JSON attributes of a sample. For example:
+//   cpuPercent > 2 and (commandName == java or userName =~ "^svc-")
+// Rules support the ==, !=, >, >=, <, <=, =~ (regex match) and !~ (regex not match) operators, combined with
+// "and"/"or" (and takes precedence) and parenthesis. Missing attributes never match.
+type ruleMatcher struct {
+	expr string
+	root ruleNode
+}
+
+func (r ruleMatcher) Evaluate(event interface{}) bool {
+	isMatch := r.root.eval(event)
+	trace.MetricMatch("sample matches rule '%v': %v", r.expr, isMatch)
+	return isMatch
+}
+
+type ruleNode interface {
+	eval(event interface{}) bool
+}
+
+type andNode []ruleNode
+
+func (n andNode) eval(event interface{}) bool {
+	for _, child := range n {
+		if !child.eval(event) {
+			return false
+		}
+	}
+	return true
+}
+
+type orNode []ruleNode
+
+func (n orNode) eval(event interface{}) bool {
+	for _, child := range n {
+		if child.eval(event) {
+			return true
+		}
+	}
+	return false
+}
+
+type comparison struct {
+	attribute string
+	op        string
+	text      string
+	number    *float64
+	regex     *regexp.Regexp
+}
+
+func (c comparison) eval(event interface{}) bool {
+	actual, found := attributeValue(event, c.attribute)
+	if !found {
+		return false
+	}
+
+	switch c.op {
+	case opRegex:
+		return c.regex.MatchString(fmt.Sprint(actual))
+	case opNotRegex:
+		return !c.regex.MatchString(fmt.Sprint(actual))
+	}
+
+	if c.number != nil {
+		if n, ok := toFloat(actual); ok {
+			switch c.op {
+			case opEqual:
+				return n == *c.number
+			case opNotEqual:
+				return n != *c.number
+			case opGreater:
+				return n > *c.number
+			case opGreaterEq:
+				return n >= *c.number
+			case opLess:
+				return n < *c.number
+			case opLessEq:
+				return n <= *c.number
+			}
+		}
+	}
+
+	switch c.op {
+	case opEqual:
+		return fmt.Sprint(actual) == c.text
+	case opNotEqual:
+		return fmt.Sprint(actual) != c.text
+	}
+	// numeric comparison on non numeric values
+	return false
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.String:
+		f, err := strconv.ParseFloat(v.String(), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// newRuleMatcher parses a rule expression.
+func newRuleMatcher(expr string) (ExpressionMatcher, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &ruleParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("unexpected '%s'", p.peek().value)
+	}
+	return ruleMatcher{expr: expr, root: root}, nil
+}
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenQuoted
+	tokenOperator
+	tokenOpenParen
+	tokenCloseParen
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+}
+
+func tokenize(expr string) (tokens []token, err error) {
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenOpenParen, value: "("})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenCloseParen, value: ")"})
+			i++
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(expr[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quoted value at position %d", i)
+			}
+			tokens = append(tokens, token{kind: tokenQuoted, value: expr[i+1 : i+1+end]})
+			i += end + 2
+		case strings.IndexByte(operatorChars, c) >= 0:
+			j := i
+			for j < len(expr) && strings.IndexByte(operatorChars, expr[j]) >= 0 {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenOperator, value: expr[i:j]})
+			i = j
+		default:
+			j := i
+			for j < len(expr) && !strings.ContainsRune(" \t()\"'"+operatorChars, rune(expr[j])) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenWord, value: expr[i:j]})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+type ruleParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *ruleParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *ruleParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *ruleParser) next() (token, error) {
+	if p.done() {
+		return token{}, fmt.Errorf("unexpected end of rule")
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+	return t, nil
+}
+
+func (p *ruleParser) isKeyword(keyword string) bool {
+	return !p.done() && p.peek().kind == tokenWord && strings.EqualFold(p.peek().value, keyword)
+}
+
+func (p *ruleParser) parseOr() (ruleNode, error) {
+	node, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	nodes := orNode{node}
+	for p.isKeyword(keywordOr) {
+		p.pos++
+		if node, err = p.parseAnd(); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return nodes, nil
+}
+
+func (p *ruleParser) parseAnd() (ruleNode, error) {
+	node, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	nodes := andNode{node}
+	for p.isKeyword(keywordAnd) {
+		p.pos++
+		if node, err = p.parseTerm(); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return nodes, nil
+}
+
+func (p *ruleParser) parseTerm() (ruleNode, error) {
+	t, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	if t.kind == tokenOpenParen {
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing, err := p.next(); err != nil || closing.kind != tokenCloseParen {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		return node, nil
+	}
+
+	if t.kind != tokenWord {
+		return nil, fmt.Errorf("expected attribute name, found '%s'", t.value)
+	}
+	c := comparison{attribute: t.value}
+
+	op, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if op.kind != tokenOperator {
+		return nil, fmt.Errorf("expected operator after '%s', found '%s'", c.attribute, op.value)
+	}
+	c.op = op.value
+
+	value, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if value.kind != tokenWord && value.kind != tokenQuoted {
+		return nil, fmt.Errorf("expected value for '%s', found '%s'", c.attribute, value.value)
+	}
+	c.text = value.value
+
+	switch c.op {
+	case opRegex, opNotRegex:
+		if c.regex, err = regexp.Compile(c.text); err != nil {
+			return nil, err
+		}
+	case opEqual, opNotEqual, opGreater, opGreaterEq, opLess, opLessEq:
+		if value.kind == tokenWord {
+			c.number = parseNumber(c.text)
+		}
+		if c.number == nil && c.op != opEqual && c.op != opNotEqual {
+			return nil, fmt.Errorf("'%s' requires a numeric value, found '%s'", c.op, c.text)
+		}
+	default:
+		return nil, fmt.Errorf("unknown operator '%s'", c.op)
+	}
+
+	return c, nil
+}
+
+// parseNumber returns nil if the value is not a number, optionally followed by a size unit.
+func parseNumber(value string) *float64 {
+	matches := numberWithUnitRegex.FindStringSubmatch(value)
+	if matches == nil {
+		return nil
+	}
+	n, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return nil
+	}
+	if matches[2] != "" {
+		unit, ok := sizeUnits[strings.ToLower(matches[2])]
+		if !ok {
+			return nil
+		}
+		n *= unit
+	}
+	return &n
+}
+
+// jsonFieldsCache stores, per struct type, the index of its fields by JSON attribute name.
+var jsonFieldsCache sync.Map
+
+// attributeValue returns the value of the attribute with the provided JSON name, looking into embedded structs, as
+// the attributes are submitted. Nil attributes are considered missing.
+func attributeValue(event interface{}, name string) (interface{}, bool) {
+	v, ok := indirect(reflect.ValueOf(event))
+	if !ok {
+		return nil, false
+	}
+
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+		mv := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+		if !mv.IsValid() {
+			return nil, false
+		}
+		return interfaceOf(mv)
+	case reflect.Struct:
+		index, found := jsonFields(v.Type())[name]
+		if !found {
+			trace.MetricMatch("attribute '%v' does NOT exist in sample", name)
+			return nil, false
+		}
+		for _, i := range index {
+			if v, ok = indirect(v); !ok {
+				return nil, false
+			}
+			v = v.Field(i)
+		}
+		return interfaceOf(v)
+	}
+	return nil, false
+}
+
+// eventTypeOf returns the event type of a sample, or the name of its type if it is not set.
+func eventTypeOf(event interface{}) string {
+	if eventType, ok := attributeValue(event, eventTypeAttribute); ok {
+		if s, ok := eventType.(string); ok && s != "" {
+			return s
+		}
+	}
+	t := reflect.TypeOf(event)
+	if t == nil {
+		return ""
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
+func indirect(v reflect.Value) (reflect.Value, bool) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return v, false
+		}
+		v = v.Elem()
+	}
+	return v, v.IsValid()
+}
+
+func interfaceOf(v reflect.Value) (interface{}, bool) {
+	v, ok := indirect(v)
+	if !ok || !v.CanInterface() {
+		return nil, false
+	}
+	return v.Interface(), true
+}
+
+func jsonFields(t reflect.Type) map[string][]int {
+	if cached, ok := jsonFieldsCache.Load(t); ok {
+		return cached.(map[string][]int)
+	}
+	fields := map[string][]int{}
+	collectJSONFields(t, nil, fields)
+	jsonFieldsCache.Store(t, fields)
+	return fields
+}
+
+// collectJSONFields follows the encoding/json rules: embedded struct fields are promoted, and the shallowest field
+// wins on name conflicts.
+func collectJSONFields(t reflect.Type, parent []int, fields map[string][]int) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		index := append(append([]int{}, parent...), i)
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				collectJSONFields(ft, index, fields)
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			// unexported
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if existing, ok := fields[name]; !ok || len(index) < len(existing) {
+			fields[name] = index
+		}
+	}
+}
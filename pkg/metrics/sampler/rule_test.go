@@ -0,0 +1,145 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package sampler
+
+import (
+	"testing"
+
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/types"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type cpuSample struct {
+	CPUPercent float64 `json:"cpuPercent"`
+}
+
+type memorySample struct {
+	MemoryTotal float64 `json:"memoryTotalBytes"`
+}
+
+type systemSample struct {
+	sample.BaseEvent
+	*cpuSample
+	*memorySample
+}
+
+func TestAttributeValue(t *testing.T) {
+	threads := int32(12)
+	processSample := &types.ProcessSample{
+		BaseEvent:          sample.BaseEvent{EventType: "ProcessSample"},
+		ProcessDisplayName: "java",
+		CPUPercent:         2.5,
+		FdCount:            &threads,
+	}
+	system := &systemSample{
+		BaseEvent: sample.BaseEvent{EventType: "SystemSample"},
+		cpuSample: &cpuSample{CPUPercent: 50},
+	}
+	flatSample := map[string]interface{}{"eventType": "ProcessSample", "containerLabel_app": "web"}
+
+	tests := []struct {
+		name      string
+		event     interface{}
+		attribute string
+		want      interface{}
+		found     bool
+	}{
+		{"field", processSample, "processDisplayName", "java", true},
+		{"numeric field", processSample, "cpuPercent", 2.5, true},
+		{"pointer field", processSample, "fileDescriptorCount", int32(12), true},
+		{"nil pointer field", processSample, "ioTotalReadBytes", nil, false},
+		{"embedded field", processSample, "eventType", "ProcessSample", true},
+		{"go field name is not an attribute", processSample, "ProcessDisplayName", nil, false},
+		{"ignored field", processSample, "ContainerLabels", nil, false},
+		{"embedded pointer field", system, "cpuPercent", 50.0, true},
+		{"nil embedded pointer field", system, "memoryTotalBytes", nil, false},
+		{"map", flatSample, "containerLabel_app", "web", true},
+		{"map missing", flatSample, "processDisplayName", nil, false},
+		{"non struct", 42, "value", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, found := attributeValue(tt.event, tt.attribute)
+			assert.Equal(t, tt.found, found)
+			assert.Equal(t, tt.want, value)
+		})
+	}
+}
+
+func TestEventTypeOf(t *testing.T) {
+	assert.Equal(t, "StorageSample", eventTypeOf(&systemSample{BaseEvent: sample.BaseEvent{EventType: "StorageSample"}}))
+	assert.Equal(t, "ProcessSample", eventTypeOf(types.ProcessSample{}))
+	assert.Equal(t, "ProcessSample", eventTypeOf(&map[string]interface{}{"eventType": "ProcessSample"}))
+}
+
+func TestRuleMatcher(t *testing.T) {
+	event := &types.ProcessSample{
+		ProcessDisplayName: "java",
+		CommandName:        "java",
+		User:               "svc-app",
+		CPUPercent:         2.5,
+		MemoryRSSBytes:     200 * 1024 * 1024,
+		CmdLine:            "/usr/bin/java -jar app.jar",
+	}
+
+	tests := []struct {
+		rule string
+		want bool
+	}{
+		{`processDisplayName == java`, true},
+		{`processDisplayName == "java"`, true},
+		{`processDisplayName != java`, false},
+		{`cpuPercent > 2`, true},
+		{`cpuPercent >= 2.5`, true},
+		{`cpuPercent < 2.5`, false},
+		{`cpuPercent <= 2.5`, true},
+		{`cpuPercent == 2.5`, true},
+		{`memoryResidentSizeBytes > 100MB`, true},
+		{`memoryResidentSizeBytes > 1gb`, false},
+		{`userName =~ "^svc-"`, true},
+		{`userName !~ "^svc-"`, false},
+		{`commandLine =~ 'app\.jar$'`, true},
+		{`cpuPercent > 2 and commandName == python`, false},
+		{`cpuPercent > 2 or commandName == python`, true},
+		{`commandName == python or cpuPercent > 5 or userName == svc-app`, true},
+		{`commandName == python or cpuPercent > 2 and userName == root`, false},
+		{`(commandName == python or cpuPercent > 2) and userName == svc-app`, true},
+		{`cpuPercent > 2 AND (userName == root OR processDisplayName == java)`, true},
+		{`missingAttribute == java`, false},
+		{`missingAttribute != java`, false},
+		{`processDisplayName > 2`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			m, err := newRuleMatcher(tt.rule)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, m.Evaluate(event))
+		})
+	}
+}
+
+func TestRuleMatcher_InvalidRules(t *testing.T) {
+	rules := []string{
+		``,
+		`cpuPercent`,
+		`cpuPercent >`,
+		`cpuPercent > high`,
+		`cpuPercent > 10XB`,
+		`cpuPercent <> 2`,
+		`userName =~ "["`,
+		`userName == "unterminated`,
+		`(cpuPercent > 2`,
+		`cpuPercent > 2)`,
+		`cpuPercent > 2 and`,
+		`== 2`,
+	}
+	for _, rule := range rules {
+		t.Run(rule, func(t *testing.T) {
+			_, err := newRuleMatcher(rule)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package sampler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+// Transformation actions.
+const (
+	transformDrop     = "drop"
+	transformRename   = "rename"
+	transformHash     = "hash"
+	transformTruncate = "truncate"
+	transformRedact   = "redact"
+	transformAdd      = "add"
+)
+
+// defaultRedactReplacement replaces the redacted values when no replacement is configured.
+const defaultRedactReplacement = "****"
+
+// TransformSampleFn func that returns the event/sample to be sent once the attribute transformations are applied.
+type TransformSampleFn func(event sample.Event) sample.Event
+
+// attributeTransform applies a single transformation to the attributes of a sample.
+type attributeTransform func(attributes map[string]interface{})
+
+// transformedEvent a sample whose attributes were transformed, it marshals to the same JSON than the original.
+type transformedEvent map[string]interface{}
+
+var _ sample.Event = transformedEvent{} // transformedEvent implements sample.Event
+
+func (t transformedEvent) Type(eventType string) {
+	t["eventType"] = eventType
+}
+
+func (t transformedEvent) Entity(key entity.Key) {
+	t["entityKey"] = key
+}
+
+func (t transformedEvent) Timestamp(timestamp int64) {
+	t["timestamp"] = timestamp
+}
+
+// NewSampleTransformFn creates a new TransformSampleFn from the agent configuration. Samples from event types without
+// transformations are returned untouched. Invalid transformations are logged and ignored.
+func NewSampleTransformFn(transformations config.MetricsTransformMap) TransformSampleFn {
+	transforms := map[string][]attributeTransform{}
+	for eventType, list := range transformations {
+		for _, t := range list {
+			transform, err := newAttributeTransform(t)
+			if err != nil {
+				mlog.WithError(err).WithField("eventType", eventType).Error("invalid metrics transformation, ignoring it")
+				continue
+			}
+			transforms[eventType] = append(transforms[eventType], transform)
+		}
+	}
+
+	return func(event sample.Event) sample.Event {
+		if len(transforms) == 0 || event == nil {
+			return event
+		}
+		eventTransforms, ok := transforms[eventTypeOf(event)]
+		if !ok {
+			return event
+		}
+		attributes, err := toAttributes(event)
+		if err != nil {
+			mlog.WithError(err).Debug("cannot transform sample attributes, sending it untouched")
+			return event
+		}
+		for _, transform := range eventTransforms {
+			transform(attributes)
+		}
+		return transformedEvent(attributes)
+	}
+}
+
+func newAttributeTransform(t config.MetricsTransform) (attributeTransform, error) {
+	name := t.Attribute
+	if name == "" {
+		return nil, fmt.Errorf("missing attribute for %q action", t.Action)
+	}
+
+	switch strings.ToLower(t.Action) {
+	case transformDrop:
+		return func(attributes map[string]interface{}) {
+			delete(attributes, name)
+		}, nil
+	case transformRename:
+		if t.To == "" {
+			return nil, fmt.Errorf("missing 'to' attribute to rename %q", name)
+		}
+		return func(attributes map[string]interface{}) {
+			if value, ok := attributes[name]; ok {
+				delete(attributes, name)
+				attributes[t.To] = value
+			}
+		}, nil
+	case transformHash:
+		return func(attributes map[string]interface{}) {
+			if value, ok := attributes[name]; ok {
+				sum := sha256.Sum256([]byte(fmt.Sprint(value)))
+				attributes[name] = hex.EncodeToString(sum[:])
+			}
+		}, nil
+	case transformTruncate:
+		if t.Length <= 0 {
+			return nil, fmt.Errorf("invalid length %d to truncate %q", t.Length, name)
+		}
+		return func(attributes map[string]interface{}) {
+			if value, ok := attributes[name].(string); ok {
+				if runes := []rune(value); len(runes) > t.Length {
+					attributes[name] = string(runes[:t.Length])
+				}
+			}
+		}, nil
+	case transformRedact:
+		regex, err := regexp.Compile(t.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex to redact %q: %s", name, err)
+		}
+		replacement := t.Replacement
+		if replacement == "" {
+			replacement = defaultRedactReplacement
+		}
+		return func(attributes map[string]interface{}) {
+			if value, ok := attributes[name].(string); ok {
+				attributes[name] = regex.ReplaceAllString(value, replacement)
+			}
+		}, nil
+	case transformAdd:
+		switch t.Value.(type) {
+		case string, bool, int, int64, uint64, float64:
+		default:
+			return nil, fmt.Errorf("invalid value %v to add %q, expected a string, number or boolean", t.Value, name)
+		}
+		return func(attributes map[string]interface{}) {
+			attributes[name] = t.Value
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown action %q", t.Action)
+}
+
+// toAttributes returns the attributes of the marshalled sample. Numbers are kept as json.Number to not lose precision.
+func toAttributes(event sample.Event) (map[string]interface{}, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	attributes := map[string]interface{}{}
+	if err := decoder.Decode(&attributes); err != nil {
+		return nil, err
+	}
+	return attributes, nil
+}
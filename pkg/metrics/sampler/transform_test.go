@@ -0,0 +1,119 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package sampler_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/sampler"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/types"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newProcessSample() *types.ProcessSample {
+	return &types.ProcessSample{
+		BaseEvent:          sample.BaseEvent{EventType: "ProcessSample"},
+		ProcessDisplayName: "mysqld",
+		CmdLine:            "/usr/sbin/mysqld --user=mysql --password=s3cr3t --port=3306",
+		User:               "mysql",
+		ProcessID:          1234,
+	}
+}
+
+func marshalAttributes(t *testing.T, event sample.Event) map[string]interface{} {
+	data, err := json.Marshal(event)
+	require.NoError(t, err)
+	attributes := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(data, &attributes))
+	return attributes
+}
+
+func TestSampleTransformFn_NoTransformations(t *testing.T) {
+	event := newProcessSample()
+
+	assert.Equal(t, event, sampler.NewSampleTransformFn(nil)(event))
+	assert.Equal(t, event, sampler.NewSampleTransformFn(config.MetricsTransformMap{
+		"StorageSample": {{Action: "drop", Attribute: "mountPoint"}},
+	})(event))
+}
+
+func TestSampleTransformFn_Actions(t *testing.T) {
+	transform := sampler.NewSampleTransformFn(config.MetricsTransformMap{
+		"ProcessSample": {
+			{Action: "redact", Attribute: "commandLine", Regex: `--password=\S+`, Replacement: "--password=[redacted]"},
+			{Action: "truncate", Attribute: "commandLine", Length: 41},
+			{Action: "rename", Attribute: "processDisplayName", To: "processName"},
+			{Action: "hash", Attribute: "userName"},
+			{Action: "drop", Attribute: "processId"},
+			{Action: "add", Attribute: "team", Value: "databases"},
+			{Action: "rename", Attribute: "missing", To: "anything"},
+		},
+	})
+
+	event := transform(newProcessSample())
+	event.Entity("my-entity")
+	attributes := marshalAttributes(t, event)
+
+	assert.Equal(t, "/usr/sbin/mysqld --user=mysql --password=", attributes["commandLine"])
+	assert.Equal(t, "mysqld", attributes["processName"])
+	assert.NotContains(t, attributes, "processDisplayName")
+	assert.Equal(t, "430005175c4c7810996d3481f0dbc3ec01103d6abcc5beec5db4b3f1eae35047", attributes["userName"])
+	assert.NotContains(t, attributes, "processId")
+	assert.Equal(t, "databases", attributes["team"])
+	assert.NotContains(t, attributes, "anything")
+	assert.Equal(t, "ProcessSample", attributes["eventType"])
+	assert.Equal(t, "my-entity", attributes["entityKey"])
+}
+
+func TestSampleTransformFn_Redact(t *testing.T) {
+	transform := sampler.NewSampleTransformFn(config.MetricsTransformMap{
+		"ProcessSample": {{Action: "redact", Attribute: "commandLine", Regex: `--password=\S+`}},
+	})
+
+	attributes := marshalAttributes(t, transform(newProcessSample()))
+
+	assert.Equal(t, "/usr/sbin/mysqld --user=mysql **** --port=3306", attributes["commandLine"])
+}
+
+func TestSampleTransformFn_KeepsNumberPrecision(t *testing.T) {
+	transform := sampler.NewSampleTransformFn(config.MetricsTransformMap{
+		"SystemSample": {{Action: "add", Attribute: "environment", Value: "production"}},
+	})
+	total := uint64(18446744073709551615)
+	event := &metrics.SystemSample{
+		BaseEvent:      sample.BaseEvent{EventType: "SystemSample"},
+		PressureSample: &metrics.PressureSample{CPUSomeTotal: &total},
+	}
+
+	data, err := json.Marshal(transform(event))
+	require.NoError(t, err)
+
+	assert.Contains(t, string(data), `"cpuPressureSomeTotalUsec":18446744073709551615`)
+	assert.Contains(t, string(data), `"environment":"production"`)
+}
+
+func TestSampleTransformFn_InvalidTransformationsAreIgnored(t *testing.T) {
+	transform := sampler.NewSampleTransformFn(config.MetricsTransformMap{
+		"ProcessSample": {
+			{Action: "unknown", Attribute: "commandLine"},
+			{Action: "drop"},
+			{Action: "rename", Attribute: "commandLine"},
+			{Action: "truncate", Attribute: "commandLine"},
+			{Action: "redact", Attribute: "commandLine", Regex: "("},
+			{Action: "add", Attribute: "labels", Value: map[interface{}]interface{}{"a": "b"}},
+			{Action: "drop", Attribute: "userName"},
+		},
+	})
+
+	attributes := marshalAttributes(t, transform(newProcessSample()))
+
+	assert.Equal(t, newProcessSample().CmdLine, attributes["commandLine"])
+	assert.NotContains(t, attributes, "labels")
+	assert.NotContains(t, attributes, "userName")
+}
@@ -0,0 +1,119 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package sensor
+
+import (
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+var sslog = log.WithComponent("SensorSampler")
+
+// Kinds of sensors, reported as the sensorType attribute.
+const (
+	typeTemperature = "temperature"
+	typeFan         = "fan"
+	typeVoltage     = "voltage"
+	typePowerSupply = "powerSupply"
+)
+
+// Sources of the sensors, named after their /sys/class directory.
+const (
+	sourceHwmon       = "hwmon"
+	sourceThermal     = "thermal"
+	sourcePowerSupply = "power_supply"
+)
+
+// Sample a single hardware sensor reading. Only the fields of its sensorType are reported.
+type Sample struct {
+	sample.BaseEvent
+
+	SensorType string `json:"sensorType"`
+	Source     string `json:"source"`
+	// Name of the chip, thermal zone or power supply providing the sensor (e.g. coretemp, thermal_zone0, BAT0)
+	DeviceName string `json:"deviceName"`
+	// Label of the sensor, if provided by the driver, or its name otherwise (e.g. Core 0, temp1, x86_pkg_temp)
+	SensorName string `json:"sensorName"`
+
+	TemperatureCelsius         *float64 `json:"temperatureCelsius,omitempty"`
+	TemperatureMaxCelsius      *float64 `json:"temperatureMaxCelsius,omitempty"`
+	TemperatureCriticalCelsius *float64 `json:"temperatureCriticalCelsius,omitempty"`
+
+	FanSpeedRPM *float64 `json:"fanSpeedRpm,omitempty"`
+	FanMinRPM   *float64 `json:"fanMinRpm,omitempty"`
+
+	VoltageVolts    *float64 `json:"voltageVolts,omitempty"`
+	VoltageMinVolts *float64 `json:"voltageMinVolts,omitempty"`
+	VoltageMaxVolts *float64 `json:"voltageMaxVolts,omitempty"`
+
+	// Battery, Mains, UPS...
+	PowerSupplyType string `json:"powerSupplyType,omitempty"`
+	// Charging, Discharging, Full...
+	PowerSupplyStatus string   `json:"powerSupplyStatus,omitempty"`
+	PowerSupplyHealth string   `json:"powerSupplyHealth,omitempty"`
+	PowerSupplyOnline string   `json:"powerSupplyOnline,omitempty"`
+	CapacityPercent   *float64 `json:"capacityPercent,omitempty"`
+	PowerWatts        *float64 `json:"powerWatts,omitempty"`
+	CurrentAmps       *float64 `json:"currentAmps,omitempty"`
+}
+
+// Sampler reports a SensorSample for each temperature, fan, voltage and power supply sensor found in
+// /sys/class/hwmon, /sys/class/thermal and /sys/class/power_supply. Sensors are discovered on every sample, so
+// hosts without any of them don't report anything.
+type Sampler struct {
+	context    agent.AgentContext
+	sampleRate time.Duration
+	classDir   string
+}
+
+func NewSampler(context agent.AgentContext) *Sampler {
+	sampleRateSec := config.DefaultMetricsSensorSampleRate
+	if context != nil {
+		sampleRateSec = context.Config().MetricsSensorSampleRate
+	}
+
+	return &Sampler{
+		context:    context,
+		sampleRate: time.Second * time.Duration(sampleRateSec),
+		classDir:   helpers.HostSys("class"),
+	}
+}
+
+func (s *Sampler) Name() string { return "SensorSampler" }
+
+func (s *Sampler) Interval() time.Duration {
+	return s.sampleRate
+}
+
+func (s *Sampler) Disabled() bool {
+	return s.Interval() <= config.FREQ_DISABLE_SAMPLING
+}
+
+func (s *Sampler) OnStartup() {}
+
+func (s *Sampler) Sample() (results sample.EventBatch, err error) {
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			err = fmt.Errorf("Panic in SensorSampler.Sample: %v\nStack: %s", panicErr, debug.Stack())
+		}
+	}()
+
+	samples, err := readSensors(s.classDir)
+	if err != nil {
+		sslog.WithError(err).Debug("Unable to read sensors.")
+		return nil, nil
+	}
+
+	for _, sensor := range samples {
+		sensor.Type("SensorSample")
+		results = append(results, sensor)
+	}
+	return results, nil
+}
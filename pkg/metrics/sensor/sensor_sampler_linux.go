@@ -0,0 +1,201 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build linux
+
+package sensor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// hwmonInputRegexp matches the hwmon input attributes of the supported sensors, e.g. temp1_input, fan2_input, in0_input
+var hwmonInputRegexp = regexp.MustCompile(`^(temp|fan|in)([0-9]+)_input$`)
+
+// readSensors returns the samples of all the sensors found under the given /sys/class directory. Missing classes are
+// skipped, as they depend on the hardware and the loaded drivers.
+func readSensors(classDir string) ([]*Sample, error) {
+	var samples []*Sample
+	for _, read := range []func(string) ([]*Sample, error){readHwmon, readThermal, readPowerSupplies} {
+		s, err := read(classDir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		samples = append(samples, s...)
+	}
+	return samples, nil
+}
+
+// readHwmon reads the temperature, fan and voltage sensors of each /sys/class/hwmon chip.
+// Temperatures are provided in millidegrees Celsius and voltages in millivolts.
+func readHwmon(classDir string) ([]*Sample, error) {
+	chips, err := deviceDirs(filepath.Join(classDir, sourceHwmon))
+	if err != nil {
+		return nil, err
+	}
+
+	var samples []*Sample
+	for _, chip := range chips {
+		attrDir := hwmonAttributesDir(chip)
+		files, err := ioutil.ReadDir(attrDir)
+		if err != nil {
+			continue
+		}
+		name := readString(chip, "name")
+		if name == "" {
+			name = filepath.Base(chip)
+		}
+		for _, file := range files {
+			match := hwmonInputRegexp.FindStringSubmatch(file.Name())
+			if match == nil {
+				continue
+			}
+			sensor := match[1] + match[2]
+			s := &Sample{
+				Source:     sourceHwmon,
+				DeviceName: name,
+				SensorName: readString(attrDir, sensor+"_label"),
+			}
+			if s.SensorName == "" {
+				s.SensorName = sensor
+			}
+			switch match[1] {
+			case "temp":
+				s.SensorType = typeTemperature
+				s.TemperatureCelsius = readScaled(attrDir, sensor+"_input", 1000)
+				s.TemperatureMaxCelsius = readScaled(attrDir, sensor+"_max", 1000)
+				s.TemperatureCriticalCelsius = readScaled(attrDir, sensor+"_crit", 1000)
+			case "fan":
+				s.SensorType = typeFan
+				s.FanSpeedRPM = readScaled(attrDir, sensor+"_input", 1)
+				s.FanMinRPM = readScaled(attrDir, sensor+"_min", 1)
+			case "in":
+				s.SensorType = typeVoltage
+				s.VoltageVolts = readScaled(attrDir, sensor+"_input", 1000)
+				s.VoltageMinVolts = readScaled(attrDir, sensor+"_min", 1000)
+				s.VoltageMaxVolts = readScaled(attrDir, sensor+"_max", 1000)
+			}
+			samples = append(samples, s)
+		}
+	}
+	return samples, nil
+}
+
+// hwmonAttributesDir returns the directory containing the sensor attributes of a hwmon chip. Older kernels place
+// them in the device directory instead of the hwmon one.
+func hwmonAttributesDir(chip string) string {
+	if matches, _ := filepath.Glob(filepath.Join(chip, "*_input")); len(matches) > 0 {
+		return chip
+	}
+	return filepath.Join(chip, "device")
+}
+
+// readThermal reads the temperature of each /sys/class/thermal zone, along with its critical trip point, if any.
+func readThermal(classDir string) ([]*Sample, error) {
+	zones, err := deviceDirs(filepath.Join(classDir, sourceThermal))
+	if err != nil {
+		return nil, err
+	}
+
+	var samples []*Sample
+	for _, zone := range zones {
+		if !strings.HasPrefix(filepath.Base(zone), "thermal_zone") {
+			// cooling devices
+			continue
+		}
+		temperature := readScaled(zone, "temp", 1000)
+		if temperature == nil {
+			continue
+		}
+		s := &Sample{
+			SensorType:         typeTemperature,
+			Source:             sourceThermal,
+			DeviceName:         filepath.Base(zone),
+			SensorName:         readString(zone, "type"),
+			TemperatureCelsius: temperature,
+		}
+		tripTypes, _ := filepath.Glob(filepath.Join(zone, "trip_point_*_type"))
+		for _, tripType := range tripTypes {
+			if readString(filepath.Dir(tripType), filepath.Base(tripType)) == "critical" {
+				tripTemp := strings.TrimSuffix(filepath.Base(tripType), "_type") + "_temp"
+				s.TemperatureCriticalCelsius = readScaled(zone, tripTemp, 1000)
+				break
+			}
+		}
+		samples = append(samples, s)
+	}
+	return samples, nil
+}
+
+// readPowerSupplies reads the state of each /sys/class/power_supply device (batteries, mains, UPS...).
+// Voltages, currents and powers are provided in micro units.
+func readPowerSupplies(classDir string) ([]*Sample, error) {
+	supplies, err := deviceDirs(filepath.Join(classDir, sourcePowerSupply))
+	if err != nil {
+		return nil, err
+	}
+
+	var samples []*Sample
+	for _, supply := range supplies {
+		name := filepath.Base(supply)
+		s := &Sample{
+			SensorType:        typePowerSupply,
+			Source:            sourcePowerSupply,
+			DeviceName:        name,
+			SensorName:        name,
+			PowerSupplyType:   readString(supply, "type"),
+			PowerSupplyStatus: readString(supply, "status"),
+			PowerSupplyHealth: readString(supply, "health"),
+			CapacityPercent:   readScaled(supply, "capacity", 1),
+			VoltageVolts:      readScaled(supply, "voltage_now", 1000000),
+			PowerWatts:        readScaled(supply, "power_now", 1000000),
+			CurrentAmps:       readScaled(supply, "current_now", 1000000),
+		}
+		switch readString(supply, "online") {
+		case "0":
+			s.PowerSupplyOnline = "false"
+		case "1":
+			s.PowerSupplyOnline = "true"
+		}
+		samples = append(samples, s)
+	}
+	return samples, nil
+}
+
+// deviceDirs returns the sorted paths of the devices of a /sys/class directory, which are usually symlinks.
+func deviceDirs(dir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	devices := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		devices = append(devices, filepath.Join(dir, entry.Name()))
+	}
+	sort.Strings(devices)
+	return devices, nil
+}
+
+// readString returns the trimmed content of a sysfs attribute, or an empty string if it can't be read.
+func readString(dir, attribute string) string {
+	content, err := ioutil.ReadFile(filepath.Join(dir, attribute))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(content))
+}
+
+// readScaled returns the numeric value of a sysfs attribute divided by the given scale, or nil if it can't be read.
+func readScaled(dir, attribute string, scale float64) *float64 {
+	value, err := strconv.ParseFloat(readString(dir, attribute), 64)
+	if err != nil {
+		return nil
+	}
+	value /= scale
+	return &value
+}
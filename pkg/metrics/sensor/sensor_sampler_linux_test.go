@@ -0,0 +1,119 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build linux
+
+package sensor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent/mocks"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+)
+
+const fixturesClassDir = "fixtures/sys/class"
+
+func sensorsByName(samples []*Sample) map[string]*Sample {
+	byName := map[string]*Sample{}
+	for _, s := range samples {
+		byName[s.DeviceName+"/"+s.SensorName] = s
+	}
+	return byName
+}
+
+func TestReadSensors(t *testing.T) {
+	samples, err := readSensors(fixturesClassDir)
+	require.NoError(t, err)
+	require.Len(t, samples, 8)
+	sensors := sensorsByName(samples)
+
+	pkg := sensors["coretemp/Package id 0"]
+	require.NotNil(t, pkg)
+	assert.Equal(t, typeTemperature, pkg.SensorType)
+	assert.Equal(t, sourceHwmon, pkg.Source)
+	assert.Equal(t, 45.0, *pkg.TemperatureCelsius)
+	assert.Equal(t, 84.0, *pkg.TemperatureMaxCelsius)
+	assert.Equal(t, 100.0, *pkg.TemperatureCriticalCelsius)
+
+	unlabeled := sensors["coretemp/temp2"]
+	require.NotNil(t, unlabeled)
+	assert.Equal(t, 43.5, *unlabeled.TemperatureCelsius)
+	assert.Nil(t, unlabeled.TemperatureCriticalCelsius)
+
+	fan := sensors["nct6775/fan1"]
+	require.NotNil(t, fan)
+	assert.Equal(t, typeFan, fan.SensorType)
+	assert.Equal(t, 1250.0, *fan.FanSpeedRPM)
+	assert.Equal(t, 300.0, *fan.FanMinRPM)
+
+	vcore := sensors["nct6775/Vcore"]
+	require.NotNil(t, vcore)
+	assert.Equal(t, typeVoltage, vcore.SensorType)
+	assert.Equal(t, 1.104, *vcore.VoltageVolts)
+	assert.Equal(t, 0.8, *vcore.VoltageMinVolts)
+	assert.Equal(t, 1.5, *vcore.VoltageMaxVolts)
+
+	// attributes in the device directory
+	legacyFan := sensors["it87/fan1"]
+	require.NotNil(t, legacyFan)
+	assert.Equal(t, 980.0, *legacyFan.FanSpeedRPM)
+
+	zone := sensors["thermal_zone0/x86_pkg_temp"]
+	require.NotNil(t, zone)
+	assert.Equal(t, sourceThermal, zone.Source)
+	assert.Equal(t, 52.0, *zone.TemperatureCelsius)
+	assert.Equal(t, 105.0, *zone.TemperatureCriticalCelsius)
+
+	ac := sensors["AC/AC"]
+	require.NotNil(t, ac)
+	assert.Equal(t, typePowerSupply, ac.SensorType)
+	assert.Equal(t, "Mains", ac.PowerSupplyType)
+	assert.Equal(t, "true", ac.PowerSupplyOnline)
+
+	battery := sensors["BAT0/BAT0"]
+	require.NotNil(t, battery)
+	assert.Equal(t, "Battery", battery.PowerSupplyType)
+	assert.Equal(t, "Discharging", battery.PowerSupplyStatus)
+	assert.Equal(t, "Good", battery.PowerSupplyHealth)
+	assert.Empty(t, battery.PowerSupplyOnline)
+	assert.Equal(t, 87.0, *battery.CapacityPercent)
+	assert.Equal(t, 12.1, *battery.VoltageVolts)
+	assert.Equal(t, 8.5, *battery.PowerWatts)
+	assert.Nil(t, battery.CurrentAmps)
+}
+
+func TestReadSensors_NoSensors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "class")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	samples, err := readSensors(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, samples)
+}
+
+func TestSampler(t *testing.T) {
+	sysDir, err := filepath.Abs("fixtures/sys")
+	require.NoError(t, err)
+	os.Setenv("HOST_SYS", sysDir)
+	defer os.Unsetenv("HOST_SYS")
+
+	ctx := new(mocks.AgentContext)
+	ctx.On("Config").Return(&config.Config{MetricsSensorSampleRate: 30})
+
+	sampler := NewSampler(ctx)
+	assert.False(t, sampler.Disabled())
+
+	results, err := sampler.Sample()
+	require.NoError(t, err)
+	require.Len(t, results, 8)
+	for _, r := range results {
+		assert.Equal(t, "SensorSample", r.(*Sample).EventType)
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"path/filepath"
 	"runtime/debug"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -60,6 +61,13 @@ type BaseSample struct {
 	HasDelta                bool     `json:"-"`
 }
 
+// blockDevice is a disk or partition which is not mounted, identified by its IO counters name (e.g. sdb, sda2).
+type blockDevice struct {
+	name      string
+	readOnly  bool
+	sizeBytes uint64
+}
+
 type PartitionStat struct {
 	Device     string `json:"device"`
 	Mountpoint string `json:"mountpoint"`
@@ -207,25 +215,8 @@ func (ss *Sampler) Sample() (results sample.EventBatch, err error) {
 
 		helpers.LogStructureDetails(sslog, fsUsage, "PartitionUsage", "raw", nil)
 
-		if cfg != nil && len(cfg.FileDevicesIgnored) > 0 {
-			found := false
-			fileDevicesIgnored := cfg.FileDevicesIgnored
-			sslog.WithField("fileDevicesIgnored", fileDevicesIgnored).Debug("Using file device ignored.")
-			for _, deviceName := range fileDevicesIgnored {
-				if strings.Contains(fs.Device, deviceName) {
-					sslog.WithFieldsF(func() logrus.Fields {
-						return logrus.Fields{
-							"fileDeviceIgnored": deviceName,
-							"skippedDevice":     fs.Device,
-						}
-					}).Debug("Skipping ignored device.")
-					found = true
-					break
-				}
-			}
-			if found {
-				continue
-			}
+		if isIgnoredDevice(cfg, fs.Device) {
+			continue
 		}
 
 		sample.FileSystemType = fs.Fstype
@@ -298,6 +289,14 @@ func (ss *Sampler) Sample() (results sample.EventBatch, err error) {
 					}).Debug("No device mapping.")
 				}
 			}
+
+			if cfg != nil && cfg.MetricsStorageUnmountedDisks {
+				for _, bd := range unmountedDevices(deviceToLogical) {
+					if s := ss.unmountedDeviceSample(cfg, bd, ioCounters, elapsedMs); s != nil {
+						samples[s.Device] = append(samples[s.Device], s)
+					}
+				}
+			}
 		}
 		ss.lastDiskStats = ioCounters
 	}
@@ -315,6 +314,60 @@ func (ss *Sampler) Sample() (results sample.EventBatch, err error) {
 	return results, nil
 }
 
+// unmountedDeviceSample returns the sample of a block device that is not mounted, only with its IO metrics, or nil if
+// it is ignored or there are no previous counters for it.
+func (ss *Sampler) unmountedDeviceSample(cfg *config.Config, bd blockDevice, ioCounters map[string]IOCountersStat, elapsedMs int64) *Sample {
+	device := "/dev/" + bd.name
+	if isIgnoredDevice(cfg, device) {
+		return nil
+	}
+	counter, ok := ioCounters[bd.name]
+	if !ok {
+		return nil
+	}
+	lastStats, ok := ss.lastDiskStats[bd.name]
+	if !ok {
+		return nil
+	}
+
+	sample := &Sample{}
+	sample.Type("StorageSample")
+	sample.ElapsedSampleDeltaMs = elapsedMs
+	sample.Device = device
+	sample.IsReadOnly = strconv.FormatBool(bd.readOnly)
+	if bd.sizeBytes > 0 {
+		totalBytes := PlatformFsByteScale(bd.sizeBytes)
+		sample.TotalBytes = &totalBytes
+	}
+
+	ioSample := ss.storageUtilities.CalculateSampleValues(counter, lastStats, elapsedMs)
+	sample.HasDelta = true
+	sample.CountersSource = counter.Source()
+	populateSample(ioSample, sample)
+	return sample
+}
+
+// isIgnoredDevice returns true if the device matches any of the file_devices_ignored configuration entries.
+func isIgnoredDevice(cfg *config.Config, device string) bool {
+	if cfg == nil || len(cfg.FileDevicesIgnored) == 0 {
+		return false
+	}
+	fileDevicesIgnored := cfg.FileDevicesIgnored
+	sslog.WithField("fileDevicesIgnored", fileDevicesIgnored).Debug("Using file device ignored.")
+	for _, deviceName := range fileDevicesIgnored {
+		if strings.Contains(device, deviceName) {
+			sslog.WithFieldsF(func() logrus.Fields {
+				return logrus.Fields{
+					"fileDeviceIgnored": deviceName,
+					"skippedDevice":     device,
+				}
+			}).Debug("Skipping ignored device.")
+			return true
+		}
+	}
+	return false
+}
+
 // PartitionsCache avoids polling for partitions on each sample, since they do not change so frequently
 type PartitionsCache struct {
 	ttl             time.Duration
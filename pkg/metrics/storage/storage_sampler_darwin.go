@@ -38,3 +38,7 @@ func populateSampleOS(source, dest *Sample) {
 
 func populateUsageOS(_ *disk.UsageStat, _ *Sample) {
 }
+
+func unmountedDevices(_ map[string]string) []blockDevice {
+	return nil
+}
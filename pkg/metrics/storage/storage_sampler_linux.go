@@ -9,8 +9,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
@@ -45,6 +47,18 @@ type Sample struct {
 	InodesFree        *uint64  `json:"inodesFree,omitempty"`
 	InodesTotal       *uint64  `json:"inodesTotal,omitempty"`
 	InodesUsedPercent *float64 `json:"inodesUsedPercent,omitempty"`
+
+	AvgQueueLen        *float64 `json:"avgQueueLen,omitempty"`
+	CurrentQueueLen    *float64 `json:"currentQueueLen,omitempty"`
+	AvgReadLatencyMs   *float64 `json:"avgReadLatencyMs,omitempty"`
+	AvgWriteLatencyMs  *float64 `json:"avgWriteLatencyMs,omitempty"`
+	MergedReadsPerSec  *float64 `json:"mergedReadIoPerSecond,omitempty"`
+	MergedWritesPerSec *float64 `json:"mergedWriteIoPerSecond,omitempty"`
+	// Discard metrics are only reported by kernels 4.18+
+	DiscardsPerSec       *float64 `json:"discardIoPerSecond,omitempty"`
+	MergedDiscardsPerSec *float64 `json:"mergedDiscardIoPerSecond,omitempty"`
+	DiscardBytesPerSec   *float64 `json:"discardBytesPerSecond,omitempty"`
+	AvgDiscardLatencyMs  *float64 `json:"avgDiscardLatencyMs,omitempty"`
 }
 
 // Enhanced from GOPSUtil, Adding Utilization
@@ -59,6 +73,12 @@ type LinuxIoCountersStat struct {
 	WriteTime               uint64 `json:"writeTime"`
 	IopsInProgress          uint64 `json:"iopsInProgress"`
 	IoTime                  uint64 `json:"ioTime"`
+	WeightedIoTime          uint64 `json:"weightedIoTime"`
+	DiscardCount            uint64 `json:"discardCount"`
+	MergedDiscardCount      uint64 `json:"mergedDiscardCount"`
+	DiscardBytes            uint64 `json:"discardBytes"`
+	DiscardTime             uint64 `json:"discardTime"`
+	HasDiscards             bool   `json:"hasDiscards"`
 	Name                    string `json:"name"`
 	SerialNumber            string `json:"serialNumber"`
 	TotalUtilizationPercent uint64 `json:"totalUtilizationPercent"`
@@ -129,7 +149,17 @@ func NewStorageSampleWrapper(cfg *config.Config) SampleWrapper {
 
 // populateSampleOS complements the populateSample function by copying into the destinations the fields from the source
 // that are exclusive of Linux Storage Samples
-func populateSampleOS(_, _ *Sample) {
+func populateSampleOS(source, dest *Sample) {
+	dest.AvgQueueLen = source.AvgQueueLen
+	dest.CurrentQueueLen = source.CurrentQueueLen
+	dest.AvgReadLatencyMs = source.AvgReadLatencyMs
+	dest.AvgWriteLatencyMs = source.AvgWriteLatencyMs
+	dest.MergedReadsPerSec = source.MergedReadsPerSec
+	dest.MergedWritesPerSec = source.MergedWritesPerSec
+	dest.DiscardsPerSec = source.DiscardsPerSec
+	dest.MergedDiscardsPerSec = source.MergedDiscardsPerSec
+	dest.DiscardBytesPerSec = source.DiscardBytesPerSec
+	dest.AvgDiscardLatencyMs = source.AvgDiscardLatencyMs
 }
 
 // populateUsage copies the Usage Stats inside the destination sample, for those metrics that are exclusive of Linux
@@ -179,6 +209,31 @@ func CalculateSampleValues(ioCounter IOCountersStat, ioLastStats IOCountersStat,
 	readsPerSec := acquire.CalculateSafeDelta(counter.ReadCount, lastStats.ReadCount, elapsedSeconds)
 	writesPerSec := acquire.CalculateSafeDelta(counter.WriteCount, lastStats.WriteCount, elapsedSeconds)
 
+	if elapsedMs > 0 {
+		avgQueueLen := float64(counterDelta(counter.WeightedIoTime, lastStats.WeightedIoTime)) / float64(elapsedMs)
+		result.AvgQueueLen = &avgQueueLen
+	}
+	currentQueueLen := float64(counter.IopsInProgress)
+	result.CurrentQueueLen = &currentQueueLen
+
+	result.AvgReadLatencyMs = averageLatency(counter.ReadTime, lastStats.ReadTime, counter.ReadCount, lastStats.ReadCount)
+	result.AvgWriteLatencyMs = averageLatency(counter.WriteTime, lastStats.WriteTime, counter.WriteCount, lastStats.WriteCount)
+
+	mergedReadsPerSec := acquire.CalculateSafeDelta(counter.MergedReadCount, lastStats.MergedReadCount, elapsedSeconds)
+	mergedWritesPerSec := acquire.CalculateSafeDelta(counter.MergedWriteCount, lastStats.MergedWriteCount, elapsedSeconds)
+	result.MergedReadsPerSec = &mergedReadsPerSec
+	result.MergedWritesPerSec = &mergedWritesPerSec
+
+	if counter.HasDiscards && lastStats.HasDiscards {
+		discardsPerSec := acquire.CalculateSafeDelta(counter.DiscardCount, lastStats.DiscardCount, elapsedSeconds)
+		mergedDiscardsPerSec := acquire.CalculateSafeDelta(counter.MergedDiscardCount, lastStats.MergedDiscardCount, elapsedSeconds)
+		discardBytesPerSec := acquire.CalculateSafeDelta(counter.DiscardBytes, lastStats.DiscardBytes, elapsedSeconds)
+		result.DiscardsPerSec = &discardsPerSec
+		result.MergedDiscardsPerSec = &mergedDiscardsPerSec
+		result.DiscardBytesPerSec = &discardBytesPerSec
+		result.AvgDiscardLatencyMs = averageLatency(counter.DiscardTime, lastStats.DiscardTime, counter.DiscardCount, lastStats.DiscardCount)
+	}
+
 	result.ReadBytesPerSec = &readBytes
 	result.WriteBytesPerSec = &writeBytes
 	result.ReadsPerSec = &readsPerSec
@@ -191,6 +246,24 @@ func CalculateSampleValues(ioCounter IOCountersStat, ioLastStats IOCountersStat,
 	return result
 }
 
+// averageLatency returns the average time in milliseconds spent by the operations completed since the last sample
+// (await), or 0 if no operation was completed.
+func averageLatency(timeMs, lastTimeMs, count, lastCount uint64) *float64 {
+	latency := float64(0)
+	if countDelta := counterDelta(count, lastCount); countDelta > 0 {
+		latency = float64(counterDelta(timeMs, lastTimeMs)) / float64(countDelta)
+	}
+	return &latency
+}
+
+// counterDelta returns the difference between two values of a counter, or 0 if the counter was reset.
+func counterDelta(current, last uint64) uint64 {
+	if current < last {
+		return 0
+	}
+	return current - last
+}
+
 func parseMountFile(filename string, line string) (mi MountInfoStat, err error) {
 	switch filename {
 	case mountInfo:
@@ -497,6 +570,10 @@ func fetchIoCounters() (map[string]IOCountersStat, error) {
 		if err != nil {
 			return ret, err
 		}
+		weightedIotime, err := strconv.ParseUint(fields[13], 10, 64)
+		if err != nil {
+			return ret, err
+		}
 		d := LinuxIoCountersStat{
 			ReadBytes:        rbytes * SectorSize,
 			WriteBytes:       wbytes * SectorSize,
@@ -508,10 +585,17 @@ func fetchIoCounters() (map[string]IOCountersStat, error) {
 			WriteTime:        wtime,
 			IopsInProgress:   iopsInProgress,
 			IoTime:           iotime,
+			WeightedIoTime:   weightedIotime,
 		}
 		if d == empty {
 			continue
 		}
+		// discard fields are available since kernel 4.18
+		if len(fields) >= 18 {
+			if err := parseDiscards(fields[14:18], &d); err != nil {
+				return ret, err
+			}
+		}
 		d.Name = name
 
 		d.SerialNumber = GetDiskSerialNumber(name)
@@ -520,6 +604,108 @@ func fetchIoCounters() (map[string]IOCountersStat, error) {
 	return ret, nil
 }
 
+// parseDiscards parses the discards completed, discards merged, sectors discarded and time spent discarding fields of
+// a /proc/diskstats line.
+func parseDiscards(fields []string, d *LinuxIoCountersStat) error {
+	values := make([]uint64, len(fields))
+	for i, field := range fields {
+		v, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return err
+		}
+		values[i] = v
+	}
+	d.DiscardCount = values[0]
+	d.MergedDiscardCount = values[1]
+	d.DiscardBytes = values[2] * SectorSize
+	d.DiscardTime = values[3]
+	d.HasDiscards = true
+	return nil
+}
+
+// unmountedDevices returns the block devices from /sys/block which are not mounted. The whole disk is returned when
+// neither it nor any of its partitions are mounted, otherwise its unmounted partitions are returned. The mounted
+// devices are the keys of the device mapping (e.g. sda1, dm-0).
+func unmountedDevices(mounted map[string]string) []blockDevice {
+	blockDir := helpers.HostSys("block")
+	disks, err := ioutil.ReadDir(blockDir)
+	if err != nil {
+		sslog.WithError(err).WithField("blockDir", blockDir).Debug("Can't read block devices.")
+		return nil
+	}
+
+	var devices []blockDevice
+	for _, entry := range disks {
+		name := entry.Name()
+		if isVirtualBlockDevice(name) {
+			continue
+		}
+		diskDir := filepath.Join(blockDir, name)
+		partitions := diskPartitions(diskDir)
+
+		_, diskMounted := mounted[name]
+		anyMounted := diskMounted
+		for _, partition := range partitions {
+			if _, ok := mounted[partition]; ok {
+				anyMounted = true
+			}
+		}
+		if !anyMounted {
+			devices = append(devices, newBlockDevice(name, diskDir))
+			continue
+		}
+		if diskMounted {
+			continue
+		}
+		for _, partition := range partitions {
+			if _, ok := mounted[partition]; !ok {
+				devices = append(devices, newBlockDevice(partition, filepath.Join(diskDir, partition)))
+			}
+		}
+	}
+	return devices
+}
+
+// isVirtualBlockDevice returns true for the memory backed and loop devices, which don't represent physical storage.
+func isVirtualBlockDevice(name string) bool {
+	for _, prefix := range []string{"loop", "ram", "zram", "sr", "fd", "nbd"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// diskPartitions returns the partitions of a disk, which are the subdirectories of its /sys/block entry containing
+// a partition file.
+func diskPartitions(diskDir string) []string {
+	entries, err := ioutil.ReadDir(diskDir)
+	if err != nil {
+		return nil
+	}
+	var partitions []string
+	for _, entry := range entries {
+		if _, err := os.Stat(filepath.Join(diskDir, entry.Name(), "partition")); err == nil {
+			partitions = append(partitions, entry.Name())
+		}
+	}
+	return partitions
+}
+
+// newBlockDevice reads the read only flag and the size, in 512 bytes sectors, of a device from its sysfs directory.
+func newBlockDevice(name, dir string) blockDevice {
+	bd := blockDevice{name: name}
+	if ro, err := ioutil.ReadFile(filepath.Join(dir, "ro")); err == nil {
+		bd.readOnly = strings.TrimSpace(string(ro)) == "1"
+	}
+	if size, err := ioutil.ReadFile(filepath.Join(dir, "size")); err == nil {
+		if sectors, err := strconv.ParseUint(strings.TrimSpace(string(size)), 10, 64); err == nil {
+			bd.sizeBytes = sectors * SectorSize
+		}
+	}
+	return bd
+}
+
 // GetDiskSerialNumber returns Serial Number of given device or empty string
 // on error. Name of device is expected, eg. /dev/sda
 func GetDiskSerialNumber(name string) string {
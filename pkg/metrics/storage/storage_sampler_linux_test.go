@@ -6,6 +6,9 @@ package storage
 
 import (
 	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/newrelic/infrastructure-agent/internal/agent/mocks"
@@ -13,6 +16,7 @@ import (
 	"github.com/shirou/gopsutil/disk"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestDeviceRegexp(t *testing.T) {
@@ -96,6 +100,61 @@ func TestCalculateBytesRate(t *testing.T) {
 	assert.Equal(t, *ioSample.WritesPerSec, float64(0))
 }
 
+func TestCalculateLatencyAndQueue(t *testing.T) {
+	lastStats := &LinuxIoCountersStat{
+		ReadCount:        100,
+		WriteCount:       200,
+		MergedReadCount:  10,
+		MergedWriteCount: 20,
+		ReadTime:         1000,
+		WriteTime:        4000,
+		WeightedIoTime:   5000,
+		DiscardCount:     5,
+		DiscardBytes:     4096,
+		DiscardTime:      50,
+		HasDiscards:      true,
+	}
+	counter := &LinuxIoCountersStat{
+		ReadCount:          150,
+		WriteCount:         200,
+		MergedReadCount:    30,
+		MergedWriteCount:   60,
+		ReadTime:           1100,
+		WriteTime:          4000,
+		IopsInProgress:     3,
+		WeightedIoTime:     7500,
+		DiscardCount:       7,
+		MergedDiscardCount: 2,
+		DiscardBytes:       12288,
+		DiscardTime:        70,
+		HasDiscards:        true,
+	}
+
+	ioSample := CalculateSampleValues(counter, lastStats, 2000)
+
+	assert.Equal(t, 2.0, *ioSample.AvgReadLatencyMs)
+	assert.Equal(t, 0.0, *ioSample.AvgWriteLatencyMs, "no writes were completed")
+	assert.Equal(t, 1.25, *ioSample.AvgQueueLen)
+	assert.Equal(t, 3.0, *ioSample.CurrentQueueLen)
+	assert.Equal(t, 10.0, *ioSample.MergedReadsPerSec)
+	assert.Equal(t, 20.0, *ioSample.MergedWritesPerSec)
+	assert.Equal(t, 1.0, *ioSample.DiscardsPerSec)
+	assert.Equal(t, 1.0, *ioSample.MergedDiscardsPerSec)
+	assert.Equal(t, 4096.0, *ioSample.DiscardBytesPerSec)
+	assert.Equal(t, 10.0, *ioSample.AvgDiscardLatencyMs)
+
+	// discards are not reported by older kernels
+	counter.HasDiscards = false
+	ioSample = CalculateSampleValues(counter, lastStats, 2000)
+	assert.Nil(t, ioSample.DiscardsPerSec)
+	assert.Nil(t, ioSample.AvgDiscardLatencyMs)
+
+	// counters reset
+	ioSample = CalculateSampleValues(lastStats, counter, 2000)
+	assert.Equal(t, 0.0, *ioSample.AvgReadLatencyMs)
+	assert.Equal(t, 0.0, *ioSample.AvgQueueLen)
+}
+
 func TestMarshallableSamples(t *testing.T) {
 	testCases := []struct {
 		elapsedTime int64
@@ -149,6 +208,82 @@ func TestDiskIOCounters(t *testing.T) {
 	}
 }
 
+func TestFetchIoCounters_Discards(t *testing.T) {
+	dir, err := ioutil.TempDir("", "proc")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	os.Setenv("HOST_PROC", dir)
+	defer os.Unsetenv("HOST_PROC")
+
+	diskstats := `   8       0 sda 100 10 800 50 200 20 1600 400 1 300 450 7 2 16 30 0 0
+   8       1 sda1 100 10 800 50 200 20 1600 400 0 300 450
+   7       0 loop0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0
+`
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "diskstats"), []byte(diskstats), 0644))
+
+	ret, err := fetchIoCounters()
+	require.NoError(t, err)
+	require.Len(t, ret, 2)
+
+	sda := ret["sda"].(*LinuxIoCountersStat)
+	assert.Equal(t, uint64(450), sda.WeightedIoTime)
+	assert.Equal(t, uint64(1), sda.IopsInProgress)
+	assert.True(t, sda.HasDiscards)
+	assert.Equal(t, uint64(7), sda.DiscardCount)
+	assert.Equal(t, uint64(2), sda.MergedDiscardCount)
+	assert.Equal(t, uint64(16*SectorSize), sda.DiscardBytes)
+	assert.Equal(t, uint64(30), sda.DiscardTime)
+
+	sda1 := ret["sda1"].(*LinuxIoCountersStat)
+	assert.Equal(t, uint64(450), sda1.WeightedIoTime)
+	assert.False(t, sda1.HasDiscards)
+}
+
+// writeBlockDevice creates the /sys/block entry of a disk and its partitions
+func writeBlockDevice(t *testing.T, sysDir, disk string, ro bool, partitions ...string) {
+	roValue := []byte("0\n")
+	if ro {
+		roValue = []byte("1\n")
+	}
+	diskDir := filepath.Join(sysDir, "block", disk)
+	require.NoError(t, os.MkdirAll(diskDir, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(diskDir, "ro"), roValue, 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(diskDir, "size"), []byte("2048\n"), 0644))
+	for _, partition := range partitions {
+		partitionDir := filepath.Join(diskDir, partition)
+		require.NoError(t, os.MkdirAll(partitionDir, 0755))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(partitionDir, "partition"), []byte("1\n"), 0644))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(partitionDir, "ro"), roValue, 0644))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(partitionDir, "size"), []byte("1024\n"), 0644))
+	}
+	// not a partition
+	require.NoError(t, os.MkdirAll(filepath.Join(diskDir, "queue"), 0755))
+}
+
+func TestUnmountedDevices(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sys")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	os.Setenv("HOST_SYS", dir)
+	defer os.Unsetenv("HOST_SYS")
+
+	writeBlockDevice(t, dir, "sda", false, "sda1", "sda2")
+	writeBlockDevice(t, dir, "sdb", true)
+	writeBlockDevice(t, dir, "sdc", false, "sdc1")
+	writeBlockDevice(t, dir, "dm-0", false)
+	writeBlockDevice(t, dir, "loop0", false)
+
+	mounted := map[string]string{"sda1": "/dev/sda1", "dm-0": "/dev/mapper/vg-data"}
+
+	devices := unmountedDevices(mounted)
+
+	assert.ElementsMatch(t, []blockDevice{
+		{name: "sda2", sizeBytes: 1024 * SectorSize},
+		{name: "sdb", readOnly: true, sizeBytes: 2048 * SectorSize},
+		{name: "sdc", sizeBytes: 2048 * SectorSize},
+	}, devices)
+}
+
 func TestParseMtab(t *testing.T) {
 
 	var lines = []string{
@@ -364,3 +499,79 @@ func TestIgnoredDevice(t *testing.T) {
 	assert.EqualValues(t, usageTotal1, *sample.TotalBytes)
 	assert.EqualValues(t, usageFree1, *sample.FreeBytes)
 }
+
+type ioMockStorageSampleWrapper struct {
+	MockStorageSampleWrapper
+	ioCounters []map[string]IOCountersStat
+}
+
+func (s *ioMockStorageSampleWrapper) IOCounters() (map[string]IOCountersStat, error) {
+	counters := s.ioCounters[0]
+	s.ioCounters = s.ioCounters[1:]
+	return counters, nil
+}
+
+func (s *ioMockStorageSampleWrapper) CalculateSampleValues(counter, lastStats IOCountersStat, elapsedMs int64) *Sample {
+	return CalculateSampleValues(counter, lastStats, elapsedMs)
+}
+
+func TestUnmountedDisksSamples(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sys")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	os.Setenv("HOST_SYS", dir)
+	defer os.Unsetenv("HOST_SYS")
+
+	writeBlockDevice(t, dir, "sda", false, "sda1", "sda2")
+	writeBlockDevice(t, dir, "sdb", false)
+	writeBlockDevice(t, dir, "sdc", false)
+
+	ctx := new(mocks.AgentContext)
+	ctx.On("Config").Return(&config.Config{
+		MetricsStorageUnmountedDisks: true,
+		FileDevicesIgnored:           []string{"sdc"},
+	})
+
+	ss := NewSampler(ctx)
+	ss.storageUtilities = &ioMockStorageSampleWrapper{
+		MockStorageSampleWrapper: MockStorageSampleWrapper{partitions: partitionStats[:1]},
+		ioCounters: []map[string]IOCountersStat{
+			{
+				"sda1": &LinuxIoCountersStat{ReadCount: 10},
+				"sdb":  &LinuxIoCountersStat{ReadCount: 10, ReadTime: 10},
+				"sdc":  &LinuxIoCountersStat{ReadCount: 10},
+			},
+			{
+				"sda1": &LinuxIoCountersStat{ReadCount: 20},
+				"sdb":  &LinuxIoCountersStat{ReadCount: 20, ReadTime: 50},
+				"sdc":  &LinuxIoCountersStat{ReadCount: 20},
+			},
+		},
+	}
+
+	// unmounted devices are reported since the second sample, with IO metrics
+	results, err := ss.Sample()
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+
+	results, err = ss.Sample()
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	samples := map[string]*Sample{}
+	for _, r := range results {
+		s := r.(*Sample)
+		samples[s.Device] = s
+	}
+	require.Contains(t, samples, "/dev/sdb")
+	sdb := samples["/dev/sdb"]
+	assert.Equal(t, "StorageSample", sdb.EventType)
+	assert.Empty(t, sdb.MountPoint)
+	assert.Equal(t, "false", sdb.IsReadOnly)
+	assert.Equal(t, float64(2048*SectorSize), *sdb.TotalBytes)
+	assert.Nil(t, sdb.UsedBytes)
+	assert.Equal(t, 4.0, *sdb.AvgReadLatencyMs)
+	assert.Equal(t, "diskstats", sdb.CountersSource)
+
+	assert.NotNil(t, samples["/dev/sda1"].ReadsPerSec)
+}
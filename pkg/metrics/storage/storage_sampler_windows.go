@@ -192,3 +192,8 @@ func populateSampleOS(source, dest *Sample) {
 // populateUsage copies the Usage Stats inside the destination sample, for those metrics that are exclusive of Windows
 func populateUsageOS(fsUsage *disk.UsageStat, dest *Sample) {
 }
+
+// unmountedDevices is not supported on Windows, where samples are driven by the logical drives
+func unmountedDevices(_ map[string]string) []blockDevice {
+	return nil
+}
@@ -27,28 +27,35 @@ type SystemSample struct {
 	*CPUSample
 	*LoadSample
 	*MemorySample
+	*MemoryDetailSample
 	*DiskSample
+	*PressureSample
+	*VmstatSample
 }
 
 type SystemSampler struct {
-	CpuMonitor     *CPUMonitor
-	DiskMonitor    *DiskMonitor
-	LoadMonitor    *LoadMonitor
-	MemoryMonitor  *MemoryMonitor
-	context        agent.AgentContext
-	stopChannel    chan bool
-	waitForCleanup *sync.WaitGroup
+	CpuMonitor      *CPUMonitor
+	DiskMonitor     *DiskMonitor
+	LoadMonitor     *LoadMonitor
+	MemoryMonitor   *MemoryMonitor
+	PressureMonitor *PressureMonitor
+	VmstatMonitor   *VmstatMonitor
+	context         agent.AgentContext
+	stopChannel     chan bool
+	waitForCleanup  *sync.WaitGroup
 }
 
 func NewSystemSampler(context agent.AgentContext, storageSampler *storage.Sampler) *SystemSampler {
 	cfg := context.Config()
 	return &SystemSampler{
-		CpuMonitor:     NewCPUMonitor(context),
-		DiskMonitor:    NewDiskMonitor(storageSampler),
-		LoadMonitor:    NewLoadMonitor(),
-		MemoryMonitor:  NewMemoryMonitor(cfg.IgnoreReclaimable),
-		context:        context,
-		waitForCleanup: &sync.WaitGroup{},
+		CpuMonitor:      NewCPUMonitor(context),
+		DiskMonitor:     NewDiskMonitor(storageSampler),
+		LoadMonitor:     NewLoadMonitor(),
+		MemoryMonitor:   NewMemoryMonitor(cfg.IgnoreReclaimable),
+		PressureMonitor: NewPressureMonitor(),
+		VmstatMonitor:   NewVmstatMonitor(),
+		context:         context,
+		waitForCleanup:  &sync.WaitGroup{},
 	}
 }
 
@@ -113,6 +120,25 @@ func (s *SystemSampler) Sample() (results sample.EventBatch, err error) {
 		sample.MemorySample = memorySample
 	}
 
+	// The following metrics are not available on every platform or kernel version, so they are optional
+	if memoryDetailSample, err := s.MemoryMonitor.SampleDetails(); err != nil {
+		syslog.WithError(err).Debug("Cannot retrieve memory breakdown.")
+	} else {
+		sample.MemoryDetailSample = memoryDetailSample
+	}
+
+	if pressureSample, err := s.PressureMonitor.Sample(); err != nil {
+		syslog.WithError(err).Debug("Cannot retrieve Pressure Stall Information.")
+	} else {
+		sample.PressureSample = pressureSample
+	}
+
+	if vmstatSample, err := s.VmstatMonitor.Sample(); err != nil {
+		syslog.WithError(err).Debug("Cannot retrieve vmstat counters.")
+	} else {
+		sample.VmstatSample = vmstatSample
+	}
+
 	if s.Debug() {
 		helpers.LogStructureDetails(syslog, sample, "SystemSample", "final", nil)
 	}
@@ -0,0 +1,15 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build !linux
+
+package systemd
+
+import (
+	"errors"
+	"time"
+)
+
+// monotonicNow is not supported out of Linux, where systemd isn't available.
+func monotonicNow() (time.Duration, error) {
+	return 0, errors.New("monotonic clock not supported")
+}
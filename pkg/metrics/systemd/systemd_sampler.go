@@ -0,0 +1,270 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package systemd
+
+import (
+	"bufio"
+	"fmt"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+var sslog = log.WithComponent("SystemdUnitSampler")
+
+const systemctlPath = "/bin/systemctl"
+
+// unitProperties properties queried to systemctl show for each unit.
+var unitProperties = []string{
+	"Id",
+	"LoadState",
+	"ActiveState",
+	"SubState",
+	"Result",
+	"MainPID",
+	"NRestarts",
+	"MemoryCurrent",
+	"CPUUsageNSec",
+	"TasksCurrent",
+	// microseconds since boot: the formatted timestamp depends on the locale and timezone of systemd, and the
+	// --timestamp=unix option requires systemd 248+
+	"StateChangeTimestampMonotonic",
+}
+
+// Sample state and resources accounting of a systemd unit. Accounting values are only reported when enabled for
+// the unit (e.g. MemoryAccounting=yes).
+type Sample struct {
+	sample.BaseEvent
+
+	UnitName    string `json:"unitName"`
+	LoadState   string `json:"loadState"`
+	ActiveState string `json:"activeState"`
+	SubState    string `json:"subState"`
+	// Result of the last run of the unit: success, exit-code, signal, timeout...
+	Result  string `json:"result,omitempty"`
+	MainPID *int   `json:"mainPid,omitempty"`
+	// Times the unit has been restarted by systemd since it was loaded (systemd 235+)
+	RestartCount *uint64 `json:"restartCount,omitempty"`
+	// Restarts since the previous sample
+	Restarts    *uint64  `json:"restarts,omitempty"`
+	MemoryBytes *uint64  `json:"memoryBytes,omitempty"`
+	CPUPercent  *float64 `json:"cpuPercent,omitempty"`
+	Tasks       *uint64  `json:"tasks,omitempty"`
+	// Unix time, in seconds, of the last active state change
+	StateChangeTimestamp *int64 `json:"stateChangeTimestamp,omitempty"`
+}
+
+type unitCache struct {
+	properties map[string]string
+	lastRun    time.Time
+}
+
+// Sampler reports a SystemdUnitSample for each unit matching the configured patterns, from the systemctl show
+// output. It's disabled when no patterns are configured.
+type Sampler struct {
+	context    agent.AgentContext
+	sampleRate time.Duration
+	patterns   []string
+	systemctl  func(args ...string) (string, error)
+	// monotonicNow returns the time of the clock of the systemd monotonic timestamps
+	monotonicNow func() (time.Duration, error)
+	last         map[string]unitCache
+}
+
+func NewSampler(context agent.AgentContext) *Sampler {
+	sampleRateSec := config.DefaultMetricsSystemdSampleRate
+	var patterns []string
+	if context != nil {
+		sampleRateSec = context.Config().MetricsSystemdSampleRate
+		patterns = context.Config().MetricsSystemdUnits
+	}
+
+	return &Sampler{
+		context:    context,
+		sampleRate: time.Second * time.Duration(sampleRateSec),
+		patterns:   patterns,
+		systemctl: func(args ...string) (string, error) {
+			return helpers.RunCommand(systemctlPath, "", args...)
+		},
+		monotonicNow: monotonicNow,
+		last:         map[string]unitCache{},
+	}
+}
+
+func (s *Sampler) Name() string { return "SystemdUnitSampler" }
+
+func (s *Sampler) Interval() time.Duration {
+	return s.sampleRate
+}
+
+func (s *Sampler) Disabled() bool {
+	return s.Interval() <= config.FREQ_DISABLE_SAMPLING || len(s.patterns) == 0
+}
+
+func (s *Sampler) OnStartup() {}
+
+func (s *Sampler) Sample() (results sample.EventBatch, err error) {
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			err = fmt.Errorf("Panic in SystemdUnitSampler.Sample: %v\nStack: %s", panicErr, debug.Stack())
+		}
+	}()
+
+	units, err := s.matchingUnits()
+	if err != nil {
+		sslog.WithError(err).Debug("Unable to list systemd units.")
+		return nil, nil
+	}
+	if len(units) == 0 {
+		return nil, nil
+	}
+
+	args := append([]string{"show", "--no-pager", "--property=" + strings.Join(unitProperties, ",")}, units...)
+	output, err := s.systemctl(args...)
+	if err != nil {
+		sslog.WithError(err).Debug("Unable to show systemd units.")
+		return nil, nil
+	}
+
+	now := time.Now()
+	// the monotonic timestamps are converted into unix time from the boot time
+	var bootTime time.Time
+	if uptime, err := s.monotonicNow(); err == nil {
+		bootTime = now.Add(-uptime)
+	} else {
+		sslog.WithError(err).Debug("Unable to read the monotonic clock, state change timestamps won't be reported.")
+	}
+	current := map[string]unitCache{}
+	for _, properties := range parseShowOutput(output) {
+		unit := properties["Id"]
+		if unit == "" {
+			continue
+		}
+		current[unit] = unitCache{properties: properties, lastRun: now}
+
+		var last *unitCache
+		if l, ok := s.last[unit]; ok {
+			last = &l
+		}
+		unitSample := newSample(properties, last, now, bootTime)
+		unitSample.Type("SystemdUnitSample")
+		results = append(results, unitSample)
+	}
+	s.last = current
+	return results, nil
+}
+
+// matchingUnits returns the loaded units whose name matches any of the configured glob patterns.
+func (s *Sampler) matchingUnits() ([]string, error) {
+	output, err := s.systemctl("list-units", "--all", "--plain", "--no-legend", "--no-pager")
+	if err != nil {
+		return nil, err
+	}
+
+	var units []string
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		for _, pattern := range s.patterns {
+			if matched, _ := filepath.Match(pattern, fields[0]); matched {
+				units = append(units, fields[0])
+				break
+			}
+		}
+	}
+	return units, nil
+}
+
+// parseShowOutput parses the systemctl show output, with a block of Key=Value lines per unit, separated by empty
+// lines.
+func parseShowOutput(output string) []map[string]string {
+	var units []map[string]string
+	var properties map[string]string
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			properties = nil
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		if properties == nil {
+			properties = map[string]string{}
+			units = append(units, properties)
+		}
+		properties[kv[0]] = kv[1]
+	}
+	return units
+}
+
+// newSample builds the sample of a unit from its properties, calculating the CPU usage and restarts from the
+// previous ones, if any. The state change timestamp is only reported when the boot time is known.
+func newSample(properties map[string]string, last *unitCache, now, bootTime time.Time) *Sample {
+	s := &Sample{
+		UnitName:    properties["Id"],
+		LoadState:   properties["LoadState"],
+		ActiveState: properties["ActiveState"],
+		SubState:    properties["SubState"],
+		Result:      properties["Result"],
+	}
+
+	if pid, err := strconv.Atoi(properties["MainPID"]); err == nil && pid > 0 {
+		s.MainPID = &pid
+	}
+	s.RestartCount = parseUint(properties["NRestarts"])
+	s.MemoryBytes = parseUint(properties["MemoryCurrent"])
+	s.Tasks = parseUint(properties["TasksCurrent"])
+	// zero means the unit state hasn't changed since it was loaded
+	if usec := parseUint(properties["StateChangeTimestampMonotonic"]); usec != nil && *usec > 0 && !bootTime.IsZero() {
+		unix := bootTime.Add(time.Duration(*usec) * time.Microsecond).Unix()
+		s.StateChangeTimestamp = &unix
+	}
+
+	if last == nil {
+		return s
+	}
+	if s.RestartCount != nil {
+		if lastCount := parseUint(last.properties["NRestarts"]); lastCount != nil {
+			var restarts uint64
+			if *s.RestartCount > *lastCount {
+				restarts = *s.RestartCount - *lastCount
+			}
+			s.Restarts = &restarts
+		}
+	}
+	cpuNs := parseUint(properties["CPUUsageNSec"])
+	lastCPUNs := parseUint(last.properties["CPUUsageNSec"])
+	elapsed := now.Sub(last.lastRun)
+	if cpuNs != nil && lastCPUNs != nil && elapsed > 0 {
+		var percent float64
+		if *cpuNs > *lastCPUNs {
+			percent = float64(*cpuNs-*lastCPUNs) / float64(elapsed.Nanoseconds()) * 100
+		}
+		s.CPUPercent = &percent
+	}
+	return s
+}
+
+// parseUint parses an unsigned property. Returns nil for the "[not set]" values and for the max uint64, used by
+// systemd when the accounting is not available.
+func parseUint(value string) *uint64 {
+	v, err := strconv.ParseUint(value, 10, 64)
+	if err != nil || v == ^uint64(0) {
+		return nil
+	}
+	return &v
+}
@@ -0,0 +1,169 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package systemd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent/mocks"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+)
+
+const listUnitsOutput = `nginx.service           loaded active   running A high performance web server
+php7.4-fpm.service      loaded failed   failed  The PHP 7.4 FastCGI Process Manager
+sshd.service            loaded active   running OpenSSH server daemon
+nginx.socket            loaded active   listening Nginx socket
+`
+
+func showOutput(restarts, cpuNs string) string {
+	return `Id=nginx.service
+LoadState=loaded
+ActiveState=active
+SubState=running
+Result=success
+MainPID=1234
+NRestarts=` + restarts + `
+MemoryCurrent=10485760
+CPUUsageNSec=` + cpuNs + `
+TasksCurrent=3
+StateChangeTimestampMonotonic=400000000
+
+Id=php7.4-fpm.service
+LoadState=loaded
+ActiveState=failed
+SubState=failed
+Result=exit-code
+MainPID=0
+NRestarts=[not set]
+MemoryCurrent=18446744073709551615
+CPUUsageNSec=[not set]
+TasksCurrent=[not set]
+StateChangeTimestampMonotonic=0
+`
+}
+
+type fakeSystemctl struct {
+	show string
+	args [][]string
+}
+
+func (f *fakeSystemctl) run(args ...string) (string, error) {
+	f.args = append(f.args, args)
+	if args[0] == "list-units" {
+		return listUnitsOutput, nil
+	}
+	return f.show, nil
+}
+
+func TestSampler_Disabled(t *testing.T) {
+	ctx := new(mocks.AgentContext)
+	ctx.On("Config").Return(&config.Config{MetricsSystemdSampleRate: 15})
+	assert.True(t, NewSampler(ctx).Disabled(), "no units configured")
+
+	ctx = new(mocks.AgentContext)
+	ctx.On("Config").Return(&config.Config{MetricsSystemdSampleRate: 15, MetricsSystemdUnits: []string{"nginx.service"}})
+	assert.False(t, NewSampler(ctx).Disabled())
+}
+
+// uptime returns a monotonic clock reading the provided time since boot.
+func uptime(d time.Duration) func() (time.Duration, error) {
+	return func() (time.Duration, error) {
+		return d, nil
+	}
+}
+
+func TestSampler_Sample(t *testing.T) {
+	systemctl := &fakeSystemctl{show: showOutput("2", "1000000000")}
+	s := &Sampler{
+		patterns:     []string{"nginx.service", "php*-fpm.service"},
+		systemctl:    systemctl.run,
+		monotonicNow: uptime(1000 * time.Second),
+		last:         map[string]unitCache{},
+	}
+
+	results, err := s.Sample()
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	require.Len(t, systemctl.args, 2)
+	showArgs := systemctl.args[1]
+	assert.Equal(t, "show", showArgs[0])
+	assert.Equal(t, []string{"nginx.service", "php7.4-fpm.service"}, showArgs[len(showArgs)-2:])
+	assert.True(t, strings.HasPrefix(showArgs[2], "--property=Id,"))
+
+	nginx := results[0].(*Sample)
+	assert.Equal(t, "SystemdUnitSample", nginx.EventType)
+	assert.Equal(t, "nginx.service", nginx.UnitName)
+	assert.Equal(t, "active", nginx.ActiveState)
+	assert.Equal(t, "running", nginx.SubState)
+	assert.Equal(t, 1234, *nginx.MainPID)
+	assert.Equal(t, uint64(2), *nginx.RestartCount)
+	assert.Equal(t, uint64(10485760), *nginx.MemoryBytes)
+	assert.Equal(t, uint64(3), *nginx.Tasks)
+	// the state changed 400 seconds after the boot, 600 seconds ago
+	require.NotNil(t, nginx.StateChangeTimestamp)
+	assert.InDelta(t, time.Now().Add(-600*time.Second).Unix(), *nginx.StateChangeTimestamp, 1)
+	assert.Nil(t, nginx.Restarts, "restarts require a previous sample")
+	assert.Nil(t, nginx.CPUPercent, "CPU usage requires a previous sample")
+
+	php := results[1].(*Sample)
+	assert.Equal(t, "failed", php.ActiveState)
+	assert.Equal(t, "exit-code", php.Result)
+	assert.Nil(t, php.MainPID)
+	assert.Nil(t, php.RestartCount)
+	assert.Nil(t, php.MemoryBytes)
+	assert.Nil(t, php.StateChangeTimestamp)
+
+	// pretend the previous sample happened 10 seconds ago
+	cache := s.last["nginx.service"]
+	cache.lastRun = time.Now().Add(-10 * time.Second)
+	s.last["nginx.service"] = cache
+	systemctl.show = showOutput("5", "6000000000")
+
+	results, err = s.Sample()
+	require.NoError(t, err)
+	nginx = results[0].(*Sample)
+	assert.Equal(t, uint64(3), *nginx.Restarts)
+	assert.InDelta(t, 50, *nginx.CPUPercent, 0.5)
+}
+
+func TestSampler_NoMatchingUnits(t *testing.T) {
+	systemctl := &fakeSystemctl{}
+	s := &Sampler{
+		patterns:  []string{"mysql*.service"},
+		systemctl: systemctl.run,
+		last:      map[string]unitCache{},
+	}
+
+	results, err := s.Sample()
+	require.NoError(t, err)
+	assert.Empty(t, results)
+	assert.Len(t, systemctl.args, 1, "units are not shown")
+}
+
+func TestSampler_Sample_NoMonotonicClock(t *testing.T) {
+	systemctl := &fakeSystemctl{show: showOutput("2", "1000000000")}
+	s := &Sampler{
+		patterns:  []string{"nginx.service"},
+		systemctl: systemctl.run,
+		monotonicNow: func() (time.Duration, error) {
+			return 0, errors.New("not supported")
+		},
+		last: map[string]unitCache{},
+	}
+
+	results, err := s.Sample()
+	require.NoError(t, err)
+	require.NotEmpty(t, results)
+
+	// THEN the sample is reported without the state change timestamp
+	nginx := results[0].(*Sample)
+	assert.Equal(t, "active", nginx.ActiveState)
+	assert.Nil(t, nginx.StateChangeTimestamp)
+}
@@ -4,6 +4,8 @@
 package types
 
 import (
+	"encoding/json"
+
 	"github.com/newrelic/infrastructure-agent/pkg/sample"
 	"github.com/shirou/gopsutil/process"
 )
@@ -39,7 +41,34 @@ type ProcessSample struct {
 	IOTotalWriteCount     *uint64  `json:"ioTotalWriteCount,omitempty"`
 	IOTotalReadBytes      *uint64  `json:"ioTotalReadBytes,omitempty"`
 	IOTotalWriteBytes     *uint64  `json:"ioTotalWriteBytes,omitempty"`
+	// Number of processes summed up by the sample, only for grouped or aggregated samples
+	ProcessCount *int `json:"processCount,omitempty"`
+	// Sockets owned by the process, only when enable_process_socket_metrics is set
+	TCPSocketCount      *int   `json:"tcpSocketCount,omitempty"`
+	TCPEstablishedCount *int   `json:"tcpEstablishedCount,omitempty"`
+	TCPListenCount      *int   `json:"tcpListenCount,omitempty"`
+	TCPSynSentCount     *int   `json:"tcpSynSentCount,omitempty"`
+	TCPCloseWaitCount   *int   `json:"tcpCloseWaitCount,omitempty"`
+	UDPSocketCount      *int   `json:"udpSocketCount,omitempty"`
+	ListeningPorts      string `json:"listeningPorts,omitempty"`
 	// Auxiliary values, not to be reported
 	LastIOCounters  *process.IOCountersStat `json:"-"`
 	ContainerLabels map[string]string       `json:"-"`
+	// Set for the samples summing up several processes, which aren't identified by any process ID
+	Aggregated bool `json:"-"`
+}
+
+// MarshalJSON leaves the process ID out of the aggregated samples, as it would misattribute them to one of their
+// processes.
+func (s ProcessSample) MarshalJSON() ([]byte, error) {
+	// the local type doesn't have the ProcessSample methods, so it's marshalled with the default encoding
+	type processSample ProcessSample
+	if !s.Aggregated {
+		return json.Marshal(processSample(s))
+	}
+	return json.Marshal(struct {
+		processSample
+		// shadows the embedded process ID
+		ProcessID *int32 `json:"processId,omitempty"`
+	}{processSample: processSample(s)})
 }
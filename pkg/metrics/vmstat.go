@@ -0,0 +1,122 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/acquire"
+)
+
+// VmstatSample virtual memory activity since the previous sample, from the /proc/vmstat counters.
+type VmstatSample struct {
+	PageFaultsPerSec      *float64 `json:"pageFaultsPerSecond,omitempty"`
+	MajorPageFaultsPerSec *float64 `json:"majorPageFaultsPerSecond,omitempty"`
+	SwapInPagesPerSec     *float64 `json:"swapInPagesPerSecond,omitempty"`
+	SwapOutPagesPerSec    *float64 `json:"swapOutPagesPerSecond,omitempty"`
+	// Processes killed by the OOM killer since the previous sample (kernels >= 4.13)
+	OOMKills *uint64 `json:"oomKills,omitempty"`
+}
+
+// vmstat counters reported by the VmstatMonitor.
+const (
+	vmstatPageFaults      = "pgfault"
+	vmstatMajorPageFaults = "pgmajfault"
+	vmstatSwapIn          = "pswpin"
+	vmstatSwapOut         = "pswpout"
+	vmstatOOMKills        = "oom_kill"
+)
+
+type VmstatMonitor struct {
+	path        string
+	unsupported bool
+	last        map[string]uint64
+	lastRun     time.Time
+}
+
+func NewVmstatMonitor() *VmstatMonitor {
+	return &VmstatMonitor{path: helpers.HostProc("vmstat")}
+}
+
+// Sample returns nil when /proc/vmstat is not available, or on the first invocation, as rates require two readings.
+func (vm *VmstatMonitor) Sample() (sample *VmstatSample, err error) {
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			err = fmt.Errorf("Panic in VmstatMonitor.Sample: %v\nStack: %s", panicErr, debug.Stack())
+		}
+	}()
+
+	if vm.unsupported {
+		return nil, nil
+	}
+	if _, err := os.Stat(vm.path); err != nil {
+		vm.unsupported = true
+		return nil, nil
+	}
+
+	current, err := readVmstat(vm.path)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	last, lastRun := vm.last, vm.lastRun
+	vm.last, vm.lastRun = current, now
+	if last == nil {
+		return nil, nil
+	}
+
+	elapsed := now.Sub(lastRun).Seconds()
+	rate := func(counter string) *float64 {
+		c, ok := current[counter]
+		if !ok {
+			return nil
+		}
+		r := acquire.CalculateSafeDelta(c, last[counter], elapsed)
+		return &r
+	}
+
+	sample = &VmstatSample{
+		PageFaultsPerSec:      rate(vmstatPageFaults),
+		MajorPageFaultsPerSec: rate(vmstatMajorPageFaults),
+		SwapInPagesPerSec:     rate(vmstatSwapIn),
+		SwapOutPagesPerSec:    rate(vmstatSwapOut),
+	}
+	if kills, ok := current[vmstatOOMKills]; ok {
+		var delta uint64
+		if prev := last[vmstatOOMKills]; kills > prev {
+			delta = kills - prev
+		}
+		sample.OOMKills = &delta
+	}
+	return sample, nil
+}
+
+// readVmstat reads the "<counter> <value>" lines of the vmstat file.
+func readVmstat(file string) (map[string]uint64, error) {
+	lines, err := acquire.ReadLines(file)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	counters := map[string]uint64{}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		switch fields[0] {
+		case vmstatPageFaults, vmstatMajorPageFaults, vmstatSwapIn, vmstatSwapOut, vmstatOOMKills:
+			if v, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+				counters[fields[0]] = v
+			}
+		}
+	}
+	return counters, nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVmstatMonitor_Sample(t *testing.T) {
+	file, err := ioutil.TempFile("", "vmstat")
+	require.NoError(t, err)
+	defer os.Remove(file.Name())
+	write := func(content string) {
+		require.NoError(t, ioutil.WriteFile(file.Name(), []byte(content), 0644))
+	}
+
+	vm := &VmstatMonitor{path: file.Name()}
+
+	write("nr_free_pages 100\npgfault 1000\npgmajfault 10\npswpin 0\npswpout 0\noom_kill 2\n")
+	sample, err := vm.Sample()
+	require.NoError(t, err)
+	assert.Nil(t, sample, "rates require a previous reading")
+
+	// pretend the previous reading happened 10 seconds ago
+	vm.lastRun = time.Now().Add(-10 * time.Second)
+	write("nr_free_pages 100\npgfault 2000\npgmajfault 20\npswpin 50\npswpout 100\noom_kill 3\n")
+	sample, err = vm.Sample()
+	require.NoError(t, err)
+	require.NotNil(t, sample)
+
+	assert.InDelta(t, 100, *sample.PageFaultsPerSec, 1)
+	assert.InDelta(t, 1, *sample.MajorPageFaultsPerSec, 0.1)
+	assert.InDelta(t, 5, *sample.SwapInPagesPerSec, 0.1)
+	assert.InDelta(t, 10, *sample.SwapOutPagesPerSec, 0.1)
+	require.NotNil(t, sample.OOMKills)
+	assert.Equal(t, uint64(1), *sample.OOMKills)
+}
+
+func TestVmstatMonitor_MissingCounters(t *testing.T) {
+	file, err := ioutil.TempFile("", "vmstat")
+	require.NoError(t, err)
+	defer os.Remove(file.Name())
+	require.NoError(t, ioutil.WriteFile(file.Name(), []byte("pgfault 1000\n"), 0644))
+
+	vm := &VmstatMonitor{path: file.Name()}
+	_, err = vm.Sample()
+	require.NoError(t, err)
+	sample, err := vm.Sample()
+	require.NoError(t, err)
+	require.NotNil(t, sample)
+
+	assert.NotNil(t, sample.PageFaultsPerSec)
+	assert.Nil(t, sample.SwapInPagesPerSec)
+	assert.Nil(t, sample.OOMKills, "oom_kill is not provided by kernels < 4.13")
+}
+
+func TestVmstatMonitor_Unsupported(t *testing.T) {
+	vm := &VmstatMonitor{path: filepath.Join(os.TempDir(), "non-existing-vmstat")}
+
+	sample, err := vm.Sample()
+	assert.NoError(t, err)
+	assert.Nil(t, sample)
+}
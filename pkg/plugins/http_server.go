@@ -3,12 +3,24 @@
 package plugins
 
 import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"os"
+	"time"
 
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/integration"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/legacy"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/emitter"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/newrelic/infrastructure-agent/internal/agent"
@@ -17,18 +29,36 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// pushedMetricsInterval is assigned to the pushed count, summary and histogram metrics lacking their own interval, as
+// pushes have no execution interval. It matches the integrations default interval.
+const pushedMetricsInterval = config.FREQ_PLUGIN_EXTERNAL_PLUGINS * time.Second
+
+// socketFileMode allows the agent user and group to submit data through the unix socket.
+const socketFileMode = 0660
+
+var (
+	errUnauthorized      = errors.New("missing or invalid bearer token")
+	errBodyTooLarge      = errors.New("request body too large")
+	errProtocolV4Missing = errors.New("protocol version 4 is not supported by this endpoint")
+	errTLSKeyPairMissing = errors.New("TLS requires both the certificate and key files")
+)
+
 type HTTPServerPlugin struct {
 	agent.PluginCommon
-	host   string
-	port   int
-	logger log.Entry
+	host string
+	port int
+	// emitter submits protocol v4 payloads through the same path as the v4 integrations
+	emitter emitter.Emitter
+	logger  log.Entry
 }
 
 type responseError struct {
 	Error string `json:"error"`
 }
 
-func NewHTTPServerPlugin(ctx agent.AgentContext, host string, port int) agent.Plugin {
+// NewHTTPServerPlugin creates the plugin receiving integration payloads over HTTP. Protocol v4 payloads are only
+// accepted when an emitter is provided.
+func NewHTTPServerPlugin(ctx agent.AgentContext, host string, port int, em emitter.Emitter) agent.Plugin {
 	id := ids.PluginID{
 		Category: "metadata",
 		Term:     "http_server",
@@ -38,45 +68,169 @@ func NewHTTPServerPlugin(ctx agent.AgentContext, host string, port int) agent.Pl
 			ID:      id,
 			Context: ctx,
 		},
-		host:   host,
-		port:   port,
-		logger: slog.WithPlugin(id.String()),
+		host:    host,
+		port:    port,
+		emitter: em,
+		logger:  slog.WithPlugin(id.String()),
 	}
 }
 
 func (p *HTTPServerPlugin) Run() {
-	addr := fmt.Sprintf("%s:%d", p.host, p.port)
+	cfg := p.Context.Config()
 	router := httprouter.New()
-	router.POST("/v1/data", p.dataHandler)
+	router.POST("/v1/data", p.authenticated(cfg.HTTPServerAuthToken, p.dataHandler))
+
+	listener, err := p.listen(cfg)
+	if err != nil {
+		p.logger.WithError(err).Error("unable to start HTTP server")
+		return
+	}
+	defer listener.Close()
+
 	p.logger.WithFields(logrus.Fields{
-		"port": p.port,
-		"host": p.host,
+		"address": listener.Addr().String(),
+		"tls":     cfg.HTTPServerCertFile != "",
+		"auth":    cfg.HTTPServerAuthToken != "",
 	}).Debug("HTTP server starting listening.")
-	err := http.ListenAndServe(addr, router)
+	err = http.Serve(listener, router)
 	if err != nil {
 		p.logger.WithError(err).Error("unable to start HTTP server")
 	}
 }
 
+// listen opens the unix socket or the TCP address of the server, wrapped on TLS when a certificate is configured.
+func (p *HTTPServerPlugin) listen(cfg *config.Config) (listener net.Listener, err error) {
+	var tlsConfig *tls.Config
+	if cfg.HTTPServerCertFile != "" || cfg.HTTPServerClientCAFile != "" {
+		if tlsConfig, err = serverTLSConfig(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.HTTPServerSocket != "" {
+		if err = removeStaleSocket(cfg.HTTPServerSocket); err != nil {
+			return nil, err
+		}
+		if listener, err = listenUnix(cfg.HTTPServerSocket); err != nil {
+			return nil, err
+		}
+	} else if listener, err = net.Listen("tcp", fmt.Sprintf("%s:%d", p.host, p.port)); err != nil {
+		return nil, err
+	}
+
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+	return listener, nil
+}
+
+// removeStaleSocket removes the socket left by a previous execution. Any other kind of file is kept, as the configured
+// path could point to a file that doesn't belong to the agent.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("refusing to replace %s, it is not a unix socket", path)
+	}
+	return os.Remove(path)
+}
+
+// serverTLSConfig loads the server certificate and, if configured, the CAs required to verify the client ones.
+func serverTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	if cfg.HTTPServerCertFile == "" || cfg.HTTPServerKeyFile == "" {
+		return nil, errTLSKeyPairMissing
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.HTTPServerCertFile, cfg.HTTPServerKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("can't load TLS certificate: %v", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if cfg.HTTPServerClientCAFile != "" {
+		caCerts, err := ioutil.ReadFile(cfg.HTTPServerClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("can't read client CA file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCerts) {
+			return nil, fmt.Errorf("no valid certificates found in client CA file %s", cfg.HTTPServerClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// authenticated rejects the requests without the bearer token, if any is configured.
+func (p *HTTPServerPlugin) authenticated(token string, handle httprouter.Handle) httprouter.Handle {
+	if token == "" {
+		return handle
+	}
+	expected := []byte("Bearer " + token)
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			p.writeError(w, http.StatusUnauthorized, errUnauthorized)
+			return
+		}
+		handle(w, r, ps)
+	}
+}
+
 func (p *HTTPServerPlugin) dataHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
-	rawBody, err := ioutil.ReadAll(r.Body)
+	cfg := p.Context.Config()
+
+	rawBody, err := readBody(r, int64(cfg.HTTPServerMaxBodySizeBytes))
+	if err == errBodyTooLarge {
+		p.writeError(w, http.StatusRequestEntityTooLarge, err)
+		return
+	}
 	if err != nil {
 		p.logger.WithError(err).Debug("Reading request body.")
 	}
-	payload, protocolVersion, err := legacy.ParsePayload(rawBody, p.Context.Config().ForceProtocolV2toV3)
 
+	protocolVersion, err := protocol.VersionFromPayload(rawBody, cfg.ForceProtocolV2toV3)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		jerr := json.NewEncoder(w).Encode(responseError{
-			Error: fmt.Sprintf("error decoding data payload: %v", err),
-		})
-		if jerr != nil {
-			p.logger.WithError(err).Warn("couldn't encode a failed response")
+		p.writeError(w, http.StatusBadRequest, fmt.Errorf("error decoding data payload: %v", err))
+		return
+	}
+
+	if protocolVersion == protocol.V4 {
+		if p.emitter == nil {
+			p.writeError(w, http.StatusBadRequest, errProtocolV4Missing)
+			return
+		}
+		definition := integration.Definition{
+			Name:            p.ID.Term,
+			Labels:          cfg.HTTPServerLabels,
+			InventorySource: p.ID,
+			Interval:        pushedMetricsInterval,
 		}
+		if err := p.emitter.Emit(definition, nil, nil, rawBody); err != nil {
+			p.writeError(w, http.StatusBadRequest, fmt.Errorf("error emitting data payload: %v", err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	payload, err := protocol.ParsePayload(rawBody, protocolVersion)
+	if err != nil {
+		p.writeError(w, http.StatusBadRequest, fmt.Errorf("error decoding data payload: %v", err))
 		return
 	}
-	labels := map[string]string{}
+	labels := cfg.HTTPServerLabels
+	if labels == nil {
+		labels = map[string]string{}
+	}
 	for _, dataSet := range payload.DataSets {
 		err := legacy.EmitDataSet(
 			p.Context,
@@ -85,7 +239,7 @@ func (p *HTTPServerPlugin) dataHandler(w http.ResponseWriter, r *http.Request, p
 			payload.IntegrationVersion,
 			"",
 			dataSet,
-			labels,
+			map[string]string{},
 			labels,
 			nil,
 			protocolVersion)
@@ -95,3 +249,30 @@ func (p *HTTPServerPlugin) dataHandler(w http.ResponseWriter, r *http.Request, p
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// readBody reads the request body, returning errBodyTooLarge if it exceeds the max size. Zero or negative sizes
+// don't limit the body.
+func readBody(r *http.Request, maxSize int64) ([]byte, error) {
+	if maxSize <= 0 {
+		return ioutil.ReadAll(r.Body)
+	}
+	if r.ContentLength > maxSize {
+		return nil, errBodyTooLarge
+	}
+	rawBody, err := ioutil.ReadAll(io.LimitReader(r.Body, maxSize+1))
+	if int64(len(rawBody)) > maxSize {
+		return nil, errBodyTooLarge
+	}
+	return rawBody, err
+}
+
+func (p *HTTPServerPlugin) writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	jerr := json.NewEncoder(w).Encode(responseError{
+		Error: err.Error(),
+	})
+	if jerr != nil {
+		p.logger.WithError(jerr).Warn("couldn't encode a failed response")
+	}
+}
@@ -3,21 +3,39 @@
 package plugins
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/internal/agent/cmdchannel/handler"
 	"github.com/newrelic/infrastructure-agent/internal/agent/mocks"
+	"github.com/newrelic/infrastructure-agent/internal/feature_flags"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/integration"
 	"github.com/newrelic/infrastructure-agent/internal/testhelpers"
 	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/emitter"
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
 	"github.com/newrelic/infrastructure-agent/pkg/sample"
 	"github.com/newrelic/infrastructure-agent/pkg/sysinfo"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
@@ -45,7 +63,7 @@ func TestHTTPServerPlugin(t *testing.T) {
 	// Given an HTTP Server Plugin
 	port, err := testhelpers.GetFreePort()
 	require.NoError(t, err)
-	hsp := NewHTTPServerPlugin(ctx, "127.0.0.1", port)
+	hsp := NewHTTPServerPlugin(ctx, "127.0.0.1", port, nil)
 	go hsp.Run()
 
 	// that is listening
@@ -111,3 +129,275 @@ func newFixedIDLookup() agent.IDLookup {
 	idLookupTable[sysinfo.HOST_SOURCE_DISPLAY_NAME] = "display_name"
 	return idLookupTable
 }
+
+const payloadV4 = `{"protocol_version":"4","integration":{"name":"int1","version":"1"},` +
+	`"data":[{"entity":{"name":"e1","type":"t"},"metrics":[{"name":"m1","type":"gauge","value":1}]}]}`
+
+type emitted struct {
+	metadata integration.Definition
+	json     []byte
+}
+
+// fakeEmitter stores the payloads submitted through the integrations emitter.
+type fakeEmitter struct {
+	emitted chan emitted
+}
+
+func (f *fakeEmitter) Emit(metadata integration.Definition, _ data.Map, _ []data.EntityRewrite, json []byte) error {
+	f.emitted <- emitted{metadata: metadata, json: json}
+	return nil
+}
+
+// startHTTPServer runs an HTTP server plugin with the given configuration, returning its URL once it's listening.
+func startHTTPServer(t *testing.T, cfg *config.Config, em emitter.Emitter) string {
+	ctx := new(mocks.AgentContext)
+	ctx.On("Config").Return(cfg)
+
+	port, err := testhelpers.GetFreePort()
+	require.NoError(t, err)
+	go NewHTTPServerPlugin(ctx, "127.0.0.1", port, em).Run()
+
+	network, address := "tcp", fmt.Sprintf("127.0.0.1:%v", port)
+	if cfg.HTTPServerSocket != "" {
+		network, address = "unix", cfg.HTTPServerSocket
+	}
+	for retries := 0; retries < 40; retries++ {
+		if conn, err := net.Dial(network, address); err == nil {
+			conn.Close()
+			return fmt.Sprintf("http://%s/v1/data", address)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	require.Fail(t, "can't get HTTPServerPlugin listening")
+	return ""
+}
+
+func TestHTTPServerPlugin_ProtocolV4(t *testing.T) {
+	em := &fakeEmitter{emitted: make(chan emitted, 1)}
+	url := startHTTPServer(t, &config.Config{HTTPServerLabels: map[string]string{"env": "prod"}}, em)
+
+	resp, err := http.Post(url, "application/json", strings.NewReader(payloadV4))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	select {
+	case e := <-em.emitted:
+		assert.Equal(t, payloadV4, string(e.json))
+		assert.Equal(t, "metadata/http_server", e.metadata.PluginID("int1").String())
+		assert.Equal(t, map[string]string{"env": "prod"}, e.metadata.Labels)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "payload not emitted")
+	}
+}
+
+// fakeMetricsSender stores the dimensional metrics submitted by the integrations emitter.
+type fakeMetricsSender struct {
+	sent chan []protocol.Metric
+}
+
+func (f *fakeMetricsSender) SendMetrics(metrics []protocol.Metric) {
+	f.sent <- metrics
+}
+
+func TestHTTPServerPlugin_ProtocolV4_CountInterval(t *testing.T) {
+	emitterCtx := new(mocks.AgentContext)
+	emitterCtx.On("IDLookup").Return(newFixedIDLookup())
+	sender := &fakeMetricsSender{sent: make(chan []protocol.Metric, 1)}
+	em := &emitter.Legacy{
+		Context:       emitterCtx,
+		MetricsSender: sender,
+		FFRetriever:   feature_flags.NewManager(map[string]bool{handler.FlagProtocolV4: true}),
+	}
+	url := startHTTPServer(t, &config.Config{}, em)
+
+	// GIVEN a pushed count metric without interval
+	payload := `{"protocol_version":"4","integration":{"name":"int1","version":"1"},` +
+		`"data":[{"entity":{"name":"e1","type":"t"},"metrics":[{"name":"requests","type":"count","value":10}]}]}`
+
+	resp, err := http.Post(url, "application/json", strings.NewReader(payload))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	// THEN it's submitted with the default integrations interval, instead of a zero one
+	select {
+	case metrics := <-sender.sent:
+		require.Len(t, metrics, 1)
+		require.NotNil(t, metrics[0].Interval)
+		assert.Equal(t, 30*time.Second, metrics[0].IntervalDuration())
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "metrics not sent")
+	}
+}
+
+func TestHTTPServerPlugin_ProtocolV4WithoutEmitter(t *testing.T) {
+	url := startHTTPServer(t, &config.Config{}, nil)
+
+	resp, err := http.Post(url, "application/json", strings.NewReader(payloadV4))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestHTTPServerPlugin_BearerToken(t *testing.T) {
+	em := &fakeEmitter{emitted: make(chan emitted, 1)}
+	url := startHTTPServer(t, &config.Config{HTTPServerAuthToken: "s3cr3t"}, em)
+
+	testCases := []struct {
+		authorization string
+		status        int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"s3cr3t", http.StatusUnauthorized},
+		{"Bearer s3cr3t", http.StatusNoContent},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.authorization, func(t *testing.T) {
+			req, err := http.NewRequest("POST", url, strings.NewReader(payloadV4))
+			require.NoError(t, err)
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			assert.Equal(t, tc.status, resp.StatusCode)
+		})
+	}
+	assert.Len(t, em.emitted, 1)
+}
+
+func TestHTTPServerPlugin_MaxBodySize(t *testing.T) {
+	em := &fakeEmitter{emitted: make(chan emitted, 1)}
+	url := startHTTPServer(t, &config.Config{HTTPServerMaxBodySizeBytes: len(payloadV4) - 1}, em)
+
+	resp, err := http.Post(url, "application/json", strings.NewReader(payloadV4))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	assert.Empty(t, em.emitted)
+}
+
+func TestHTTPServerPlugin_UnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "http_server")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "agent.sock")
+
+	em := &fakeEmitter{emitted: make(chan emitted, 1)}
+	startHTTPServer(t, &config.Config{HTTPServerSocket: socket}, em)
+
+	info, err := os.Stat(socket)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(socketFileMode), info.Mode().Perm())
+	// the private directory where the socket is created is removed
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	client := http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	resp, err := client.Post("http://unix/v1/data", "application/json", strings.NewReader(payloadV4))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Len(t, em.emitted, 1)
+}
+
+func TestHTTPServerPlugin_UnixSocket_KeepsNonSocketFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "http_server")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "agent.sock")
+	require.NoError(t, ioutil.WriteFile(path, []byte("not a socket"), 0644))
+
+	// GIVEN a configured socket path pointing to a regular file
+	p := NewHTTPServerPlugin(nil, "", 0, nil).(*HTTPServerPlugin)
+
+	// WHEN the server starts listening
+	_, err = p.listen(&config.Config{HTTPServerSocket: path})
+
+	// THEN it fails and the file is kept
+	require.Error(t, err)
+	content, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "not a socket", string(content))
+}
+
+func TestHTTPServerPlugin_MutualTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "http_server")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ca, caKey := newTestCert(t, nil, nil)
+	server, serverKey := newTestCert(t, ca, caKey)
+	client, clientKey := newTestCert(t, ca, caKey)
+	writePEM(t, filepath.Join(dir, "ca.pem"), "CERTIFICATE", ca.Raw)
+	writePEM(t, filepath.Join(dir, "server.pem"), "CERTIFICATE", server.Raw)
+	writePEM(t, filepath.Join(dir, "server.key"), "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(serverKey))
+
+	em := &fakeEmitter{emitted: make(chan emitted, 1)}
+	url := startHTTPServer(t, &config.Config{
+		HTTPServerCertFile:     filepath.Join(dir, "server.pem"),
+		HTTPServerKeyFile:      filepath.Join(dir, "server.key"),
+		HTTPServerClientCAFile: filepath.Join(dir, "ca.pem"),
+	}, em)
+	url = strings.Replace(url, "http://", "https://", 1)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	// without client certificate
+	anonymous := http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	_, err = anonymous.Post(url, "application/json", strings.NewReader(payloadV4))
+	assert.Error(t, err)
+
+	// with a client certificate signed by the CA
+	authenticated := http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs: roots,
+		Certificates: []tls.Certificate{{
+			Certificate: [][]byte{client.Raw},
+			PrivateKey:  clientKey,
+		}},
+	}}}
+	resp, err := authenticated.Post(url, "application/json", strings.NewReader(payloadV4))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Len(t, em.emitted, 1)
+}
+
+func TestServerTLSConfig_MissingKeyPair(t *testing.T) {
+	_, err := serverTLSConfig(&config.Config{HTTPServerClientCAFile: "/etc/ca.pem"})
+	assert.Equal(t, errTLSKeyPairMissing, err)
+}
+
+// newTestCert creates a certificate for 127.0.0.1 signed by the parent one, or a self-signed CA if parent is nil.
+func newTestCert(t *testing.T, parent *x509.Certificate, parentKey *rsa.PrivateKey) (*x509.Certificate, *rsa.PrivateKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+		parent, parentKey = template, key
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(raw)
+	require.NoError(t, err)
+	return cert, key
+}
+
+func writePEM(t *testing.T, path, blockType string, bytes []byte) {
+	content := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: bytes})
+	require.NoError(t, ioutil.WriteFile(path, content, 0600))
+}
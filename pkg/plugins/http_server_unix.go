@@ -0,0 +1,41 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// +build darwin linux
+
+package plugins
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+// listenUnix creates the unix socket within a private directory, only accessible by the agent user, and moves it to
+// the provided path once its permissions are restricted. This way the socket is never reachable by other users,
+// without changing the umask of the whole process.
+func listenUnix(path string) (net.Listener, error) {
+	// ioutil.TempDir creates the directory with 0700 permissions
+	privateDir, err := ioutil.TempDir(filepath.Dir(path), ".sock")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(privateDir)
+
+	privatePath := filepath.Join(privateDir, filepath.Base(path))
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: privatePath, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	// the socket is moved, so it's not removed from its creation path when closed
+	listener.SetUnlinkOnClose(false)
+
+	if err = os.Chmod(privatePath, socketFileMode); err == nil {
+		err = os.Rename(privatePath, path)
+	}
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
@@ -0,0 +1,12 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package plugins
+
+import (
+	"net"
+)
+
+// listenUnix creates the unix socket. Its access is controlled by the ACLs of the parent folder.
+func listenUnix(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
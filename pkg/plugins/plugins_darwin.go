@@ -4,11 +4,12 @@ package plugins
 
 import (
 	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/emitter"
 	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
 	"github.com/newrelic/infrastructure-agent/pkg/plugins/proxy"
 )
 
-func RegisterPlugins(a *agent.Agent) error {
+func RegisterPlugins(a *agent.Agent, em emitter.Emitter) error {
 	a.RegisterPlugin(NewHostAliasesPlugin(a.Context, a.GetCloudHarvester()))
 	config := a.Context.Config()
 
@@ -18,7 +19,7 @@ func RegisterPlugins(a *agent.Agent) error {
 	a.RegisterPlugin(NewCustomAttrsPlugin(a.Context))
 	a.RegisterPlugin(NewAgentConfigPlugin(*ids.NewPluginID("metadata", "agent_config"), a.Context))
 	if config.HTTPServerEnabled {
-		a.RegisterPlugin(NewHTTPServerPlugin(a.Context, config.HTTPServerHost, config.HTTPServerPort))
+		a.RegisterPlugin(NewHTTPServerPlugin(a.Context, config.HTTPServerHost, config.HTTPServerPort, em))
 	}
 	if config.FilesConfigOn {
 		a.RegisterPlugin(NewConfigFilePlugin(*ids.NewPluginID("files", "config"), a.Context))
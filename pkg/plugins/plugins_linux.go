@@ -7,12 +7,17 @@ import (
 	pluginsLinux "github.com/newrelic/infrastructure-agent/internal/plugins/linux"
 	config2 "github.com/newrelic/infrastructure-agent/pkg/config"
 	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/emitter"
 	"github.com/newrelic/infrastructure-agent/pkg/metrics"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/container"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/file"
 	"github.com/newrelic/infrastructure-agent/pkg/metrics/network"
 	"github.com/newrelic/infrastructure-agent/pkg/metrics/process"
 	metricsSender "github.com/newrelic/infrastructure-agent/pkg/metrics/sender"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/sensor"
 	"github.com/newrelic/infrastructure-agent/pkg/metrics/storage"
 	"github.com/newrelic/infrastructure-agent/pkg/metrics/storage/nfs"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/systemd"
 	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
 	"github.com/newrelic/infrastructure-agent/pkg/plugins/proxy"
 	"github.com/newrelic/infrastructure-agent/pkg/sysinfo/cloud"
@@ -25,7 +30,7 @@ func registerForwarderHeartbeat(a *agnt.Agent) {
 	a.RegisterMetricsSender(sender)
 }
 
-func RegisterPlugins(agent *agnt.Agent) error {
+func RegisterPlugins(agent *agnt.Agent, em emitter.Emitter) error {
 	config := agent.GetContext().Config()
 	// Deprecating a pluging causes the agent to delete its inventory
 	agent.DeprecatePlugin(ids.PluginID{"metadata", "cloud_instance"})
@@ -40,7 +45,7 @@ func RegisterPlugins(agent *agnt.Agent) error {
 	}
 
 	if config.HTTPServerEnabled {
-		agent.RegisterPlugin(NewHTTPServerPlugin(agent.Context, config.HTTPServerHost, config.HTTPServerPort))
+		agent.RegisterPlugin(NewHTTPServerPlugin(agent.Context, config.HTTPServerHost, config.HTTPServerPort, em))
 	}
 
 	if config.IsForwardOnly {
@@ -117,8 +122,14 @@ func RegisterPlugins(agent *agnt.Agent) error {
 	procSampler := process.NewProcessSampler(agent.Context)
 	storageSampler := storage.NewSampler(agent.Context)
 	nfsSampler := nfs.NewSampler(agent.Context)
+	containerSampler := container.NewSampler(agent.Context)
 	networkSampler := network.NewNetworkSampler(agent.Context)
+	networkProtocolSampler := network.NewProtocolSampler(agent.Context)
+	sensorSampler := sensor.NewSampler(agent.Context)
 	systemSampler := metrics.NewSystemSampler(agent.Context, storageSampler)
+	cpuCoreSampler := metrics.NewCPUCoreSampler(agent.Context)
+	systemdSampler := systemd.NewSampler(agent.Context)
+	fileSampler := file.NewSampler(agent.Context)
 
 	// Prime Storage Sampler, ignoring results
 	if !storageSampler.Disabled() {
@@ -137,9 +148,15 @@ func RegisterPlugins(agent *agnt.Agent) error {
 	}
 
 	sender.RegisterSampler(systemSampler)
+	sender.RegisterSampler(cpuCoreSampler)
 	sender.RegisterSampler(storageSampler)
 	sender.RegisterSampler(nfsSampler)
+	sender.RegisterSampler(containerSampler)
 	sender.RegisterSampler(networkSampler)
+	sender.RegisterSampler(networkProtocolSampler)
+	sender.RegisterSampler(sensorSampler)
+	sender.RegisterSampler(systemdSampler)
+	sender.RegisterSampler(fileSampler)
 	sender.RegisterSampler(procSampler)
 
 	agent.RegisterMetricsSender(sender)
@@ -3,6 +3,8 @@
 package plugins
 
 import (
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/emitter"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/file"
 	"github.com/newrelic/infrastructure-agent/pkg/metrics/network"
 	metricsSender "github.com/newrelic/infrastructure-agent/pkg/metrics/sender"
 	"github.com/newrelic/infrastructure-agent/pkg/metrics/storage"
@@ -14,7 +16,9 @@ import (
 	"github.com/newrelic/infrastructure-agent/pkg/metrics"
 )
 
-func RegisterPlugins(agent *agnt.Agent) error {
+// RegisterPlugins registers the Windows plugins and samplers. The integrations emitter is not used, as the HTTP server
+// plugin is not available on Windows.
+func RegisterPlugins(agent *agnt.Agent, _ emitter.Emitter) error {
 	config := agent.GetContext().Config()
 
 	if config.IsForwardOnly {
@@ -67,10 +71,12 @@ func RegisterPlugins(agent *agnt.Agent) error {
 	}
 
 	systemSampler := metrics.NewSystemSampler(agent.Context, storageSampler)
+	fileSampler := file.NewSampler(agent.Context)
 	sender.RegisterSampler(systemSampler)
 	sender.RegisterSampler(storageSampler)
 	sender.RegisterSampler(networkSampler)
 	sender.RegisterSampler(procSampler)
+	sender.RegisterSampler(fileSampler)
 	agent.RegisterMetricsSender(sender)
 
 	return nil